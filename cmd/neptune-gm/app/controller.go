@@ -46,9 +46,12 @@ func NewControllerCommand() *cobra.Command {
 				klog.Fatal(util.SpliceErrors(errs.ToAggregate().Errors()))
 			}
 			c := controller.NewController(config)
+			c.ConfigFile = opts.ConfigFile
 			c.Start()
 		},
 	}
+	cmd.AddCommand(NewRenderCommand())
+
 	fs := cmd.Flags()
 	namedFs := opts.Flags()
 	verflag.AddFlags(namedFs.FlagSet("global"))
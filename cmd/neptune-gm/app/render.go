@@ -0,0 +1,136 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	cliflag "k8s.io/component-base/cli/flag"
+	"k8s.io/component-base/term"
+	"sigs.k8s.io/yaml"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptune-gm/app/options"
+	neptunescheme "github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned/scheme"
+	controller "github.com/edgeai-neptune/neptune/pkg/globalmanager"
+)
+
+// NewRenderCommand creates the command rendering the pods, services and secrets of the jobs and services offline
+func NewRenderCommand() *cobra.Command {
+	opts := options.NewControllerOptions()
+	var files []string
+	cmd := &cobra.Command{
+		Use:   "render -f FILE...",
+		Short: "Render the pods and services GM would create for the jobs and services, without a cluster",
+		Long: `Render the pods and services GM would create for the federated learning jobs and
+the joint inference services in the files, without a cluster.
+The models, datasets and nodes referenced are loaded from the files too, and the ips of the nodes
+not given are rendered as placeholders. The names of the pods and services are rendered as their
+generateName, the node ports are allocated from 30000, and the worker tokens are rendered as
+placeholders, which are generated when created.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(files) == 0 {
+				return fmt.Errorf("at least one file is required by -f")
+			}
+
+			config, err := opts.Config()
+			if err != nil {
+				return err
+			}
+
+			var objects []runtime.Object
+			for _, file := range files {
+				objs, err := decodeFile(file)
+				if err != nil {
+					return err
+				}
+				objects = append(objects, objs...)
+			}
+
+			rendered, err := controller.Render(config, objects)
+			if err != nil {
+				return err
+			}
+			for _, obj := range rendered {
+				data, err := yaml.Marshal(obj)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "---\n%s", data)
+			}
+			return nil
+		},
+	}
+
+	namedFs := opts.Flags()
+	fs := namedFs.FlagSet("render")
+	fs.StringArrayVarP(&files, "filename", "f", files, "The files of the jobs and services to render, and the models, datasets and nodes referenced, - for stdin.")
+	for _, f := range namedFs.FlagSets {
+		cmd.Flags().AddFlagSet(f)
+	}
+
+	usageFmt := "Usage:\n  %s\n"
+	cols, _, _ := term.TerminalSize(cmd.OutOrStdout())
+	cmd.SetUsageFunc(func(cmd *cobra.Command) error {
+		fmt.Fprintf(cmd.OutOrStderr(), usageFmt, cmd.UseLine())
+		cliflag.PrintSections(cmd.OutOrStderr(), namedFs, cols)
+		return nil
+	})
+	cmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\n\n"+usageFmt, cmd.Long, cmd.UseLine())
+		cliflag.PrintSections(cmd.OutOrStdout(), namedFs, cols)
+	})
+
+	return cmd
+}
+
+// decodeFile decodes the objects of the kubernetes and neptune kinds in the yaml or json file
+func decodeFile(file string) ([]runtime.Object, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := runtime.NewScheme()
+	if err = clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err = neptunescheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	deserializer := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+
+	var objects []runtime.Object
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var raw runtime.RawExtension
+		if err = decoder.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", file, err)
+		}
+		if len(bytes.TrimSpace(raw.Raw)) == 0 || string(raw.Raw) == "null" {
+			continue
+		}
+
+		obj, _, err := deserializer.Decode(raw.Raw, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", file, err)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
@@ -1,11 +1,60 @@
 package options
 
+import "time"
+
 // LocalControllerOptions defines options
 type LocalControllerOptions struct {
 	GMAddr            string
 	NodeName          string
 	BindPort          string
 	VolumeMountPrefix string
+	// PingInterval is the interval of the pings sent to GM, 0 disables the pings
+	PingInterval time.Duration
+	// ReadTimeout is the time to wait for any message or heartbeat from GM,
+	// otherwise the connection is considered dead. 0 means no timeout
+	ReadTimeout time.Duration
+	// WriteTimeout is the time to write a message to GM, 0 means no timeout
+	WriteTimeout time.Duration
+	// GMCAFile is the CA bundle verifying the certificate of GM, LC connects
+	// GM over TLS when either GMCAFile or CertFile is specified
+	GMCAFile string
+	// CertFile and KeyFile are the client certificate and key presented to GM,
+	// whose identity must be the node name
+	CertFile string
+	KeyFile  string
+	// Token is the bootstrap token of the node presented to GM
+	Token string
+	// TokenFile is the file of the bootstrap token, which is read on every
+	// connection so that the token can be rotated. It takes precedence over Token
+	TokenFile string
+	// Transport is the transport to GM, websocket(default), mqtt or grpc
+	Transport string
+	// MQTTBroker is the address of the MQTT broker used by the mqtt transport,
+	// such as tcp://127.0.0.1:1883
+	MQTTBroker string
+	// MQTTTopicPrefix is the prefix of the topics of the node
+	MQTTTopicPrefix string
+	// MQTTQoS is the QoS of the messages
+	MQTTQoS byte
+	// WorkerHeartbeatInterval is the interval which the workers must report to LC
+	// within, otherwise they are reported unhealthy. 0 disables the liveness check
+	WorkerHeartbeatInterval time.Duration
+	// WorkerSocket is the path of the unix domain socket serving the workers besides BindPort
+	WorkerSocket string
+	// WorkerTokenPublicKeyFile is the ed25519 public key file verifying the tokens of the workers,
+	// the messages of the workers without valid tokens are rejected when specified
+	WorkerTokenPublicKeyFile string
+	// WorkerTokenKeyGracePeriod is the time which the previous public key is still accepted
+	// for after rotated, so that the workers with the tokens issued before can be recreated meanwhile
+	WorkerTokenKeyGracePeriod time.Duration
+	// DatasetStatsMaxSamples is the computation budget of the dataset statistics, i.e. the maximum number
+	// of the latest sample files checked per scan of a data source. 0 disables checking the sample files
+	DatasetStatsMaxSamples int
+	// ModelCacheDir is the directory of the node caching the verified models by their digests
+	ModelCacheDir string
+	// ModelTrustedKeyFiles are the files of the public keys in PEM trusted to sign the models,
+	// only the models signed by them are exposed when specified
+	ModelTrustedKeyFiles []string
 }
 
 // NewLocalControllerOptions create options object
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	cliflag "k8s.io/component-base/cli/flag"
@@ -12,6 +15,7 @@ import (
 
 	"github.com/edgeai-neptune/neptune/cmd/neptune-lc/app/options"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/common/constants"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/manager"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/server"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/wsclient"
@@ -59,22 +63,86 @@ It manages dataset and models, and controls ai features in local nodes.`, cmdNam
 		Options.BindPort = "9100"
 	}
 
+	Options.PingInterval = getSecondsEnv(constants.PingIntervalENV, 10)
+	Options.ReadTimeout = getSecondsEnv(constants.ReadTimeoutENV, 30)
+	Options.WriteTimeout = getSecondsEnv(constants.WriteTimeoutENV, 10)
+	Options.WorkerHeartbeatInterval = getSecondsEnv(constants.WorkerHeartbeatIntervalENV, 60)
+	Options.WorkerSocket = os.Getenv(constants.WorkerSocketENV)
+	Options.WorkerTokenPublicKeyFile = os.Getenv(constants.WorkerTokenPublicKeyFileENV)
+	Options.WorkerTokenKeyGracePeriod = getSecondsEnv(constants.WorkerTokenKeyGracePeriodENV, 86400)
+	Options.DatasetStatsMaxSamples = 10000
+	if v := os.Getenv(constants.DatasetStatsMaxSamplesENV); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			Options.DatasetStatsMaxSamples = n
+		} else {
+			klog.Warningf("invalid value %q of env %s, use default value %d", v, constants.DatasetStatsMaxSamplesENV,
+				Options.DatasetStatsMaxSamples)
+		}
+	}
+	if Options.ModelCacheDir = os.Getenv(constants.ModelCacheDirENV); Options.ModelCacheDir == "" {
+		Options.ModelCacheDir = "/var/lib/neptune/models"
+	}
+	for _, f := range strings.Split(os.Getenv(constants.ModelTrustedKeyFilesENV), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			Options.ModelTrustedKeyFiles = append(Options.ModelTrustedKeyFiles, f)
+		}
+	}
+
+	Options.GMCAFile = os.Getenv(constants.GMCAFileENV)
+	Options.CertFile = os.Getenv(constants.CertFileENV)
+	Options.KeyFile = os.Getenv(constants.KeyFileENV)
+
+	Options.Token = os.Getenv(constants.TokenENV)
+	Options.TokenFile = os.Getenv(constants.TokenFileENV)
+
+	Options.Transport = os.Getenv(constants.TransportENV)
+	Options.MQTTBroker = os.Getenv(constants.MQTTBrokerENV)
+	if Options.MQTTTopicPrefix = os.Getenv(constants.MQTTTopicPrefixENV); Options.MQTTTopicPrefix == "" {
+		Options.MQTTTopicPrefix = "neptune"
+	}
+	Options.MQTTQoS = 1
+	if v := os.Getenv(constants.MQTTQoSENV); v != "" {
+		if qos, err := strconv.Atoi(v); err == nil && qos >= 0 && qos <= 2 {
+			Options.MQTTQoS = byte(qos)
+		} else {
+			klog.Warningf("invalid value %q of env %s, use default value %d", v, constants.MQTTQoSENV, Options.MQTTQoS)
+		}
+	}
+
 	return cmd
 }
 
+// getSecondsEnv gets the duration in seconds from the env, or defaultSeconds if not set
+func getSecondsEnv(name string, defaultSeconds int) time.Duration {
+	seconds := defaultSeconds
+	if v := os.Getenv(name); v != "" {
+		var err error
+		if seconds, err = strconv.Atoi(v); err != nil || seconds < 0 {
+			klog.Warningf("invalid value %q of env %s, use default value %d", v, name, defaultSeconds)
+			seconds = defaultSeconds
+		}
+	}
+	return time.Duration(seconds) * time.Second
+}
+
 // runServer runs server
 func runServer() {
+	if err := db.Open(constants.DataBaseURL); err != nil {
+		klog.Errorf("open db failed, error: %v", err)
+		return
+	}
+
 	c := wsclient.NewClient(Options)
 	if err := c.Start(); err != nil {
 		return
 	}
 
-	_, err := manager.NewDatasetManager(c, Options)
+	dm, err := manager.NewDatasetManager(c, Options)
 	if err != nil {
 		klog.Errorf("create dataset manager failed, error: %v", err)
 	}
 
-	_, err = manager.NewModelManager(c)
+	_, err = manager.NewModelManager(c, Options)
 	if err != nil {
 		klog.Errorf("create model manager failed, error: %v", err)
 	}
@@ -82,7 +150,7 @@ func runServer() {
 	jm := manager.NewJointInferenceManager(c)
 	fm := manager.NewFederatedLearningManager(c)
 
-	s := server.NewServer(Options, jm, fm)
+	s := server.NewServer(Options, c, dm, jm, fm)
 
 	s.Start()
 }
@@ -0,0 +1,461 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptunectl/app/options"
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	neptunescheme "github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned/scheme"
+)
+
+// creator creates the resources, or prints them if dry run
+type creator struct {
+	opts   *options.NeptunectlOptions
+	out    io.Writer
+	dryRun bool
+}
+
+func newCreateCommand(opts *options.NeptunectlOptions) *cobra.Command {
+	c := &creator{opts: opts}
+	var (
+		files  []string
+		values []string
+	)
+	cmd := &cobra.Command{
+		Use:   "create -f FILE | create KIND NAME [flags]",
+		Short: "Create the resources from the templates or the flags",
+		Long: `Create the resources from the template files, or from the flags by the subcommands.
+The template files are the manifests in yaml or json rendered as Go templates,
+with the values given by --set referred as {{.key}}.`,
+		Example: `  # create the resources of the manifest
+  neptunectl create -f federatedlearningjob.yaml
+
+  # create the job from the template with the values
+  neptunectl create -f fljob-template.yaml --set name=job1 --set rounds=10
+
+  # print the job rendered without creating it
+  neptunectl create -f fljob-template.yaml --set name=job1 --dry-run`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(files) == 0 {
+				return cmd.Usage()
+			}
+			c.out = cmd.OutOrStdout()
+
+			data, err := parseKeyValues(values)
+			if err != nil {
+				return fmt.Errorf("invalid --set: %v", err)
+			}
+			var objects []runtime.Object
+			for _, file := range files {
+				objs, err := renderTemplate(file, data)
+				if err != nil {
+					return err
+				}
+				objects = append(objects, objs...)
+			}
+			for _, obj := range objects {
+				if err := c.create(obj); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&files, "filename", "f", files, "The template files of the resources, - for stdin.")
+	cmd.Flags().StringArrayVar(&values, "set", values, "The values of the templates, in the form of key=value.")
+	cmd.PersistentFlags().BoolVar(&c.dryRun, "dry-run", c.dryRun, "Only print the resources without creating them.")
+
+	cmd.AddCommand(
+		newCreateDatasetCommand(c),
+		newCreateModelCommand(c),
+		newCreateFederatedLearningJobCommand(c),
+		newCreateJointInferenceServiceCommand(c),
+	)
+	return cmd
+}
+
+// renderTemplate renders the template file with the data, and decodes the resources in it
+func renderTemplate(file string, data map[string]string) ([]runtime.Object, error) {
+	var content []byte
+	var err error
+	if file == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(file).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", file, err)
+	}
+	var rendered bytes.Buffer
+	if err = tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %v", file, err)
+	}
+
+	var objects []runtime.Object
+	decoder := utilyaml.NewYAMLOrJSONDecoder(&rendered, 4096)
+	deserializer := neptunescheme.Codecs.UniversalDeserializer()
+	for {
+		var raw runtime.RawExtension
+		if err = decoder.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", file, err)
+		}
+		if len(bytes.TrimSpace(raw.Raw)) == 0 || string(raw.Raw) == "null" {
+			continue
+		}
+
+		obj, _, err := deserializer.Decode(raw.Raw, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", file, err)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// create creates the resource in its namespace, or the namespace of the options if not set
+func (c *creator) create(obj runtime.Object) error {
+	meta, ok := obj.(metav1.Object)
+	if !ok {
+		return fmt.Errorf("unsupported object %T", obj)
+	}
+	if meta.GetNamespace() == "" {
+		namespace, err := c.opts.GetNamespace()
+		if err != nil {
+			return err
+		}
+		meta.SetNamespace(namespace)
+	}
+
+	var kind *resourceKind
+	switch obj.(type) {
+	case *neptunev1.FederatedLearningJob:
+		kind = federatedLearningJobKind
+	case *neptunev1.JointInferenceService:
+		kind = jointInferenceServiceKind
+	case *neptunev1.Model:
+		kind = modelKind
+	case *neptunev1.Dataset:
+		kind = datasetKind
+	default:
+		return fmt.Errorf("unsupported kind %s, supported kinds: %s", obj.GetObjectKind().GroupVersionKind().Kind, resourceKindNames)
+	}
+
+	if c.dryRun {
+		obj.GetObjectKind().SetGroupVersionKind(neptunev1.SchemeGroupVersion.WithKind(kind.Kind))
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "---\n%s", data)
+		return nil
+	}
+
+	client, _, err := c.opts.Clients()
+	if err != nil {
+		return err
+	}
+	ctx := context.TODO()
+	namespace := meta.GetNamespace()
+	neptune := client.NeptuneV1alpha1()
+	switch o := obj.(type) {
+	case *neptunev1.FederatedLearningJob:
+		_, err = neptune.FederatedLearningJobs(namespace).Create(ctx, o, metav1.CreateOptions{})
+	case *neptunev1.JointInferenceService:
+		_, err = neptune.JointInferenceServices(namespace).Create(ctx, o, metav1.CreateOptions{})
+	case *neptunev1.Model:
+		_, err = neptune.Models(namespace).Create(ctx, o, metav1.CreateOptions{})
+	case *neptunev1.Dataset:
+		_, err = neptune.Datasets(namespace).Create(ctx, o, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "%s created\n", resourceName(kind, meta.GetName()))
+	return nil
+}
+
+// parseKeyValues parses the key=value pairs
+func parseKeyValues(pairs []string) (map[string]string, error) {
+	result := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%q is not in the form of key=value", pair)
+		}
+		result[kv[0]] = kv[1]
+	}
+	return result, nil
+}
+
+// parseParameters parses the key=value pairs as the parameters of the worker in order
+func parseParameters(pairs []string) ([]neptunev1.ParaSpec, error) {
+	var parameters []neptunev1.ParaSpec
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("parameter %q is not in the form of key=value", pair)
+		}
+		parameters = append(parameters, neptunev1.ParaSpec{Key: kv[0], Value: kv[1]})
+	}
+	return parameters, nil
+}
+
+// workerFlags defines the flags of a worker, prefixed by the role of the worker
+type workerFlags struct {
+	prefix         string
+	scriptDir      string
+	scriptBootFile string
+	parameters     []string
+}
+
+func (w *workerFlags) addFlags(fs *pflag.FlagSet, role string) {
+	fs.StringVar(&w.scriptDir, w.prefix+"script-dir", w.scriptDir, "The directory of the scripts of the "+role+".")
+	fs.StringVar(&w.scriptBootFile, w.prefix+"script-boot-file", w.scriptBootFile, "The boot file of the scripts of the "+role+".")
+	fs.StringArrayVar(&w.parameters, w.prefix+"parameter", w.parameters, "The parameters of the "+role+", in the form of key=value.")
+}
+
+// workerSpec returns the spec of the worker with the framework
+func (w *workerFlags) workerSpec(framework *frameworkFlags) (neptunev1.CommonWorkerSpec, error) {
+	spec := neptunev1.CommonWorkerSpec{
+		ScriptDir:        w.scriptDir,
+		ScriptBootFile:   w.scriptBootFile,
+		FrameworkType:    framework.frameworkType,
+		FrameworkVersion: framework.frameworkVersion,
+	}
+	if spec.ScriptDir == "" || spec.ScriptBootFile == "" {
+		return spec, fmt.Errorf("--%sscript-dir and --%sscript-boot-file are required", w.prefix, w.prefix)
+	}
+
+	var err error
+	spec.Parameters, err = parseParameters(w.parameters)
+	return spec, err
+}
+
+// frameworkFlags defines the framework flags of the workers
+type frameworkFlags struct {
+	frameworkType    string
+	frameworkVersion string
+}
+
+func (f *frameworkFlags) addFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&f.frameworkType, "framework-type", f.frameworkType, "The framework of the workers, e.g. tensorflow.")
+	fs.StringVar(&f.frameworkVersion, "framework-version", f.frameworkVersion, "The version of the framework of the workers.")
+}
+
+// requireFlags returns the error if any of the flags isn't set
+func requireFlags(cmd *cobra.Command, names ...string) error {
+	var missing []string
+	for _, name := range names {
+		if !cmd.Flags().Changed(name) {
+			missing = append(missing, "--"+name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("required flags not set: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func newCreateDatasetCommand(c *creator) *cobra.Command {
+	dataset := &neptunev1.Dataset{}
+	cmd := &cobra.Command{
+		Use:     "dataset NAME",
+		Aliases: []string{"ds"},
+		Short:   "Create a dataset",
+		Example: `  neptunectl create dataset edge1-dataset --url /data/train.txt --format txt --node edge1`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireFlags(cmd, "url", "format", "node"); err != nil {
+				return err
+			}
+			c.out = cmd.OutOrStdout()
+			dataset.Name = args[0]
+			return c.create(dataset)
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&dataset.Spec.URL, "url", "", "The path of the index file of the dataset on the node.")
+	fs.StringVar(&dataset.Spec.Format, "format", "", "The format of the index file, e.g. txt.")
+	fs.StringVar(&dataset.Spec.NodeName, "node", "", "The node of the dataset.")
+	return cmd
+}
+
+func newCreateModelCommand(c *creator) *cobra.Command {
+	model := &neptunev1.Model{}
+	cmd := &cobra.Command{
+		Use:     "model NAME",
+		Short:   "Create a model",
+		Example: `  neptunectl create model helmet-detection-model --url /models/helmet.pb --format pb --digest sha256:...`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireFlags(cmd, "url", "format"); err != nil {
+				return err
+			}
+			c.out = cmd.OutOrStdout()
+			model.Name = args[0]
+			return c.create(model)
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&model.Spec.ModelURL, "url", "", "The url of the model, a http(s) url or a path of the node.")
+	fs.StringVar(&model.Spec.Format, "format", "", "The format of the model, e.g. pb.")
+	fs.StringVar(&model.Spec.Digest, "digest", "", "The expected digest of the model file, in the form of sha256:<hex>.")
+	fs.Int64Var(&model.Spec.Size, "size", 0, "The expected size in bytes of the model file.")
+	fs.StringVar(&model.Spec.Signature, "signature", "", "The base64 encoded signature of the sha256 digest of the model file.")
+	return cmd
+}
+
+func newCreateFederatedLearningJobCommand(c *creator) *cobra.Command {
+	var (
+		model           string
+		aggregationNode string
+		trainingWorkers []string
+		framework       frameworkFlags
+		aggregation     = workerFlags{prefix: "aggregation-"}
+		training        = workerFlags{prefix: "training-"}
+	)
+	cmd := &cobra.Command{
+		Use:     "federatedlearningjob NAME",
+		Aliases: []string{"fl", "fljob"},
+		Short:   "Create a federated learning job",
+		Example: `  neptunectl create fl surface-defect-detection --model surface-defect-detection-model \
+    --aggregation-node cloud0 --aggregation-script-dir /code --aggregation-script-boot-file aggregate.py \
+    --aggregation-parameter exit_round=3 \
+    --training-worker node=edge1,dataset=edge1-dataset --training-worker node=edge2,dataset=edge2-dataset \
+    --training-script-dir /code --training-script-boot-file train.py --training-parameter batch_size=32 \
+    --framework-type tensorflow --framework-version 2.3`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireFlags(cmd, "model", "aggregation-node", "training-worker"); err != nil {
+				return err
+			}
+
+			job := &neptunev1.FederatedLearningJob{}
+			job.Name = args[0]
+			job.Spec.AggregationWorker.Model.Name = model
+			job.Spec.AggregationWorker.NodeName = aggregationNode
+			spec, err := aggregation.workerSpec(&framework)
+			if err != nil {
+				return err
+			}
+			job.Spec.AggregationWorker.WorkerSpec.CommonWorkerSpec = spec
+
+			trainingSpec, err := training.workerSpec(&framework)
+			if err != nil {
+				return err
+			}
+			for _, worker := range trainingWorkers {
+				kv, err := parseKeyValues(strings.Split(worker, ","))
+				if err != nil || kv["node"] == "" || kv["dataset"] == "" || len(kv) != 2 {
+					return fmt.Errorf("training worker %q is not in the form of node=<node>,dataset=<dataset>", worker)
+				}
+				t := neptunev1.TrainingWorker{NodeName: kv["node"]}
+				t.Dataset.Name = kv["dataset"]
+				t.WorkerSpec.CommonWorkerSpec = *trainingSpec.DeepCopy()
+				job.Spec.TrainingWorkers = append(job.Spec.TrainingWorkers, t)
+			}
+
+			c.out = cmd.OutOrStdout()
+			return c.create(job)
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&model, "model", model, "The model trained by the job.")
+	fs.StringVar(&aggregationNode, "aggregation-node", aggregationNode, "The node of the aggregation worker.")
+	fs.StringArrayVar(&trainingWorkers, "training-worker", trainingWorkers, "The training worker in the form of node=<node>,dataset=<dataset>, one flag for each worker.")
+	framework.addFlags(fs)
+	aggregation.addFlags(fs, "aggregation worker")
+	training.addFlags(fs, "training workers")
+	return cmd
+}
+
+func newCreateJointInferenceServiceCommand(c *creator) *cobra.Command {
+	var (
+		edgeNode, edgeModel     string
+		cloudNode, cloudModel   string
+		hardExampleMining       string
+		hardExampleMiningParams []string
+		framework               frameworkFlags
+		edge                    = workerFlags{prefix: "edge-"}
+		cloud                   = workerFlags{prefix: "cloud-"}
+	)
+	cmd := &cobra.Command{
+		Use:     "jointinferenceservice NAME",
+		Aliases: []string{"ji"},
+		Short:   "Create a joint inference service",
+		Example: `  neptunectl create ji helmet-detection \
+    --edge-node edge1 --edge-model helmet-detection-little-model \
+    --edge-script-dir /code --edge-script-boot-file little_model.py \
+    --hard-example-mining IBT --hard-example-mining-parameter threshold_img=0.9 \
+    --cloud-node cloud0 --cloud-model helmet-detection-big-model \
+    --cloud-script-dir /code --cloud-script-boot-file big_model.py \
+    --framework-type tensorflow --framework-version 1.15`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireFlags(cmd, "edge-node", "edge-model", "cloud-node", "cloud-model", "hard-example-mining"); err != nil {
+				return err
+			}
+
+			service := &neptunev1.JointInferenceService{}
+			service.Name = args[0]
+			service.Spec.EdgeWorker.NodeName = edgeNode
+			service.Spec.EdgeWorker.Model.Name = edgeModel
+			service.Spec.CloudWorker.NodeName = cloudNode
+			service.Spec.CloudWorker.Model.Name = cloudModel
+
+			var err error
+			service.Spec.EdgeWorker.HardExampleMining.Name = hardExampleMining
+			if service.Spec.EdgeWorker.HardExampleMining.Parameters, err = parseParameters(hardExampleMiningParams); err != nil {
+				return err
+			}
+			if service.Spec.EdgeWorker.WorkerSpec, err = edge.workerSpec(&framework); err != nil {
+				return err
+			}
+			if service.Spec.CloudWorker.WorkerSpec, err = cloud.workerSpec(&framework); err != nil {
+				return err
+			}
+
+			c.out = cmd.OutOrStdout()
+			return c.create(service)
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&edgeNode, "edge-node", edgeNode, "The node of the edge worker.")
+	fs.StringVar(&edgeModel, "edge-model", edgeModel, "The little model of the edge worker.")
+	fs.StringVar(&cloudNode, "cloud-node", cloudNode, "The node of the cloud worker.")
+	fs.StringVar(&cloudModel, "cloud-model", cloudModel, "The big model of the cloud worker.")
+	fs.StringVar(&hardExampleMining, "hard-example-mining", hardExampleMining, "The hard example mining algorithm of the edge worker, e.g. IBT.")
+	fs.StringArrayVar(&hardExampleMiningParams, "hard-example-mining-parameter", hardExampleMiningParams, "The parameters of the hard example mining algorithm, in the form of key=value.")
+	framework.addFlags(fs)
+	edge.addFlags(fs, "edge worker")
+	cloud.addFlags(fs, "cloud worker")
+	return cmd
+}
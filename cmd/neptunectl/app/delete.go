@@ -0,0 +1,261 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptunectl/app/options"
+	"github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned"
+)
+
+// deleteOptions describes the options of the delete command
+type deleteOptions struct {
+	Cascade        bool
+	Wait           bool
+	Timeout        time.Duration
+	WithReferences bool
+}
+
+func newDeleteCommand(opts *options.NeptunectlOptions) *cobra.Command {
+	o := &deleteOptions{
+		Cascade: true,
+		Wait:    true,
+		Timeout: 5 * time.Minute,
+	}
+	cmd := &cobra.Command{
+		Use:   "delete KIND NAME...",
+		Short: "Delete the resources with their pods, services and optionally the models and datasets referenced",
+		Long: `Delete the resources with their pods and services created by GM, which are deleted
+before the resources by the foreground cascading deletion.
+The models and datasets referenced by the deleted jobs and services are also deleted with --with-references,
+unless referenced by any other job or service.
+Supported kinds: ` + resourceKindNames + `.`,
+		Example: `  # delete the federated learning job, and wait until its pods deleted
+  neptunectl delete fl surface-defect-detection
+
+  # delete the joint inference service with its models
+  neptunectl delete ji helmet-detection --with-references`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, err := parseResourceKind(args[0])
+			if err != nil {
+				return err
+			}
+			namespace, err := opts.GetNamespace()
+			if err != nil {
+				return err
+			}
+			client, _, err := opts.Clients()
+			if err != nil {
+				return err
+			}
+
+			d := &deleter{out: cmd.OutOrStdout(), client: client, namespace: namespace, opts: o}
+			return d.run(kind, args[1:])
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.BoolVar(&o.Cascade, "cascade", o.Cascade, "Delete the pods and services of the resources, otherwise they are orphaned.")
+	fs.BoolVar(&o.Wait, "wait", o.Wait, "Wait until the resources are deleted, which is after their pods and services deleted if cascading.")
+	fs.DurationVar(&o.Timeout, "timeout", o.Timeout, "The timeout of waiting for the deletion.")
+	fs.BoolVar(&o.WithReferences, "with-references", o.WithReferences, "Also delete the models and datasets referenced only by the deleted resources.")
+	return cmd
+}
+
+// deleter deletes the resources of a namespace
+type deleter struct {
+	out       io.Writer
+	client    versioned.Interface
+	namespace string
+	opts      *deleteOptions
+}
+
+// references defines the models and datasets referenced by the jobs and services
+type references struct {
+	models   sets.String
+	datasets sets.String
+}
+
+func newReferences() *references {
+	return &references{models: sets.NewString(), datasets: sets.NewString()}
+}
+
+func (d *deleter) run(kind *resourceKind, names []string) error {
+	refs := newReferences()
+	if d.opts.WithReferences {
+		for _, name := range names {
+			if err := d.collectReferences(kind, name, refs); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range names {
+		if err := d.delete(kind, name); err != nil {
+			return err
+		}
+	}
+
+	if d.opts.Wait {
+		for _, name := range names {
+			if err := d.waitDeleted(kind, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !d.opts.WithReferences {
+		return nil
+	}
+
+	inUse, err := d.referencesInUse(kind, sets.NewString(names...))
+	if err != nil {
+		return err
+	}
+	for _, name := range refs.models.Difference(inUse.models).List() {
+		if err = d.delete(modelKind, name); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	for _, name := range refs.datasets.Difference(inUse.datasets).List() {
+		if err = d.delete(datasetKind, name); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	for _, name := range refs.models.Intersection(inUse.models).List() {
+		fmt.Fprintf(d.out, "%s kept, still in use\n", resourceName(modelKind, name))
+	}
+	for _, name := range refs.datasets.Intersection(inUse.datasets).List() {
+		fmt.Fprintf(d.out, "%s kept, still in use\n", resourceName(datasetKind, name))
+	}
+	return nil
+}
+
+// delete deletes the resource of the kind
+func (d *deleter) delete(kind *resourceKind, name string) error {
+	propagation := metav1.DeletePropagationForeground
+	if !d.opts.Cascade {
+		propagation = metav1.DeletePropagationOrphan
+	}
+	options := metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	ctx := context.TODO()
+	c := d.client.NeptuneV1alpha1()
+	var err error
+	switch kind {
+	case federatedLearningJobKind:
+		err = c.FederatedLearningJobs(d.namespace).Delete(ctx, name, options)
+	case jointInferenceServiceKind:
+		err = c.JointInferenceServices(d.namespace).Delete(ctx, name, options)
+	case modelKind:
+		err = c.Models(d.namespace).Delete(ctx, name, options)
+	default:
+		err = c.Datasets(d.namespace).Delete(ctx, name, options)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(d.out, "%s deleted\n", resourceName(kind, name))
+	return nil
+}
+
+// exists returns whether the resource of the kind exists
+func (d *deleter) exists(kind *resourceKind, name string) (bool, error) {
+	ctx := context.TODO()
+	c := d.client.NeptuneV1alpha1()
+	var err error
+	switch kind {
+	case federatedLearningJobKind:
+		_, err = c.FederatedLearningJobs(d.namespace).Get(ctx, name, metav1.GetOptions{})
+	case jointInferenceServiceKind:
+		_, err = c.JointInferenceServices(d.namespace).Get(ctx, name, metav1.GetOptions{})
+	case modelKind:
+		_, err = c.Models(d.namespace).Get(ctx, name, metav1.GetOptions{})
+	default:
+		_, err = c.Datasets(d.namespace).Get(ctx, name, metav1.GetOptions{})
+	}
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// waitDeleted waits until the resource is deleted
+func (d *deleter) waitDeleted(kind *resourceKind, name string) error {
+	err := wait.PollImmediate(time.Second, d.opts.Timeout, func() (bool, error) {
+		exists, err := d.exists(kind, name)
+		return !exists, err
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("timed out waiting for the deletion of %s", resourceName(kind, name))
+	}
+	return err
+}
+
+// collectReferences collects the models and datasets referenced by the resource
+func (d *deleter) collectReferences(kind *resourceKind, name string, refs *references) error {
+	ctx := context.TODO()
+	c := d.client.NeptuneV1alpha1()
+	switch kind {
+	case federatedLearningJobKind:
+		job, err := c.FederatedLearningJobs(d.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		refs.models.Insert(job.Spec.AggregationWorker.Model.Name)
+		for _, t := range job.Spec.TrainingWorkers {
+			refs.datasets.Insert(t.Dataset.Name)
+		}
+	case jointInferenceServiceKind:
+		service, err := c.JointInferenceServices(d.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		refs.models.Insert(service.Spec.EdgeWorker.Model.Name, service.Spec.CloudWorker.Model.Name)
+	}
+	return nil
+}
+
+// referencesInUse returns the models and datasets referenced by the jobs and services
+// except the deleted resources of the kind
+func (d *deleter) referencesInUse(kind *resourceKind, deleted sets.String) (*references, error) {
+	ctx := context.TODO()
+	c := d.client.NeptuneV1alpha1()
+	refs := newReferences()
+
+	jobs, err := c.FederatedLearningJobs(d.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs.Items {
+		if kind == federatedLearningJobKind && deleted.Has(job.Name) {
+			continue
+		}
+		refs.models.Insert(job.Spec.AggregationWorker.Model.Name)
+		for _, t := range job.Spec.TrainingWorkers {
+			refs.datasets.Insert(t.Dataset.Name)
+		}
+	}
+
+	services, err := c.JointInferenceServices(d.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services.Items {
+		if kind == jointInferenceServiceKind && deleted.Has(service.Name) {
+			continue
+		}
+		refs.models.Insert(service.Spec.EdgeWorker.Model.Name, service.Spec.CloudWorker.Model.Name)
+	}
+	return refs, nil
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned/fake"
+)
+
+const testNamespace = "default"
+
+func newTestFLJob(name, model string, datasets ...string) *neptunev1.FederatedLearningJob {
+	job := &neptunev1.FederatedLearningJob{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name}}
+	job.Spec.AggregationWorker.Model.Name = model
+	for _, dataset := range datasets {
+		var w neptunev1.TrainingWorker
+		w.Dataset.Name = dataset
+		job.Spec.TrainingWorkers = append(job.Spec.TrainingWorkers, w)
+	}
+	return job
+}
+
+func newTestJointInferenceService(name, edgeModel, cloudModel string) *neptunev1.JointInferenceService {
+	service := &neptunev1.JointInferenceService{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name}}
+	service.Spec.EdgeWorker.Model.Name = edgeModel
+	service.Spec.CloudWorker.Model.Name = cloudModel
+	return service
+}
+
+func newTestModel(name string) *neptunev1.Model {
+	return &neptunev1.Model{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name}}
+}
+
+func newTestDataset(name string) *neptunev1.Dataset {
+	return &neptunev1.Dataset{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name}}
+}
+
+// newTestObjects returns the jobs and services sharing the models and datasets:
+// fl1 uses agg-model, shared-dataset and fl1-dataset, fl2 uses agg-model and shared-dataset,
+// ji1 uses ji1-edge and shared-cloud, ji2 uses ji2-edge and shared-cloud
+func newTestObjects() []runtime.Object {
+	return []runtime.Object{
+		newTestFLJob("fl1", "agg-model", "shared-dataset", "fl1-dataset"),
+		newTestFLJob("fl2", "agg-model", "shared-dataset"),
+		newTestJointInferenceService("ji1", "ji1-edge", "shared-cloud"),
+		newTestJointInferenceService("ji2", "ji2-edge", "shared-cloud"),
+		newTestModel("agg-model"),
+		newTestModel("ji1-edge"),
+		newTestModel("ji2-edge"),
+		newTestModel("shared-cloud"),
+		newTestDataset("shared-dataset"),
+		newTestDataset("fl1-dataset"),
+	}
+}
+
+func TestReferencesInUse(t *testing.T) {
+	d := &deleter{client: fake.NewSimpleClientset(newTestObjects()...), namespace: testNamespace, opts: &deleteOptions{}}
+
+	cases := []struct {
+		name     string
+		kind     *resourceKind
+		deleted  []string
+		models   []string
+		datasets []string
+	}{
+		{"nothing deleted", federatedLearningJobKind, nil,
+			[]string{"agg-model", "ji1-edge", "ji2-edge", "shared-cloud"}, []string{"fl1-dataset", "shared-dataset"}},
+		{"a job deleted", federatedLearningJobKind, []string{"fl1"},
+			[]string{"agg-model", "ji1-edge", "ji2-edge", "shared-cloud"}, []string{"shared-dataset"}},
+		{"all jobs deleted", federatedLearningJobKind, []string{"fl1", "fl2"},
+			[]string{"ji1-edge", "ji2-edge", "shared-cloud"}, []string{}},
+		{"a service deleted", jointInferenceServiceKind, []string{"ji1"},
+			[]string{"agg-model", "ji2-edge", "shared-cloud"}, []string{"fl1-dataset", "shared-dataset"}},
+		// the names of another kind are not excluded
+		{"a service of the name of a job deleted", jointInferenceServiceKind, []string{"fl1"},
+			[]string{"agg-model", "ji1-edge", "ji2-edge", "shared-cloud"}, []string{"fl1-dataset", "shared-dataset"}},
+	}
+	for _, c := range cases {
+		refs, err := d.referencesInUse(c.kind, sets.NewString(c.deleted...))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if models := refs.models.List(); !reflect.DeepEqual(models, c.models) {
+			t.Errorf("%s: expected models %v in use, got %v", c.name, c.models, models)
+		}
+		if datasets := refs.datasets.List(); !reflect.DeepEqual(datasets, c.datasets) {
+			t.Errorf("%s: expected datasets %v in use, got %v", c.name, c.datasets, datasets)
+		}
+	}
+}
+
+func TestDeleteRun(t *testing.T) {
+	cases := []struct {
+		name           string
+		kind           *resourceKind
+		names          []string
+		withReferences bool
+		models         []string
+		datasets       []string
+		kept           []string
+	}{
+		{"without references", federatedLearningJobKind, []string{"fl1"}, false,
+			[]string{"agg-model", "ji1-edge", "ji2-edge", "shared-cloud"}, []string{"fl1-dataset", "shared-dataset"}, nil},
+		{"references of a job", federatedLearningJobKind, []string{"fl1"}, true,
+			[]string{"agg-model", "ji1-edge", "ji2-edge", "shared-cloud"}, []string{"shared-dataset"},
+			[]string{"model.neptune.io/agg-model", "dataset.neptune.io/shared-dataset"}},
+		{"references of all jobs", federatedLearningJobKind, []string{"fl1", "fl2"}, true,
+			[]string{"ji1-edge", "ji2-edge", "shared-cloud"}, []string{}, nil},
+		{"references of a service", jointInferenceServiceKind, []string{"ji1"}, true,
+			[]string{"agg-model", "ji2-edge", "shared-cloud"}, []string{"fl1-dataset", "shared-dataset"},
+			[]string{"model.neptune.io/shared-cloud"}},
+	}
+	for _, c := range cases {
+		client := fake.NewSimpleClientset(newTestObjects()...)
+		var out bytes.Buffer
+		d := &deleter{out: &out, client: client, namespace: testNamespace,
+			opts: &deleteOptions{Cascade: true, Wait: true, Timeout: 5 * time.Second, WithReferences: c.withReferences}}
+		if err := d.run(c.kind, c.names); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+
+		for _, name := range c.names {
+			if exists, err := d.exists(c.kind, name); exists || err != nil {
+				t.Errorf("%s: expected %s deleted, got %v", c.name, name, err)
+			}
+		}
+		models, err := client.NeptuneV1alpha1().Models(testNamespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		modelNames := []string{}
+		for _, m := range models.Items {
+			modelNames = append(modelNames, m.Name)
+		}
+		if modelNames = sets.NewString(modelNames...).List(); !reflect.DeepEqual(modelNames, c.models) {
+			t.Errorf("%s: expected models %v left, got %v", c.name, c.models, modelNames)
+		}
+		datasets, err := client.NeptuneV1alpha1().Datasets(testNamespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		datasetNames := []string{}
+		for _, ds := range datasets.Items {
+			datasetNames = append(datasetNames, ds.Name)
+		}
+		if datasetNames = sets.NewString(datasetNames...).List(); !reflect.DeepEqual(datasetNames, c.datasets) {
+			t.Errorf("%s: expected datasets %v left, got %v", c.name, c.datasets, datasetNames)
+		}
+
+		for _, name := range c.kept {
+			if !strings.Contains(out.String(), name+" kept, still in use\n") {
+				t.Errorf("%s: expected %s kept, got output %q", c.name, name, out.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptunectl/app/options"
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned"
+)
+
+// maxDescribedConditions is the max number of the latest conditions described,
+// since a condition is appended for each round of the federated learning jobs
+const maxDescribedConditions = 10
+
+func newDescribeCommand(opts *options.NeptunectlOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "describe KIND NAME",
+		Short: "Show the details of a resource with its pods, workers, conditions and events",
+		Long: `Show the details of a resource with its pods, workers, conditions and events.
+Supported kinds: ` + resourceKindNames + `.`,
+		Example: `  # describe the federated learning job
+  neptunectl describe fl surface-defect-detection`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, err := parseResourceKind(args[0])
+			if err != nil {
+				return err
+			}
+			namespace, err := opts.GetNamespace()
+			if err != nil {
+				return err
+			}
+			client, kubeClient, err := opts.Clients()
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			defer w.Flush()
+			d := &describer{out: w, client: client, kubeClient: kubeClient, namespace: namespace}
+			name := args[1]
+			switch kind {
+			case federatedLearningJobKind:
+				return d.describeFederatedLearningJob(name)
+			case jointInferenceServiceKind:
+				return d.describeJointInferenceService(name)
+			case modelKind:
+				return d.describeModel(name)
+			default:
+				return d.describeDataset(name)
+			}
+		},
+	}
+	return cmd
+}
+
+// describer describes the resources of a namespace
+type describer struct {
+	out        io.Writer
+	client     versioned.Interface
+	kubeClient kubernetes.Interface
+	namespace  string
+}
+
+// printf prints the line indented by the level
+func (d *describer) printf(level int, format string, a ...interface{}) {
+	fmt.Fprint(d.out, strings.Repeat("  ", level))
+	fmt.Fprintf(d.out, format, a...)
+	fmt.Fprint(d.out, "\n")
+}
+
+// printRow prints the row of a table indented by the level
+func (d *describer) printRow(level int, columns ...interface{}) {
+	fmt.Fprint(d.out, strings.Repeat("  ", level))
+	printRow(d.out, columns...)
+}
+
+func (d *describer) describeMeta(meta *metav1.ObjectMeta) {
+	d.printf(0, "Name:\t%s", meta.Name)
+	d.printf(0, "Namespace:\t%s", meta.Namespace)
+	d.printf(0, "Created:\t%s (%s ago)", formatTime(&meta.CreationTimestamp), age(meta.CreationTimestamp))
+}
+
+func (d *describer) describeWorkerSpec(level int, spec *neptunev1.CommonWorkerSpec) {
+	d.printf(level, "Script:\t%s", scriptPath(spec))
+	d.printf(level, "Framework:\t%s %s", spec.FrameworkType, spec.FrameworkVersion)
+	d.printf(level, "Parameters:\t%s", formatParameters(spec.Parameters))
+}
+
+// scriptPath returns the path of the boot file of the worker
+func scriptPath(spec *neptunev1.CommonWorkerSpec) string {
+	if spec.ScriptBootFile == "" {
+		return "<none>"
+	}
+	return path.Join(spec.ScriptDir, spec.ScriptBootFile)
+}
+
+// describePods describes the pods created by GM for the resource
+func (d *describer) describePods(kind *resourceKind, name string) error {
+	selector, err := workerSelector(kind, name)
+	if err != nil {
+		return err
+	}
+	pods, err := d.kubeClient.CoreV1().Pods(d.namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+
+	if len(pods.Items) == 0 {
+		d.printf(0, "Pods:\t<none>")
+		return nil
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+	d.printf(0, "Pods:")
+	d.printRow(1, "NAME", "NODE", "STATUS", "RESTARTS", "AGE")
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		d.printRow(1, pod.Name, orNone(pod.Spec.NodeName), podStatus(pod), podRestarts(pod), age(pod.CreationTimestamp))
+	}
+	return nil
+}
+
+// describeWorkers describes the liveness of the workers reported by LCs
+func (d *describer) describeWorkers(workers []neptunev1.WorkerHealth) {
+	if len(workers) == 0 {
+		d.printf(0, "Workers:\t<none>")
+		return
+	}
+	d.printf(0, "Workers:")
+	d.printRow(1, "NAME", "NODE", "HEALTHY", "PHASE", "STATUS", "LAST-HEARTBEAT", "MESSAGE")
+	for _, w := range workers {
+		d.printRow(1, w.Name, orNone(w.NodeName), w.Healthy, orNone(w.Phase), orNone(w.Status),
+			age(w.LastHeartbeatTime), orNone(w.Message))
+	}
+}
+
+// condition is the common view of the conditions of the kinds
+type condition struct {
+	Type    string
+	Status  v1.ConditionStatus
+	Reason  string
+	Message string
+	Time    metav1.Time
+}
+
+// describeConditions describes the latest conditions
+func (d *describer) describeConditions(conditions []condition) {
+	if len(conditions) == 0 {
+		d.printf(0, "Conditions:\t<none>")
+		return
+	}
+	d.printf(0, "Conditions:")
+	if omitted := len(conditions) - maxDescribedConditions; omitted > 0 {
+		d.printf(1, "(%d earlier conditions omitted)", omitted)
+		conditions = conditions[omitted:]
+	}
+	d.printRow(1, "TYPE", "STATUS", "REASON", "AGE", "MESSAGE")
+	for _, c := range conditions {
+		d.printRow(1, c.Type, c.Status, orNone(c.Reason), age(c.Time), orNone(c.Message))
+	}
+}
+
+// describeEvents describes the events of the resource
+func (d *describer) describeEvents(kind *resourceKind, name string) error {
+	events, err := d.kubeClient.CoreV1().Events(d.namespace).List(context.TODO(), metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=%s,involvedObject.name=%s", kind.Kind, name),
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(events.Items) == 0 {
+		d.printf(0, "Events:\t<none>")
+		return nil
+	}
+	sort.Slice(events.Items, func(i, j int) bool {
+		return events.Items[i].LastTimestamp.Before(&events.Items[j].LastTimestamp)
+	})
+	d.printf(0, "Events:")
+	d.printRow(1, "TYPE", "REASON", "AGE", "FROM", "MESSAGE")
+	for _, e := range events.Items {
+		eventAge := age(e.LastTimestamp)
+		if e.Count > 1 {
+			eventAge = fmt.Sprintf("%s (x%d over %s)", eventAge, e.Count, age(e.FirstTimestamp))
+		}
+		d.printRow(1, e.Type, e.Reason, eventAge, orNone(e.Source.Component), strings.TrimSpace(e.Message))
+	}
+	return nil
+}
+
+func (d *describer) describeFederatedLearningJob(name string) error {
+	job, err := d.client.NeptuneV1alpha1().FederatedLearningJobs(d.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	d.describeMeta(&job.ObjectMeta)
+	d.printf(0, "Phase:\t%s", orNone(string(job.Status.Phase)))
+	round := "<none>"
+	if job.Status.CurrentRound > 0 {
+		round = fmt.Sprintf("%d (reported %s ago)", job.Status.CurrentRound, agePtr(job.Status.RoundUpdateTime))
+	}
+	d.printf(0, "Round:\t%s", round)
+	d.printf(0, "Start Time:\t%s", formatTime(job.Status.StartTime))
+	d.printf(0, "Completion Time:\t%s", formatTime(job.Status.CompletionTime))
+	d.printf(0, "Pods Statuses:\t%d Active / %d Succeeded / %d Failed", job.Status.Active, job.Status.Succeeded, job.Status.Failed)
+
+	aggregation := &job.Spec.AggregationWorker
+	d.printf(0, "Aggregation Worker:")
+	d.printf(1, "Node:\t%s", aggregation.NodeName)
+	d.printf(1, "Model:\t%s", aggregation.Model.Name)
+	d.describeWorkerSpec(1, &aggregation.WorkerSpec.CommonWorkerSpec)
+	d.printf(0, "Training Workers:")
+	d.printRow(1, "NODE", "DATASET", "SCRIPT", "FRAMEWORK", "PARAMETERS")
+	for _, t := range job.Spec.TrainingWorkers {
+		spec := &t.WorkerSpec.CommonWorkerSpec
+		d.printRow(1, t.NodeName, t.Dataset.Name, scriptPath(spec),
+			spec.FrameworkType+" "+spec.FrameworkVersion, formatParameters(spec.Parameters))
+	}
+
+	if err = d.describePods(federatedLearningJobKind, name); err != nil {
+		return err
+	}
+	d.describeWorkers(job.Status.Workers)
+
+	var conditions []condition
+	for _, c := range job.Status.Conditions {
+		conditions = append(conditions, condition{Type: string(c.Type), Status: c.Status,
+			Reason: c.Reason, Message: c.Message, Time: c.LastHeartbeatTime})
+	}
+	d.describeConditions(conditions)
+
+	return d.describeEvents(federatedLearningJobKind, name)
+}
+
+func (d *describer) describeJointInferenceService(name string) error {
+	service, err := d.client.NeptuneV1alpha1().JointInferenceServices(d.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	d.describeMeta(&service.ObjectMeta)
+	d.printf(0, "Start Time:\t%s", formatTime(service.Status.StartTime))
+	d.printf(0, "Pods Statuses:\t%d Active / %d Failed", service.Status.Active, service.Status.Failed)
+	d.printf(0, "Metrics:\t%s", formatMetrics(service.Status.Metrics))
+
+	edge := &service.Spec.EdgeWorker
+	d.printf(0, "Edge Worker:")
+	d.printf(1, "Node:\t%s", edge.NodeName)
+	d.printf(1, "Model:\t%s", edge.Model.Name)
+	d.printf(1, "Hard Example Mining:\t%s (%s)", orNone(edge.HardExampleMining.Name),
+		formatParameters(edge.HardExampleMining.Parameters))
+	d.describeWorkerSpec(1, &edge.WorkerSpec)
+	cloud := &service.Spec.CloudWorker
+	d.printf(0, "Cloud Worker:")
+	d.printf(1, "Node:\t%s", cloud.NodeName)
+	d.printf(1, "Model:\t%s", cloud.Model.Name)
+	d.describeWorkerSpec(1, &cloud.WorkerSpec)
+
+	if err = d.describePods(jointInferenceServiceKind, name); err != nil {
+		return err
+	}
+	d.describeWorkers(service.Status.Workers)
+
+	var conditions []condition
+	for _, c := range service.Status.Conditions {
+		conditions = append(conditions, condition{Type: string(c.Type), Status: c.Status,
+			Reason: c.Reason, Message: c.Message, Time: c.LastTransitionTime})
+	}
+	d.describeConditions(conditions)
+
+	return d.describeEvents(jointInferenceServiceKind, name)
+}
+
+func (d *describer) describeModel(name string) error {
+	model, err := d.client.NeptuneV1alpha1().Models(d.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	d.describeMeta(&model.ObjectMeta)
+	d.printf(0, "URL:\t%s", model.Spec.ModelURL)
+	d.printf(0, "Format:\t%s", orNone(model.Spec.Format))
+	d.printf(0, "Digest:\t%s", orNone(model.Spec.Digest))
+	if model.Spec.Size > 0 {
+		d.printf(0, "Size:\t%d", model.Spec.Size)
+	}
+	d.printf(0, "Signed:\t%t", model.Spec.Signature != "")
+	d.printf(0, "Metrics:\t%s", formatMetrics(model.Status.Metrics))
+	d.printf(0, "Metrics Updated:\t%s", formatTime(model.Status.UpdateTime))
+
+	if len(model.Status.Verifications) == 0 {
+		d.printf(0, "Verifications:\t<none>")
+	} else {
+		d.printf(0, "Verifications:")
+		d.printRow(1, "NODE", "VERIFIED", "DIGEST", "CONDITIONS", "AGE", "MESSAGE")
+		for _, v := range model.Status.Verifications {
+			var conditions []string
+			for _, c := range v.Conditions {
+				conditions = append(conditions, fmt.Sprintf("%s=%s", c.Type, c.Status))
+			}
+			d.printRow(1, v.NodeName, v.Verified, orNone(v.Digest), orNone(strings.Join(conditions, ",")),
+				age(v.LastVerifyTime), orNone(v.Message))
+		}
+	}
+
+	return d.describeEvents(modelKind, name)
+}
+
+func (d *describer) describeDataset(name string) error {
+	dataset, err := d.client.NeptuneV1alpha1().Datasets(d.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	status := &dataset.Status
+	d.describeMeta(&dataset.ObjectMeta)
+	d.printf(0, "URL:\t%s", dataset.Spec.URL)
+	d.printf(0, "Format:\t%s", dataset.Spec.Format)
+	d.printf(0, "Node:\t%s", dataset.Spec.NodeName)
+	d.printf(0, "Samples:\t%d (%d added in total)", status.NumberOfSamples, status.SamplesAdded)
+	d.printf(0, "Checked Samples:\t%d (%d missing, %d bytes)", status.CheckedSamples, status.MissingSamples, status.TotalBytes)
+	d.printf(0, "Last Modified:\t%s", formatTime(status.LastModifiedTime))
+	d.printf(0, "Updated:\t%s", formatTime(status.UpdateTime))
+
+	if len(status.LabelDistribution) == 0 {
+		d.printf(0, "Labels:\t<none>")
+	} else {
+		labels := make([]string, 0, len(status.LabelDistribution))
+		for label := range status.LabelDistribution {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		d.printf(0, "Labels:")
+		d.printRow(1, "LABEL", "SAMPLES")
+		for _, label := range labels {
+			d.printRow(1, label, status.LabelDistribution[label])
+		}
+	}
+
+	return d.describeEvents(datasetKind, name)
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptunectl/app/options"
+	"github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned"
+)
+
+func newListCommand(opts *options.NeptunectlOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list KIND",
+		Aliases: []string{"ls", "get"},
+		Short:   "List the resources of the kind with the state of their workers",
+		Long: `List the resources of the kind with the state of their workers.
+Supported kinds: ` + resourceKindNames + `.`,
+		Example: `  # list the federated learning jobs with their rounds and pods
+  neptunectl list fl
+
+  # list the models of all namespaces
+  neptunectl list models -A`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, err := parseResourceKind(args[0])
+			if err != nil {
+				return err
+			}
+			namespace, err := opts.ListNamespace()
+			if err != nil {
+				return err
+			}
+			client, kubeClient, err := opts.Clients()
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			defer w.Flush()
+			l := &lister{out: w, client: client, kubeClient: kubeClient, namespace: namespace}
+			switch kind {
+			case federatedLearningJobKind:
+				return l.listFederatedLearningJobs()
+			case jointInferenceServiceKind:
+				return l.listJointInferenceServices()
+			case modelKind:
+				return l.listModels()
+			default:
+				return l.listDatasets()
+			}
+		},
+	}
+	cmd.Flags().BoolVarP(&opts.AllNamespaces, "all-namespaces", "A", false, "List the resources across all namespaces.")
+	return cmd
+}
+
+// lister lists the resources of a namespace, or all namespaces if the namespace is empty
+type lister struct {
+	out        io.Writer
+	client     versioned.Interface
+	kubeClient kubernetes.Interface
+	namespace  string
+}
+
+// printRow prints the row, prefixed by the namespace when listing all namespaces
+func (l *lister) printRow(namespace string, columns ...interface{}) {
+	if l.namespace == "" {
+		columns = append([]interface{}{namespace}, columns...)
+	}
+	printRow(l.out, columns...)
+}
+
+// workerPods returns the pods of the workers of the kind, grouped by namespace/name of the resource
+func (l *lister) workerPods(kind *resourceKind) (map[string][]v1.Pod, error) {
+	key := workerLabelKey(kind)
+	pods, err := l.kubeClient.CoreV1().Pods(l.namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: key})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]v1.Pod)
+	for _, pod := range pods.Items {
+		name := pod.Namespace + "/" + pod.Labels[key]
+		result[name] = append(result[name], pod)
+	}
+	return result, nil
+}
+
+func (l *lister) listFederatedLearningJobs() error {
+	jobs, err := l.client.NeptuneV1alpha1().FederatedLearningJobs(l.namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	pods, err := l.workerPods(federatedLearningJobKind)
+	if err != nil {
+		return err
+	}
+
+	l.printRow("NAMESPACE", "NAME", "PHASE", "ROUND", "WORKERS", "PODS", "AGE")
+	for _, job := range jobs.Items {
+		l.printRow(job.Namespace, job.Name, orNone(string(job.Status.Phase)), job.Status.CurrentRound,
+			fmt.Sprintf("%d/%d", healthyWorkers(job.Status.Workers), len(job.Spec.TrainingWorkers)),
+			podCounts(pods[job.Namespace+"/"+job.Name]), age(job.CreationTimestamp))
+	}
+	return nil
+}
+
+func (l *lister) listJointInferenceServices() error {
+	services, err := l.client.NeptuneV1alpha1().JointInferenceServices(l.namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	pods, err := l.workerPods(jointInferenceServiceKind)
+	if err != nil {
+		return err
+	}
+
+	l.printRow("NAMESPACE", "NAME", "ACTIVE", "FAILED", "EDGE-WORKER", "PODS", "AGE")
+	for _, service := range services.Items {
+		edgeWorker := "<unknown>"
+		if len(service.Status.Workers) > 0 {
+			edgeWorker = "Unhealthy"
+			if healthyWorkers(service.Status.Workers) > 0 {
+				edgeWorker = "Healthy"
+			}
+		}
+		l.printRow(service.Namespace, service.Name, service.Status.Active, service.Status.Failed, edgeWorker,
+			podCounts(pods[service.Namespace+"/"+service.Name]), age(service.CreationTimestamp))
+	}
+	return nil
+}
+
+func (l *lister) listModels() error {
+	models, err := l.client.NeptuneV1alpha1().Models(l.namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	l.printRow("NAMESPACE", "NAME", "FORMAT", "VERIFIED", "METRICS", "UPDATED", "AGE")
+	for _, model := range models.Items {
+		verified := 0
+		for _, v := range model.Status.Verifications {
+			if v.Verified {
+				verified++
+			}
+		}
+		l.printRow(model.Namespace, model.Name, orNone(model.Spec.Format),
+			fmt.Sprintf("%d/%d", verified, len(model.Status.Verifications)),
+			formatMetrics(model.Status.Metrics), agePtr(model.Status.UpdateTime), age(model.CreationTimestamp))
+	}
+	return nil
+}
+
+func (l *lister) listDatasets() error {
+	datasets, err := l.client.NeptuneV1alpha1().Datasets(l.namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	l.printRow("NAMESPACE", "NAME", "NODE", "FORMAT", "SAMPLES", "MISSING", "UPDATED", "AGE")
+	for _, dataset := range datasets.Items {
+		l.printRow(dataset.Namespace, dataset.Name, dataset.Spec.NodeName, dataset.Spec.Format,
+			dataset.Status.NumberOfSamples, dataset.Status.MissingSamples,
+			agePtr(dataset.Status.UpdateTime), age(dataset.CreationTimestamp))
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptunectl/app/options"
+)
+
+// logsOptions describes the options of the logs command
+type logsOptions struct {
+	Follow     bool
+	Since      time.Duration
+	Tail       int64
+	Timestamps bool
+	Role       string
+}
+
+func newLogsCommand(opts *options.NeptunectlOptions) *cobra.Command {
+	o := &logsOptions{Tail: -1}
+	cmd := &cobra.Command{
+		Use:   "logs KIND NAME",
+		Short: "Print the logs of all the workers of a job or service",
+		Long: `Print the logs of all the worker pods of a federated learning job or a joint inference service,
+each line prefixed by the role and the node of the worker.
+The pods whose logs can't be got, e.g. the edge nodes unreachable by the API server, are reported and skipped.`,
+		Example: `  # print the logs of the workers of the federated learning job
+  neptunectl logs fl surface-defect-detection
+
+  # follow the logs of the training workers since 10 minutes ago
+  neptunectl logs fl surface-defect-detection --role training -f --since 10m
+
+  # print the last 20 lines of each worker of the joint inference service
+  neptunectl logs ji helmet-detection --tail 20`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, err := parseResourceKind(args[0])
+			if err != nil {
+				return err
+			}
+			if kind != federatedLearningJobKind && kind != jointInferenceServiceKind {
+				return fmt.Errorf("only the workers of %s and %s have logs", federatedLearningJobKind.Kind, jointInferenceServiceKind.Kind)
+			}
+			namespace, err := opts.GetNamespace()
+			if err != nil {
+				return err
+			}
+			_, kubeClient, err := opts.Clients()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			defer signal.Stop(signals)
+			go func() {
+				select {
+				case <-signals:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+
+			s := &logStreamer{
+				out:        cmd.OutOrStdout(),
+				errOut:     cmd.ErrOrStderr(),
+				kubeClient: kubeClient,
+				namespace:  namespace,
+				kind:       kind,
+				name:       args[1],
+				opts:       o,
+				streamed:   make(map[streamKey]bool),
+			}
+			return s.run(ctx)
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.BoolVarP(&o.Follow, "follow", "f", o.Follow, "Follow the logs, including the workers created later, until interrupted.")
+	fs.DurationVar(&o.Since, "since", o.Since, "Only print the logs newer than the duration, e.g. 5s, 2m or 3h.")
+	fs.Int64Var(&o.Tail, "tail", o.Tail, "The number of the latest lines of each worker to print, -1 for all.")
+	fs.BoolVar(&o.Timestamps, "timestamps", o.Timestamps, "Include the timestamps of the lines.")
+	fs.StringVar(&o.Role, "role", o.Role, "Only print the logs of the workers of the role, e.g. aggregation, training, edge or cloud.")
+	return cmd
+}
+
+// logStreamer streams the logs of the worker pods of a resource
+type logStreamer struct {
+	out        io.Writer
+	errOut     io.Writer
+	kubeClient kubernetes.Interface
+	namespace  string
+	kind       *resourceKind
+	name       string
+	opts       *logsOptions
+
+	// lock serializes the lines written by the streams
+	lock sync.Mutex
+	wg   sync.WaitGroup
+	// streamed is the containers whose logs have been streamed, keyed by the pod and its restarts
+	// so that the restarted containers are streamed again when following
+	streamed map[streamKey]bool
+	failed   int
+}
+
+// streamKey identifies the containers of a pod between restarts
+type streamKey struct {
+	uid      types.UID
+	restarts int32
+}
+
+// run streams the logs of the pods, and follows the pods created later if following
+func (s *logStreamer) run(ctx context.Context) error {
+	selector, err := workerSelector(s.kind, s.name)
+	if err != nil {
+		return err
+	}
+	pods, err := s.kubeClient.CoreV1().Pods(s.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+
+	for i := range pods.Items {
+		s.stream(ctx, &pods.Items[i])
+	}
+
+	if s.opts.Follow {
+		s.followPods(ctx, selector, pods.ResourceVersion)
+	}
+	s.wg.Wait()
+
+	if len(s.streamed) == 0 {
+		if s.opts.Follow {
+			return nil
+		}
+		return fmt.Errorf("no worker pods of %s found", resourceName(s.kind, s.name))
+	}
+	if s.failed == len(s.streamed) {
+		return fmt.Errorf("failed to get the logs of all the %d worker pods", s.failed)
+	}
+	return nil
+}
+
+// followPods streams the logs of the pods once they are started, until the context canceled
+func (s *logStreamer) followPods(ctx context.Context, selector, resourceVersion string) {
+	for ctx.Err() == nil {
+		watcher, err := s.kubeClient.CoreV1().Pods(s.namespace).Watch(ctx, metav1.ListOptions{
+			LabelSelector:   selector,
+			ResourceVersion: resourceVersion,
+		})
+		if err != nil {
+			s.warnf("watch the worker pods failed, retry later: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+			resourceVersion = ""
+			continue
+		}
+
+		s.handlePodEvents(ctx, watcher, &resourceVersion)
+		watcher.Stop()
+	}
+}
+
+// handlePodEvents streams the logs of the pods watched, until the watch closed or the context canceled
+func (s *logStreamer) handlePodEvents(ctx context.Context, watcher watch.Interface, resourceVersion *string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return
+			}
+			pod, ok := event.Object.(*v1.Pod)
+			if !ok {
+				// the resource version is expired, so the pods are watched from the latest
+				*resourceVersion = ""
+				return
+			}
+			*resourceVersion = pod.ResourceVersion
+			if event.Type == watch.Added || event.Type == watch.Modified {
+				s.stream(ctx, pod)
+			}
+		}
+	}
+}
+
+// workerRole returns the role of the worker pod, which is named <name>-<role>-<suffix> by GM
+func (s *logStreamer) workerRole(pod *v1.Pod) string {
+	role := strings.TrimSuffix(strings.TrimPrefix(pod.GenerateName, s.name+"-"), "-")
+	if pod.GenerateName == "" || role == "" {
+		return "worker"
+	}
+	return role
+}
+
+// stream starts streaming the logs of the pod if started and not streamed yet since its last restart
+func (s *logStreamer) stream(ctx context.Context, pod *v1.Pod) {
+	key := streamKey{uid: pod.UID, restarts: podRestarts(pod)}
+	if s.streamed[key] {
+		return
+	}
+	role := s.workerRole(pod)
+	if s.opts.Role != "" && !strings.EqualFold(s.opts.Role, role) {
+		return
+	}
+
+	prefix := fmt.Sprintf("[%s %s/%s] ", role, orNone(pod.Spec.NodeName), pod.Name)
+	if pod.Status.Phase == v1.PodPending {
+		// the pending pod is streamed once started if following
+		if !s.opts.Follow {
+			s.streamed[key] = true
+			s.failed++
+			s.warnf("%sskipped, the pod is pending", prefix)
+		}
+		return
+	}
+	s.streamed[key] = true
+
+	logOptions := &v1.PodLogOptions{
+		Follow:     s.opts.Follow,
+		Timestamps: s.opts.Timestamps,
+	}
+	if s.opts.Since > 0 {
+		seconds := int64(s.opts.Since.Seconds())
+		logOptions.SinceSeconds = &seconds
+	}
+	if s.opts.Tail >= 0 {
+		logOptions.TailLines = &s.opts.Tail
+	}
+
+	// the pods are streamed concurrently when following, otherwise one by one
+	// so that the logs of each pod are printed together
+	if !s.opts.Follow {
+		s.streamLogs(ctx, pod, logOptions, prefix)
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.streamLogs(ctx, pod, logOptions, prefix)
+	}()
+}
+
+// streamLogs copies the logs of the pod to the output line by line with the prefix
+func (s *logStreamer) streamLogs(ctx context.Context, pod *v1.Pod, logOptions *v1.PodLogOptions, prefix string) {
+	logs, err := s.kubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOptions).Stream(ctx)
+	if err != nil {
+		s.lock.Lock()
+		s.failed++
+		s.lock.Unlock()
+		s.warnf("%sfailed to get logs, the node may be unreachable by the API server: %v", prefix, err)
+		return
+	}
+	defer logs.Close()
+
+	reader := bufio.NewReader(logs)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			s.lock.Lock()
+			fmt.Fprint(s.out, prefix+strings.TrimSuffix(line, "\n")+"\n")
+			s.lock.Unlock()
+		}
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				s.warnf("%slogs interrupted: %v", prefix, err)
+			}
+			return
+		}
+	}
+}
+
+// warnf prints the warning to the error output
+func (s *logStreamer) warnf(format string, a ...interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	fmt.Fprintf(s.errOut, "warning: "+format+"\n", a...)
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStreamRestartedPods(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "fl1-train-abcde", GenerateName: "fl1-train-", UID: "uid1"},
+		Spec:       v1.PodSpec{NodeName: "edge1"},
+		Status:     v1.PodStatus{Phase: v1.PodRunning, ContainerStatuses: []v1.ContainerStatus{{}}},
+	}
+	var out, errOut bytes.Buffer
+	s := &logStreamer{
+		out:        &out,
+		errOut:     &errOut,
+		kubeClient: kubefake.NewSimpleClientset(pod),
+		namespace:  testNamespace,
+		kind:       federatedLearningJobKind,
+		name:       "fl1",
+		opts:       &logsOptions{Follow: true, Tail: -1},
+		streamed:   make(map[streamKey]bool),
+	}
+	streams := func() int {
+		s.wg.Wait()
+		return strings.Count(out.String(), "[train edge1/fl1-train-abcde] fake logs\n")
+	}
+
+	ctx := context.Background()
+	s.stream(ctx, pod)
+	if n := streams(); n != 1 {
+		t.Fatalf("expected the logs streamed once, got %d: %q", n, out.String())
+	}
+
+	// modified without restarted
+	s.stream(ctx, pod.DeepCopy())
+	if n := streams(); n != 1 {
+		t.Errorf("expected the streamed pod not streamed again, got %d", n)
+	}
+
+	restarted := pod.DeepCopy()
+	restarted.Status.ContainerStatuses[0].RestartCount = 1
+	s.stream(ctx, restarted)
+	if n := streams(); n != 2 {
+		t.Errorf("expected the restarted container streamed again, got %d", n)
+	}
+	s.stream(ctx, restarted)
+	if n := streams(); n != 2 {
+		t.Errorf("expected the restarted container streamed once, got %d", n)
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("unexpected warnings %q", errOut.String())
+	}
+}
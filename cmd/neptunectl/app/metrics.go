@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptunectl/app/options"
+)
+
+func newMetricsCommand(opts *options.NeptunectlOptions) *cobra.Command {
+	var job string
+	cmd := &cobra.Command{
+		Use:   "metrics [MODEL]",
+		Short: "Show the metrics history of a model",
+		Long: `Show the metrics history of a model, one row for each report of the metrics.
+The model can be given by the federated learning job training it.`,
+		Example: `  # show the metrics history of the model
+  neptunectl metrics surface-defect-detection-model
+
+  # show the metrics history of the model trained by the federated learning job
+  neptunectl metrics --job surface-defect-detection`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 0) == (job == "") {
+				return cmd.Usage()
+			}
+			namespace, err := opts.GetNamespace()
+			if err != nil {
+				return err
+			}
+			client, _, err := opts.Clients()
+			if err != nil {
+				return err
+			}
+
+			var name string
+			if job != "" {
+				flJob, err := client.NeptuneV1alpha1().FederatedLearningJobs(namespace).Get(context.TODO(), job, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				name = flJob.Spec.AggregationWorker.Model.Name
+			} else {
+				name = args[0]
+			}
+
+			model, err := client.NeptuneV1alpha1().Models(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			history := model.Status.MetricsHistory
+			if len(history) == 0 {
+				// the models updated before the history introduced only have the latest metrics
+				if len(model.Status.Metrics) > 0 {
+					cmd.Printf("No metrics history of model %s, the latest metrics: %s\n", name, formatMetrics(model.Status.Metrics))
+				} else {
+					cmd.Printf("No metrics of model %s reported yet\n", name)
+				}
+				return nil
+			}
+
+			keySet := make(map[string]bool)
+			for _, record := range history {
+				for _, m := range record.Metrics {
+					keySet[m.Key] = true
+				}
+			}
+			keys := make([]string, 0, len(keySet))
+			for key := range keySet {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			w := newTabWriter(cmd.OutOrStdout())
+			defer w.Flush()
+			header := []interface{}{"TIME", "ROUND"}
+			for _, key := range keys {
+				header = append(header, key)
+			}
+			printRow(w, header...)
+			for _, record := range history {
+				values := make(map[string]string, len(record.Metrics))
+				for _, m := range record.Metrics {
+					values[m.Key] = m.Value
+				}
+				round := "<none>"
+				if record.Round > 0 {
+					round = fmt.Sprint(record.Round)
+				}
+				row := []interface{}{record.UpdateTime.Format(time.RFC3339), round}
+				for _, key := range keys {
+					row = append(row, orNone(values[key]))
+				}
+				printRow(w, row...)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&job, "job", job, "The federated learning job whose aggregation model is shown.")
+	return cmd
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptunectl/app/options"
+	"github.com/edgeai-neptune/neptune/pkg/version/verflag"
+)
+
+// NewNeptunectlCommand creates a new neptunectl command
+func NewNeptunectlCommand() *cobra.Command {
+	opts := options.NewNeptunectlOptions()
+	cmd := &cobra.Command{
+		Use:   "neptunectl",
+		Short: "neptunectl controls the Neptune resources",
+		Long: `neptunectl controls the Neptune resources: the federated learning jobs,
+the joint inference services, the models and the datasets.`,
+		SilenceUsage: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			verflag.PrintAndExitIfRequested()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	fs := cmd.PersistentFlags()
+	opts.AddFlags(fs)
+	verflag.AddFlags(fs)
+
+	cmd.AddCommand(
+		newCreateCommand(opts),
+		newListCommand(opts),
+		newDescribeCommand(opts),
+		newWatchCommand(opts),
+		newMetricsCommand(opts),
+		newLogsCommand(opts),
+		newDeleteCommand(opts),
+	)
+	return cmd
+}
@@ -0,0 +1,77 @@
+package options
+
+import (
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned"
+)
+
+// NeptunectlOptions describes the options of neptunectl shared by all commands
+type NeptunectlOptions struct {
+	KubeConfig string
+	Master     string
+	Namespace  string
+	// AllNamespaces lists the resources across all namespaces
+	AllNamespaces bool
+
+	clientConfig clientcmd.ClientConfig
+}
+
+// NewNeptunectlOptions creates a new neptunectl options
+func NewNeptunectlOptions() *NeptunectlOptions {
+	return &NeptunectlOptions{}
+}
+
+// AddFlags adds the flags of NeptunectlOptions to the flagset
+func (o *NeptunectlOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.KubeConfig, "kubeconfig", o.KubeConfig, "Path to the kubeconfig file, the default loading rules of kubectl are used if not set.")
+	fs.StringVar(&o.Master, "master", o.Master, "The address of the Kubernetes API server, overrides any value in kubeconfig.")
+	fs.StringVarP(&o.Namespace, "namespace", "n", o.Namespace, "The namespace of the resources, the namespace of the current context is used if not set.")
+}
+
+// ClientConfig returns the client config loaded from the kubeconfig
+func (o *NeptunectlOptions) ClientConfig() clientcmd.ClientConfig {
+	if o.clientConfig == nil {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		rules.ExplicitPath = o.KubeConfig
+		overrides := &clientcmd.ConfigOverrides{}
+		overrides.ClusterInfo.Server = o.Master
+		overrides.Context.Namespace = o.Namespace
+		o.clientConfig = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+	}
+	return o.clientConfig
+}
+
+// GetNamespace returns the namespace of the resources
+func (o *NeptunectlOptions) GetNamespace() (string, error) {
+	namespace, _, err := o.ClientConfig().Namespace()
+	return namespace, err
+}
+
+// ListNamespace returns the namespace to list the resources, which is empty for all namespaces
+func (o *NeptunectlOptions) ListNamespace() (string, error) {
+	if o.AllNamespaces {
+		return "", nil
+	}
+	return o.GetNamespace()
+}
+
+// Clients returns the clientsets of neptune and kubernetes
+func (o *NeptunectlOptions) Clients() (versioned.Interface, kubernetes.Interface, error) {
+	config, err := o.ClientConfig().ClientConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := versioned.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, kubeClient, nil
+}
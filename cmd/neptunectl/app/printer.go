@@ -0,0 +1,143 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+)
+
+// newTabWriter creates a writer printing the tab separated columns aligned, like kubectl
+func newTabWriter(out io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(out, 6, 4, 3, ' ', 0)
+}
+
+// printRow prints the columns as a row of the table
+func printRow(w io.Writer, columns ...interface{}) {
+	for i, column := range columns {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprint(w, column)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// age returns the human readable duration since the time, or <unknown> if not set
+func age(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(t.Time))
+}
+
+// agePtr returns the age of the time, or <none> if not set
+func agePtr(t *metav1.Time) string {
+	if t == nil {
+		return "<none>"
+	}
+	return age(*t)
+}
+
+// formatTime returns the time in RFC3339, or <none> if not set
+func formatTime(t *metav1.Time) string {
+	if t == nil || t.IsZero() {
+		return "<none>"
+	}
+	return t.Time.Format(time.RFC3339)
+}
+
+// orNone returns <none> for the empty string
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+// formatMetrics returns the metrics as key=value sorted by key
+func formatMetrics(metrics []neptunev1.Metric) string {
+	if len(metrics) == 0 {
+		return "<none>"
+	}
+	pairs := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		pairs = append(pairs, m.Key+"="+m.Value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// formatParameters returns the parameters as key=value
+func formatParameters(parameters []neptunev1.ParaSpec) string {
+	if len(parameters) == 0 {
+		return "<none>"
+	}
+	pairs := make([]string, 0, len(parameters))
+	for _, p := range parameters {
+		pairs = append(pairs, p.Key+"="+p.Value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// healthyWorkers returns the number of the healthy workers
+func healthyWorkers(workers []neptunev1.WorkerHealth) int {
+	healthy := 0
+	for _, w := range workers {
+		if w.Healthy {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// podCounts counts the pods by phase, in the form of running/total
+func podCounts(pods []v1.Pod) string {
+	if len(pods) == 0 {
+		return "0/0"
+	}
+	running := 0
+	for i := range pods {
+		if pods[i].Status.Phase == v1.PodRunning {
+			running++
+		}
+	}
+	return fmt.Sprintf("%d/%d", running, len(pods))
+}
+
+// podRestarts returns the restart count of the containers of the pod
+func podRestarts(pod *v1.Pod) int32 {
+	var restarts int32
+	for _, s := range pod.Status.ContainerStatuses {
+		restarts += s.RestartCount
+	}
+	return restarts
+}
+
+// podStatus returns the status of the pod like kubectl, i.e. the reason of the waiting
+// or terminated container if any, otherwise the phase
+func podStatus(pod *v1.Pod) string {
+	if pod.DeletionTimestamp != nil {
+		return "Terminating"
+	}
+	for _, s := range pod.Status.ContainerStatuses {
+		if s.State.Waiting != nil && s.State.Waiting.Reason != "" {
+			return s.State.Waiting.Reason
+		}
+		if s.State.Terminated != nil && s.State.Terminated.Reason != "" {
+			return s.State.Terminated.Reason
+		}
+	}
+	if pod.Status.Reason != "" {
+		return pod.Status.Reason
+	}
+	return string(pod.Status.Phase)
+}
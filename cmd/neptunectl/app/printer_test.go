@@ -0,0 +1,206 @@
+package app
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned/fake"
+)
+
+func TestPodStatus(t *testing.T) {
+	now := metav1.Now()
+	cases := []struct {
+		name   string
+		pod    v1.Pod
+		status string
+	}{
+		{"phase", v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning}}, "Running"},
+		{"reason of the pod", v1.Pod{Status: v1.PodStatus{Phase: v1.PodFailed, Reason: "Evicted"}}, "Evicted"},
+		{"waiting container", v1.Pod{Status: v1.PodStatus{Phase: v1.PodPending, ContainerStatuses: []v1.ContainerStatus{
+			{State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+			{State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+		}}}, "CrashLoopBackOff"},
+		{"terminated container", v1.Pod{Status: v1.PodStatus{Phase: v1.PodFailed, ContainerStatuses: []v1.ContainerStatus{
+			{State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled"}}},
+		}}}, "OOMKilled"},
+		{"deleted", v1.Pod{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now},
+			Status: v1.PodStatus{Phase: v1.PodRunning}}, "Terminating"},
+	}
+	for _, c := range cases {
+		if status := podStatus(&c.pod); status != c.status {
+			t.Errorf("%s: expected status %s, got %s", c.name, c.status, status)
+		}
+	}
+}
+
+func TestPodCountsAndRestarts(t *testing.T) {
+	pods := []v1.Pod{
+		{Status: v1.PodStatus{Phase: v1.PodRunning, ContainerStatuses: []v1.ContainerStatus{{RestartCount: 1}, {RestartCount: 2}}}},
+		{Status: v1.PodStatus{Phase: v1.PodPending}},
+		{Status: v1.PodStatus{Phase: v1.PodRunning}},
+	}
+	if counts := podCounts(pods); counts != "2/3" {
+		t.Errorf("expected 2/3 pods running, got %s", counts)
+	}
+	if counts := podCounts(nil); counts != "0/0" {
+		t.Errorf("expected no pods, got %s", counts)
+	}
+	if restarts := podRestarts(&pods[0]); restarts != 3 {
+		t.Errorf("expected 3 restarts, got %d", restarts)
+	}
+}
+
+func TestFormatters(t *testing.T) {
+	updated := metav1.NewTime(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	cases := []struct {
+		name     string
+		actual   string
+		expected string
+	}{
+		{"metrics sorted", formatMetrics([]neptunev1.Metric{{Key: "recall", Value: "0.8"}, {Key: "precision", Value: "0.9"}}),
+			"precision=0.9,recall=0.8"},
+		{"no metrics", formatMetrics(nil), "<none>"},
+		{"parameters in order", formatParameters([]neptunev1.ParaSpec{{Key: "epochs", Value: "2"}, {Key: "batch", Value: "32"}}),
+			"epochs=2,batch=32"},
+		{"no parameters", formatParameters(nil), "<none>"},
+		{"time", formatTime(&updated), "2021-01-02T03:04:05Z"},
+		{"no time", formatTime(nil), "<none>"},
+		{"zero time", formatTime(&metav1.Time{}), "<none>"},
+		{"no age", agePtr(nil), "<none>"},
+		{"unknown age", age(metav1.Time{}), "<unknown>"},
+		{"age", age(metav1.NewTime(time.Now().Add(-90 * time.Second))), "90s"},
+		{"empty", orNone(""), "<none>"},
+		{"not empty", orNone("pb"), "pb"},
+	}
+	for _, c := range cases {
+		if c.actual != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, c.actual)
+		}
+	}
+
+	workers := []neptunev1.WorkerHealth{{Healthy: true}, {Healthy: false}, {Healthy: true}}
+	if healthy := healthyWorkers(workers); healthy != 2 {
+		t.Errorf("expected 2 healthy workers, got %d", healthy)
+	}
+}
+
+// listRows returns the fields of the rows printed by list
+func listRows(t *testing.T, namespace string, objects []runtime.Object, pods []runtime.Object, list func(*lister) error) [][]string {
+	t.Helper()
+	var out bytes.Buffer
+	w := newTabWriter(&out)
+	l := &lister{
+		out:        w,
+		client:     fake.NewSimpleClientset(objects...),
+		kubeClient: kubefake.NewSimpleClientset(pods...),
+		namespace:  namespace,
+	}
+	if err := list(l); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+
+	var rows [][]string
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		rows = append(rows, strings.Fields(line))
+	}
+	return rows
+}
+
+func TestListModels(t *testing.T) {
+	m1 := newTestModel("m1")
+	m1.Spec.Format = "pb"
+	m1.Status.Verifications = []neptunev1.ModelVerification{{NodeName: "edge1", Verified: true}, {NodeName: "edge2"}}
+	m1.Status.Metrics = []neptunev1.Metric{{Key: "recall", Value: "0.8"}}
+	m2 := newTestModel("m2")
+	m2.Namespace = "other"
+
+	rows := listRows(t, "", []runtime.Object{m1, m2}, nil, (*lister).listModels)
+	expected := [][]string{
+		{"NAMESPACE", "NAME", "FORMAT", "VERIFIED", "METRICS", "UPDATED", "AGE"},
+		{"default", "m1", "pb", "1/2", "recall=0.8", "<none>", "<unknown>"},
+		{"other", "m2", "<none>", "0/0", "<none>", "<none>", "<unknown>"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, rows)
+	}
+
+	// the namespace column is only printed for all namespaces
+	rows = listRows(t, testNamespace, []runtime.Object{m1, m2}, nil, (*lister).listModels)
+	expected = [][]string{
+		{"NAME", "FORMAT", "VERIFIED", "METRICS", "UPDATED", "AGE"},
+		{"m1", "pb", "1/2", "recall=0.8", "<none>", "<unknown>"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, rows)
+	}
+}
+
+func TestListDatasets(t *testing.T) {
+	d1 := newTestDataset("d1")
+	d1.Spec.NodeName = "edge1"
+	d1.Spec.Format = "txt"
+	d1.Status.NumberOfSamples = 100
+	d1.Status.MissingSamples = 2
+
+	rows := listRows(t, testNamespace, []runtime.Object{d1}, nil, (*lister).listDatasets)
+	expected := [][]string{
+		{"NAME", "NODE", "FORMAT", "SAMPLES", "MISSING", "UPDATED", "AGE"},
+		{"d1", "edge1", "txt", "100", "2", "<none>", "<unknown>"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, rows)
+	}
+}
+
+func newTestWorkerPod(name, label, value string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name, Labels: map[string]string{label: value}},
+		Status:     v1.PodStatus{Phase: phase},
+	}
+}
+
+func TestListWorkers(t *testing.T) {
+	job := newTestFLJob("fl1", "agg-model", "d1", "d2")
+	job.Status.Phase = neptunev1.FLJobRunning
+	job.Status.CurrentRound = 3
+	job.Status.Workers = []neptunev1.WorkerHealth{{Healthy: true}, {Healthy: false}}
+	service := newTestJointInferenceService("ji1", "ji1-edge", "ji1-cloud")
+	service.Status.Active = 1
+	service.Status.Workers = []neptunev1.WorkerHealth{{Healthy: true}}
+	pods := []runtime.Object{
+		newTestWorkerPod("fl1-agg", "federatedlearningjob.neptune.io/name", "fl1", v1.PodRunning),
+		newTestWorkerPod("fl1-train-0", "federatedlearningjob.neptune.io/name", "fl1", v1.PodRunning),
+		newTestWorkerPod("fl1-train-1", "federatedlearningjob.neptune.io/name", "fl1", v1.PodPending),
+		newTestWorkerPod("ji1-edge", "jointinferenceservice.neptune.io/name", "ji1", v1.PodRunning),
+		newTestWorkerPod("other", "app", "fl1", v1.PodRunning),
+	}
+	objects := []runtime.Object{job, service}
+
+	rows := listRows(t, testNamespace, objects, pods, (*lister).listFederatedLearningJobs)
+	expected := [][]string{
+		{"NAME", "PHASE", "ROUND", "WORKERS", "PODS", "AGE"},
+		{"fl1", "Running", "3", "1/2", "2/3", "<unknown>"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, rows)
+	}
+
+	rows = listRows(t, testNamespace, objects, pods, (*lister).listJointInferenceServices)
+	expected = [][]string{
+		{"NAME", "ACTIVE", "FAILED", "EDGE-WORKER", "PODS", "AGE"},
+		{"ji1", "1", "0", "Healthy", "1/1", "<unknown>"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, rows)
+	}
+}
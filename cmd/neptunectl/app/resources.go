@@ -0,0 +1,81 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager"
+)
+
+// resourceKind defines a kind of the Neptune resources
+type resourceKind struct {
+	// Kind is the kind of the resource, e.g. FederatedLearningJob
+	Kind    string
+	aliases []string
+}
+
+var (
+	federatedLearningJobKind  = &resourceKind{Kind: "FederatedLearningJob", aliases: []string{"federatedlearningjobs", "fljob", "fljobs", "fl"}}
+	jointInferenceServiceKind = &resourceKind{Kind: "JointInferenceService", aliases: []string{"jointinferenceservices", "jis", "ji"}}
+	modelKind                 = &resourceKind{Kind: "Model", aliases: []string{"models"}}
+	datasetKind               = &resourceKind{Kind: "Dataset", aliases: []string{"datasets", "ds"}}
+
+	resourceKinds = []*resourceKind{federatedLearningJobKind, jointInferenceServiceKind, modelKind, datasetKind}
+)
+
+// resourceKindNames is the help of the kinds for the commands
+const resourceKindNames = "federatedlearningjob (fl), jointinferenceservice (ji), model, dataset (ds)"
+
+// parseResourceKind returns the kind of the name, which is case insensitive and may be plural or short
+func parseResourceKind(name string) (*resourceKind, error) {
+	name = strings.ToLower(name)
+	for _, kind := range resourceKinds {
+		if name == strings.ToLower(kind.Kind) {
+			return kind, nil
+		}
+		for _, alias := range kind.aliases {
+			if name == alias {
+				return kind, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown resource kind %q, supported kinds: %s", name, resourceKindNames)
+}
+
+// resource returns the resource of the kind qualified by the group, e.g. model.neptune.io
+func (k *resourceKind) resource() string {
+	return strings.ToLower(k.Kind) + "." + neptunev1.SchemeGroupVersion.Group
+}
+
+// resourceName returns the name of the resource like kubectl, e.g. model.neptune.io/name
+func resourceName(kind *resourceKind, name string) string {
+	return kind.resource() + "/" + name
+}
+
+// object returns the metadata of the resource of the kind with the name,
+// from which GM generates the labels of the workers
+func (k *resourceKind) object(name string) globalmanager.CommonInterface {
+	object := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	object.SetGroupVersionKind(neptunev1.SchemeGroupVersion.WithKind(k.Kind))
+	return object
+}
+
+// workerLabelKey returns the label key of the pods and the services created by GM for the resources of the kind
+func workerLabelKey(kind *resourceKind) string {
+	for key := range globalmanager.GenerateLabels(kind.object("")) {
+		return key
+	}
+	return ""
+}
+
+// workerSelector returns the label selector of the pods and the services created by GM for the resource
+func workerSelector(kind *resourceKind, name string) (string, error) {
+	selector, err := globalmanager.GenerateSelector(kind.object(name))
+	if err != nil {
+		return "", fmt.Errorf("invalid name %q of %s: %v", name, kind.Kind, err)
+	}
+	return selector.String(), nil
+}
@@ -0,0 +1,61 @@
+package app
+
+import "testing"
+
+func TestParseResourceKind(t *testing.T) {
+	cases := []struct {
+		name string
+		kind *resourceKind
+	}{
+		{"FederatedLearningJob", federatedLearningJobKind},
+		{"federatedlearningjobs", federatedLearningJobKind},
+		{"fl", federatedLearningJobKind},
+		{"FLJob", federatedLearningJobKind},
+		{"jointinferenceservice", jointInferenceServiceKind},
+		{"ji", jointInferenceServiceKind},
+		{"Model", modelKind},
+		{"models", modelKind},
+		{"ds", datasetKind},
+		{"DATASETS", datasetKind},
+		{"pod", nil},
+		{"", nil},
+	}
+	for _, c := range cases {
+		kind, err := parseResourceKind(c.name)
+		if c.kind == nil {
+			if err == nil {
+				t.Errorf("%q: expected unknown kind, got %s", c.name, kind.Kind)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.name, err)
+			continue
+		}
+		if kind != c.kind {
+			t.Errorf("%q: expected kind %s, got %s", c.name, c.kind.Kind, kind.Kind)
+		}
+	}
+
+	if resource := modelKind.resource(); resource != "model.neptune.io" {
+		t.Errorf("expected the resource qualified by the group, got %s", resource)
+	}
+}
+
+func TestWorkerSelector(t *testing.T) {
+	if key := workerLabelKey(federatedLearningJobKind); key != "federatedlearningjob.neptune.io/name" {
+		t.Errorf("expected the label key generated by GM, got %s", key)
+	}
+
+	selector, err := workerSelector(jointInferenceServiceKind, "ji1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selector != "jointinferenceservice.neptune.io/name=ji1" {
+		t.Errorf("expected the selector generated by GM, got %s", selector)
+	}
+
+	if _, err = workerSelector(modelKind, "invalid/name"); err == nil {
+		t.Errorf("expected the invalid name rejected")
+	}
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptunectl/app/options"
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned"
+)
+
+func newWatchCommand(opts *options.NeptunectlOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch FEDERATED_LEARNING_JOB",
+		Short: "Watch the training rounds of a federated learning job until it completes",
+		Long: `Watch the training rounds of a federated learning job until it completes.
+A line is printed whenever the round, the phase, the pods or the workers of the job change.`,
+		Example: `  # watch the rounds of the federated learning job
+  neptunectl watch surface-defect-detection`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespace, err := opts.GetNamespace()
+			if err != nil {
+				return err
+			}
+			client, _, err := opts.Clients()
+			if err != nil {
+				return err
+			}
+			return watchFederatedLearningJob(context.Background(), cmd.OutOrStdout(), client, namespace, args[0])
+		},
+	}
+	return cmd
+}
+
+// roundFormat is the format of the progress lines, whose columns are aligned by fixed widths
+// since the lines are printed one by one
+const roundFormat = "%-25v %-6v %-10v %-25v %v\n"
+
+// roundPrinter prints the progress of the federated learning job once changed
+type roundPrinter struct {
+	out  io.Writer
+	last string
+}
+
+// print prints the progress of the job if changed, and returns whether the job is finished
+func (p *roundPrinter) print(job *neptunev1.FederatedLearningJob) bool {
+	status := &job.Status
+	columns := []interface{}{status.CurrentRound, orNone(string(status.Phase)),
+		fmt.Sprintf("%d/%d/%d", status.Active, status.Succeeded, status.Failed),
+		fmt.Sprintf("%d/%d", healthyWorkers(status.Workers), len(job.Spec.TrainingWorkers))}
+	if progress := fmt.Sprintf("%v", columns); progress != p.last {
+		p.last = progress
+		fmt.Fprintf(p.out, roundFormat, append([]interface{}{time.Now().Format(time.RFC3339)}, columns...)...)
+	}
+	return status.Phase == neptunev1.FLJobSucceeded || status.Phase == neptunev1.FLJobFailed
+}
+
+// watchFederatedLearningJob prints the progress of the job until it's finished or deleted
+func watchFederatedLearningJob(ctx context.Context, out io.Writer, client versioned.Interface, namespace, name string) error {
+	jobs := client.NeptuneV1alpha1().FederatedLearningJobs(namespace)
+	p := &roundPrinter{out: out}
+	fmt.Fprintf(out, roundFormat, "TIME", "ROUND", "PHASE", "ACTIVE/SUCCEEDED/FAILED", "HEALTHY-WORKERS")
+
+	for {
+		job, err := jobs.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if p.print(job) {
+			return nil
+		}
+
+		watcher, err := jobs.Watch(ctx, metav1.ListOptions{
+			FieldSelector:   "metadata.name=" + name,
+			ResourceVersion: job.ResourceVersion,
+		})
+		if err != nil {
+			return err
+		}
+
+		// the job is got again once the watch closed or expired
+		for event := range watcher.ResultChan() {
+			if event.Type == watch.Deleted {
+				watcher.Stop()
+				return fmt.Errorf("federated learning job %s/%s is deleted", namespace, name)
+			}
+			job, ok := event.Object.(*neptunev1.FederatedLearningJob)
+			if !ok {
+				break
+			}
+			if p.print(job) {
+				watcher.Stop()
+				return nil
+			}
+		}
+		watcher.Stop()
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+
+	"k8s.io/component-base/logs"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptunectl/app"
+)
+
+func main() {
+	command := app.NewNeptunectlCommand()
+	logs.InitLogs()
+	defer logs.FlushLogs()
+
+	if err := command.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
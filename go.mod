@@ -3,10 +3,18 @@ module github.com/edgeai-neptune/neptune
 go 1.14
 
 require (
+	github.com/eclipse/paho.mqtt.golang v1.3.5
 	github.com/emicklei/go-restful/v3 v3.4.0
-	github.com/gorilla/websocket v1.4.0
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/golang/protobuf v1.4.2
+	github.com/gorilla/websocket v1.4.2
+	github.com/mochi-co/mqtt v1.0.0
+	github.com/prometheus/client_golang v1.7.1
 	github.com/spf13/cobra v1.0.0
 	github.com/spf13/pflag v1.0.5
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	google.golang.org/grpc v1.27.1
+	google.golang.org/protobuf v1.24.0
 	gorm.io/driver/sqlite v1.1.4
 	gorm.io/gorm v1.20.9
 	k8s.io/api v0.19.3
@@ -153,6 +153,7 @@ neptune::check::env() {
 ALL_BINARIES_AND_TARGETS=(
   gm:cmd/neptune-gm
   lc:cmd/neptune-lc
+  ctl:cmd/neptunectl
 )
 
 neptune::golang::get_target_by_binary() {
@@ -0,0 +1,12 @@
+#!/bin/bash
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+NEPTUNE_ROOT="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd -P)"
+
+# requires protoc, and protoc-gen-go of the github.com/golang/protobuf version in go.mod:
+#   go install github.com/golang/protobuf/protoc-gen-go
+cd ${NEPTUNE_ROOT}/pkg/messagelayer/pb
+protoc -I . --go_out=plugins=grpc,paths=source_relative:. message.proto
@@ -15,7 +15,11 @@ class BaseConfig:
     # the name of FederatedLearningJob and others Job
     job_name = os.getenv("JOB_NAME", "")
 
+    # the model to load, which is the model verified in the cache of the lc
+    # if the model has a digest or a signature
     model_url = os.getenv("MODEL_URL")
+    # where the model trained is saved, the model to load by default
+    model_save_url = os.getenv("MODEL_SAVE_URL") or model_url
 
     # user parameter
     parameters = os.getenv("PARAMETERS")
@@ -65,6 +65,13 @@ def train(model,
 
     LOG.info("transmitter started!")
 
+    LCClient.start_heartbeat(fl_config.worker_name, {
+        "name": fl_config.worker_name,
+        "namespace": fl_config.namespace,
+        "ownerName": fl_config.job_name,
+        "ownerKind": K8sResourceKind.FEDERATED_LEARNING_JOB.value,
+    })
+
     model.compile(loss=loss,
                   optimizer=optimizer,
                   metrics=metrics)
@@ -104,10 +111,10 @@ def _report_task_info(task_info, status, history):
     if task_info is None:
         LOG.info(f"task info is None, no need to report to lc.")
         return
-    ckpt_model_url = remove_path_prefix(fl_config.model_url,
+    ckpt_model_url = remove_path_prefix(fl_config.model_save_url,
                                         fl_config.data_path_prefix)
     pb_model_url = remove_path_prefix(
-        os.path.join(fl_config.model_url, 'model.pb'),
+        os.path.join(fl_config.model_save_url, 'model.pb'),
         fl_config.data_path_prefix)
     ckpt_result = {
         "format": "ckpt",
@@ -334,6 +334,13 @@ class JointInference:
         self.lc_reporter.setDaemon(True)
         self.lc_reporter.start()
 
+        LCClient.start_heartbeat(BaseConfig.worker_name, {
+            "name": BaseConfig.worker_name,
+            "namespace": BaseConfig.namespace,
+            "ownerName": BaseConfig.service_name,
+            "ownerKind": K8sResourceKind.JOINT_INFERENCE_SERVICE.value,
+        })
+
     def inference(self, img_data) -> InferenceResult:
         """Image inference function."""
         img_data_pre = img_data
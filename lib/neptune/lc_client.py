@@ -1,37 +1,85 @@
+import http.client
+import json
 import logging
 import os
+import socket
+import threading
 import time
 
 import requests
 
 LOG = logging.getLogger(__name__)
 
+UNIX_SCHEME = "unix://"
+
+
+class UnixHTTPConnection(http.client.HTTPConnection):
+    """HTTP connection to the lc over its unix domain socket."""
+
+    def __init__(self, socket_path, timeout=None):
+        http.client.HTTPConnection.__init__(self, "localhost",
+                                            timeout=timeout)
+        self.socket_path = socket_path
+
+    def connect(self):
+        sock = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+        sock.settimeout(self.timeout)
+        sock.connect(self.socket_path)
+        self.sock = sock
+
 
 class LCClientConfig:
     def __init__(self):
         self.lc_server = os.getenv("LC_SERVER", "http://127.0.0.1:9100")
+        self.heartbeat_interval = int(os.getenv("HEARTBEAT_INTERVAL", "10"))
+        # the token issued to the worker, presented to the lc if not empty
+        self.worker_token = os.getenv("WORKER_TOKEN", "")
 
 
 class LCClient:
     _retry = 3
     _retry_interval_seconds = 0.5
+    _timeout_seconds = 10
     config = LCClientConfig()
 
+    @classmethod
+    def _post(cls, path, message: dict):
+        """Post the message to the lc, returns the status code."""
+        headers = {"Content-Type": "application/json"}
+        if cls.config.worker_token:
+            headers["Authorization"] = f"Bearer {cls.config.worker_token}"
+
+        lc_server = cls.config.lc_server
+        if not lc_server.startswith(UNIX_SCHEME):
+            res = requests.post(url=lc_server + path, json=message,
+                                headers=headers,
+                                timeout=cls._timeout_seconds)
+            return res.status_code
+
+        conn = UnixHTTPConnection(lc_server[len(UNIX_SCHEME):],
+                                  timeout=cls._timeout_seconds)
+        try:
+            conn.request("POST", path, body=json.dumps(message),
+                         headers=headers)
+            res = conn.getresponse()
+            res.read()
+            return res.status
+        finally:
+            conn.close()
+
     @classmethod
     def send(cls, worker_name, message: dict):
 
-        url = '{0}/neptune/workers/{1}/info'.format(
-            cls.config.lc_server,
-            worker_name
-        )
+        path = '/neptune/workers/{0}/info'.format(worker_name)
+        url = cls.config.lc_server + path
         error = None
         for i in range(cls._retry):
             try:
-                res = requests.post(url=url, json=message)
+                status_code = cls._post(path, message)
                 LOG.info(
                     f"send to lc, url={url}, data={message},"
-                    f"state={res.status_code}")
-                return res.status_code < 300
+                    f"state={status_code}")
+                return status_code < 300
             except Exception as e:
                 error = e
                 time.sleep(cls._retry_interval_seconds)
@@ -41,3 +89,30 @@ class LCClient:
             f"data={message}, error={error}, "
             f"retry times: {cls._retry}")
         return False
+
+    @classmethod
+    def start_heartbeat(cls, worker_name, message: dict):
+        """Report the liveness of the worker to the lc periodically in
+        background, the message identifies the worker and its owner."""
+        heartbeat = LCHeartbeat(worker_name, message,
+                                cls.config.heartbeat_interval)
+        heartbeat.setDaemon(True)
+        heartbeat.start()
+        return heartbeat
+
+
+class LCHeartbeat(threading.Thread):
+    """Daemon thread which periodically reports the liveness of the worker
+    to the lc.
+    """
+
+    def __init__(self, worker_name, message: dict, interval):
+        threading.Thread.__init__(self)
+        self.worker_name = worker_name
+        self.message = dict(message, kind="heartbeat")
+        self.interval = interval
+
+    def run(self):
+        while True:
+            LCClient.send(self.worker_name, self.message)
+            time.sleep(self.interval)
@@ -9,5 +9,5 @@ def load_model():
 
 
 def save_model(model):
-    model_url = BaseConfig.model_url
+    model_url = BaseConfig.model_save_url
     model.save(model_url)
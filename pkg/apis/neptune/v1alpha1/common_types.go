@@ -1,5 +1,9 @@
 package v1alpha1
 
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 // Metric describes the data that a resource model metric should have
 type Metric struct {
 	Key   string `json:"key"`
@@ -15,6 +19,31 @@ type CommonWorkerSpec struct {
 	Parameters       []ParaSpec `json:"parameters"`
 }
 
+// WorkerHealth describes the liveness of a worker reported by the LC of its node
+type WorkerHealth struct {
+	// Name of the worker.
+	Name string `json:"name"`
+	// The node which the worker runs on.
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+	// Whether the worker reported to LC within the heartbeat interval.
+	Healthy bool `json:"healthy"`
+	// The phase and status last reported by the worker.
+	// +optional
+	Phase string `json:"phase,omitempty"`
+	// +optional
+	Status string `json:"status,omitempty"`
+	// Last time the worker reported to LC.
+	// +optional
+	LastHeartbeatTime metav1.Time `json:"lastHeartbeatTime,omitempty"`
+	// Last time the health of the worker changed.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Human readable message indicating details about the health.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 // ParaSpec is a description of a parameter
 type ParaSpec struct {
 	Key   string `json:"key"`
@@ -25,10 +25,31 @@ type DatasetSpec struct {
 }
 
 // DatasetStatus represents information about the status of a dataset
-// including the time a dataset updated, and number of samples in a dataset
+// including the time a dataset updated, number of samples in a dataset,
+// and the statistics of the samples computed by LC
 type DatasetStatus struct {
 	UpdateTime      *metav1.Time `json:"updateTime,omitempty" protobuf:"bytes,1,opt,name=updateTime"`
 	NumberOfSamples int          `json:"numberOfSamples"`
+	// The total number of samples added to the data source, which only increases,
+	// so the samples added between two statuses are the difference of them.
+	// +optional
+	SamplesAdded int `json:"samplesAdded,omitempty"`
+	// The number of the latest samples whose files are checked,
+	// which is limited by the computation budget of LC.
+	// +optional
+	CheckedSamples int `json:"checkedSamples,omitempty"`
+	// The total bytes of the sample files checked.
+	// +optional
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	// The number of the sample files checked which are missing or unreadable.
+	// +optional
+	MissingSamples int `json:"missingSamples,omitempty"`
+	// The number of samples of each label, for the labeled formats.
+	// +optional
+	LabelDistribution map[string]int `json:"labelDistribution,omitempty"`
+	// The last time the data source or the sample files checked were modified.
+	// +optional
+	LastModifiedTime *metav1.Time `json:"lastModifiedTime,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
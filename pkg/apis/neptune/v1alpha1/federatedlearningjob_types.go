@@ -97,6 +97,18 @@ type FLJobStatus struct {
 	// The phase of the federatedlearning job.
 	// +optional
 	Phase FLJobPhase `json:"phase,omitempty"`
+
+	// The liveness of the training workers reported by LCs.
+	// +optional
+	Workers []WorkerHealth `json:"workers,omitempty"`
+
+	// The latest training round reported by the aggregation worker.
+	// +optional
+	CurrentRound int32 `json:"currentRound,omitempty"`
+
+	// Last time the aggregation worker reported the training round.
+	// +optional
+	RoundUpdateTime *metav1.Time `json:"roundUpdateTime,omitempty"`
 }
 
 type FLJobConditionType string
@@ -87,6 +87,10 @@ type JointInferenceServiceStatus struct {
 
 	// Metrics of the joint inference service.
 	Metrics []Metric `json:"metrics,omitempty"`
+
+	// The liveness of the edge workers reported by LCs.
+	// +optional
+	Workers []WorkerHealth `json:"workers,omitempty"`
 }
 
 // JointInferenceServiceConditionType defines the condition type
@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -21,6 +22,17 @@ type Model struct {
 type ModelSpec struct {
 	ModelURL string `json:"url"`
 	Format   string `json:"format"`
+	// Digest is the expected digest of the model file, in the form of sha256:<hex>.
+	// LCs verify the model against it before exposing the model.
+	// +optional
+	Digest string `json:"digest,omitempty"`
+	// Size is the expected size in bytes of the model file.
+	// +optional
+	Size int64 `json:"size,omitempty"`
+	// Signature is the base64 encoded detached signature of the sha256 digest
+	// of the model file. LCs with trusted keys only expose the signed models.
+	// +optional
+	Signature string `json:"signature,omitempty"`
 }
 
 // ModelStatus represents information about the status of a model
@@ -28,6 +40,83 @@ type ModelSpec struct {
 type ModelStatus struct {
 	UpdateTime *metav1.Time `json:"updateTime,omitempty" protobuf:"bytes,1,opt,name=updateTime"`
 	Metrics    []Metric     `json:"metrics,omitempty" protobuf:"bytes,2,rep,name=metrics"`
+	// The latest metrics reported before, in the order of time.
+	// At most MaxModelMetricsHistory records are kept.
+	// +optional
+	MetricsHistory []ModelMetricsRecord `json:"metricsHistory,omitempty"`
+	// The verifications of the model on the nodes by LCs.
+	// +optional
+	Verifications []ModelVerification `json:"verifications,omitempty"`
+}
+
+// MaxModelMetricsHistory is the max number of records in the metrics history of a model
+const MaxModelMetricsHistory = 50
+
+// ModelMetricsRecord describes the metrics of a model reported at a time
+type ModelMetricsRecord struct {
+	// The time the metrics reported.
+	UpdateTime metav1.Time `json:"updateTime"`
+	// The training round of the federated learning job when reported.
+	// +optional
+	Round   int32    `json:"round,omitempty"`
+	Metrics []Metric `json:"metrics,omitempty"`
+}
+
+// ModelVerification describes the verification of the model on a node
+type ModelVerification struct {
+	// The node which verified the model.
+	NodeName string `json:"nodeName"`
+	// The generation of the model verified, the workers using the model on the node
+	// are only created after the current generation verified.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Whether the model matches the digest, the size and the signature of the spec.
+	Verified bool `json:"verified"`
+	// The digest and the size of the model file fetched by LC.
+	// +optional
+	Digest string `json:"digest,omitempty"`
+	// +optional
+	Size int64 `json:"size,omitempty"`
+	// The path of the model in the cache of LC.
+	// +optional
+	CachePath string `json:"cachePath,omitempty"`
+	// Human readable message indicating details about the verification.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Last time the model was verified.
+	// +optional
+	LastVerifyTime metav1.Time `json:"lastVerifyTime,omitempty"`
+	// The conditions of the verification.
+	// +optional
+	Conditions []ModelCondition `json:"conditions,omitempty"`
+}
+
+// ModelConditionType defines the condition type of the model verification
+type ModelConditionType string
+
+// These are valid conditions of the model verification.
+const (
+	// ModelCondIntegrityVerified means the model matches the digest and the size.
+	ModelCondIntegrityVerified ModelConditionType = "IntegrityVerified"
+	// ModelCondSignatureVerified means the signature of the model is verified by a trusted key.
+	ModelCondSignatureVerified ModelConditionType = "SignatureVerified"
+)
+
+// ModelCondition describes the state of the model verification on a node.
+type ModelCondition struct {
+	// Type of model condition, IntegrityVerified or SignatureVerified.
+	Type ModelConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status v1.ConditionStatus `json:"status"`
+	// Last time the condition transit from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// (brief) reason for the condition's last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Human readable message indicating details about last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -182,6 +182,17 @@ func (in *DatasetStatus) DeepCopyInto(out *DatasetStatus) {
 		in, out := &in.UpdateTime, &out.UpdateTime
 		*out = (*in).DeepCopy()
 	}
+	if in.LabelDistribution != nil {
+		in, out := &in.LabelDistribution, &out.LabelDistribution
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LastModifiedTime != nil {
+		in, out := &in.LastModifiedTime, &out.LastModifiedTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -274,6 +285,17 @@ func (in *FLJobStatus) DeepCopyInto(out *FLJobStatus) {
 		in, out := &in.CompletionTime, &out.CompletionTime
 		*out = (*in).DeepCopy()
 	}
+	if in.Workers != nil {
+		in, out := &in.Workers, &out.Workers
+		*out = make([]WorkerHealth, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RoundUpdateTime != nil {
+		in, out := &in.RoundUpdateTime, &out.RoundUpdateTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -485,6 +507,13 @@ func (in *JointInferenceServiceStatus) DeepCopyInto(out *JointInferenceServiceSt
 		*out = make([]Metric, len(*in))
 		copy(*out, *in)
 	}
+	if in.Workers != nil {
+		in, out := &in.Workers, &out.Workers
+		*out = make([]WorkerHealth, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -542,6 +571,23 @@ func (in *Model) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelCondition) DeepCopyInto(out *ModelCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelCondition.
+func (in *ModelCondition) DeepCopy() *ModelCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ModelList) DeepCopyInto(out *ModelList) {
 	*out = *in
@@ -575,6 +621,28 @@ func (in *ModelList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelMetricsRecord) DeepCopyInto(out *ModelMetricsRecord) {
+	*out = *in
+	in.UpdateTime.DeepCopyInto(&out.UpdateTime)
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]Metric, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelMetricsRecord.
+func (in *ModelMetricsRecord) DeepCopy() *ModelMetricsRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelMetricsRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ModelSpec) DeepCopyInto(out *ModelSpec) {
 	*out = *in
@@ -603,6 +671,20 @@ func (in *ModelStatus) DeepCopyInto(out *ModelStatus) {
 		*out = make([]Metric, len(*in))
 		copy(*out, *in)
 	}
+	if in.MetricsHistory != nil {
+		in, out := &in.MetricsHistory, &out.MetricsHistory
+		*out = make([]ModelMetricsRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Verifications != nil {
+		in, out := &in.Verifications, &out.Verifications
+		*out = make([]ModelVerification, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -616,6 +698,30 @@ func (in *ModelStatus) DeepCopy() *ModelStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelVerification) DeepCopyInto(out *ModelVerification) {
+	*out = *in
+	in.LastVerifyTime.DeepCopyInto(&out.LastVerifyTime)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]ModelCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelVerification.
+func (in *ModelVerification) DeepCopy() *ModelVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ParaSpec) DeepCopyInto(out *ParaSpec) {
 	*out = *in
@@ -682,3 +788,21 @@ func (in *TrainingWorkerSpec) DeepCopy() *TrainingWorkerSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkerHealth) DeepCopyInto(out *WorkerHealth) {
+	*out = *in
+	in.LastHeartbeatTime.DeepCopyInto(&out.LastHeartbeatTime)
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerHealth.
+func (in *WorkerHealth) DeepCopy() *WorkerHealth {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkerHealth)
+	in.DeepCopyInto(out)
+	return out
+}
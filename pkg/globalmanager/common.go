@@ -2,18 +2,30 @@ package globalmanager
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
 	"math"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	neptuneclientset "github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned/typed/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+	"github.com/edgeai-neptune/neptune/pkg/util"
 )
 
 const (
@@ -26,6 +38,14 @@ const (
 	codePrefix         = "/home/work"
 	dataPrefix         = "/home/data"
 	bigModelPort int32 = 5000
+	// lcSocketVolumeName is the name of the volume of the LC socket directory
+	lcSocketVolumeName = "lc-socket"
+	// workerNameEnv is the env of the worker name, which the worker reports to LC by
+	workerNameEnv = "WORKER_NAME"
+	// workerTokenEnv is the env of the worker token, which the worker presents to LC
+	workerTokenEnv = "WORKER_TOKEN"
+	// workerTokenSecretKey is the key of the worker token in the secret of the worker
+	workerTokenSecretKey = "token"
 )
 
 // CreateVolumeMap creates volumeMap for container and
@@ -53,11 +73,226 @@ func CreateVolumeMap(containerPara *ContainerPara) ([]v1.VolumeMount, []v1.Volum
 		}
 		volumes = append(volumes, tempVolume)
 	}
+	if containerPara.modelCachePath != "" {
+		// the cached model is a file or a directory
+		volumeMounts = append(volumeMounts, v1.VolumeMount{
+			MountPath: dataPrefix + containerPara.modelCachePath,
+			Name:      modelCacheVolumeName,
+			ReadOnly:  true,
+		})
+		volumes = append(volumes, v1.Volume{
+			Name: modelCacheVolumeName,
+			VolumeSource: v1.VolumeSource{
+				HostPath: &v1.HostPathVolumeSource{Path: containerPara.modelCachePath},
+			},
+		})
+	}
 	return volumeMounts, volumes
 }
 
+// injectLCAccess configures the worker to access the LC of its node,
+// i.e. the LC socket mounted into the worker if configured, and the token of
+// the worker owned by the object if the worker tokens are enabled, which is
+// stored in the secret of the worker and issued when the pod created, see createWorkerPod.
+func injectLCAccess(lc config.LCConfig, containerPara *ContainerPara, owner CommonInterface, gvk schema.GroupVersionKind) {
+	if lc.Socket != "" {
+		socketDir := filepath.Dir(lc.Socket)
+		containerPara.volumeMountList = append(containerPara.volumeMountList, socketDir)
+		containerPara.volumeList = append(containerPara.volumeList, socketDir)
+		containerPara.volumeMapName = append(containerPara.volumeMapName, lcSocketVolumeName)
+		containerPara.env["LC_SERVER"] = "unix://" + lc.Socket
+	}
+
+	if lc.TokenKeyFile != "" {
+		workerName := containerPara.env[workerNameEnv]
+		containerPara.tokenSecret = newWorkerTokenSecret(owner, gvk, workerName)
+		containerPara.tokenClaims = &util.WorkerTokenClaims{
+			Namespace:  owner.GetNamespace(),
+			OwnerKind:  strings.ToLower(gvk.Kind),
+			OwnerName:  owner.GetName(),
+			WorkerName: workerName,
+		}
+	}
+}
+
+// newWorkerTokenSecret creates the secret of the token of the worker owned by the object,
+// without the token issued yet
+func newWorkerTokenSecret(owner CommonInterface, gvk schema.GroupVersionKind, workerName string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: owner.GetNamespace(),
+			Name:      workerName + "-token",
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(owner, gvk),
+			},
+			Labels: GenerateLabels(owner),
+		},
+		Type: v1.SecretTypeOpaque,
+	}
+}
+
+// workerPod is the pod of a worker to create, with the secret of its token if the worker tokens are enabled
+type workerPod struct {
+	pod *v1.Pod
+	// tokenSecret is the secret of the worker token referenced by the pod, and tokenClaims are the
+	// claims of the token issued into the secret when created, both nil if the worker tokens are disabled
+	tokenSecret *v1.Secret
+	tokenClaims *util.WorkerTokenClaims
+}
+
+// newWorkerPod returns the worker of the pod built from the container parameters
+func newWorkerPod(pod *v1.Pod, containerPara *ContainerPara) *workerPod {
+	return &workerPod{pod: pod, tokenSecret: containerPara.tokenSecret, tokenClaims: containerPara.tokenClaims}
+}
+
+// issueWorkerToken issues the token of the worker on the node of its pod into its secret,
+// signed by the private key of LC config
+func issueWorkerToken(lc config.LCConfig, w *workerPod) error {
+	key, err := util.LoadWorkerTokenSigningKey(lc.TokenKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load worker token key: %w", err)
+	}
+	claims := *w.tokenClaims
+	claims.NodeName = w.pod.Spec.NodeName
+	token, err := util.NewWorkerToken(key, claims, time.Now(), time.Duration(lc.TokenTTL)*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to create worker token: %w", err)
+	}
+	w.tokenSecret.Data = map[string][]byte{
+		workerTokenSecretKey: []byte(token),
+	}
+	return nil
+}
+
+// createWorkerPod creates the pod of the worker, after the secret of its token issued now if any
+func createWorkerPod(kubeClient kubernetes.Interface, lc config.LCConfig, w *workerPod) (*v1.Pod, error) {
+	if w.tokenSecret != nil {
+		if err := issueWorkerToken(lc, w); err != nil {
+			return nil, err
+		}
+		if err := createWorkerTokenSecret(kubeClient, w.tokenSecret); err != nil {
+			return nil, err
+		}
+	}
+	return kubeClient.CoreV1().Pods(w.pod.Namespace).Create(context.Background(), w.pod, metav1.CreateOptions{})
+}
+
+// createWorkerTokenSecret creates the secret of the worker token,
+// or updates it if left by the last attempt creating the worker
+func createWorkerTokenSecret(kubeClient kubernetes.Interface, secret *v1.Secret) error {
+	ctx := context.Background()
+	secrets := kubeClient.CoreV1().Secrets(secret.Namespace)
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create worker token secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	return nil
+}
+
+// workerRefs looks up the objects referenced by the workers to create
+type workerRefs interface {
+	getModel(namespace, name string) (*neptunev1.Model, error)
+	getDataset(namespace, name string) (*neptunev1.Dataset, error)
+	getNode(name string) (*v1.Node, error)
+}
+
+// clusterRefs looks up the objects referenced by the workers in the cluster,
+// and the nodes in the node store of the controller
+type clusterRefs struct {
+	client     neptuneclientset.NeptuneV1alpha1Interface
+	nodeLister corelisters.NodeLister
+}
+
+func (r *clusterRefs) getModel(namespace, name string) (*neptunev1.Model, error) {
+	return r.client.Models(namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+func (r *clusterRefs) getDataset(namespace, name string) (*neptunev1.Dataset, error) {
+	return r.client.Datasets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+func (r *clusterRefs) getNode(name string) (*v1.Node, error) {
+	return r.nodeLister.Get(name)
+}
+
+// NodeNotFoundError means the node of a worker is not found,
+// and the worker is not created until the node found
+type NodeNotFoundError struct {
+	NodeName string
+	Err      error
+}
+
+func (e *NodeNotFoundError) Error() string {
+	return fmt.Sprintf("failed to get node %s: %v", e.NodeName, e.Err)
+}
+
+func (e *NodeNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// isNodeNotFound returns whether the error is caused by the node of a worker not found
+func isNodeNotFound(err error) bool {
+	var notFound *NodeNotFoundError
+	return goerrors.As(err, &notFound)
+}
+
+// getWorkerNode returns the node of the worker, or a NodeNotFoundError if failed
+func getWorkerNode(refs workerRefs, nodeName string) (*v1.Node, error) {
+	node, err := refs.getNode(nodeName)
+	if err != nil {
+		return nil, &NodeNotFoundError{NodeName: nodeName, Err: err}
+	}
+	return node, nil
+}
+
+// selectWorkerBaseImage selects the base image of the worker for its node
+func selectWorkerBaseImage(refs workerRefs, cfg *config.ControllerConfig, nodeName string,
+	workerSpec neptunev1.CommonWorkerSpec) (*BaseImage, error) {
+	node, err := getWorkerNode(refs, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	return SelectBaseImage(cfg.GetImageHub(), cfg.ImageVariantLabel, node, workerSpec.FrameworkType, workerSpec.FrameworkVersion)
+}
+
+// getWorkerNodeIP returns the ip of the node of the worker
+func getWorkerNodeIP(refs workerRefs, nodeName string) (string, error) {
+	node, err := getWorkerNode(refs, nodeName)
+	if err != nil {
+		return "", err
+	}
+	return getNodeIP(node)
+}
+
+// createWorkerEnvVars creates the env vars of the worker sorted by name,
+// including the worker token referenced from its secret if any
+func createWorkerEnvVars(containerPara *ContainerPara) []v1.EnvVar {
+	envVars := CreateEnvVars(containerPara.env)
+	if containerPara.tokenSecret == nil {
+		return envVars
+	}
+
+	envVars = append(envVars, v1.EnvVar{
+		Name: workerTokenEnv,
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: containerPara.tokenSecret.Name},
+				Key:                  workerTokenSecretKey,
+			},
+		},
+	})
+	sort.Slice(envVars, func(i, j int) bool {
+		return envVars[i].Name < envVars[j].Name
+	})
+	return envVars
+}
+
 // CreateEnvVars creates EnvMap for container
-// include EnvName and EnvValue map for stage of creating a pod
+// include EnvName and EnvValue map for stage of creating a pod,
+// the env vars are sorted by name so that the pods are created deterministically
 func CreateEnvVars(envMap map[string]string) []v1.EnvVar {
 	var envVars []v1.EnvVar
 	for envName, envValue := range envMap {
@@ -67,27 +302,23 @@ func CreateEnvVars(envMap map[string]string) []v1.EnvVar {
 		}
 		envVars = append(envVars, Env)
 	}
+	sort.Slice(envVars, func(i, j int) bool {
+		return envVars[i].Name < envVars[j].Name
+	})
 	return envVars
 }
 
-// MatchContainerBaseImage searches the base image
-func MatchContainerBaseImage(imageHub map[string]string, frameName string, frameVersion string) (string, error) {
-	inputImageName := frameName + ":" + frameVersion
-	for imageName, imageURL := range imageHub {
-		if inputImageName == imageName {
-			return imageURL, nil
-		}
-	}
-	return "", fmt.Errorf("image %v not exists in imagehub", inputImageName)
-}
-
 // GetNodeIPByName get node ip by node name
 func GetNodeIPByName(kubeClient kubernetes.Interface, name string) (string, error) {
 	n, err := kubeClient.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
 	if err != nil {
 		return "", err
 	}
+	return getNodeIP(n)
+}
 
+// getNodeIP returns the external ip of the node, or the internal ip if no external one
+func getNodeIP(n *v1.Node) (string, error) {
 	typeToAddress := make(map[v1.NodeAddressType]string)
 	for _, addr := range n.Status.Addresses {
 		typeToAddress[addr.Type] = addr.Address
@@ -101,7 +332,22 @@ func GetNodeIPByName(kubeClient kubernetes.Interface, name string) (string, erro
 	if found {
 		return address, nil
 	}
-	return "", fmt.Errorf("can't found node ip for node %s", name)
+	return "", fmt.Errorf("can't found node ip for node %s", n.Name)
+}
+
+// podWorkerNames returns the names of the workers running in the pods
+func podWorkerNames(pods []*v1.Pod) sets.String {
+	names := sets.NewString()
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			for _, env := range container.Env {
+				if env.Name == workerNameEnv && env.Value != "" {
+					names.Insert(env.Value)
+				}
+			}
+		}
+	}
+	return names
 }
 
 // GenerateLabels generates labels for an object
@@ -123,16 +369,32 @@ func GenerateSelector(object CommonInterface) (labels.Selector, error) {
 	return metav1.LabelSelectorAsSelector(ls)
 }
 
-// CreateKubernetesService creates a k8s service for an object given ip and port
+// CreateKubernetesService creates a k8s service for an object given ip and port,
+// and returns the node port allocated
 func CreateKubernetesService(kubeClient kubernetes.Interface, object CommonInterface, inputPort int32, inputIP string) (int32, error) {
 	ctx := context.Background()
 	name := object.GetName()
 	namespace := object.GetNamespace()
 	kind := object.GroupVersionKind().Kind
+	serviceSpec := NewKubernetesService(object, inputPort, inputIP)
+	service, err := kubeClient.CoreV1().Services(namespace).Create(ctx, serviceSpec, metav1.CreateOptions{})
+	if err != nil {
+		klog.Warningf("failed to create service for %v %v/%v, err:%s", kind, namespace, name, err)
+		return 0, err
+	}
+
+	klog.V(2).Infof("Service %s is created successfully for %v %v/%v", service.Name, kind, namespace, name)
+	return service.Spec.Ports[0].NodePort, nil
+}
+
+// NewKubernetesService returns the k8s service for an object given ip and port,
+// whose name and node port are allocated by the API server when created
+func NewKubernetesService(object CommonInterface, inputPort int32, inputIP string) *v1.Service {
+	name := object.GetName()
 	targePort := intstr.IntOrString{
 		IntVal: inputPort,
 	}
-	serviceSpec := &v1.Service{
+	return &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace:    object.GetNamespace(),
 			GenerateName: name + "-" + "service" + "-",
@@ -155,14 +417,6 @@ func CreateKubernetesService(kubeClient kubernetes.Interface, object CommonInter
 			},
 		},
 	}
-	service, err := kubeClient.CoreV1().Services(namespace).Create(ctx, serviceSpec, metav1.CreateOptions{})
-	if err != nil {
-		klog.Warningf("failed to create service for %v %v/%v, err:%s", kind, namespace, name, err)
-		return 0, err
-	}
-
-	klog.V(2).Infof("Service %s is created successfully for %v %v/%v", service.Name, kind, namespace, name)
-	return service.Spec.Ports[0].NodePort, nil
 }
 
 // getBackoff calc the next wait time for the key
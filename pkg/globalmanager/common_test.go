@@ -0,0 +1,91 @@
+package globalmanager
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+	"github.com/edgeai-neptune/neptune/pkg/util"
+)
+
+func TestInjectLCAccessWorkerToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "globalmanager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key")
+	if err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	gvk := neptunev1.SchemeGroupVersion.WithKind("FederatedLearningJob")
+	job := &neptunev1.FederatedLearningJob{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "job1"}}
+	job.SetGroupVersionKind(gvk)
+	containerPara := &ContainerPara{env: map[string]string{workerNameEnv: "trainworker-1"}}
+	lc := config.LCConfig{TokenKeyFile: keyFile, TokenTTL: 3600}
+	injectLCAccess(lc, containerPara, job, gvk)
+
+	secret := containerPara.tokenSecret
+	if secret == nil {
+		t.Fatal("expected the secret of the worker token")
+	}
+	if secret.Name != "trainworker-1-token" || secret.Namespace != "default" {
+		t.Errorf("unexpected secret %s/%s", secret.Namespace, secret.Name)
+	}
+	if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].Name != "job1" {
+		t.Errorf("expected the secret owned by the job, got %+v", secret.OwnerReferences)
+	}
+
+	// the token is only issued when the worker created
+	if len(secret.Data) != 0 {
+		t.Errorf("expected no token issued before created, got %v", secret.Data)
+	}
+	pod := &v1.Pod{Spec: v1.PodSpec{NodeName: "edge1"}}
+	if err = issueWorkerToken(lc, newWorkerPod(pod, containerPara)); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := util.ParseWorkerToken([]ed25519.PublicKey{public}, string(secret.Data[workerTokenSecretKey]), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claims.IsIssuedTo("default", "federatedlearningjob", "job1", "trainworker-1") ||
+		claims.NodeName != "edge1" || claims.ExpiresAt-claims.IssuedAt != 3600 {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	// the token is referenced from the secret, never in the pod spec
+	found := false
+	for _, env := range createWorkerEnvVars(containerPara) {
+		if env.Name != workerTokenEnv {
+			continue
+		}
+		found = true
+		if env.Value != "" || env.ValueFrom == nil || env.ValueFrom.SecretKeyRef == nil ||
+			env.ValueFrom.SecretKeyRef.Name != secret.Name || env.ValueFrom.SecretKeyRef.Key != workerTokenSecretKey {
+			t.Errorf("expected %s referenced from secret %s, got %+v", workerTokenEnv, secret.Name, env)
+		}
+	}
+	if !found {
+		t.Errorf("expected env %s", workerTokenEnv)
+	}
+}
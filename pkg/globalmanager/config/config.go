@@ -1,9 +1,15 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/yaml"
@@ -12,11 +18,25 @@ import (
 )
 
 const (
-	defaultKubeConfig       = ""
-	defaultNamespace        = v1.NamespaceAll
-	defaultWebsocketAddress = "0.0.0.0"
-	defaultWebsocketPort    = 9000
-	defaultLCServer         = "http://localhost:9100"
+	defaultKubeConfig        = ""
+	defaultNamespace         = v1.NamespaceAll
+	defaultWebsocketAddress  = "0.0.0.0"
+	defaultWebsocketPort     = 9000
+	defaultPingInterval      = 10
+	defaultReadTimeout       = 30
+	defaultWriteTimeout      = 10
+	defaultLCServer          = "http://localhost:9100"
+	defaultNodeAuthNS        = "default"
+	defaultTransport         = TransportWebSocket
+	defaultMQTTClientID      = "neptune-gm"
+	defaultMQTTTopicPrefix   = "neptune"
+	defaultMQTTQoS           = 1
+	defaultGRPCAddress       = "0.0.0.0"
+	defaultGRPCPort          = 9002
+	defaultOutboxEnable      = false
+	defaultOutboxNS          = "default"
+	defaultOutboxFlush       = 1
+	defaultImageVariantLabel = "neptune.io/image-variant"
 )
 
 // ControllerConfig indicates the config of controller
@@ -31,16 +51,100 @@ type ControllerConfig struct {
 	// Namespace indicates which namespace the controller listening to.
 	// default ""
 	Namespace string `json:"namespace,omitempty"`
-	// ImageHub indicates the image which the framework/version mapping to
+	// ImageHub indicates the image which the framework/version mapping to.
+	// The key is framework:version[:arch[:variant]], the image of a worker is
+	// searched by the architecture and the image variant of its node first,
+	// then by the architecture only, at last by framework:version.
 	// +Required
 	ImageHub map[string]string `json:"imageHub,omitempty"`
+	// ImageVariantLabel is the label of the nodes whose value is the image variant
+	// of the node, such as the accelerator.
+	// default defaultImageVariantLabel
+	ImageVariantLabel string `json:"imageVariantLabel,omitempty"`
 
 	// websocket server config
 	// Since the current limit of kubeedge(1.5), GM needs to build the websocket channel for communicating between GM and LCs.
 	WebSocket WebSocket `json:"websocket,omitempty"`
 
+	// Transport is the transport of the messages between GM and LCs,
+	// TransportWebSocket, TransportMQTT or TransportGRPC.
+	// default defaultTransport
+	Transport string `json:"transport,omitempty"`
+
+	// mqtt config, used when Transport is TransportMQTT
+	MQTT MQTT `json:"mqtt,omitempty"`
+
+	// grpc server config, used when Transport is TransportGRPC.
+	// The certificates, node authentication and timeouts of the websocket
+	// config are shared by the grpc server.
+	GRPC GRPC `json:"grpc,omitempty"`
+
+	// Outbox configures persisting the pending messages to the nodes
+	Outbox Outbox `json:"outbox,omitempty"`
+
 	// lc config to info the worker
 	LC LCConfig `json:"localController,omitempty"`
+
+	// LogLevel is the verbosity of the logs, which overrides the -v flag when specified.
+	// default nil
+	LogLevel *int32 `json:"logLevel,omitempty"`
+}
+
+// reloadableFields are the json names of the fields applied by Reload,
+// the changes of the others require restarting GM.
+var reloadableFields = sets.NewString("imageHub", "localController", "logLevel")
+
+// reloadLock guards the reloadable fields
+var reloadLock sync.RWMutex
+
+// Outbox describes the persistence of the pending messages to the nodes
+type Outbox struct {
+	// Enable persists the pending messages of each node to a configmap,
+	// which are restored when GM restarts.
+	// default defaultOutboxEnable
+	Enable bool `json:"enable"`
+	// Namespace is the namespace of the outbox configmaps
+	// default defaultOutboxNS
+	Namespace string `json:"namespace,omitempty"`
+	// FlushInterval is the interval in seconds the changes of a node are flushed at most once
+	// default defaultOutboxFlush
+	FlushInterval int64 `json:"flushInterval,omitempty"`
+}
+
+const (
+	// TransportWebSocket means GM serves the websocket connected by LCs
+	TransportWebSocket = "websocket"
+	// TransportMQTT means GM and LCs exchange the messages through a MQTT broker
+	TransportMQTT = "mqtt"
+	// TransportGRPC means GM serves the grpc streams connected by LCs
+	TransportGRPC = "grpc"
+)
+
+// GRPC describes GM of grpc server config
+type GRPC struct {
+	// default defaultGRPCAddress
+	Address string `json:"address,omitempty"`
+	// default defaultGRPCPort
+	Port int64 `json:"port,omitempty"`
+}
+
+// MQTT describes GM of mqtt config
+type MQTT struct {
+	// Broker is the address of the MQTT broker, such as tcp://127.0.0.1:1883
+	// +Required when Transport is TransportMQTT
+	Broker string `json:"broker,omitempty"`
+	// ClientID is the client id of GM
+	// default defaultMQTTClientID
+	ClientID string `json:"clientID,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// TopicPrefix is the prefix of the per-node topics,
+	// i.e. <prefix>/<node>/downstream, <prefix>/<node>/upstream and <prefix>/<node>/presence
+	// default defaultMQTTTopicPrefix
+	TopicPrefix string `json:"topicPrefix,omitempty"`
+	// QoS is the QoS of the messages
+	// default defaultMQTTQoS
+	QoS byte `json:"qos,omitempty"`
 }
 
 // WebSocket describes GM of websocket config
@@ -49,12 +153,59 @@ type WebSocket struct {
 	Address string `json:"address,omitempty"`
 	// default defaultWebsocketPort
 	Port int64 `json:"port,omitempty"`
+	// PingInterval is the interval in seconds of the pings sent to LCs, 0 disables the pings.
+	// default defaultPingInterval
+	PingInterval int64 `json:"pingInterval,omitempty"`
+	// ReadTimeout is the time in seconds to wait for any message or heartbeat from a LC,
+	// otherwise the LC is considered disconnected. 0 means no timeout.
+	// default defaultReadTimeout
+	ReadTimeout int64 `json:"readTimeout,omitempty"`
+	// WriteTimeout is the time in seconds to write a message to a LC, 0 means no timeout.
+	// default defaultWriteTimeout
+	WriteTimeout int64 `json:"writeTimeout,omitempty"`
+
+	// CertFile and KeyFile are the server certificate and key, the websocket
+	// is served over TLS(wss) when specified. They are reloaded once modified.
+	// default ""
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// ClientCAFile is the CA bundle verifying the client certificates of LCs,
+	// which enables the mutual TLS. The identity of the client certificate
+	// must match the node name of LC. It's reloaded once modified.
+	// default ""
+	ClientCAFile string `json:"clientCAFile,omitempty"`
+
+	// NodeAuth configures the authentication of LCs by bootstrap tokens
+	NodeAuth NodeAuth `json:"nodeAuth,omitempty"`
+}
+
+// NodeAuth describes the bootstrap token authentication of LCs
+type NodeAuth struct {
+	// Enable requires each LC to present the token of its node,
+	// which is stored in a node token secret.
+	// default false
+	Enable bool `json:"enable,omitempty"`
+	// Namespace is the namespace of the node token secrets
+	// default defaultNodeAuthNS
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // LCConfig describes LC config to inject the worker
 type LCConfig struct {
 	// default defaultLCServer
 	Server string `json:"server"`
+	// Socket is the path of the unix domain socket of LCs on the nodes, whose
+	// directory is mounted into the workers to connect LC instead of Server.
+	// default ""
+	Socket string `json:"socket,omitempty"`
+	// TokenKeyFile is the ed25519 private key file in PEM signing the token injected
+	// into each worker, which LCs verify with the public key. It's read on every worker created.
+	// default ""
+	TokenKeyFile string `json:"tokenKeyFile,omitempty"`
+	// TokenTTL is the lifetime in seconds of the worker tokens, after which LCs
+	// reject them, 0 means the tokens never expire.
+	// default 0
+	TokenTTL int64 `json:"tokenTTL,omitempty"`
 }
 
 // Parse parses from filename
@@ -72,24 +223,145 @@ func (c *ControllerConfig) Parse(filename string) error {
 	return nil
 }
 
+// GetImageHub returns the current imageHub, which can be reloaded
+func (c *ControllerConfig) GetImageHub() map[string]string {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return c.ImageHub
+}
+
+// GetLC returns the current lc config, which can be reloaded
+func (c *ControllerConfig) GetLC() LCConfig {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return c.LC
+}
+
+// Reload applies the reloadable fields of newConfig, and returns the names
+// of the fields changed. Nothing is applied if any of the other fields is
+// changed, since they require restarting GM.
+func (c *ControllerConfig) Reload(newConfig *ControllerConfig) ([]string, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	var changed, restartRequired []string
+	current, desired := reflect.ValueOf(c).Elem(), reflect.ValueOf(newConfig).Elem()
+	for i := 0; i < current.NumField(); i++ {
+		if reflect.DeepEqual(current.Field(i).Interface(), desired.Field(i).Interface()) {
+			continue
+		}
+		name := strings.Split(current.Type().Field(i).Tag.Get("json"), ",")[0]
+		if reloadableFields.Has(name) {
+			changed = append(changed, name)
+		} else {
+			restartRequired = append(restartRequired, name)
+		}
+	}
+	if len(restartRequired) > 0 {
+		return nil, fmt.Errorf("the changes of %s require restarting GM", strings.Join(restartRequired, ", "))
+	}
+
+	c.ImageHub = newConfig.ImageHub
+	c.LC = newConfig.LC
+	c.LogLevel = newConfig.LogLevel
+	return changed, nil
+}
+
 // Validate validate the config
 func (c *ControllerConfig) Validate() field.ErrorList {
 	allErrs := field.ErrorList{}
+	for key := range c.ImageHub {
+		parts := strings.Split(key, ":")
+		if len(parts) < 2 || len(parts) > 4 || sets.NewString(parts...).Has("") {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("imageHub").Key(key), key,
+				"key must be framework:version[:arch[:variant]]"))
+		}
+	}
 	if c.KubeConfig != "" && !util.FileIsExist(c.KubeConfig) {
 		allErrs = append(allErrs, field.Invalid(field.NewPath("kubeconfig"), c.KubeConfig, "kubeconfig not exist"))
 	}
+
+	switch c.Transport {
+	case TransportWebSocket, TransportGRPC:
+	case TransportMQTT:
+		if c.MQTT.Broker == "" {
+			allErrs = append(allErrs, field.Required(field.NewPath("mqtt", "broker"), "mqtt broker is required by mqtt transport"))
+		}
+		if c.MQTT.QoS > 2 {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("mqtt", "qos"), c.MQTT.QoS, "qos must be 0, 1 or 2"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("transport"), c.Transport, []string{TransportWebSocket, TransportMQTT, TransportGRPC}))
+	}
+
+	if c.Outbox.Enable && c.Outbox.FlushInterval <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("outbox", "flushInterval"), c.Outbox.FlushInterval, "flushInterval must be positive"))
+	}
+
+	lcPath := field.NewPath("localController")
+	if c.LC.Socket != "" && !filepath.IsAbs(c.LC.Socket) {
+		allErrs = append(allErrs, field.Invalid(lcPath.Child("socket"), c.LC.Socket, "socket must be an absolute path"))
+	}
+	if c.LC.TokenKeyFile != "" && !util.FileIsExist(c.LC.TokenKeyFile) {
+		allErrs = append(allErrs, field.Invalid(lcPath.Child("tokenKeyFile"), c.LC.TokenKeyFile, "file not exist"))
+	}
+	if c.LC.TokenTTL < 0 {
+		allErrs = append(allErrs, field.Invalid(lcPath.Child("tokenTTL"), c.LC.TokenTTL, "tokenTTL must not be negative"))
+	}
+	if c.LogLevel != nil && *c.LogLevel < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("logLevel"), *c.LogLevel, "logLevel must not be negative"))
+	}
+
+	wsPath := field.NewPath("websocket")
+	if (c.WebSocket.CertFile == "") != (c.WebSocket.KeyFile == "") {
+		allErrs = append(allErrs, field.Invalid(wsPath.Child("certFile"), c.WebSocket.CertFile, "certFile and keyFile must be specified together"))
+	}
+	if c.WebSocket.ClientCAFile != "" && c.WebSocket.CertFile == "" {
+		allErrs = append(allErrs, field.Invalid(wsPath.Child("clientCAFile"), c.WebSocket.ClientCAFile, "clientCAFile requires certFile and keyFile"))
+	}
+	for _, f := range []struct{ name, file string }{
+		{"certFile", c.WebSocket.CertFile},
+		{"keyFile", c.WebSocket.KeyFile},
+		{"clientCAFile", c.WebSocket.ClientCAFile},
+	} {
+		if f.file != "" && !util.FileIsExist(f.file) {
+			allErrs = append(allErrs, field.Invalid(wsPath.Child(f.name), f.file, "file not exist"))
+		}
+	}
 	return allErrs
 }
 
 // NewDefaultControllerConfig creates default config
 func NewDefaultControllerConfig() *ControllerConfig {
 	return &ControllerConfig{
-		KubeConfig: defaultKubeConfig,
-		Master:     "",
-		Namespace:  defaultNamespace,
+		KubeConfig:        defaultKubeConfig,
+		Master:            "",
+		Namespace:         defaultNamespace,
+		ImageVariantLabel: defaultImageVariantLabel,
 		WebSocket: WebSocket{
-			Address: defaultWebsocketAddress,
-			Port:    defaultWebsocketPort,
+			Address:      defaultWebsocketAddress,
+			Port:         defaultWebsocketPort,
+			PingInterval: defaultPingInterval,
+			ReadTimeout:  defaultReadTimeout,
+			WriteTimeout: defaultWriteTimeout,
+			NodeAuth: NodeAuth{
+				Namespace: defaultNodeAuthNS,
+			},
+		},
+		Transport: defaultTransport,
+		MQTT: MQTT{
+			ClientID:    defaultMQTTClientID,
+			TopicPrefix: defaultMQTTTopicPrefix,
+			QoS:         defaultMQTTQoS,
+		},
+		GRPC: GRPC{
+			Address: defaultGRPCAddress,
+			Port:    defaultGRPCPort,
+		},
+		Outbox: Outbox{
+			Enable:        defaultOutboxEnable,
+			Namespace:     defaultOutboxNS,
+			FlushInterval: defaultOutboxFlush,
 		},
 		LC: LCConfig{
 			Server: defaultLCServer,
@@ -0,0 +1,160 @@
+package globalmanager
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+)
+
+// configPollInterval is the interval of checking whether the config file is modified,
+// when the config file can't be watched by inotify
+const configPollInterval = 10 * time.Second
+
+// configReloader reloads the config file once modified, and applies the
+// reloadable fields to the config shared by the controllers.
+// The config file mounted from a configmap is reloaded once the configmap is updated.
+type configReloader struct {
+	filename string
+	cfg      *config.ControllerConfig
+
+	// data is the content of the config file last handled,
+	// so that a rejected content is reported only once
+	data []byte
+
+	// flagLogLevel is the verbosity set by the -v flag,
+	// which is restored once logLevel is removed from the config file
+	flagLogLevel klog.Level
+}
+
+// newConfigReloader creates a configReloader of the config loaded from filename,
+// and applies the log level of the config.
+func newConfigReloader(filename string, cfg *config.ControllerConfig) (*configReloader, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", filename, err)
+	}
+
+	r := &configReloader{
+		filename:     filename,
+		cfg:          cfg,
+		data:         data,
+		flagLogLevel: currentLogLevel(),
+	}
+	r.applyLogLevel()
+	return r, nil
+}
+
+// run reloads the config file once modified until stopCh closed.
+// The directory of the config file is watched by inotify, so that the config file replaced is
+// noticed too, e.g. the configmap updated by kubelet swapping the symlink of its data directory.
+// The config file is polled instead if failed to watch.
+func (r *configReloader) run(stopCh <-chan struct{}) {
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		if err = watcher.Add(filepath.Dir(r.filename)); err != nil {
+			watcher.Close()
+		}
+	}
+	if err != nil {
+		klog.Warningf("failed to watch config file %s by inotify, it is polled instead: %v", r.filename, err)
+		wait.Until(r.reload, configPollInterval, stopCh)
+		return
+	}
+	defer watcher.Close()
+
+	klog.Infof("watching config file %s", r.filename)
+	for {
+		select {
+		case <-stopCh:
+			return
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// the config file is read and compared on any change of the directory,
+			// since the file changed may be the target of its symlinks
+			r.reload()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			klog.Errorf("inotify watcher of config file %s failed: %v", r.filename, err)
+		}
+	}
+}
+
+// reload reloads the config file if modified.
+// The current config is kept if the new one is invalid, or any of the
+// fields requiring restarting GM is changed.
+func (r *configReloader) reload() {
+	data, err := ioutil.ReadFile(r.filename)
+	if err != nil {
+		klog.Warningf("failed to read config file %s, keep the current config: %v", r.filename, err)
+		return
+	}
+	if bytes.Equal(data, r.data) {
+		return
+	}
+	r.data = data
+
+	newConfig := config.NewDefaultControllerConfig()
+	if err = yaml.Unmarshal(data, newConfig); err != nil {
+		klog.Errorf("failed to unmarshal config file %s, keep the current config: %v", r.filename, err)
+		return
+	}
+	if errs := newConfig.Validate(); len(errs) > 0 {
+		klog.Errorf("invalid config file %s, keep the current config: %v", r.filename, errs.ToAggregate())
+		return
+	}
+
+	changed, err := r.cfg.Reload(newConfig)
+	if err != nil {
+		klog.Errorf("rejected the modified config file %s, keep the current config: %v", r.filename, err)
+		return
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	r.applyLogLevel()
+	klog.Infof("reloaded %s from config file %s", strings.Join(changed, ", "), r.filename)
+}
+
+// applyLogLevel sets the verbosity of the logs to logLevel of the config,
+// or the one set by the -v flag if not specified.
+func (r *configReloader) applyLogLevel() {
+	level := r.flagLogLevel
+	if r.cfg.LogLevel != nil {
+		level = klog.Level(*r.cfg.LogLevel)
+	}
+	if level == currentLogLevel() {
+		return
+	}
+
+	var v klog.Level
+	if err := v.Set(strconv.Itoa(int(level))); err != nil {
+		klog.Warningf("failed to set log level to %d: %v", level, err)
+		return
+	}
+	klog.Infof("set log level to %d", level)
+}
+
+// currentLogLevel returns the current verbosity of the logs
+func currentLogLevel() klog.Level {
+	var level klog.Level
+	for klog.V(level + 1).Enabled() {
+		level++
+	}
+	return level
+}
@@ -0,0 +1,68 @@
+package globalmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+)
+
+func TestConfigReloaderWatchesConfigMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "globalmanager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// writeConfigMap updates the config file like kubelet updating the configmap mounted,
+	// i.e. writes the data into a new directory and swaps the symlink of the data directory
+	writeConfigMap := func(version, data string) {
+		dataDir := filepath.Join(dir, "..data_"+version)
+		if err := os.Mkdir(dataDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dataDir, "gm.yaml"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Base(dataDir), filepath.Join(dir, "..data_tmp")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfigMap("1", "imageHub:\n  tensorflow:1.15: tensorflow:1.15\n")
+	filename := filepath.Join(dir, "gm.yaml")
+	if err = os.Symlink(filepath.Join("..data", "gm.yaml"), filename); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.NewDefaultControllerConfig()
+	data, _ := ioutil.ReadFile(filename)
+	if err = yaml.Unmarshal(data, cfg); err != nil {
+		t.Fatal(err)
+	}
+	r, err := newConfigReloader(filename, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go r.run(stopCh)
+	// wait for the watch started
+	time.Sleep(100 * time.Millisecond)
+
+	writeConfigMap("2", "imageHub:\n  tensorflow:1.15: tensorflow:1.15-v2\n")
+	// reloaded by the watch far before polled
+	for deadline := time.Now().Add(configPollInterval / 2); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cfg.GetImageHub()["tensorflow:1.15"] == "tensorflow:1.15-v2" {
+			return
+		}
+	}
+	t.Errorf("expected the configmap updated reloaded, got imageHub %v", cfg.GetImageHub())
+}
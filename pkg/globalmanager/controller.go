@@ -2,17 +2,25 @@ package globalmanager
 
 import (
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	"k8s.io/klog/v2"
 
 	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/grpc"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/mqtt"
 	websocket "github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/ws"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/metrics"
 )
 
 // MainController defines the main controller
 type MainController struct {
 	Config *config.ControllerConfig
+	// ConfigFile is the file Config loaded from, which is watched and
+	// whose reloadable fields are applied once modified when specified.
+	ConfigFile string
 }
 
 // NewController creates a new main controller
@@ -25,12 +33,33 @@ func NewController(cc *config.ControllerConfig) *MainController {
 
 // Start starts the main controller
 func (c *MainController) Start() {
+	if c.ConfigFile != "" {
+		reloader, err := newConfigReloader(c.ConfigFile, c.Config)
+		if err != nil {
+			klog.Fatalf("failed to watch config file: %v", err)
+		}
+		go reloader.run(websocket.Done())
+	}
+
+	if c.Config.Outbox.Enable {
+		// restore the pending messages before any message sent
+		outbox, err := NewConfigMapOutbox(c.Config)
+		if err == nil {
+			err = websocket.EnableOutbox(outbox, time.Duration(c.Config.Outbox.FlushInterval)*time.Second)
+		}
+		if err != nil {
+			klog.Fatalf("failed to enable outbox: %v", err)
+			os.Exit(1)
+		}
+	}
+
 	type newFunc func(cfg *config.ControllerConfig) (FeatureControllerI, error)
 
 	for _, featureFunc := range []newFunc{
 		NewUpstreamController,
 		NewDownstreamController,
 		NewJointController,
+		NewNodeStatusController,
 	} {
 		f, _ := featureFunc(c.Config)
 		err := f.Start()
@@ -41,12 +70,90 @@ func (c *MainController) Start() {
 		}
 	}
 
+	switch c.Config.Transport {
+	case config.TransportMQTT:
+		c.serveMQTT()
+	case config.TransportGRPC:
+		c.serveGRPC()
+	default:
+		c.serveWebSocket()
+	}
+}
+
+// newAuthenticator creates the node authenticator, nil if disabled
+func (c *MainController) newAuthenticator() websocket.Authenticator {
+	if !c.Config.WebSocket.NodeAuth.Enable {
+		return nil
+	}
+
+	na, err := NewNodeTokenAuthenticator(c.Config)
+	if err != nil {
+		klog.Fatalf("failed to create node authenticator: %v", err)
+		os.Exit(1)
+	}
+	return na
+}
+
+// serveMetrics serves the metrics at the websocket address,
+// used when the websocket is not served.
+func (c *MainController) serveMetrics() {
+	addr := fmt.Sprintf("%s:%d", c.Config.WebSocket.Address, c.Config.WebSocket.Port)
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			klog.Errorf("failed to serve metrics at %s: %v", addr, err)
+		}
+	}()
+}
+
+// serveWebSocket serves the websocket connected by LCs
+func (c *MainController) serveWebSocket() {
 	addr := fmt.Sprintf("%s:%d", c.Config.WebSocket.Address, c.Config.WebSocket.Port)
 
-	ws := websocket.NewServer(addr)
-	err := ws.ListenAndServe()
+	ws, err := websocket.NewServer(addr, c.Config.WebSocket, c.newAuthenticator())
+	if err != nil {
+		klog.Fatalf("failed to create websocket server: %v", err)
+		os.Exit(1)
+	}
+	err = ws.ListenAndServe()
 	if err != nil {
 		klog.Fatalf("failed to listen websocket at %s", addr)
 		os.Exit(1)
 	}
 }
+
+// serveMQTT exchanges the messages with LCs through the mqtt broker,
+// and serves the metrics at the websocket address.
+func (c *MainController) serveMQTT() {
+	c.serveMetrics()
+
+	// the nodes sign the messages by their tokens, since anyone permitted
+	// by the broker could publish to the topics of the nodes
+	var verifier mqtt.Verifier
+	if authenticator, ok := c.newAuthenticator().(mqtt.Verifier); ok {
+		verifier = authenticator
+	}
+	server := mqtt.NewServer(c.Config.MQTT, time.Duration(c.Config.WebSocket.WriteTimeout)*time.Second, verifier)
+	if err := server.ListenAndServe(); err != nil {
+		klog.Fatalf("failed to serve mqtt: %v", err)
+		os.Exit(1)
+	}
+}
+
+// serveGRPC serves the grpc streams opened by LCs,
+// and serves the metrics at the websocket address.
+func (c *MainController) serveGRPC() {
+	c.serveMetrics()
+
+	addr := fmt.Sprintf("%s:%d", c.Config.GRPC.Address, c.Config.GRPC.Port)
+	server, err := grpc.NewServer(addr, c.Config.WebSocket, c.newAuthenticator())
+	if err != nil {
+		klog.Fatalf("failed to create grpc server: %v", err)
+		os.Exit(1)
+	}
+	if err = server.ListenAndServe(); err != nil {
+		klog.Fatalf("failed to serve grpc at %s: %v", addr, err)
+		os.Exit(1)
+	}
+}
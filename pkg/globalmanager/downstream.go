@@ -12,6 +12,7 @@ import (
 
 	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
 	clientset "github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned/typed/neptune/v1alpha1"
+	neptunev1listers "github.com/edgeai-neptune/neptune/pkg/client/listers/neptune/v1alpha1"
 	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
 	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer"
 	"github.com/edgeai-neptune/neptune/pkg/globalmanager/utils"
@@ -26,6 +27,12 @@ type DownstreamController struct {
 
 	client       *clientset.NeptuneV1alpha1Client
 	messageLayer messagelayer.MessageLayer
+
+	// the listers finding the nodes the models bound to, which share the
+	// caches of the watches
+	serviceLister   neptunev1listers.JointInferenceServiceLister
+	jobLister       neptunev1listers.FederatedLearningJobLister
+	informersSynced []cache.InformerSynced
 }
 
 // syncDataset syncs the dataset resources
@@ -50,6 +57,19 @@ func (dc *DownstreamController) syncJointInferenceService(eventType watch.EventT
 	return dc.messageLayer.SendResourceObject(nodeName, eventType, joint)
 }
 
+// syncModel syncs the model resources to the nodes using them
+func (dc *DownstreamController) syncModel(eventType watch.EventType, model *neptunev1.Model) error {
+	nodes, err := getModelBoundNodes(dc.serviceLister, dc.jobLister, model.Namespace, model.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, nodeName := range nodes {
+		dc.messageLayer.SendResourceObject(nodeName, eventType, model)
+	}
+	return nil
+}
+
 // syncFederatedLearningJob syncs the federated resources
 func (dc *DownstreamController) syncFederatedLearningJob(eventType watch.EventType, job *neptunev1.FederatedLearningJob) error {
 	// broadcast to all nodes specified in spec
@@ -111,6 +131,15 @@ func (dc *DownstreamController) sync(stopCh <-chan struct{}) {
 				name = t.Name
 				err = dc.syncFederatedLearningJob(e.Type, t)
 
+			case (*neptunev1.Model):
+				if len(t.Kind) == 0 {
+					t.Kind = "Model"
+				}
+				kind = t.Kind
+				namespace = t.Namespace
+				name = t.Name
+				err = dc.syncModel(e.Type, t)
+
 			default:
 				klog.Warningf("object type: %T unsupported", e)
 				continue
@@ -153,16 +182,22 @@ func (dc *DownstreamController) watch(stopCh <-chan struct{}) {
 	resyncPeriod := time.Second * 60
 	namespace := dc.cfg.Namespace
 
-	for resourceName, object := range map[string]runtime.Object{
-		"datasets":               &neptunev1.Dataset{},
-		"jointinferenceservices": &neptunev1.JointInferenceService{},
-		"federatedlearningjobs":  &neptunev1.FederatedLearningJob{},
-	} {
+	newInformer := func(resourceName string, object runtime.Object) cache.SharedIndexInformer {
 		lw := cache.NewListWatchFromClient(client, resourceName, namespace, fields.Everything())
-		si := cache.NewSharedInformer(lw, object, resyncPeriod)
+		si := cache.NewSharedIndexInformer(lw, object, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 		si.AddEventHandler(rh)
 		go si.Run(stopCh)
+		return si
 	}
+
+	newInformer("datasets", &neptunev1.Dataset{})
+	newInformer("models", &neptunev1.Model{})
+	serviceInformer := newInformer("jointinferenceservices", &neptunev1.JointInferenceService{})
+	jobInformer := newInformer("federatedlearningjobs", &neptunev1.FederatedLearningJob{})
+
+	dc.serviceLister = neptunev1listers.NewJointInferenceServiceLister(serviceInformer.GetIndexer())
+	dc.jobLister = neptunev1listers.NewFederatedLearningJobLister(jobInformer.GetIndexer())
+	dc.informersSynced = []cache.InformerSynced{serviceInformer.HasSynced, jobInformer.HasSynced}
 }
 
 // Start starts the controller
@@ -172,8 +207,13 @@ func (dc *DownstreamController) Start() error {
 	// watch is an asynchronous call
 	dc.watch(stopCh)
 
-	// sync is a synchronous call
-	go dc.sync(stopCh)
+	// the models are synced to the nodes found by the listers
+	go func() {
+		if !cache.WaitForNamedCacheSync("downstream", stopCh, dc.informersSynced...) {
+			return
+		}
+		dc.sync(stopCh)
+	}()
 
 	return nil
 }
@@ -13,7 +13,6 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	utilrand "k8s.io/apimachinery/pkg/util/rand"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubeinformers "k8s.io/client-go/informers"
@@ -66,6 +65,11 @@ type FederatedController struct {
 	// A store of pods, populated by the podController
 	podStore corelisters.PodLister
 
+	// nodeStoreSynced returns true if the node store has been synced at least once.
+	nodeStoreSynced cache.InformerSynced
+	// A store of nodes, which the base images and the ips of the workers are selected by
+	nodeLister corelisters.NodeLister
+
 	// FLJobs that need to be updated
 	queue workqueue.RateLimitingInterface
 
@@ -85,7 +89,7 @@ func (fc *FederatedController) Start() error {
 		klog.Infof("Starting federatedlearning job controller")
 		defer klog.Infof("Shutting down federatedlearning job controller")
 
-		if !cache.WaitForNamedCacheSync("federatedlearning job", stopCh, fc.podStoreSynced, fc.jobStoreSynced) {
+		if !cache.WaitForNamedCacheSync("federatedlearning job", stopCh, fc.podStoreSynced, fc.nodeStoreSynced, fc.jobStoreSynced) {
 			klog.Errorf("failed to wait for caches to sync")
 
 			return
@@ -292,6 +296,16 @@ func (fc *FederatedController) syncFLJob(key string) (bool, error) {
 		if len(pods) == 0 {
 			active, manageJobErr = fc.createPod(&flJob)
 		}
+		if isModelNotVerified(manageJobErr) {
+			// no pod is created until the model verified, which is waited without changing the job
+			fc.recorder.Event(&flJob, v1.EventTypeWarning, "ModelNotVerified", manageJobErr.Error())
+			return false, manageJobErr
+		}
+		if isNodeNotFound(manageJobErr) {
+			// no pod is created until the nodes of the workers found, which is waited without changing the job
+			fc.recorder.Event(&flJob, v1.EventTypeWarning, "NodeNotFound", manageJobErr.Error())
+			return false, manageJobErr
+		}
 		complete := false
 		if succeeded > 0 && active == 0 {
 			complete = true
@@ -388,21 +402,110 @@ func IsFLJobFinished(j *neptunev1.FederatedLearningJob) bool {
 	return false
 }
 
+// flAggPort is the port the aggregation worker binds
+const flAggPort int32 = 7363
+
+// flJobRefs is the objects referenced by the workers of a federated learning job
+type flJobRefs struct {
+	model    *neptunev1.Model
+	aggImage *BaseImage
+	aggIP    string
+	// the datasets, the model caches and the base images of the training workers in order
+	datasets    []*neptunev1.Dataset
+	modelCaches []string
+	trainImages []*BaseImage
+}
+
+// resolveFLJobRefs looks up the objects referenced by the workers of the job,
+// so that no worker is created unless all of them could be
+func resolveFLJobRefs(refs workerRefs, cfg *config.ControllerConfig, job *neptunev1.FederatedLearningJob) (*flJobRefs, error) {
+	var err error
+	r := &flJobRefs{}
+	aggWorker := job.Spec.AggregationWorker
+	modelName := aggWorker.Model.Name
+	if r.model, err = refs.getModel(job.Namespace, modelName); err != nil {
+		return nil, fmt.Errorf("failed to get model %s: %w",
+			modelName, err)
+	}
+	if r.aggImage, err = selectWorkerBaseImage(refs, cfg, aggWorker.NodeName, aggWorker.WorkerSpec.CommonWorkerSpec); err != nil {
+		return nil, fmt.Errorf("%s pod occurs error: %w", FLJobStageAgg, err)
+	}
+	if r.aggIP, err = getWorkerNodeIP(refs, aggWorker.NodeName); err != nil {
+		return nil, err
+	}
+
+	// the training workers are only created after the model verified on their nodes
+	for _, trainingWorker := range job.Spec.TrainingWorkers {
+		datasetName := trainingWorker.Dataset.Name
+		dataset, err := refs.getDataset(job.Namespace, datasetName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dataset %s: %w",
+				datasetName, err)
+		}
+		modelCache, err := resolveModelCache(r.model, trainingWorker.NodeName)
+		if err != nil {
+			return nil, err
+		}
+		baseImage, err := selectWorkerBaseImage(refs, cfg, trainingWorker.NodeName, trainingWorker.WorkerSpec.CommonWorkerSpec)
+		if err != nil {
+			return nil, fmt.Errorf("%s pod occurs error: %w", FLJobStageTrain, err)
+		}
+		r.datasets = append(r.datasets, dataset)
+		r.modelCaches = append(r.modelCaches, modelCache)
+		r.trainImages = append(r.trainImages, baseImage)
+	}
+	return r, nil
+}
+
 func (fc *FederatedController) createPod(job *neptunev1.FederatedLearningJob) (active int32, err error) {
 	active = 0
-	ctx := context.Background()
+	r, err := resolveFLJobRefs(&clusterRefs{client: fc.client, nodeLister: fc.nodeLister}, fc.cfg, job)
+	if err != nil {
+		klog.Warningf("failed to resolve the workers of federatedlearning job %v/%v: %v", job.Namespace, job.Name, err)
+		return active, err
+	}
+
+	// the same lc config for all the workers even if reloaded meanwhile
+	lc := fc.cfg.GetLC()
+
+	// create aggpod based on configured parameters
+	if err = fc.createWorker(job, FLJobStageAgg, newFLAggWorker(job, r), lc); err != nil {
+		return active, err
+	}
+	active++
 
-	modelName := job.Spec.AggregationWorker.Model.Name
-	model, err := fc.client.Models(job.Namespace).Get(ctx, modelName, metav1.GetOptions{})
+	aggServicePort, err := CreateKubernetesService(fc.kubeClient, job, flAggPort, r.aggIP)
 	if err != nil {
-		return active, fmt.Errorf("failed to get model %s: %w",
-			modelName, err)
+		return active, err
+	}
+
+	// deliver pod for training worker
+	for i := range job.Spec.TrainingWorkers {
+		if err = fc.createWorker(job, FLJobStageTrain, newFLTrainWorker(job, r, i, aggServicePort, lc), lc); err != nil {
+			return active, err
+		}
+		active++
+	}
+	return
+}
+
+func (fc *FederatedController) createWorker(job *neptunev1.FederatedLearningJob, podtype FLJobStage, w *workerPod, lc config.LCConfig) error {
+	pod, err := createWorkerPod(fc.kubeClient, lc, w)
+	if err != nil {
+		klog.Warningf("failed to create %s pod for federatedlearning job %v/%v, err:%s", string(podtype), job.Namespace, job.Name, err)
+		return err
 	}
-	modelPath := model.Spec.ModelURL
+	klog.V(2).Infof("%s pod %s is created successfully for federatedlearning job %v/%v", string(podtype), pod.Name, job.Namespace, job.Name)
+	return nil
+}
+
+// newFLAggWorker builds the aggregation worker of the job
+func newFLAggWorker(job *neptunev1.FederatedLearningJob, r *flJobRefs) *workerPod {
+	modelPath := r.model.Spec.ModelURL
 	participantsCount := strconv.Itoa(len(job.Spec.TrainingWorkers))
 
 	// convert crd to json, and put them into env of container
-	modeljson, _ := json.Marshal(model)
+	modeljson, _ := json.Marshal(r.model)
 	modelstring := string(modeljson)
 
 	// deliver pod for aggregation worker
@@ -419,128 +522,110 @@ func (fc *FederatedController) createPod(job *neptunev1.FederatedLearningJob) (a
 	aggModelURL := aggModelConPath
 
 	// Configure container mounting and Env information by initial ContainerPara
-	var aggPort int32 = 7363
 	var aggContainer *ContainerPara = new(ContainerPara)
 	aggContainer.volumeMountList = []string{aggCodeConPath, aggModelConPath}
 	aggContainer.volumeList = []string{aggCodePath, modelPath}
 	aggContainer.volumeMapName = []string{"code", "model"}
 	aggContainer.env = map[string]string{
 		"MODEL":              modelstring,
-		"WORKER_NAME":        "aggworker-" + utilrand.String(5),
+		"WORKER_NAME":        job.Name + "-aggworker",
 		"JOB_NAME":           job.Name,
 		"PARTICIPANTS_COUNT": participantsCount,
 		"PARAMETERS":         parameterString,
 		"MODEL_URL":          aggModelURL,
 		"NAMESPACE":          job.Namespace,
-		"AGG_BIND_PORT":      strconv.Itoa(int(aggPort)),
+		"AGG_BIND_PORT":      strconv.Itoa(int(flAggPort)),
 	}
 	aggContainer.scriptBootFile = aggWorker.WorkerSpec.ScriptBootFile
 	aggContainer.nodeName = aggWorker.NodeName
 	aggContainer.frameName = aggWorker.WorkerSpec.FrameworkType
 	aggContainer.frameVersion = aggWorker.WorkerSpec.FrameworkVersion
 
-	// create aggpod based on configured parameters
-	fc.generatedPod(job, FLJobStageAgg, aggContainer, &active, false)
-	var appIP string
-	var aggServicePort int32
+	return newWorkerPod(newFLWorkerPod(job, FLJobStageAgg, aggContainer, r.aggImage, false), aggContainer)
+}
 
-	appIP, err = GetNodeIPByName(fc.kubeClient, job.Spec.AggregationWorker.NodeName)
-	aggServicePort, err = CreateKubernetesService(fc.kubeClient, job, aggPort, appIP)
-	if err != nil {
-		return active, err
-	}
-	// deliver pod for training worker
-	for _, trainingWorker := range job.Spec.TrainingWorkers {
-		// get dataseturl through parsing crd of dataset
-		parameterJSON, _ = json.Marshal(trainingWorker.WorkerSpec.Parameters)
-		parameterString = string(parameterJSON)
-		datasetName := trainingWorker.Dataset.Name
-		dataset, err := fc.client.Datasets(job.Namespace).Get(ctx, datasetName, metav1.GetOptions{})
-		datasetjson, _ := json.Marshal(dataset)
-		datasetstring := string(datasetjson)
-		var trainDatasetPath string
-		if err != nil {
-			return active, fmt.Errorf("failed to get dataset %s: %w",
-				datasetName, err)
-		}
-		trainDatasetPath = dataset.Spec.URL
-		datasetParent := filepath.Dir(trainDatasetPath)
-		trainCodePath := trainingWorker.WorkerSpec.ScriptDir
-
-		// Container VolumeMounts parameters
-		trainCodeConPath := codePrefix
-		trainDataConPath := dataPrefix + datasetParent
-		trainModelConPath := dataPrefix + modelPath
-
-		// Env parameters for train
-		trainDatasetURL := dataPrefix + trainDatasetPath
-		trainModelURL := trainModelConPath
-
-		// Configure container mounting and Env information by initial ContainerPara
-		var trainContainer *ContainerPara = new(ContainerPara)
-		trainContainer.volumeMountList = []string{trainCodeConPath, trainDataConPath, trainModelConPath}
-		trainContainer.volumeList = []string{trainCodePath, datasetParent, modelPath}
-		trainContainer.volumeMapName = []string{"code", "data", "model"}
-		trainContainer.env = map[string]string{
-			"DATASET":            datasetstring,
-			"AGG_PORT":           strconv.Itoa(int(aggServicePort)),
-			"AGG_IP":             appIP,
-			"MODEL_URL":          trainModelURL,
-			"TRAIN_DATASET_URL":  trainDatasetURL,
-			"WORKER_NAME":        "trainworker-" + utilrand.String(5),
-			"JOB_NAME":           job.Name,
-			"PARAMETERS":         parameterString,
-			"PARTICIPANTS_COUNT": participantsCount,
-			"NAMESPACE":          job.Namespace,
-			"MODEL_NAME":         modelName,
-			"DATASET_NAME":       datasetName,
-			"LC_SERVER":          fc.cfg.LC.Server,
-		}
-		trainContainer.scriptBootFile = trainingWorker.WorkerSpec.ScriptBootFile
-		trainContainer.nodeName = trainingWorker.NodeName
-		trainContainer.frameName = trainingWorker.WorkerSpec.FrameworkType
-		trainContainer.frameVersion = trainingWorker.WorkerSpec.FrameworkVersion
+// newFLTrainWorker builds the i-th training worker of the job, accessing the aggregation worker by the service port
+func newFLTrainWorker(job *neptunev1.FederatedLearningJob, r *flJobRefs, i int, aggServicePort int32, lc config.LCConfig) *workerPod {
+	trainingWorker := job.Spec.TrainingWorkers[i]
+	modelPath := r.model.Spec.ModelURL
+	participantsCount := strconv.Itoa(len(job.Spec.TrainingWorkers))
 
-		// create trainpod based on configured parameters
-		err = fc.generatedPod(job, FLJobStageTrain, trainContainer, &active, true)
-		if err != nil {
-			return active, err
-		}
-	}
-	return
+	// get dataseturl through parsing crd of dataset
+	parameterJSON, _ := json.Marshal(trainingWorker.WorkerSpec.Parameters)
+	parameterString := string(parameterJSON)
+	dataset := r.datasets[i]
+	datasetjson, _ := json.Marshal(dataset)
+	datasetstring := string(datasetjson)
+	trainDatasetPath := dataset.Spec.URL
+	datasetParent := filepath.Dir(trainDatasetPath)
+	trainCodePath := trainingWorker.WorkerSpec.ScriptDir
+
+	// Container VolumeMounts parameters
+	trainCodeConPath := codePrefix
+	trainDataConPath := dataPrefix + datasetParent
+	trainModelConPath := dataPrefix + modelPath
+
+	// Env parameters for train
+	trainDatasetURL := dataPrefix + trainDatasetPath
+	trainModelURL := trainModelConPath
+
+	// Configure container mounting and Env information by initial ContainerPara
+	var trainContainer *ContainerPara = new(ContainerPara)
+	trainContainer.volumeMountList = []string{trainCodeConPath, trainDataConPath, trainModelConPath}
+	trainContainer.volumeList = []string{trainCodePath, datasetParent, modelPath}
+	trainContainer.volumeMapName = []string{"code", "data", "model"}
+	trainContainer.env = map[string]string{
+		"DATASET":            datasetstring,
+		"AGG_PORT":           strconv.Itoa(int(aggServicePort)),
+		"AGG_IP":             r.aggIP,
+		"MODEL_URL":          trainModelURL,
+		"MODEL_SAVE_URL":     trainModelURL,
+		"TRAIN_DATASET_URL":  trainDatasetURL,
+		"WORKER_NAME":        job.Name + "-trainworker-" + strconv.Itoa(i),
+		"JOB_NAME":           job.Name,
+		"PARAMETERS":         parameterString,
+		"PARTICIPANTS_COUNT": participantsCount,
+		"NAMESPACE":          job.Namespace,
+		"MODEL_NAME":         r.model.Name,
+		"DATASET_NAME":       dataset.Name,
+		"LC_SERVER":          lc.Server,
+	}
+	if modelCache := r.modelCaches[i]; modelCache != "" {
+		// load the model verified in the cache, and save the model trained to its url
+		trainContainer.modelCachePath = modelCache
+		trainContainer.env["MODEL_URL"] = dataPrefix + modelCache
+	}
+	injectLCAccess(lc, trainContainer, job, neptunev1.SchemeGroupVersion.WithKind("FederatedLearningJob"))
+	trainContainer.scriptBootFile = trainingWorker.WorkerSpec.ScriptBootFile
+	trainContainer.nodeName = trainingWorker.NodeName
+	trainContainer.frameName = trainingWorker.WorkerSpec.FrameworkType
+	trainContainer.frameVersion = trainingWorker.WorkerSpec.FrameworkVersion
+
+	return newWorkerPod(newFLWorkerPod(job, FLJobStageTrain, trainContainer, r.trainImages[i], true), trainContainer)
 }
 
-func (fc *FederatedController) generatedPod(job *neptunev1.FederatedLearningJob, podtype FLJobStage, containerPara *ContainerPara, active *int32, hostNetwork bool) error {
-	var volumeMounts []v1.VolumeMount
-	var volumes []v1.Volume
-	var envs []v1.EnvVar
-	ctx := context.Background()
+// newFLWorkerPod builds the pod of the worker of the job, whose name is generated when created
+func newFLWorkerPod(job *neptunev1.FederatedLearningJob, podtype FLJobStage, containerPara *ContainerPara,
+	baseImage *BaseImage, hostNetwork bool) *v1.Pod {
 	command := []string{"python"}
-	// get baseImgURL from imageHub based on user's configuration in job CRD
-	baseImgURL, err := MatchContainerBaseImage(fc.cfg.ImageHub, containerPara.frameName, containerPara.frameVersion)
-	// TODO: if matched image is empty, the pod creation process will not proceed, return error directly.
-	if err != nil {
-		klog.Warningf("federatedlearning job %v/%v %v worker matching container base image occurs error:%v", job.Namespace, job.Name, podtype, err)
-		return fmt.Errorf("%s pod occurs error: %w",
-			podtype, err)
-	}
-	volumeMounts, volumes = CreateVolumeMap(containerPara)
-	envs = CreateEnvVars(containerPara.env)
-	podSpec := &v1.Pod{
+	volumeMounts, volumes := CreateVolumeMap(containerPara)
+	envs := createWorkerEnvVars(containerPara)
+	return &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace:    job.Namespace,
 			GenerateName: job.Name + "-" + strings.ToLower(string(podtype)) + "-",
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(job, neptunev1.SchemeGroupVersion.WithKind("FederatedLearningJob")),
 			},
-			Labels: GenerateLabels(job),
+			Labels:      GenerateLabels(job),
+			Annotations: baseImage.Annotations(),
 		},
 		Spec: v1.PodSpec{
 			RestartPolicy: v1.RestartPolicyNever,
 			NodeName:      containerPara.nodeName,
 			Containers: []v1.Container{
-				{Name: "container-" + job.Name + "-" + strings.ToLower(string(podtype)) + "-" + utilrand.String(5),
-					Image:        baseImgURL,
+				{Name: "container-" + job.Name + "-" + strings.ToLower(string(podtype)),
+					Image:        baseImage.Image,
 					Command:      command,
 					Args:         []string{containerPara.scriptBootFile},
 					Env:          envs,
@@ -550,14 +635,6 @@ func (fc *FederatedController) generatedPod(job *neptunev1.FederatedLearningJob,
 			HostNetwork: hostNetwork,
 		},
 	}
-	pod, err := fc.kubeClient.CoreV1().Pods(job.Namespace).Create(ctx, podSpec, metav1.CreateOptions{})
-	if err != nil {
-		klog.Warningf("failed to create %s pod %s for federatedlearning job %v/%v, err:%s", string(podtype), pod.Name, job.Namespace, job.Name, err)
-		return err
-	}
-	klog.V(2).Infof("%s pod %s is created successfully for federatedlearning job %v/%v", string(podtype), pod.Name, job.Namespace, job.Name)
-	*active++
-	return nil
 }
 
 func (fc *FederatedController) GetName() string {
@@ -577,6 +654,7 @@ func NewFederatedController(cfg *config.ControllerConfig) (FeatureControllerI, e
 	kubeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, time.Second*30, kubeinformers.WithNamespace(namespace))
 
 	podInformer := kubeInformerFactory.Core().V1().Pods()
+	nodeInformer := kubeInformerFactory.Core().V1().Nodes()
 
 	jobInformerFactory := informers.NewSharedInformerFactoryWithOptions(crdclient, time.Second*30, informers.WithNamespace(namespace))
 	jobInformer := jobInformerFactory.Neptune().V1alpha1().FederatedLearningJobs()
@@ -618,6 +696,8 @@ func NewFederatedController(cfg *config.ControllerConfig) (FeatureControllerI, e
 	})
 	fc.podStore = podInformer.Lister()
 	fc.podStoreSynced = podInformer.Informer().HasSynced
+	fc.nodeLister = nodeInformer.Lister()
+	fc.nodeStoreSynced = nodeInformer.Informer().HasSynced
 
 	stopCh := make(chan struct{})
 	kubeInformerFactory.Start(stopCh)
@@ -0,0 +1,101 @@
+package globalmanager
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// BaseImageKeyAnnotation is the annotation of the worker pod,
+	// whose value is the imageHub key its base image selected by
+	BaseImageKeyAnnotation = "neptune.io/base-image-key"
+	// NodeArchAnnotation is the annotation of the worker pod,
+	// whose value is the architecture of the node its base image selected for
+	NodeArchAnnotation = "neptune.io/node-arch"
+	// ImageVariantAnnotation is the annotation of the worker pod,
+	// whose value is the image variant of the node its base image selected for
+	ImageVariantAnnotation = "neptune.io/image-variant"
+)
+
+// BaseImage is the base image of a worker selected from imageHub
+type BaseImage struct {
+	// Image is the url of the image
+	Image string
+	// Key is the imageHub key matched
+	Key string
+	// Arch and Variant are the architecture and the image variant of the node,
+	// empty if unknown
+	Arch    string
+	Variant string
+}
+
+// Annotations returns the annotations recording the selection
+func (b *BaseImage) Annotations() map[string]string {
+	annotations := map[string]string{
+		BaseImageKeyAnnotation: b.Key,
+	}
+	if b.Arch != "" {
+		annotations[NodeArchAnnotation] = b.Arch
+	}
+	if b.Variant != "" {
+		annotations[ImageVariantAnnotation] = b.Variant
+	}
+	return annotations
+}
+
+// ImageHubKeys returns the imageHub keys of the framework searched in order,
+// from the most specific to the least.
+func ImageHubKeys(frameName, frameVersion, arch, variant string) []string {
+	key := frameName + ":" + frameVersion
+	var keys []string
+	if arch != "" {
+		if variant != "" {
+			keys = append(keys, key+":"+arch+":"+variant)
+		}
+		keys = append(keys, key+":"+arch)
+	}
+	return append(keys, key)
+}
+
+// MatchContainerBaseImage searches the base image of the framework for the architecture
+// and the image variant, and returns the key matched and the image.
+// The image keyed by framework:version is shared by all the architectures, e.g. a multi-arch image.
+func MatchContainerBaseImage(imageHub map[string]string, frameName, frameVersion, arch, variant string) (string, string, error) {
+	keys := ImageHubKeys(frameName, frameVersion, arch, variant)
+	for _, key := range keys {
+		if imageURL, ok := imageHub[key]; ok {
+			return key, imageURL, nil
+		}
+	}
+	return "", "", fmt.Errorf("image %v not exists in imagehub", strings.Join(keys, ", "))
+}
+
+// SelectBaseImage selects the base image of the framework for the node, by the
+// architecture label of the node and the image variant by variantLabel of the node.
+// Only the images shared by all the architectures are searched if the node is unknown, i.e. nil.
+func SelectBaseImage(imageHub map[string]string, variantLabel string, node *v1.Node,
+	frameName, frameVersion string) (*BaseImage, error) {
+	var arch, variant, nodeName string
+	if node != nil {
+		nodeName = node.Name
+		arch = node.Labels[v1.LabelArchStable]
+		if variantLabel != "" {
+			variant = node.Labels[variantLabel]
+		}
+	}
+
+	key, image, err := MatchContainerBaseImage(imageHub, frameName, frameVersion, arch, variant)
+	if err != nil {
+		return nil, err
+	}
+	klog.V(4).Infof("selected base image %s by key %s for node %s", image, key, nodeName)
+	return &BaseImage{
+		Image:   image,
+		Key:     key,
+		Arch:    arch,
+		Variant: variant,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package globalmanager
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+)
+
+const testImageVariantLabel = "neptune.io/image-variant"
+
+func newTestNode(name string, labels map[string]string) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestSelectBaseImage(t *testing.T) {
+	imageHub := map[string]string{
+		"tensorflow:1.15":              "tensorflow:1.15",
+		"tensorflow:1.15:arm64":        "tensorflow:1.15-arm64",
+		"tensorflow:1.15:arm64:jetson": "tensorflow:1.15-jetson",
+	}
+
+	cases := []struct {
+		name      string
+		node      *v1.Node
+		frame     string
+		baseImage *BaseImage
+	}{
+		{"arch and variant", newTestNode("edge1", map[string]string{v1.LabelArchStable: "arm64", testImageVariantLabel: "jetson"}),
+			"tensorflow", &BaseImage{Image: "tensorflow:1.15-jetson", Key: "tensorflow:1.15:arm64:jetson", Arch: "arm64", Variant: "jetson"}},
+		{"arch only", newTestNode("edge1", map[string]string{v1.LabelArchStable: "arm64"}),
+			"tensorflow", &BaseImage{Image: "tensorflow:1.15-arm64", Key: "tensorflow:1.15:arm64", Arch: "arm64"}},
+		{"variant of no image", newTestNode("edge1", map[string]string{v1.LabelArchStable: "arm64", testImageVariantLabel: "atlas"}),
+			"tensorflow", &BaseImage{Image: "tensorflow:1.15-arm64", Key: "tensorflow:1.15:arm64", Arch: "arm64", Variant: "atlas"}},
+		{"shared by the architectures", newTestNode("cloud", map[string]string{v1.LabelArchStable: "amd64"}),
+			"tensorflow", &BaseImage{Image: "tensorflow:1.15", Key: "tensorflow:1.15", Arch: "amd64"}},
+		{"unknown node", nil, "tensorflow", &BaseImage{Image: "tensorflow:1.15", Key: "tensorflow:1.15"}},
+		{"no image of the framework", newTestNode("edge1", nil), "pytorch", nil},
+	}
+	for _, c := range cases {
+		baseImage, err := SelectBaseImage(imageHub, testImageVariantLabel, c.node, c.frame, "1.15")
+		if c.baseImage == nil {
+			if err == nil {
+				t.Errorf("%s: expected no image selected, got %+v", c.name, baseImage)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(baseImage, c.baseImage) {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.baseImage, baseImage)
+		}
+	}
+}
+
+func TestSelectWorkerBaseImage(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(newTestNode("edge1", map[string]string{v1.LabelArchStable: "arm64"})); err != nil {
+		t.Fatal(err)
+	}
+	refs := &clusterRefs{nodeLister: corelisters.NewNodeLister(indexer)}
+	cfg := &config.ControllerConfig{ImageHub: map[string]string{
+		"tensorflow:1.15":       "tensorflow:1.15",
+		"tensorflow:1.15:arm64": "tensorflow:1.15-arm64",
+	}}
+	workerSpec := neptunev1.CommonWorkerSpec{FrameworkType: "tensorflow", FrameworkVersion: "1.15"}
+
+	baseImage, err := selectWorkerBaseImage(refs, cfg, "edge1", workerSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baseImage.Image != "tensorflow:1.15-arm64" {
+		t.Errorf("expected the image of the node architecture, got %s", baseImage.Image)
+	}
+
+	// never falls back to the image of another architecture, until the node found
+	if baseImage, err = selectWorkerBaseImage(refs, cfg, "edge2", workerSpec); !isNodeNotFound(err) {
+		t.Errorf("expected NodeNotFoundError, got %+v, %v", baseImage, err)
+	}
+}
@@ -12,7 +12,6 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	utilrand "k8s.io/apimachinery/pkg/util/rand"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubeinformers "k8s.io/client-go/informers"
@@ -57,6 +56,11 @@ type JointInferenceServiceController struct {
 	// A store of pods
 	podStore corelisters.PodLister
 
+	// nodeStoreSynced returns true if the node store has been synced at least once.
+	nodeStoreSynced cache.InformerSynced
+	// A store of nodes, which the base images and the ips of the workers are selected by
+	nodeLister corelisters.NodeLister
+
 	// serviceStoreSynced returns true if the jointinferenceservice store has been synced at least once.
 	serviceStoreSynced cache.InformerSynced
 	// A store of service
@@ -81,7 +85,7 @@ func (jc *JointInferenceServiceController) Start() error {
 		klog.Infof("Starting joint inference service controller")
 		defer klog.Infof("Shutting down joint inference service controller")
 
-		if !cache.WaitForNamedCacheSync("jointinferenceservice", stopCh, jc.podStoreSynced, jc.serviceStoreSynced) {
+		if !cache.WaitForNamedCacheSync("jointinferenceservice", stopCh, jc.podStoreSynced, jc.nodeStoreSynced, jc.serviceStoreSynced) {
 			klog.Errorf("failed to wait for joint inferce service caches to sync")
 
 			return
@@ -306,6 +310,16 @@ func (jc *JointInferenceServiceController) sync(key string) (bool, error) {
 		if len(pods) == 0 {
 			active, manageServiceErr = jc.createPod(&jointinferenceservice)
 		}
+		if isModelNotVerified(manageServiceErr) {
+			// no pod is created until the model verified, which is waited without failing the service
+			jc.recorder.Event(&jointinferenceservice, v1.EventTypeWarning, "ModelNotVerified", manageServiceErr.Error())
+			return false, manageServiceErr
+		}
+		if isNodeNotFound(manageServiceErr) {
+			// no pod is created until the nodes of the workers found, which is waited without failing the service
+			jc.recorder.Event(&jointinferenceservice, v1.EventTypeWarning, "NodeNotFound", manageServiceErr.Error())
+			return false, manageServiceErr
+		}
 		if manageServiceErr != nil {
 			serviceFailed = true
 			message = error.Error(manageServiceErr)
@@ -381,26 +395,76 @@ func isJointinferenceserviceFinished(j *neptunev1.JointInferenceService) bool {
 	return false
 }
 
+// jointInferenceRefs is the objects referenced by the workers of a joint inference service
+type jointInferenceRefs struct {
+	cloudModel *neptunev1.Model
+	cloudImage *BaseImage
+	cloudIP    string
+	edgeModel  *neptunev1.Model
+	// edgeModelCache is the cache path of the edge model verified on the edge node, empty if not required
+	edgeModelCache string
+	edgeImage      *BaseImage
+}
+
+// resolveJointInferenceRefs looks up the objects referenced by the workers of the service,
+// so that no worker is created unless all of them could be
+func resolveJointInferenceRefs(refs workerRefs, cfg *config.ControllerConfig,
+	service *neptunev1.JointInferenceService) (*jointInferenceRefs, error) {
+	var err error
+	r := &jointInferenceRefs{}
+	cloudWorker := service.Spec.CloudWorker
+	cloudModelName := cloudWorker.Model.Name
+	if r.cloudModel, err = refs.getModel(service.Namespace, cloudModelName); err != nil {
+		return nil, fmt.Errorf("failed to get cloud model %s: %w",
+			cloudModelName, err)
+	}
+	if r.cloudImage, err = selectWorkerBaseImage(refs, cfg, cloudWorker.NodeName, cloudWorker.WorkerSpec); err != nil {
+		return nil, fmt.Errorf("%s pod occurs error: %w", jointInferenceForCloud, err)
+	}
+	if r.cloudIP, err = getWorkerNodeIP(refs, cloudWorker.NodeName); err != nil {
+		return nil, err
+	}
+
+	// the edge worker is only created after its model verified on its node
+	edgeWorker := service.Spec.EdgeWorker
+	edgeModelName := edgeWorker.Model.Name
+	if r.edgeModel, err = refs.getModel(service.Namespace, edgeModelName); err != nil {
+		return nil, fmt.Errorf("failed to get edge model %s: %w",
+			edgeModelName, err)
+	}
+	if r.edgeModelCache, err = resolveModelCache(r.edgeModel, edgeWorker.NodeName); err != nil {
+		return nil, err
+	}
+	if r.edgeImage, err = selectWorkerBaseImage(refs, cfg, edgeWorker.NodeName, edgeWorker.WorkerSpec); err != nil {
+		return nil, fmt.Errorf("%s pod occurs error: %w", jointInferenceForEdge, err)
+	}
+	return r, nil
+}
+
 func (jc *JointInferenceServiceController) createPod(service *neptunev1.JointInferenceService) (active int32, err error) {
 	active = 0
+	r, err := resolveJointInferenceRefs(&clusterRefs{client: jc.client, nodeLister: jc.nodeLister}, jc.cfg, service)
+	if err != nil {
+		klog.Warningf("failed to resolve the workers of jointinference service %v/%v: %v", service.Namespace, service.Name, err)
+		return active, err
+	}
+	lc := jc.cfg.GetLC()
 
 	// create pod for cloudPod
-	err = jc.createCloudPod(service)
+	err = jc.createWorker(service, jointInferenceForCloud, newJointInferenceCloudWorker(service, r), lc)
 	if err != nil {
 		return active, err
 	}
 	active++
 
 	// create kubernetesService for cloudPod, and get bigServicePort for edgePod
-	var bigServicePort int32
-	bigModelIP, err := GetNodeIPByName(jc.kubeClient, service.Spec.CloudWorker.NodeName)
-	bigServicePort, err = CreateKubernetesService(jc.kubeClient, service, bigModelPort, bigModelIP)
+	bigServicePort, err := CreateKubernetesService(jc.kubeClient, service, bigModelPort, r.cloudIP)
 	if err != nil {
 		return active, err
 	}
 
 	// create pod for edgePod
-	err = jc.createEdgePod(service, bigServicePort)
+	err = jc.createWorker(service, jointInferenceForEdge, newJointInferenceEdgeWorker(service, r, bigServicePort, lc), lc)
 	if err != nil {
 		return active, err
 	}
@@ -409,21 +473,24 @@ func (jc *JointInferenceServiceController) createPod(service *neptunev1.JointInf
 	return active, err
 }
 
-func (jc *JointInferenceServiceController) createCloudPod(service *neptunev1.JointInferenceService) error {
-	// deliver pod for cloudworker
-	ctx := context.Background()
-	var cloudModelPath string
-	cloudModelName := service.Spec.CloudWorker.Model.Name
-	cloudModel, err := jc.client.Models(service.Namespace).Get(ctx, cloudModelName, metav1.GetOptions{})
+func (jc *JointInferenceServiceController) createWorker(service *neptunev1.JointInferenceService, podtype jointInferenceType,
+	w *workerPod, lc config.LCConfig) error {
+	pod, err := createWorkerPod(jc.kubeClient, lc, w)
 	if err != nil {
-		return fmt.Errorf("failed to get cloud model %s: %w",
-			cloudModelName, err)
+		klog.Warningf("failed to create %s pod for jointinference service %v/%v, err:%s", string(podtype), service.Namespace, service.Name, err)
+		return err
 	}
+	klog.V(2).Infof("%s pod %s is created successfully for jointinference service %v/%v", string(podtype), pod.Name, service.Namespace, service.Name)
+	return nil
+}
 
-	cloudModelPath = cloudModel.Spec.ModelURL
+// newJointInferenceCloudWorker builds the cloud worker of the service
+func newJointInferenceCloudWorker(service *neptunev1.JointInferenceService, r *jointInferenceRefs) *workerPod {
+	// deliver pod for cloudworker
+	cloudModelPath := r.cloudModel.Spec.ModelURL
 
 	// convert crd to json, and put them into env of container
-	cloudModelJSON, _ := json.Marshal(cloudModel)
+	cloudModelJSON, _ := json.Marshal(r.cloudModel)
 	cloudModelString := string(cloudModelJSON)
 	cloudModelParent := filepath.Dir(cloudModelPath)
 
@@ -446,7 +513,7 @@ func (jc *JointInferenceServiceController) createCloudPod(service *neptunev1.Joi
 	cloudContainer.volumeMapName = []string{"code", "model"}
 	cloudContainer.env = map[string]string{
 		"MODEL":               cloudModelString,
-		"WORKER_NAME":         "cloudworker-" + utilrand.String(5),
+		"WORKER_NAME":         service.Name + "-cloudworker",
 		"SERVICE_NAME":        service.Name,
 		"PARAMETERS":          cloudParameterString,
 		"MODEL_URL":           cloudModelURL,
@@ -454,33 +521,18 @@ func (jc *JointInferenceServiceController) createCloudPod(service *neptunev1.Joi
 		"BIG_MODEL_BIND_PORT": strconv.Itoa(int(bigModelPort)),
 	}
 
-	// create cloud pod
-	err = jc.generatedPod(service, jointInferenceForCloud, cloudContainer, false)
-	if err != nil {
-		return err
-	}
-	return nil
+	return newWorkerPod(newJointInferenceWorkerPod(service, jointInferenceForCloud, cloudContainer, r.cloudImage, false), cloudContainer)
 }
 
-func (jc *JointInferenceServiceController) createEdgePod(service *neptunev1.JointInferenceService, bigServicePort int32) error {
+// newJointInferenceEdgeWorker builds the edge worker of the service, accessing the cloud worker by the service port,
+// and loading the edge model from the cache path of LC if not empty
+func newJointInferenceEdgeWorker(service *neptunev1.JointInferenceService, r *jointInferenceRefs,
+	bigServicePort int32, lc config.LCConfig) *workerPod {
 	// deliver pod for edgeworker
-	ctx := context.Background()
-	edgeModelName := service.Spec.EdgeWorker.Model.Name
-	edgeModel, err := jc.client.Models(service.Namespace).Get(ctx, edgeModelName, metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to get edge model %s: %w",
-			edgeModelName, err)
-	}
-	edgeModelPath := edgeModel.Spec.ModelURL
-
-	// get bigModelIP from nodeName in cloudWorker
-	bigModelIP, err := GetNodeIPByName(jc.kubeClient, service.Spec.CloudWorker.NodeName)
-	if err != nil {
-		return fmt.Errorf("failed to get node ip: %w", err)
-	}
+	edgeModelPath := r.edgeModel.Spec.ModelURL
 
 	// convert crd to json, and put them into env of container
-	edgeModelJSON, _ := json.Marshal(edgeModel)
+	edgeModelJSON, _ := json.Marshal(r.edgeModel)
 	edgeModelString := string(edgeModelJSON)
 	edgeModelParent := filepath.Dir(edgeModelPath)
 
@@ -505,34 +557,35 @@ func (jc *JointInferenceServiceController) createEdgePod(service *neptunev1.Join
 	edgeContainer.volumeMapName = []string{"code", "model"}
 	edgeContainer.env = map[string]string{
 		"MODEL":          edgeModelString,
-		"WORKER_NAME":    "edgeworker-" + utilrand.String(5),
+		"WORKER_NAME":    service.Name + "-edgeworker",
 		"SERVICE_NAME":   service.Name,
-		"BIG_MODEL_IP":   bigModelIP,
+		"BIG_MODEL_IP":   r.cloudIP,
 		"BIG_MODEL_PORT": strconv.Itoa(int(bigServicePort)),
 		"PARAMETERS":     edgeParameterString,
 		"HEM_PARAMETERS": HEMParameterString,
 		"MODEL_URL":      edgeModelURL,
 		"NAMESPACE":      service.Namespace,
 		"HEM_NAME":       edgeWorker.HardExampleMining.Name,
-		"LC_SERVER":      jc.cfg.LC.Server,
+		"LC_SERVER":      lc.Server,
 	}
-
-	// create edge pod
-	err = jc.generatedPod(service, jointInferenceForEdge, edgeContainer, true)
-	if err != nil {
-		return err
+	if r.edgeModelCache != "" {
+		// load the model verified in the cache instead of its url
+		edgeContainer.volumeMountList = []string{edgeCodeConPath}
+		edgeContainer.volumeList = []string{edgeCodePath}
+		edgeContainer.volumeMapName = []string{"code"}
+		edgeContainer.modelCachePath = r.edgeModelCache
+		edgeContainer.env["MODEL_URL"] = dataPrefix + r.edgeModelCache
 	}
-	return nil
+	injectLCAccess(lc, edgeContainer, service, jointServiceControllerKind)
+
+	return newWorkerPod(newJointInferenceWorkerPod(service, jointInferenceForEdge, edgeContainer, r.edgeImage, true), edgeContainer)
 }
 
-func (jc *JointInferenceServiceController) generatedPod(service *neptunev1.JointInferenceService, podtype jointInferenceType,
-	containerPara *ContainerPara, hostNetwork bool) error {
+// newJointInferenceWorkerPod builds the pod of the worker of the service, whose name is generated when created
+func newJointInferenceWorkerPod(service *neptunev1.JointInferenceService, podtype jointInferenceType,
+	containerPara *ContainerPara, baseImage *BaseImage, hostNetwork bool) *v1.Pod {
 	var workerSpec neptunev1.CommonWorkerSpec
-	var volumeMounts []v1.VolumeMount
-	var volumes []v1.Volume
-	var envs []v1.EnvVar
 	var nodeName string
-	ctx := context.Background()
 	if podtype == jointInferenceForEdge {
 		workerSpec = service.Spec.EdgeWorker.WorkerSpec
 		nodeName = service.Spec.EdgeWorker.NodeName
@@ -540,33 +593,24 @@ func (jc *JointInferenceServiceController) generatedPod(service *neptunev1.Joint
 		workerSpec = service.Spec.CloudWorker.WorkerSpec
 		nodeName = service.Spec.CloudWorker.NodeName
 	}
-	// get baseImgURL from imageHub based on user's configuration in job CRD
-	frameName := workerSpec.FrameworkType
-	frameVersion := workerSpec.FrameworkVersion
-	baseImgURL, err := MatchContainerBaseImage(jc.cfg.ImageHub, frameName, frameVersion)
-	// TODO: if matched image is empty, the pod creation process will not proceed, return error directly.
-	if err != nil {
-		klog.Warningf("jointinference service %v/%v %v worker matching container base image occurs error:%v", service.Namespace, service.Name, podtype, err)
-		return fmt.Errorf("%s pod occurs error: %w",
-			podtype, err)
-	}
-	volumeMounts, volumes = CreateVolumeMap(containerPara)
-	envs = CreateEnvVars(containerPara.env)
-	podSpec := &v1.Pod{
+	volumeMounts, volumes := CreateVolumeMap(containerPara)
+	envs := createWorkerEnvVars(containerPara)
+	return &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace:    service.Namespace,
 			GenerateName: service.Name + "-" + strings.ToLower(string(podtype)) + "-",
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(service, jointServiceControllerKind),
 			},
-			Labels: GenerateLabels(service),
+			Labels:      GenerateLabels(service),
+			Annotations: baseImage.Annotations(),
 		},
 		Spec: v1.PodSpec{
 			RestartPolicy: v1.RestartPolicyNever,
 			NodeName:      nodeName,
 			Containers: []v1.Container{
-				{Name: "container-" + service.Name + "-" + strings.ToLower(string(podtype)) + "-" + utilrand.String(5),
-					Image:        baseImgURL,
+				{Name: "container-" + service.Name + "-" + strings.ToLower(string(podtype)),
+					Image:        baseImage.Image,
 					Args:         []string{workerSpec.ScriptBootFile},
 					Env:          envs,
 					VolumeMounts: volumeMounts,
@@ -575,13 +619,6 @@ func (jc *JointInferenceServiceController) generatedPod(service *neptunev1.Joint
 			HostNetwork: hostNetwork,
 		},
 	}
-	pod, err := jc.kubeClient.CoreV1().Pods(service.Namespace).Create(ctx, podSpec, metav1.CreateOptions{})
-	if err != nil {
-		klog.Warningf("failed to create %s pod %s for jointinference service %v/%v, err:%s", string(podtype), pod.Name, service.Namespace, service.Name, err)
-		return err
-	}
-	klog.V(2).Infof("%s pod %s is created successfully for jointinference service %v/%v", string(podtype), pod.Name, service.Namespace, service.Name)
-	return nil
 }
 
 // GetName returns the name of the joint inference controller
@@ -604,6 +641,7 @@ func NewJointController(cfg *config.ControllerConfig) (FeatureControllerI, error
 	kubeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, time.Second*30, kubeinformers.WithNamespace(namespace))
 
 	podInformer := kubeInformerFactory.Core().V1().Pods()
+	nodeInformer := kubeInformerFactory.Core().V1().Nodes()
 
 	serviceInformerFactory := informers.NewSharedInformerFactoryWithOptions(crdclient, time.Second*30, informers.WithNamespace(namespace))
 	serviceInformer := serviceInformerFactory.Neptune().V1alpha1().JointInferenceServices()
@@ -645,6 +683,8 @@ func NewJointController(cfg *config.ControllerConfig) (FeatureControllerI, error
 
 	jc.podStore = podInformer.Lister()
 	jc.podStoreSynced = podInformer.Informer().HasSynced
+	jc.nodeLister = nodeInformer.Lister()
+	jc.nodeStoreSynced = nodeInformer.Informer().HasSynced
 
 	stopCh := messageContext.Done()
 	kubeInformerFactory.Start(stopCh)
@@ -16,6 +16,7 @@ import (
 // MessageLayer define all functions that message layer must implement
 type MessageLayer interface {
 	SendResourceObject(nodeName string, eventType watch.EventType, obj interface{}) error
+	SendResyncResources(nodeName string, resources []model.ResyncResource) error
 	ReceiveResourceUpdate() (*ResourceUpdateSpec, error)
 	Done() <-chan struct{}
 }
@@ -26,11 +27,15 @@ type ContextMessageLayer struct {
 
 // ResourceUpdateSpec describes the resource update from upstream
 type ResourceUpdateSpec struct {
+	// NodeName is the node sending the update
+	NodeName  string
 	Kind      string
 	Namespace string
 	Name      string
 	Operation string
 	Content   []byte
+	// MessageID is the idempotency key of the update
+	MessageID string
 }
 
 // SendResourceObject message to the node with resource object and event type
@@ -42,7 +47,7 @@ func (cml *ContextMessageLayer) SendResourceObject(nodeName string, eventType wa
 	case watch.Modified:
 		operation = "update"
 	case watch.Deleted:
-		operation = "delete"
+		operation = model.DeleteOperation
 	default:
 		// should never get here
 		return fmt.Errorf("event type: %s unsupported", eventType)
@@ -72,7 +77,24 @@ func (cml *ContextMessageLayer) SendResourceObject(nodeName string, eventType wa
 
 	klog.V(2).Infof("sending %s %s/%s to node(%s)", kind, namespace, name, nodeName)
 	klog.V(4).Infof("sending %s %s/%s to node(%s), msg:%+v", kind, namespace, name, nodeName, msg)
-	// TODO: may need to guarantee message send to node
+	// the message is retransmitted until acknowledged by the node
+	return wsContext.SendToEdge(nodeName, &msg)
+}
+
+// SendResyncResources sends the authoritative resources bound to the node
+func (cml *ContextMessageLayer) SendResyncResources(nodeName string, resources []model.ResyncResource) error {
+	payload, err := json.Marshal(resources)
+	if err != nil {
+		return err
+	}
+
+	var msg model.Message
+	msg.ResourceKind = model.NodeKind
+	msg.ResourceName = nodeName
+	msg.Operation = model.ResyncOperation
+	msg.Content = payload
+
+	klog.V(2).Infof("sending %d resync resources to node(%s)", len(resources), nodeName)
 	return wsContext.SendToEdge(nodeName, &msg)
 }
 
@@ -91,11 +113,13 @@ func (cml *ContextMessageLayer) ReceiveResourceUpdate() (*ResourceUpdateSpec, er
 	content := msg.Content
 
 	return &ResourceUpdateSpec{
+		NodeName:  nodeName,
 		Kind:      kind,
 		Namespace: namespace,
 		Name:      name,
 		Operation: operation,
 		Content:   content,
+		MessageID: msg.MessageID,
 	}, nil
 }
 
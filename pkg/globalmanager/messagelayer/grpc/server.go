@@ -0,0 +1,296 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	// register the gzip compressor, the responses are compressed the same as the requests
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+	messageContext "github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/ws"
+	"github.com/edgeai-neptune/neptune/pkg/messagelayer/pb"
+	"github.com/edgeai-neptune/neptune/pkg/util"
+)
+
+const (
+	// minClientPingInterval is the minimum interval of the keepalive pings
+	// allowed from LCs, the streams of LCs pinging faster are closed.
+	// It's half of the interval LCs ping at least, tolerating the delays of the network.
+	minClientPingInterval = pb.MinKeepaliveInterval / 2
+)
+
+// Server serves the bidirectional message streams opened by LCs, each node
+// has one stream which is registered to the message context like a
+// websocket connection.
+// The messages are flow controlled by HTTP/2: a node not reading blocks the
+// writes to it until the write timeout, and the upstream messages are not
+// received while the message context is busy.
+type Server struct {
+	address string
+	server  *grpclib.Server
+
+	// authenticator is nil when the nodes are not authenticated
+	authenticator messageContext.Authenticator
+	// verifyClient is true when the client certificates are required
+	verifyClient bool
+
+	writeTimeout time.Duration
+}
+
+// NewServer creates a grpc server, which shares the certificates, the node
+// authentication and the timeouts of the websocket config.
+func NewServer(address string, cfg config.WebSocket, authenticator messageContext.Authenticator) (*Server, error) {
+	srv := &Server{
+		address:       address,
+		authenticator: authenticator,
+		writeTimeout:  time.Duration(cfg.WriteTimeout) * time.Second,
+	}
+
+	opts := []grpclib.ServerOption{
+		grpclib.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             minClientPingInterval,
+			PermitWithoutStream: true,
+		}),
+	}
+	if cfg.PingInterval > 0 {
+		// the node is considered dead when the ping is not answered within
+		// the read timeout
+		params := keepalive.ServerParameters{
+			Time: time.Duration(cfg.PingInterval) * time.Second,
+		}
+		if cfg.ReadTimeout > 0 {
+			params.Timeout = time.Duration(cfg.ReadTimeout) * time.Second
+		}
+		opts = append(opts, grpclib.KeepaliveParams(params))
+	}
+
+	if cfg.CertFile != "" {
+		certificates, err := util.NewCertificateReloader(cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load grpc certificates: %w", err)
+		}
+		srv.verifyClient = cfg.ClientCAFile != ""
+		tlsConfig := messageContext.NewTLSConfig(certificates, srv.verifyClient, "h2")
+		opts = append(opts, grpclib.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	srv.server = grpclib.NewServer(opts...)
+	pb.RegisterMessageServiceServer(srv.server, srv)
+	return srv, nil
+}
+
+// getMetadata returns the first value of the key in the metadata
+func getMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// authenticateNode checks the bearer token and the client certificate
+// presented for the node
+func (srv *Server) authenticateNode(ctx context.Context, md metadata.MD, nodeName string) error {
+	if srv.authenticator != nil {
+		const prefix = "Bearer "
+		auth := getMetadata(md, pb.MetadataAuthorization)
+		if !strings.HasPrefix(auth, prefix) {
+			return status.Error(codes.Unauthenticated, "no bearer token")
+		}
+		if err := srv.authenticator.AuthenticateNode(nodeName, strings.TrimSpace(auth[len(prefix):])); err != nil {
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+	}
+
+	if srv.verifyClient {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return status.Error(codes.PermissionDenied, "no client certificate")
+		}
+		tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+			return status.Error(codes.PermissionDenied, "no client certificate")
+		}
+		if err := messageContext.VerifyNodeCertificate(tlsInfo.State.PeerCertificates[0], nodeName); err != nil {
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+	return nil
+}
+
+// Stream serves the message stream of a node until it's closed
+func (srv *Server) Stream(stream pb.MessageService_StreamServer) error {
+	ctx := stream.Context()
+	md, _ := metadata.FromIncomingContext(ctx)
+	nodeName := getMetadata(md, pb.MetadataNodeName)
+
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+	if nodeName == "" {
+		klog.Warningf("reject the stream from %s without node name", remoteAddr)
+		return status.Error(codes.InvalidArgument, "node name is required")
+	}
+	if err := srv.authenticateNode(ctx, md, nodeName); err != nil {
+		klog.Warningf("reject the stream from %s for node %s: %v", remoteAddr, nodeName, err)
+		return err
+	}
+	// the header echoing the node name tells LC the stream is accepted
+	if err := stream.SendHeader(metadata.Pairs(pb.MetadataNodeName, nodeName)); err != nil {
+		return err
+	}
+
+	ns := &nodeStream{
+		stream:       stream,
+		nodeName:     nodeName,
+		writeTimeout: srv.writeTimeout,
+		sends:        make(chan *sendRequest),
+	}
+	go ns.writeLoop(ctx.Done())
+	klog.Infof("established stream for node %s", nodeName)
+
+	closeCh := make(chan struct{}, 1)
+	messageContext.AddNode(nodeName, getMetadata(md, pb.MetadataNodeVersion), ns.readOneMsg, ns.writeOneMsg, closeCh)
+	<-closeCh
+
+	// returning ends the stream, which breaks the blocked reads and writes
+	klog.Infof("closed stream for node %s", nodeName)
+	return nil
+}
+
+// ListenAndServe listens and serves the server until the message context done
+func (srv *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", srv.address)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-messageContext.Done()
+		srv.server.Stop()
+	}()
+	return srv.server.Serve(lis)
+}
+
+type nodeStream struct {
+	stream   pb.MessageService_StreamServer
+	nodeName string
+
+	// writeLock serializes the writes of the messages and acks
+	writeLock sync.Mutex
+	// broken is true when a write timeout, whose send may be still pending
+	broken bool
+
+	writeTimeout time.Duration
+	// sends passes the messages to the writer goroutine of the stream
+	sends chan *sendRequest
+}
+
+// sendRequest is a message to send and the result of sending it
+type sendRequest struct {
+	msg *pb.Message
+	// errCh is buffered so that the writer never blocks on a timeout write
+	errCh chan error
+}
+
+// writeLoop sends the messages until done, i.e. the stream ends.
+// The only goroutine sending to the stream, whose send blocked by the flow
+// control is released when the stream ends.
+func (ns *nodeStream) writeLoop(done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case req := <-ns.sends:
+			req.errCh <- ns.stream.Send(req.msg)
+		}
+	}
+}
+
+func (ns *nodeStream) readOneMsg() (model.Message, error) {
+	for {
+		m, err := ns.stream.Recv()
+		if err != nil {
+			return model.Message{}, err
+		}
+
+		h := m.GetHeader()
+		if h.GetOperation() == pb.PingOperation {
+			messageContext.MarkNodeHeartbeat(ns.nodeName)
+			continue
+		}
+
+		return model.Message{
+			MessageHeader: model.MessageHeader{
+				Namespace:    h.GetNamespace(),
+				ResourceKind: h.GetResourceKind(),
+				ResourceName: h.GetResourceName(),
+				Operation:    h.GetOperation(),
+				Sequence:     h.GetSequence(),
+				MessageID:    h.GetMessageId(),
+			},
+			Content: m.GetContent(),
+		}, nil
+	}
+}
+
+// writeOneMsg sends the message, which blocks while the flow control window
+// of the node is exhausted. The stream is broken if the write timeout.
+func (ns *nodeStream) writeOneMsg(msg model.Message) error {
+	ns.writeLock.Lock()
+	defer ns.writeLock.Unlock()
+
+	if ns.broken {
+		return fmt.Errorf("stream of node %s is broken", ns.nodeName)
+	}
+
+	m := &pb.Message{
+		Header: &pb.MessageHeader{
+			Namespace:    msg.Namespace,
+			ResourceKind: msg.ResourceKind,
+			ResourceName: msg.ResourceName,
+			Operation:    msg.Operation,
+			Sequence:     msg.Sequence,
+			MessageId:    msg.MessageID,
+		},
+		Content: msg.Content,
+	}
+
+	var timeout <-chan time.Time
+	if ns.writeTimeout > 0 {
+		timer := time.NewTimer(ns.writeTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	req := &sendRequest{msg: m, errCh: make(chan error, 1)}
+	select {
+	case ns.sends <- req:
+	case <-ns.stream.Context().Done():
+		return ns.stream.Context().Err()
+	}
+
+	select {
+	case err := <-req.errCh:
+		return err
+	case <-timeout:
+		// the failed write closes the stream, the pending send is
+		// released when the stream ends
+		ns.broken = true
+		return fmt.Errorf("write to node %s timeout", ns.nodeName)
+	}
+}
@@ -0,0 +1,249 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+	messageContext "github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/ws"
+	"github.com/edgeai-neptune/neptune/pkg/messagelayer/pb"
+)
+
+// testTimeout is the time waiting for a result expected
+const testTimeout = 5 * time.Second
+
+// tokenAuthenticator authenticates the nodes by the tokens
+type tokenAuthenticator map[string]string
+
+func (a tokenAuthenticator) AuthenticateNode(nodeName, token string) error {
+	if expected, ok := a[nodeName]; !ok || expected != token {
+		return fmt.Errorf("invalid token of node %s", nodeName)
+	}
+	return nil
+}
+
+// startTestServer serves a server on a random local port, and returns the
+// connection to it
+func startTestServer(t *testing.T, authenticator messageContext.Authenticator) (*grpclib.ClientConn, func()) {
+	srv, err := NewServer("127.0.0.1:0", config.WebSocket{WriteTimeout: 5}, authenticator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", srv.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.server.Serve(lis)
+
+	conn, err := grpclib.Dial(lis.Addr().String(), grpclib.WithInsecure(), grpclib.WithBlock())
+	if err != nil {
+		srv.server.Stop()
+		t.Fatal(err)
+	}
+	return conn, func() {
+		conn.Close()
+		srv.server.Stop()
+	}
+}
+
+// openStream opens a stream with the metadata pairs, and waits for the header
+func openStream(ctx context.Context, conn *grpclib.ClientConn, kv ...string) (pb.MessageService_StreamClient, metadata.MD, error) {
+	ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(kv...))
+	stream, err := pb.NewMessageServiceClient(conn).Stream(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, err := stream.Header()
+	if err == nil && len(header) == 0 {
+		// rejected, the status is returned by the receive
+		_, err = stream.Recv()
+	}
+	return stream, header, err
+}
+
+// waitNodeConnected waits for the node connected or disconnected
+func waitNodeConnected(t *testing.T, nodeName string, connected bool) {
+	t.Helper()
+	for deadline := time.Now().Add(testTimeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for _, node := range messageContext.ListNodes() {
+			if node.Name == nodeName && node.Connected == connected {
+				return
+			}
+		}
+	}
+	t.Fatalf("expected node %s connected %v", nodeName, connected)
+}
+
+func TestStreamAuthentication(t *testing.T) {
+	conn, stop := startTestServer(t, tokenAuthenticator{"node1": "token1"})
+	defer stop()
+
+	cases := []struct {
+		name     string
+		metadata []string
+		code     codes.Code
+	}{
+		{"no node name", []string{pb.MetadataAuthorization, "Bearer token1"}, codes.InvalidArgument},
+		{"no token", []string{pb.MetadataNodeName, "node1"}, codes.Unauthenticated},
+		{"token of another node", []string{pb.MetadataNodeName, "node2", pb.MetadataAuthorization, "Bearer token1"}, codes.Unauthenticated},
+	}
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		_, _, err := openStream(ctx, conn, c.metadata...)
+		cancel()
+		if status.Code(err) != c.code {
+			t.Errorf("%s: expected code %v, got %v", c.name, c.code, err)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	const nodeName = "node-stream"
+	conn, stop := startTestServer(t, nil)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, header, err := openStream(ctx, conn, pb.MetadataNodeName, nodeName, pb.MetadataNodeVersion, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := header.Get(pb.MetadataNodeName); len(names) != 1 || names[0] != nodeName {
+		t.Errorf("expected the header echoing the node name, got %v", header)
+	}
+	waitNodeConnected(t, nodeName, true)
+
+	if err = stream.Send(&pb.Message{Header: &pb.MessageHeader{Operation: pb.PingOperation}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = stream.Send(&pb.Message{
+		Header: &pb.MessageHeader{
+			Namespace:    "default",
+			ResourceKind: "dataset",
+			ResourceName: "d1",
+			Operation:    "status",
+			Sequence:     1,
+			MessageId:    "lc-1",
+		},
+		Content: []byte("{}"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ping is consumed by the transport, only the message is received
+	received := make(chan model.Message, 1)
+	go func() {
+		_, msg, _ := messageContext.ReceiveFromEdge()
+		received <- msg
+	}()
+	select {
+	case msg := <-received:
+		if msg.MessageID != "lc-1" || msg.ResourceName != "d1" || string(msg.Content) != "{}" {
+			t.Errorf("unexpected message %+v", msg)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timeout waiting for the message received")
+	}
+
+	ack, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.GetHeader().GetOperation() != model.AckOperation || ack.GetHeader().GetMessageId() != "lc-1" {
+		t.Errorf("expected the ack of the message, got %+v", ack.GetHeader())
+	}
+
+	// canceling the stream context of LC closes the stream
+	cancel()
+	waitNodeConnected(t, nodeName, false)
+}
+
+// blockingStream is a stream whose sends block until the stream ends,
+// i.e. the flow control window of the node is exhausted
+type blockingStream struct {
+	pb.MessageService_StreamServer
+	ctx context.Context
+}
+
+func (s *blockingStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *blockingStream) Send(*pb.Message) error {
+	<-s.ctx.Done()
+	return s.ctx.Err()
+}
+
+func TestWriteTimeoutBreaksStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ns := &nodeStream{
+		stream:       &blockingStream{ctx: ctx},
+		nodeName:     "node1",
+		writeTimeout: 50 * time.Millisecond,
+		sends:        make(chan *sendRequest),
+	}
+	go ns.writeLoop(ctx.Done())
+
+	msg := model.Message{MessageHeader: model.MessageHeader{MessageID: "gm-1"}}
+	if err := ns.writeOneMsg(msg); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected the write timeout, got %v", err)
+	}
+	if !ns.broken {
+		t.Errorf("expected the stream broken after the write timeout")
+	}
+
+	// the pending send still holds the writer, the later writes fail at once
+	start := time.Now()
+	if err := ns.writeOneMsg(msg); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected the stream broken, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= ns.writeTimeout {
+		t.Errorf("expected the write to the broken stream failed at once, took %v", elapsed)
+	}
+}
+
+func TestWriteAfterStreamEnded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ns := &nodeStream{
+		stream:   &blockingStream{ctx: ctx},
+		nodeName: "node1",
+		// no timeout, the write is released by the stream ended
+		sends: make(chan *sendRequest),
+	}
+	done := make(chan struct{})
+	go func() {
+		ns.writeLoop(ctx.Done())
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("expected the writer exited after the stream ended")
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- ns.writeOneMsg(model.Message{MessageHeader: model.MessageHeader{MessageID: "gm-1"}})
+	}()
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Errorf("expected %v, got %v", context.Canceled, err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("expected the write released after the stream ended")
+	}
+}
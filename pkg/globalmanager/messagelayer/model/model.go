@@ -1,5 +1,24 @@
 package model
 
+const (
+	// AckOperation is the operation of the message acknowledging a received message
+	AckOperation = "ack"
+
+	// ResyncOperation is the operation of the message requesting/answering the
+	// full state of the resources bound to a node
+	ResyncOperation = "resync"
+
+	// WorkerHealthOperation is the operation of the message reporting the
+	// liveness of a worker of the resource
+	WorkerHealthOperation = "workerhealth"
+
+	// DeleteOperation is the operation of the message deleting a resource
+	DeleteOperation = "delete"
+
+	// NodeKind is the resource kind of the messages about the node itself
+	NodeKind = "node"
+)
+
 // MessageHeader defines the header between LC and GM
 type MessageHeader struct {
 	Namespace string `json:"namespace"`
@@ -9,6 +28,15 @@ type MessageHeader struct {
 	ResourceName string `json:"resourceName"`
 
 	Operation string `json:"operation"`
+
+	// Sequence is the per-node sequence number of the message which the
+	// receiver acknowledges with an ack message carrying the same sequence.
+	// 0 means no ack required.
+	Sequence uint64 `json:"sequence,omitempty"`
+
+	// MessageID is the idempotency key of the message, a retransmitted
+	// message keeps its MessageID.
+	MessageID string `json:"messageID,omitempty"`
 }
 
 // Message defines the message between LC and GM
@@ -16,3 +44,28 @@ type Message struct {
 	MessageHeader `json:"header"`
 	Content       []byte `json:"content"`
 }
+
+// NewAckMessage creates the message acknowledging msg
+func NewAckMessage(msg *Message) Message {
+	return Message{
+		MessageHeader: MessageHeader{
+			Operation: AckOperation,
+			Sequence:  msg.Sequence,
+			MessageID: msg.MessageID,
+		},
+	}
+}
+
+// ResyncResource describes a resource in the resync messages.
+// In the request LC lists the resources it holds, in the response GM lists
+// the authoritative resources bound to the node.
+type ResyncResource struct {
+	Kind            string `json:"kind"`
+	Namespace       string `json:"namespace"`
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+
+	// Object is the resource object in the response,
+	// omitted when LC holds the same resource version
+	Object []byte `json:"object,omitempty"`
+}
@@ -0,0 +1,453 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+	messageContext "github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/ws"
+	"github.com/edgeai-neptune/neptune/pkg/messagelayer"
+)
+
+const (
+	// DownstreamTopic is the topic suffix of the messages from GM to LC
+	DownstreamTopic = "downstream"
+	// UpstreamTopic is the topic suffix of the messages from LC to GM
+	UpstreamTopic = "upstream"
+	// PresenceTopic is the topic suffix of the retained presence of LC
+	PresenceTopic = "presence"
+
+	// upstreamChannelSize is the size of upstream messages buffered per node,
+	// including the ones received before the presence of the node
+	upstreamChannelSize = 100
+	// incomingChannelSize is the size of the messages received from the
+	// broker and not dispatched yet
+	incomingChannelSize = 1000
+)
+
+// Verifier provides the keys verifying the signatures of the messages
+// published by the nodes, which also sign the messages to the nodes
+type Verifier interface {
+	// NodeSigningKeys returns the signing keys of the node, more than one
+	// while its token is rotating
+	NodeSigningKeys(nodeName string) ([][]byte, error)
+}
+
+// Presence is published retained by LC to its presence topic when connected
+// and periodically as the heartbeat, and as its last will when disconnected.
+type Presence struct {
+	Online  bool   `json:"online"`
+	Version string `json:"version,omitempty"`
+	// SessionID distinguishes the connections of LC
+	SessionID string `json:"sessionID,omitempty"`
+}
+
+// Server exchanges the messages with LCs through a MQTT broker, each node
+// has its own downstream, upstream and presence topics.
+// The connection of a node is emulated by a session, which is registered to
+// the message context like a websocket connection.
+type Server struct {
+	cfg          config.MQTT
+	writeTimeout time.Duration
+	client       paho.Client
+	// verifier is nil when the nodes are not authenticated
+	verifier Verifier
+	// guard rejects the messages of the nodes replayed
+	guard *messagelayer.ReplayGuard
+
+	// incoming holds the messages received from the broker, which are
+	// dispatched by another goroutine than the one of the mqtt client
+	incoming chan paho.Message
+
+	lock sync.Mutex
+	// nodeName => the current session
+	sessions map[string]*session
+	// nodeName => the upstream messages received before the presence
+	pending map[string][]model.Message
+}
+
+// session is the emulated connection of a node
+type session struct {
+	id       string
+	nodeName string
+	// key signs the messages to the node, which is the one verified the
+	// presence opening the session, nil if the nodes are not authenticated
+	key []byte
+
+	// publishLock serializes the downstream messages, so that they're
+	// published in the order of their sequences
+	publishLock sync.Mutex
+	// sequence is the last sequence of the downstream messages signed
+	sequence uint64
+
+	upstream chan model.Message
+	// closed is closed when the session is closed
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewServer creates a mqtt server, which authenticates the messages of
+// the nodes by verifier if not nil
+func NewServer(cfg config.MQTT, writeTimeout time.Duration, verifier Verifier) *Server {
+	return &Server{
+		cfg:          cfg,
+		writeTimeout: writeTimeout,
+		verifier:     verifier,
+		guard:        messagelayer.NewReplayGuard(),
+		incoming:     make(chan paho.Message, incomingChannelSize),
+		sessions:     make(map[string]*session),
+		pending:      make(map[string][]model.Message),
+	}
+}
+
+func (srv *Server) topic(nodeName, suffix string) string {
+	return strings.Join([]string{srv.cfg.TopicPrefix, nodeName, suffix}, "/")
+}
+
+// parseTopic returns the node name and the topic suffix of the topic
+func (srv *Server) parseTopic(topic string) (string, string, error) {
+	parts := strings.Split(strings.TrimPrefix(topic, srv.cfg.TopicPrefix+"/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("invalid topic %s", topic)
+	}
+	return parts[0], parts[1], nil
+}
+
+// close closes the session, the read loop of the node exits then
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+}
+
+func (s *session) read() (model.Message, error) {
+	select {
+	case msg := <-s.upstream:
+		return msg, nil
+	case <-s.closed:
+		return model.Message{}, fmt.Errorf("session %s of node %s closed", s.id, s.nodeName)
+	}
+}
+
+// openSession replaces the session of the node if the session id changed,
+// and returns the current one. The messages to the node are signed by key.
+func (srv *Server) openSession(nodeName string, p Presence, key []byte) *session {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if s, ok := srv.sessions[nodeName]; ok {
+		if s.id == p.SessionID {
+			return s
+		}
+		s.close()
+	}
+
+	s := &session{
+		id:       p.SessionID,
+		nodeName: nodeName,
+		key:      key,
+		upstream: make(chan model.Message, upstreamChannelSize),
+		closed:   make(chan struct{}),
+	}
+	srv.sessions[nodeName] = s
+	// the pending messages never exceed the channel size
+	for _, msg := range srv.pending[nodeName] {
+		s.upstream <- msg
+	}
+	delete(srv.pending, nodeName)
+
+	write := func(msg model.Message) error {
+		select {
+		case <-s.closed:
+			return fmt.Errorf("session %s of node %s closed", s.id, nodeName)
+		default:
+		}
+		return srv.publish(s, &msg)
+	}
+
+	closeCh := make(chan struct{}, 1)
+	messageContext.AddNode(nodeName, p.Version, s.read, write, closeCh)
+	go func() {
+		<-closeCh
+		s.close()
+		srv.removeSession(s)
+	}()
+
+	klog.Infof("opened session %s for node %s", s.id, nodeName)
+	return s
+}
+
+// removeSession forgets the session if it's still the current one of the node
+func (srv *Server) removeSession(s *session) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	if srv.sessions[s.nodeName] == s {
+		delete(srv.sessions, s.nodeName)
+		klog.Infof("closed session %s for node %s", s.id, s.nodeName)
+	}
+}
+
+// getSession returns the current session of the node, nil if none
+func (srv *Server) getSession(nodeName string) *session {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return srv.sessions[nodeName]
+}
+
+// getSessionOrHold returns the current session of the node, or holds the
+// message until the presence of the node received if no session.
+func (srv *Server) getSessionOrHold(nodeName string, msg model.Message) *session {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	if s, ok := srv.sessions[nodeName]; ok {
+		return s
+	}
+
+	pending := srv.pending[nodeName]
+	if len(pending) >= upstreamChannelSize {
+		// LC retransmits the messages not acknowledged in time
+		klog.Warningf("drop the upstream message %s of node %s since too many messages before its presence",
+			pending[0].MessageID, nodeName)
+		pending = pending[1:]
+	}
+	srv.pending[nodeName] = append(pending, msg)
+	return nil
+}
+
+// closeAllSessions closes all sessions, e.g. the connection to the broker is lost
+func (srv *Server) closeAllSessions() {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	for _, s := range srv.sessions {
+		s.close()
+	}
+}
+
+// publish publishes the message to the downstream topic of the node of the
+// session, which is signed by the key of the session if not nil
+func (srv *Server) publish(s *session, msg *model.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	s.publishLock.Lock()
+	defer s.publishLock.Unlock()
+
+	topic := srv.topic(s.nodeName, DownstreamTopic)
+	signed := messagelayer.SignedPayload{Data: data}
+	if s.key != nil {
+		s.sequence++
+		signed.SessionID = s.id
+		signed.Sequence = s.sequence
+		signed.Timestamp = time.Now().Unix()
+		signed.Sign(s.key, topic)
+	}
+	payload, err := json.Marshal(&signed)
+	if err != nil {
+		return err
+	}
+
+	token := srv.client.Publish(topic, srv.cfg.QoS, false, payload)
+	if srv.writeTimeout > 0 {
+		if !token.WaitTimeout(srv.writeTimeout) {
+			return fmt.Errorf("publish to node %s timeout", s.nodeName)
+		}
+	} else {
+		token.Wait()
+	}
+	return token.Error()
+}
+
+// handlePresence opens or closes the session of the node
+func (srv *Server) handlePresence(nodeName, topic string, signed *messagelayer.SignedPayload, key []byte) {
+	var p Presence
+	if err := json.Unmarshal(signed.Data, &p); err != nil {
+		klog.Warningf("invalid presence of node %s: %v", nodeName, err)
+		return
+	}
+	if srv.verifier != nil && p.SessionID != signed.SessionID {
+		klog.Warningf("reject the presence of node %s signed for session %s other than %s",
+			nodeName, signed.SessionID, p.SessionID)
+		return
+	}
+
+	if p.Online {
+		if err := srv.checkReplay(nodeName, topic, signed); err != nil {
+			klog.Warningf("reject the presence of node %s: %v", nodeName, err)
+			return
+		}
+		srv.openSession(nodeName, p, key)
+		messageContext.MarkNodeHeartbeat(nodeName)
+		return
+	}
+
+	// the absence is the last will signed when LC connected, so it's not
+	// checked for replaying, but only closes the session it belongs to
+	if s := srv.getSession(nodeName); s != nil && (p.SessionID == s.id || (srv.verifier == nil && p.SessionID == "")) {
+		s.close()
+	}
+}
+
+// checkReplay rejects the payload replayed if the nodes are authenticated
+func (srv *Server) checkReplay(nodeName, topic string, signed *messagelayer.SignedPayload) error {
+	if srv.verifier == nil {
+		return nil
+	}
+	return srv.guard.Check(nodeName, topic, signed, time.Now())
+}
+
+// handleUpstream passes the message to the session of the node
+func (srv *Server) handleUpstream(nodeName, topic string, signed *messagelayer.SignedPayload) {
+	if err := srv.checkReplay(nodeName, topic, signed); err != nil {
+		klog.Warningf("reject the upstream message of node %s: %v", nodeName, err)
+		return
+	}
+
+	var msg model.Message
+	if err := json.Unmarshal(signed.Data, &msg); err != nil {
+		klog.Warningf("invalid upstream message of node %s: %v", nodeName, err)
+		return
+	}
+
+	s := srv.getSessionOrHold(nodeName, msg)
+	if s == nil {
+		// the session is opened by the presence of the node
+		return
+	}
+
+	select {
+	case s.upstream <- msg:
+	case <-s.closed:
+		klog.Warningf("drop the upstream message %s of node %s since session closed, wait for retransmitting",
+			msg.MessageID, nodeName)
+	}
+}
+
+// verify returns the signed payload published to the topic by the node and
+// the key verified it, which must be signed by the node if the nodes are
+// authenticated, otherwise the key is nil.
+func (srv *Server) verify(nodeName, topic string, payload []byte) (*messagelayer.SignedPayload, []byte, error) {
+	var signed messagelayer.SignedPayload
+	if err := json.Unmarshal(payload, &signed); err != nil {
+		return nil, nil, err
+	}
+	if srv.verifier == nil {
+		return &signed, nil, nil
+	}
+
+	keys, err := srv.verifier.NodeSigningKeys(nodeName)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, key := range keys {
+		if signed.Verify(key, topic) {
+			return &signed, key, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("invalid signature of node %s", nodeName)
+}
+
+// handleMessage receives the message from the broker, which must not block
+// the mqtt client.
+func (srv *Server) handleMessage(_ paho.Client, m paho.Message) {
+	select {
+	case srv.incoming <- m:
+	default:
+		// LC retransmits the messages not acknowledged in time, and
+		// publishes the presence periodically
+		klog.Warningf("drop the message of topic %s since too many messages not dispatched", m.Topic())
+	}
+}
+
+// dispatch dispatches the messages received until stopCh closed
+func (srv *Server) dispatch(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case m := <-srv.incoming:
+			srv.dispatchOne(m)
+		}
+	}
+}
+
+func (srv *Server) dispatchOne(m paho.Message) {
+	nodeName, suffix, err := srv.parseTopic(m.Topic())
+	if err != nil {
+		klog.Warningf("ignore message: %v", err)
+		return
+	}
+	if suffix != PresenceTopic && suffix != UpstreamTopic {
+		return
+	}
+
+	payload := m.Payload()
+	if len(payload) == 0 {
+		// the retained presence is cleared
+		return
+	}
+	signed, key, err := srv.verify(nodeName, m.Topic(), payload)
+	if err != nil {
+		klog.Warningf("reject the message of topic %s: %v", m.Topic(), err)
+		return
+	}
+
+	switch suffix {
+	case PresenceTopic:
+		srv.handlePresence(nodeName, m.Topic(), signed, key)
+	case UpstreamTopic:
+		srv.handleUpstream(nodeName, m.Topic(), signed)
+	}
+}
+
+// subscribe subscribes the upstream and presence topics of all nodes,
+// the retained presences reopen the sessions of the online nodes.
+func (srv *Server) subscribe(client paho.Client) {
+	filters := map[string]byte{
+		srv.topic("+", UpstreamTopic): srv.cfg.QoS,
+		srv.topic("+", PresenceTopic): srv.cfg.QoS,
+	}
+	token := client.SubscribeMultiple(filters, srv.handleMessage)
+	if token.Wait() && token.Error() != nil {
+		klog.Errorf("failed to subscribe mqtt topics of nodes: %v", token.Error())
+		return
+	}
+	klog.Infof("subscribed mqtt topics of nodes under %s", srv.cfg.TopicPrefix)
+}
+
+// ListenAndServe connects the broker and serves until the message context done
+func (srv *Server) ListenAndServe() error {
+	opts := paho.NewClientOptions().
+		AddBroker(srv.cfg.Broker).
+		SetClientID(srv.cfg.ClientID).
+		SetUsername(srv.cfg.Username).
+		SetPassword(srv.cfg.Password).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(srv.subscribe).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			klog.Warningf("lost connection to mqtt broker %s: %v", srv.cfg.Broker, err)
+			srv.closeAllSessions()
+		})
+
+	stopCh := messageContext.Done()
+	go srv.dispatch(stopCh)
+
+	srv.client = paho.NewClient(opts)
+	token := srv.client.Connect()
+	if token.Wait() && token.Error() != nil {
+		return fmt.Errorf("failed to connect mqtt broker %s: %w", srv.cfg.Broker, token.Error())
+	}
+	klog.Infof("connected mqtt broker %s", srv.cfg.Broker)
+
+	<-stopCh
+	srv.client.Disconnect(250)
+	return nil
+}
@@ -0,0 +1,362 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	broker "github.com/mochi-co/mqtt/server"
+	"github.com/mochi-co/mqtt/server/listeners"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+	messageContext "github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/ws"
+	"github.com/edgeai-neptune/neptune/pkg/messagelayer"
+)
+
+const (
+	testTopicPrefix = "neptune"
+	testGMUser      = "gm"
+	testGMPassword  = "gm-password"
+	// testTimeout is the time waiting for a message expected
+	testTimeout = 5 * time.Second
+	// testQuiet is the time asserting no message arrives
+	testQuiet = 500 * time.Millisecond
+	// testSessionID is the session of the nodes
+	testSessionID = "session1"
+)
+
+// testNodeTokens are the bootstrap tokens of the nodes
+var testNodeTokens = map[string]string{
+	"node-held":   "token-held",
+	"node-signed": "token-signed",
+	"node-forged": "token-forged",
+	"node-acl":    "token-acl",
+	"node-replay": "token-replay",
+}
+
+// nodeACL is the recommended ACL of the broker: GM accesses all topics,
+// a node publishes to its upstream and presence topics and subscribes its
+// downstream topic only.
+type nodeACL struct{}
+
+func (nodeACL) Authenticate(user, password []byte) bool {
+	if string(user) == testGMUser {
+		return string(password) == testGMPassword
+	}
+	token, ok := testNodeTokens[string(user)]
+	return ok && token == string(password)
+}
+
+func (nodeACL) ACL(user []byte, topic string, write bool) bool {
+	if string(user) == testGMUser {
+		return true
+	}
+	prefix := testTopicPrefix + "/" + string(user) + "/"
+	if write {
+		return topic == prefix+UpstreamTopic || topic == prefix+PresenceTopic
+	}
+	return topic == prefix+DownstreamTopic
+}
+
+// tokenVerifier provides the keys derived from the tokens of testNodeTokens
+type tokenVerifier struct{}
+
+func (tokenVerifier) NodeSigningKeys(nodeName string) ([][]byte, error) {
+	token, ok := testNodeTokens[nodeName]
+	if !ok {
+		return nil, fmt.Errorf("unknown node %s", nodeName)
+	}
+	return [][]byte{messagelayer.DeriveSigningKey(token)}, nil
+}
+
+type received struct {
+	nodeName string
+	msg      model.Message
+}
+
+type testEnv struct {
+	t       *testing.T
+	address string
+	broker  *broker.Server
+	// edge receives the messages passed to the message context
+	edge chan received
+	// sequence is the last sequence of the payloads signed
+	sequence uint64
+}
+
+// newTestEnv starts an embedded broker and a mqtt server connected to it
+func newTestEnv(t *testing.T) *testEnv {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	b := broker.New()
+	if err = b.AddListener(listeners.NewTCP("tcp", address), &listeners.Config{Auth: nodeACL{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+
+	env := &testEnv{
+		t:       t,
+		address: address,
+		broker:  b,
+		edge:    make(chan received, 100),
+	}
+
+	srv := NewServer(config.MQTT{
+		Broker:      "tcp://" + address,
+		ClientID:    "gm",
+		Username:    testGMUser,
+		Password:    testGMPassword,
+		TopicPrefix: testTopicPrefix,
+		QoS:         1,
+	}, time.Second, tokenVerifier{})
+	go func() {
+		if err := srv.ListenAndServe(); err != nil {
+			t.Errorf("failed to serve: %v", err)
+		}
+	}()
+	go func() {
+		for {
+			nodeName, msg, err := messageContext.ReceiveFromEdge()
+			if err != nil {
+				return
+			}
+			env.edge <- received{nodeName: nodeName, msg: msg}
+		}
+	}()
+
+	// the messages published before GM subscribed are lost
+	upstream := testTopicPrefix + "/any/" + UpstreamTopic
+	env.waitFor(func() bool { return len(b.Topics.Subscribers(upstream)) > 0 })
+	return env
+}
+
+func (env *testEnv) waitFor(condition func() bool) {
+	deadline := time.Now().Add(testTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			env.t.Fatal("timeout waiting for the condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// connect connects the broker as the node, and returns the client and the
+// channel of the messages of its downstream topic
+func (env *testEnv) connect(nodeName, token string) (paho.Client, chan model.Message) {
+	opts := paho.NewClientOptions().
+		AddBroker("tcp://" + env.address).
+		SetClientID(nodeName).
+		SetUsername(nodeName).
+		SetPassword(token)
+	client := paho.NewClient(opts)
+	if t := client.Connect(); t.Wait() && t.Error() != nil {
+		env.t.Fatal(t.Error())
+	}
+	env.t.Cleanup(func() { client.Disconnect(0) })
+
+	downstream := make(chan model.Message, 100)
+	topic := strings.Join([]string{testTopicPrefix, nodeName, DownstreamTopic}, "/")
+	var lastSequence uint64
+	if t := client.Subscribe(topic, 1, func(_ paho.Client, m paho.Message) {
+		// the downstream messages are signed by GM for the session
+		var signed messagelayer.SignedPayload
+		if err := json.Unmarshal(m.Payload(), &signed); err != nil {
+			env.t.Errorf("invalid downstream payload: %v", err)
+			return
+		}
+		if !signed.Verify(messagelayer.DeriveSigningKey(token), topic) || signed.SessionID != testSessionID ||
+			signed.Sequence <= lastSequence {
+			env.t.Errorf("invalid signature of the downstream payload %+v", signed)
+			return
+		}
+		lastSequence = signed.Sequence
+
+		var msg model.Message
+		if err := json.Unmarshal(signed.Data, &msg); err != nil {
+			env.t.Errorf("invalid downstream message: %v", err)
+			return
+		}
+		downstream <- msg
+	}); t.Wait() && t.Error() != nil {
+		env.t.Fatal(t.Error())
+	}
+	return client, downstream
+}
+
+// sign returns the topic of the node and the payload of the data signed by
+// the key derived from the token, unsigned if the token is empty
+func (env *testEnv) sign(nodeName, suffix, token string, data interface{}) (string, []byte) {
+	content, err := json.Marshal(data)
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	topic := strings.Join([]string{testTopicPrefix, nodeName, suffix}, "/")
+	env.sequence++
+	signed := messagelayer.SignedPayload{
+		Data:      content,
+		SessionID: testSessionID,
+		Sequence:  env.sequence,
+		Timestamp: time.Now().Unix(),
+	}
+	if token != "" {
+		signed.Sign(messagelayer.DeriveSigningKey(token), topic)
+	}
+	payload, err := json.Marshal(signed)
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	return topic, payload
+}
+
+// publish publishes the data to the topic of the node signed by the token,
+// unsigned if the token is empty
+func (env *testEnv) publish(client paho.Client, nodeName, suffix, token string, data interface{}) {
+	topic, payload := env.sign(nodeName, suffix, token, data)
+	env.publishPayload(client, topic, payload)
+}
+
+func (env *testEnv) publishPayload(client paho.Client, topic string, payload []byte) {
+	// QoS 0 since the broker drops the messages denied by ACL without acknowledging
+	if t := client.Publish(topic, 0, false, payload); t.Wait() && t.Error() != nil {
+		env.t.Fatal(t.Error())
+	}
+}
+
+func (env *testEnv) expectEdge(nodeName, messageID string) {
+	select {
+	case r := <-env.edge:
+		if r.nodeName != nodeName || r.msg.MessageID != messageID {
+			env.t.Fatalf("expected message %s of node %s, got %s of node %s",
+				messageID, nodeName, r.msg.MessageID, r.nodeName)
+		}
+	case <-time.After(testTimeout):
+		env.t.Fatalf("message %s of node %s not received", messageID, nodeName)
+	}
+}
+
+func (env *testEnv) expectNoEdge() {
+	select {
+	case r := <-env.edge:
+		env.t.Fatalf("unexpected message %s of node %s", r.msg.MessageID, r.nodeName)
+	case <-time.After(testQuiet):
+	}
+}
+
+func expectAck(t *testing.T, downstream chan model.Message, sequence uint64) {
+	select {
+	case msg := <-downstream:
+		if msg.Operation != model.AckOperation || msg.Sequence != sequence {
+			t.Fatalf("expected ack of sequence %d, got %+v", sequence, msg.MessageHeader)
+		}
+	case <-time.After(testTimeout):
+		t.Fatalf("ack of sequence %d not received", sequence)
+	}
+}
+
+func newUpstreamMessage(sequence uint64, messageID string) model.Message {
+	return model.Message{
+		MessageHeader: model.MessageHeader{
+			Namespace:    "default",
+			ResourceKind: "dataset",
+			ResourceName: "dataset1",
+			Operation:    "status",
+			Sequence:     sequence,
+			MessageID:    messageID,
+		},
+		Content: []byte("{}"),
+	}
+}
+
+func TestServer(t *testing.T) {
+	env := newTestEnv(t)
+	online := Presence{Online: true, Version: "v1", SessionID: testSessionID}
+
+	t.Run("signed messages are delivered and acked", func(t *testing.T) {
+		env.t = t
+		nodeName := "node-signed"
+		token := testNodeTokens[nodeName]
+		client, downstream := env.connect(nodeName, token)
+
+		env.publish(client, nodeName, PresenceTopic, token, online)
+		env.publish(client, nodeName, UpstreamTopic, token, newUpstreamMessage(1, "signed-1"))
+		env.expectEdge(nodeName, "signed-1")
+		expectAck(t, downstream, 1)
+	})
+
+	t.Run("upstream messages before the presence are held", func(t *testing.T) {
+		env.t = t
+		nodeName := "node-held"
+		token := testNodeTokens[nodeName]
+		client, downstream := env.connect(nodeName, token)
+
+		env.publish(client, nodeName, UpstreamTopic, token, newUpstreamMessage(1, "held-1"))
+		env.expectNoEdge()
+
+		env.publish(client, nodeName, PresenceTopic, token, online)
+		env.expectEdge(nodeName, "held-1")
+		expectAck(t, downstream, 1)
+	})
+
+	t.Run("forged and unsigned messages are rejected", func(t *testing.T) {
+		env.t = t
+		nodeName := "node-forged"
+		token := testNodeTokens[nodeName]
+		client, _ := env.connect(nodeName, token)
+
+		env.publish(client, nodeName, PresenceTopic, "wrong-token", online)
+		env.publish(client, nodeName, UpstreamTopic, "wrong-token", newUpstreamMessage(1, "forged-1"))
+		env.publish(client, nodeName, UpstreamTopic, "", newUpstreamMessage(2, "forged-2"))
+		env.expectNoEdge()
+
+		// the held messages are rejected ones, nothing is delivered by
+		// the genuine presence
+		env.publish(client, nodeName, PresenceTopic, token, online)
+		env.expectNoEdge()
+	})
+
+	t.Run("the broker denies publishing to the topics of other nodes", func(t *testing.T) {
+		env.t = t
+		nodeName := "node-acl"
+		client, _ := env.connect(nodeName, testNodeTokens[nodeName])
+
+		// signed with the token of the victim, e.g. leaked, still denied by the broker
+		victim := "node-signed"
+		env.publish(client, victim, UpstreamTopic, testNodeTokens[victim], newUpstreamMessage(2, "acl-1"))
+		env.expectNoEdge()
+	})
+
+	t.Run("replayed messages are rejected", func(t *testing.T) {
+		env.t = t
+		nodeName := "node-replay"
+		token := testNodeTokens[nodeName]
+		client, downstream := env.connect(nodeName, token)
+
+		env.publish(client, nodeName, PresenceTopic, token, online)
+		topic, payload := env.sign(nodeName, UpstreamTopic, token, newUpstreamMessage(1, "replay-1"))
+		env.publishPayload(client, topic, payload)
+		env.expectEdge(nodeName, "replay-1")
+		expectAck(t, downstream, 1)
+
+		// captured by the broker and published again
+		env.publishPayload(client, topic, payload)
+		env.expectNoEdge()
+		select {
+		case msg := <-downstream:
+			t.Fatalf("unexpected downstream message %+v", msg.MessageHeader)
+		default:
+		}
+	})
+}
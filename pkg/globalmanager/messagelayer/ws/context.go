@@ -3,14 +3,32 @@ package ws
 import (
 	gocontext "context"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 
 	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+	"github.com/edgeai-neptune/neptune/pkg/util"
+)
+
+const (
+	// recentMessageIDsSize is the number of recent upstream message ids
+	// remembered per node for detecting duplicates.
+	recentMessageIDsSize = 1024
+)
+
+var (
+	// retransmitTimeout is the time waiting for the ack of a message
+	// before it's written again on the same connection
+	retransmitTimeout = 30 * time.Second
+	// retransmitCheckInterval is the interval of checking the messages
+	// waiting for the acks
+	retransmitCheckInterval = 5 * time.Second
 )
 
 type nodeMessage struct {
@@ -27,14 +45,66 @@ type ChannelContext struct {
 	//downstreamChannel chan nodeMessage
 
 	// downstream map
-	// nodeName => queue
-	nodeQueue sync.Map
+	// nodeName => store of the messages not yet sent
 	nodeStore sync.Map
+
+	// connection registry
+	// nodeName => *nodeConnection
+	nodeConnections sync.Map
+
+	// outbox is nil when the pending messages are not persisted
+	outbox *outboxFlusher
+}
+
+// NodeInfo describes the connection state of a node's LC
+type NodeInfo struct {
+	Name string
+	// Version is the version reported by LC
+	Version string
+	// Connected is true when LC is connected
+	Connected bool
+	// ConnectTime is the time of the latest connection established
+	ConnectTime time.Time
+	// DisconnectTime is the time of the latest connection closed
+	DisconnectTime time.Time
+	// LastHeartbeatTime is the last time a message or heartbeat received from LC
+	LastHeartbeatTime time.Time
+	// PendingMessages is the number of messages waiting to be sent to LC
+	PendingMessages int
+}
+
+type nodeConnection struct {
+	sync.Mutex
+	info NodeInfo
+
+	// queue holds the keys to be written by the write loop of the current
+	// connection, nil when the node is disconnected.
+	queue workqueue.Interface
+	// closeCh requests to close the current connection, nil when the node is disconnected.
+	closeCh chan struct{}
+
+	// sequence is the last sequence assigned to the downstream messages
+	sequence uint64
+	// inflight maps the sequences of the messages written but not acked
+	// to their keys in the node store and the time written
+	inflight map[uint64]inflightMessage
+
+	// recentMessageIDs detects the retransmitted upstream messages
+	recentMessageIDs *util.RecentSet
+}
+
+type inflightMessage struct {
+	key       string
+	writeTime time.Time
 }
 
 var (
 	// singleton
 	context *ChannelContext
+
+	// instanceID distinguishes the message ids of this GM instance
+	// from the ones of previous instances
+	instanceID = strconv.FormatInt(time.Now().UnixNano(), 36)
 )
 
 func init() {
@@ -63,15 +133,6 @@ func getMsgKey(obj interface{}) (string, error) {
 	return strings.Join([]string{kind, namespace, name}, "/"), nil
 }
 
-func getNodeQueue(nodeName string) workqueue.RateLimitingInterface {
-	q, ok := context.nodeQueue.Load(nodeName)
-	if !ok {
-		newQ := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), nodeName)
-		q, _ = context.nodeQueue.LoadOrStore(nodeName, newQ)
-	}
-	return q.(workqueue.RateLimitingInterface)
-}
-
 func getNodeStore(nodeName string) cache.Store {
 	s, ok := context.nodeStore.Load(nodeName)
 	if !ok {
@@ -81,14 +142,238 @@ func getNodeStore(nodeName string) cache.Store {
 	return s.(cache.Store)
 }
 
-// SendToEdge sends the msg to nodeName
+func getNodeConnection(nodeName string) *nodeConnection {
+	c, ok := context.nodeConnections.Load(nodeName)
+	if !ok {
+		newC := &nodeConnection{
+			info:             NodeInfo{Name: nodeName},
+			inflight:         make(map[uint64]inflightMessage),
+			recentMessageIDs: util.NewRecentSet(recentMessageIDsSize),
+		}
+		c, _ = context.nodeConnections.LoadOrStore(nodeName, newC)
+	}
+	return c.(*nodeConnection)
+}
+
+// markNodeConnected records the node connected, and returns the write queue
+// of the new connection which is filled with all pending messages.
+func markNodeConnected(nodeName, version string, closeCh chan struct{}) workqueue.Interface {
+	c := getNodeConnection(nodeName)
+	c.Lock()
+	defer c.Unlock()
+
+	if c.queue != nil {
+		// the previous connection is not torn down yet
+		c.queue.ShutDown()
+	}
+
+	now := time.Now()
+	c.info.Version = version
+	c.info.Connected = true
+	c.info.ConnectTime = now
+	c.info.LastHeartbeatTime = now
+
+	// retransmit all unacknowledged messages
+	c.inflight = make(map[uint64]inflightMessage)
+	q := workqueue.NewNamed(nodeName)
+	for _, key := range getNodeStore(nodeName).ListKeys() {
+		q.Add(key)
+	}
+	c.queue = q
+	c.closeCh = closeCh
+	return q
+}
+
+// markNodeDisconnected records the node disconnected, and shuts down the
+// write queue q if it's still the one of current connection.
+// The pending messages are kept in the node store for the next connection.
+func markNodeDisconnected(nodeName string, q workqueue.Interface) {
+	c := getNodeConnection(nodeName)
+	c.Lock()
+	defer c.Unlock()
+
+	q.ShutDown()
+	if c.queue != q {
+		// a new connection has been established
+		return
+	}
+
+	c.queue = nil
+	c.closeCh = nil
+	c.info.Connected = false
+	c.info.DisconnectTime = time.Now()
+}
+
+// CloseNode closes the current connection of the node if connected,
+// e.g. the credential of the node is revoked.
+func CloseNode(nodeName string) {
+	c := getNodeConnection(nodeName)
+	c.Lock()
+	defer c.Unlock()
+
+	if c.closeCh == nil {
+		return
+	}
+	if notifyClose(c.closeCh) {
+		klog.Infof("closing connection of node %s", nodeName)
+	}
+	c.closeCh = nil
+}
+
+// notifyClose requests the caller of AddNode to close the connection, and
+// returns false if it has been requested. It never blocks, since the caller
+// waits for only one notification.
+func notifyClose(closeCh chan struct{}) bool {
+	select {
+	case closeCh <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// MarkNodeHeartbeat records the node alive
+func MarkNodeHeartbeat(nodeName string) {
+	c := getNodeConnection(nodeName)
+	c.Lock()
+	defer c.Unlock()
+	c.info.LastHeartbeatTime = time.Now()
+}
+
+// ListNodes returns the connection states of all nodes which have ever connected
+func ListNodes() []NodeInfo {
+	var nodes []NodeInfo
+	context.nodeConnections.Range(func(key, value interface{}) bool {
+		c := value.(*nodeConnection)
+		c.Lock()
+		info := c.info
+		c.Unlock()
+
+		info.PendingMessages = len(getNodeStore(info.Name).ListKeys())
+		nodes = append(nodes, info)
+		return true
+	})
+	return nodes
+}
+
+// SendToEdge sends the msg to nodeName.
+// The msg is kept until acknowledged by the node, a newer msg of the same
+// resource replaces the older one, and the resync answer replaces the
+// pending messages except the deletions.
 func SendToEdge(nodeName string, msg *model.Message) error {
-	q := getNodeQueue(nodeName)
 	key, _ := getMsgKey(msg)
-	q.Add(key)
+
+	c := getNodeConnection(nodeName)
+	c.Lock()
+	defer c.Unlock()
+
+	c.sequence++
+	msg.Sequence = c.sequence
+	msg.MessageID = instanceID + "-" + strconv.FormatUint(msg.Sequence, 10)
 
 	s := getNodeStore(nodeName)
-	return s.Add(msg)
+	if msg.ResourceKind == model.NodeKind && msg.Operation == model.ResyncOperation {
+		// the resync answer carries the authoritative resources bound to the
+		// node, which supersedes the pending messages. The deletions are kept,
+		// since the answer may be listed before them, and LC skips the
+		// resources of the answer deleted after.
+		for _, obj := range s.List() {
+			if obj.(*model.Message).Operation == model.DeleteOperation {
+				continue
+			}
+			_ = s.Delete(obj)
+		}
+	}
+	if err := s.Add(msg); err != nil {
+		return err
+	}
+	markNodeStoreChanged(nodeName)
+
+	if c.queue != nil {
+		c.queue.Add(key)
+	}
+	return nil
+}
+
+// markMessageInflight records the message written to the node
+func markMessageInflight(nodeName string, msg *model.Message) {
+	key, _ := getMsgKey(msg)
+
+	c := getNodeConnection(nodeName)
+	c.Lock()
+	defer c.Unlock()
+	c.inflight[msg.Sequence] = inflightMessage{key: key, writeTime: time.Now()}
+}
+
+// retransmitInflight requeues the messages not acked within the retransmit
+// timeout, if q is still the write queue of current connection, since the
+// ack may be lost without the connection broken, e.g. dropped by LC.
+// A duplicate is acked again and ignored by LC.
+func retransmitInflight(nodeName string, q workqueue.Interface) {
+	c := getNodeConnection(nodeName)
+	c.Lock()
+	defer c.Unlock()
+
+	if c.queue != q {
+		return
+	}
+	s := getNodeStore(nodeName)
+	now := time.Now()
+	for sequence, m := range c.inflight {
+		if obj, exists, _ := s.GetByKey(m.key); !exists || obj.(*model.Message).Sequence != sequence {
+			// superseded by a newer message, whose ack is waited instead
+			delete(c.inflight, sequence)
+			continue
+		}
+		if now.Sub(m.writeTime) < retransmitTimeout {
+			continue
+		}
+		klog.V(2).Infof("retransmit key %s(sequence=%d) to node %s since not acked in %v",
+			m.key, sequence, nodeName, retransmitTimeout)
+		// the time is reset once written again, but requeued only
+		// once until then
+		m.writeTime = now
+		c.inflight[sequence] = m
+		q.Add(m.key)
+	}
+}
+
+// ackMessage deletes the message acknowledged by the node from the node store
+func ackMessage(nodeName string, sequence uint64) {
+	c := getNodeConnection(nodeName)
+	c.Lock()
+	defer c.Unlock()
+
+	m, ok := c.inflight[sequence]
+	if !ok {
+		klog.V(4).Infof("ignore unknown ack %d from node %s", sequence, nodeName)
+		return
+	}
+	delete(c.inflight, sequence)
+
+	key := m.key
+	s := getNodeStore(nodeName)
+	obj, exists, _ := s.GetByKey(key)
+	if !exists {
+		return
+	}
+
+	msg := obj.(*model.Message)
+	if msg.Sequence != sequence {
+		// replaced by a newer message which is not acked yet
+		return
+	}
+	_ = s.Delete(msg)
+	markNodeStoreChanged(nodeName)
+	klog.V(4).Infof("node %s acked key %s(sequence=%d)", nodeName, key, sequence)
+}
+
+// isDuplicateMessage checks whether the upstream msg has been received recently
+func isDuplicateMessage(nodeName string, msg *model.Message) bool {
+	if msg.MessageID == "" {
+		return false
+	}
+	return !getNodeConnection(nodeName).recentMessageIDs.Add(msg.MessageID)
 }
 
 // ReceiveFromEdge receives a message from edge
@@ -116,10 +401,13 @@ type ReadMsgFunc func() (model.Message, error)
 // WriteMsgFunc defines write msg callback
 type WriteMsgFunc func(model.Message) error
 
-// AddNode registers a node
-func AddNode(nodeName string, read ReadMsgFunc, write WriteMsgFunc, closeCh chan struct{}) {
-	getNodeQueue(nodeName)
-	getNodeStore(nodeName)
+// AddNode registers a node with the version of its LC.
+// closeCh is notified when either the read loop or the write loop exits or
+// CloseNode called, then the caller should close the connection, which breaks
+// the loops. closeCh must have a buffer of at least 1.
+// write is called by both loops, so it must be safe for concurrent use.
+func AddNode(nodeName, version string, read ReadMsgFunc, write WriteMsgFunc, closeCh chan struct{}) {
+	q := markNodeConnected(nodeName, version, closeCh)
 
 	go func() {
 		// read loop
@@ -131,15 +419,32 @@ func AddNode(nodeName string, read ReadMsgFunc, write WriteMsgFunc, closeCh chan
 				break
 			}
 			klog.V(4).Infof("received msg from %s: %+v", nodeName, msg)
-			_ = SendToCloud(nodeName, msg)
+			MarkNodeHeartbeat(nodeName)
+
+			if msg.Operation == model.AckOperation {
+				ackMessage(nodeName, msg.Sequence)
+				continue
+			}
+
+			if isDuplicateMessage(nodeName, &msg) {
+				klog.V(2).Infof("ignore duplicate msg %s from %s", msg.MessageID, nodeName)
+			} else {
+				_ = SendToCloud(nodeName, msg)
+			}
+
+			if msg.Sequence > 0 {
+				if err = write(model.NewAckMessage(&msg)); err != nil {
+					break
+				}
+			}
 		}
-		closeCh <- struct{}{}
+		markNodeDisconnected(nodeName, q)
+		notifyClose(closeCh)
 		klog.Errorf("read loop of node %s closed, due to: %+v", nodeName, err)
 	}()
 
 	go func() {
 		// write loop
-		q := getNodeQueue(nodeName)
 		s := getNodeStore(nodeName)
 		var err error
 		for {
@@ -150,27 +455,39 @@ func AddNode(nodeName string, read ReadMsgFunc, write WriteMsgFunc, closeCh chan
 			}
 			obj, exists, _ := s.GetByKey(key.(string))
 			if !exists {
-				klog.Warningf("key %s not exists in node store %s", key, nodeName)
-				q.Forget(key)
+				klog.V(4).Infof("key %s not exists in node store %s, acked or superseded", key, nodeName)
 				q.Done(key)
 				continue
 			}
 			msg := obj.(*model.Message)
+			markMessageInflight(nodeName, msg)
 			err = write(*msg)
 			klog.V(4).Infof("writing msg to %s: %+v", nodeName, msg)
 			if err != nil {
-				klog.Warningf("failed to write key %s to node %s, requeue", key, nodeName)
-				q.AddRateLimited(key)
-				q.Forget(key)
+				// the message is kept in the node store, and will be
+				// resent when the node reconnects.
+				klog.Warningf("failed to write key %s to node %s, wait for reconnecting", key, nodeName)
 				q.Done(key)
 				break
 			}
-			klog.Infof("write key %s to node %s successfully", key, nodeName)
-			_ = s.Delete(msg)
-			q.Forget(key)
+			// the message is deleted from the node store when acked
+			klog.Infof("write key %s(sequence=%d) to node %s successfully", key, msg.Sequence, nodeName)
 			q.Done(key)
 		}
-		closeCh <- struct{}{}
+		markNodeDisconnected(nodeName, q)
+		notifyClose(closeCh)
 		klog.Errorf("write loop of node %s closed, due to: %+v", nodeName, err)
 	}()
+
+	go func() {
+		// retransmit loop, which stops with the write loop
+		ticker := time.NewTicker(retransmitCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if q.ShuttingDown() {
+				return
+			}
+			retransmitInflight(nodeName, q)
+		}
+	}()
 }
@@ -0,0 +1,167 @@
+package ws
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+)
+
+// fakeNodeConn is a connection of a node, whose messages to read are fed by
+// the test and the messages written are recorded
+type fakeNodeConn struct {
+	readCh  chan model.Message
+	writeCh chan model.Message
+	closeCh chan struct{}
+}
+
+// connectFakeNode connects a fake node by AddNode
+func connectFakeNode(nodeName string) *fakeNodeConn {
+	conn := &fakeNodeConn{
+		readCh:  make(chan model.Message),
+		writeCh: make(chan model.Message, 100),
+		closeCh: make(chan struct{}, 1),
+	}
+	AddNode(nodeName, "test", conn.read, conn.write, conn.closeCh)
+	return conn
+}
+
+func (conn *fakeNodeConn) read() (model.Message, error) {
+	msg, ok := <-conn.readCh
+	if !ok {
+		return msg, fmt.Errorf("connection closed")
+	}
+	return msg, nil
+}
+
+func (conn *fakeNodeConn) write(msg model.Message) error {
+	conn.writeCh <- msg
+	return nil
+}
+
+// close breaks the read loop, which tears down the connection
+func (conn *fakeNodeConn) close() {
+	close(conn.readCh)
+	<-conn.closeCh
+}
+
+func (conn *fakeNodeConn) expectWritten(t *testing.T) model.Message {
+	t.Helper()
+	select {
+	case msg := <-conn.writeCh:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for a message written")
+	}
+	return model.Message{}
+}
+
+func (conn *fakeNodeConn) expectNothingWritten(t *testing.T, wait time.Duration) {
+	t.Helper()
+	select {
+	case msg := <-conn.writeCh:
+		t.Fatalf("expected nothing written, got %+v", msg)
+	case <-time.After(wait):
+	}
+}
+
+// waitStoreEmpty waits for all messages of the node acked
+func waitStoreEmpty(t *testing.T, nodeName string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if len(getNodeStore(nodeName).ListKeys()) == 0 {
+			return
+		}
+	}
+	t.Fatalf("expected the messages of node %s acked, got %v", nodeName, messageIDs(storeMessages(nodeName)))
+}
+
+func TestDuplicateUpstreamMessageAcked(t *testing.T) {
+	nodeName := "node-duplicate"
+	conn := connectFakeNode(nodeName)
+	defer conn.close()
+
+	msg := *newResourceMessage("dataset", "d1", "status")
+	msg.Sequence, msg.MessageID = 1, "lc-1"
+	// retransmitted by LC since the ack lost
+	conn.readCh <- msg
+	conn.readCh <- msg
+
+	for i := 0; i < 2; i++ {
+		ack := conn.expectWritten(t)
+		if ack.Operation != model.AckOperation || ack.Sequence != 1 || ack.MessageID != "lc-1" {
+			t.Errorf("expected the ack of the message, got %+v", ack.MessageHeader)
+		}
+	}
+
+	received := 0
+	for {
+		select {
+		case nodeMsg := <-context.upstreamChannel:
+			if nodeMsg.nodeName != nodeName || nodeMsg.msg.MessageID != "lc-1" {
+				t.Errorf("unexpected message %+v from node %s", nodeMsg.msg.MessageHeader, nodeMsg.nodeName)
+			}
+			received++
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+	if received != 1 {
+		t.Errorf("expected the message received once, got %d", received)
+	}
+}
+
+func TestRetransmitAfterReconnect(t *testing.T) {
+	nodeName := "node-reconnect"
+	if err := SendToEdge(nodeName, newResourceMessage("model", "m1", "update")); err != nil {
+		t.Fatal(err)
+	}
+
+	conn := connectFakeNode(nodeName)
+	first := conn.expectWritten(t)
+	// disconnected before acked
+	conn.close()
+
+	conn = connectFakeNode(nodeName)
+	defer conn.close()
+	again := conn.expectWritten(t)
+	if again.MessageID != first.MessageID || again.Sequence != first.Sequence {
+		t.Errorf("expected message %s retransmitted, got %+v", first.MessageID, again.MessageHeader)
+	}
+
+	conn.readCh <- model.NewAckMessage(&again)
+	waitStoreEmpty(t, nodeName)
+}
+
+func TestRetransmitUnackedMessage(t *testing.T) {
+	timeout, interval := retransmitTimeout, retransmitCheckInterval
+	retransmitTimeout, retransmitCheckInterval = 50*time.Millisecond, 10*time.Millisecond
+	defer func() {
+		retransmitTimeout, retransmitCheckInterval = timeout, interval
+	}()
+
+	nodeName := "node-retransmit"
+	conn := connectFakeNode(nodeName)
+	defer conn.close()
+
+	if err := SendToEdge(nodeName, newResourceMessage("model", "m1", "update")); err != nil {
+		t.Fatal(err)
+	}
+	first := conn.expectWritten(t)
+	// the ack is lost without the connection broken
+	again := conn.expectWritten(t)
+	if again.MessageID != first.MessageID {
+		t.Errorf("expected message %s retransmitted, got %+v", first.MessageID, again.MessageHeader)
+	}
+
+	conn.readCh <- model.NewAckMessage(&again)
+	waitStoreEmpty(t, nodeName)
+	// drain the retransmissions before acked, including the one being written
+	time.Sleep(retransmitCheckInterval)
+	for len(conn.writeCh) > 0 {
+		<-conn.writeCh
+	}
+	conn.expectNothingWritten(t, 5*retransmitTimeout)
+}
@@ -0,0 +1,160 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/metrics"
+)
+
+// Outbox persists the pending downstream messages of the nodes, so that the
+// nodes offline across the restarts of GM still receive them.
+type Outbox interface {
+	// Load returns the persisted messages of all nodes
+	Load() (map[string][]model.Message, error)
+	// Save replaces the persisted messages of the node, no messages means
+	// nothing pending for the node. The messages are in order, and an
+	// *OutboxOverflowError is returned if the oldest ones are not persisted.
+	Save(nodeName string, msgs []model.Message) error
+}
+
+// OutboxOverflowError means the messages of the node exceed the capacity of
+// the outbox, and the oldest ones are not persisted.
+type OutboxOverflowError struct {
+	NodeName string
+	// Dropped is the number of the messages not persisted
+	Dropped int
+}
+
+func (e *OutboxOverflowError) Error() string {
+	return fmt.Sprintf("outbox of node %s is full, the oldest %d messages are not persisted", e.NodeName, e.Dropped)
+}
+
+// outboxFlusher flushes the node stores changed to the outbox
+type outboxFlusher struct {
+	outbox Outbox
+	// queue holds the names of the nodes whose stores changed
+	queue         workqueue.DelayingInterface
+	flushInterval time.Duration
+}
+
+// EnableOutbox restores the pending messages from the outbox, and persists
+// the node stores once changed from now on. The changes of a node are
+// flushed at most once per flushInterval.
+// It must be called before any message sent to the nodes.
+func EnableOutbox(outbox Outbox, flushInterval time.Duration) error {
+	nodeMsgs, err := outbox.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load the outbox: %w", err)
+	}
+
+	for nodeName, msgs := range nodeMsgs {
+		restoreNodeMessages(nodeName, msgs)
+	}
+
+	f := &outboxFlusher{
+		outbox:        outbox,
+		queue:         workqueue.NewNamedDelayingQueue("outbox"),
+		flushInterval: flushInterval,
+	}
+	context.outbox = f
+	go f.run()
+
+	go func() {
+		<-Done()
+		f.queue.ShutDown()
+	}()
+
+	klog.Infof("restored the pending messages of %d nodes from the outbox", len(nodeMsgs))
+	return nil
+}
+
+// restoreNodeMessages adds the persisted messages of the node to its store
+// in their original order. The sequences are reassigned for this instance,
+// while the message ids are kept for the node to detect the duplicates.
+func restoreNodeMessages(nodeName string, msgs []model.Message) {
+	sortMessages(msgs)
+
+	c := getNodeConnection(nodeName)
+	c.Lock()
+	defer c.Unlock()
+
+	s := getNodeStore(nodeName)
+	for i := range msgs {
+		msg := msgs[i]
+		key, _ := getMsgKey(&msg)
+		if _, exists, _ := s.GetByKey(key); exists {
+			continue
+		}
+
+		c.sequence++
+		msg.Sequence = c.sequence
+		if err := s.Add(&msg); err != nil {
+			klog.Warningf("failed to restore key %s of node %s: %v", key, nodeName, err)
+		}
+	}
+}
+
+// sortMessages sorts the messages by their sequences
+func sortMessages(msgs []model.Message) {
+	sort.Slice(msgs, func(i, j int) bool {
+		return msgs[i].Sequence < msgs[j].Sequence
+	})
+}
+
+// markNodeStoreChanged schedules flushing the store of the node
+func markNodeStoreChanged(nodeName string) {
+	if f := context.outbox; f != nil {
+		f.queue.AddAfter(nodeName, f.flushInterval)
+	}
+}
+
+// flush saves the current resource messages of the node store in order.
+// The messages of the node itself, e.g. the resync answer, are not persisted
+// since the node requests them again once reconnected.
+func (f *outboxFlusher) flush(nodeName string) error {
+	objs := getNodeStore(nodeName).List()
+	msgs := make([]model.Message, 0, len(objs))
+	for _, obj := range objs {
+		msg := obj.(*model.Message)
+		if msg.ResourceKind == model.NodeKind {
+			continue
+		}
+		msgs = append(msgs, *msg)
+	}
+	sortMessages(msgs)
+	return f.outbox.Save(nodeName, msgs)
+}
+
+func (f *outboxFlusher) run() {
+	for {
+		item, shutdown := f.queue.Get()
+		if shutdown {
+			return
+		}
+
+		nodeName := item.(string)
+		err := f.flush(nodeName)
+		var overflow *OutboxOverflowError
+		switch {
+		case err == nil:
+			metrics.OutboxMessagesNotPersisted.WithLabelValues(nodeName).Set(0)
+			klog.V(4).Infof("flushed the outbox of node %s", nodeName)
+		case errors.As(err, &overflow):
+			// retrying never fits, the messages not persisted are still
+			// sent to the node until GM restarts
+			metrics.OutboxMessagesNotPersisted.WithLabelValues(nodeName).Set(float64(overflow.Dropped))
+			klog.Errorf("failed to flush all messages to the outbox of node %s: %v", nodeName, err)
+		default:
+			klog.Warningf("failed to flush the outbox of node %s, retry later: %v", nodeName, err)
+			f.queue.AddAfter(nodeName, f.flushInterval)
+		}
+		f.queue.Done(item)
+	}
+}
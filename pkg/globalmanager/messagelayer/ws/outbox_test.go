@@ -0,0 +1,185 @@
+package ws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+)
+
+// memoryOutbox keeps the saved messages in memory
+type memoryOutbox struct {
+	saved map[string][]model.Message
+}
+
+func (o *memoryOutbox) Load() (map[string][]model.Message, error) {
+	return o.saved, nil
+}
+
+func (o *memoryOutbox) Save(nodeName string, msgs []model.Message) error {
+	o.saved[nodeName] = msgs
+	return nil
+}
+
+func newResourceMessage(kind, name, operation string) *model.Message {
+	return &model.Message{
+		MessageHeader: model.MessageHeader{
+			Namespace:    "default",
+			ResourceKind: kind,
+			ResourceName: name,
+			Operation:    operation,
+		},
+		Content: []byte(name),
+	}
+}
+
+func newResyncMessage(nodeName string) *model.Message {
+	return &model.Message{
+		MessageHeader: model.MessageHeader{
+			ResourceKind: model.NodeKind,
+			ResourceName: nodeName,
+			Operation:    model.ResyncOperation,
+		},
+		Content: []byte("[]"),
+	}
+}
+
+// storeMessages returns the messages of the node store in order
+func storeMessages(nodeName string) []model.Message {
+	var msgs []model.Message
+	for _, obj := range getNodeStore(nodeName).List() {
+		msgs = append(msgs, *obj.(*model.Message))
+	}
+	sortMessages(msgs)
+	return msgs
+}
+
+// messageIDs returns the keys and the message ids of the messages
+func messageIDs(msgs []model.Message) []string {
+	var ids []string
+	for i := range msgs {
+		key, _ := getMsgKey(&msgs[i])
+		ids = append(ids, key+"@"+msgs[i].MessageID)
+	}
+	return ids
+}
+
+func TestRestoreNodeMessages(t *testing.T) {
+	nodeName := "node-restore"
+	persisted := []model.Message{
+		*newResourceMessage("model", "m1", "update"),
+		*newResourceMessage("dataset", "d1", "insert"),
+		*newResourceMessage("dataset", "d2", "delete"),
+	}
+	persisted[0].Sequence, persisted[0].MessageID = 30, "old-30"
+	persisted[1].Sequence, persisted[1].MessageID = 10, "old-10"
+	persisted[2].Sequence, persisted[2].MessageID = 20, "old-20"
+
+	restoreNodeMessages(nodeName, persisted)
+
+	msgs := storeMessages(nodeName)
+	expected := []string{"dataset/default/d1@old-10", "dataset/default/d2@old-20", "model/default/m1@old-30"}
+	if ids := messageIDs(msgs); !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected restored messages %v, got %v", expected, ids)
+	}
+	for i, msg := range msgs {
+		// the sequences are reassigned in the original order
+		if msg.Sequence != uint64(i+1) {
+			t.Errorf("expected sequence %d of %s, got %d", i+1, msg.MessageID, msg.Sequence)
+		}
+	}
+
+	// the messages sent after restoring continue the sequences
+	if err := SendToEdge(nodeName, newResourceMessage("model", "m2", "insert")); err != nil {
+		t.Fatal(err)
+	}
+	msgs = storeMessages(nodeName)
+	if last := msgs[len(msgs)-1]; last.ResourceName != "m2" || last.Sequence != 4 {
+		t.Errorf("expected m2 with sequence 4 at last, got %s with sequence %d", last.ResourceName, last.Sequence)
+	}
+}
+
+func TestSendToEdgeCoalescesMessages(t *testing.T) {
+	nodeName := "node-coalesce"
+	for _, msg := range []*model.Message{
+		newResourceMessage("dataset", "d1", "insert"),
+		newResourceMessage("model", "m1", "insert"),
+		newResourceMessage("dataset", "d1", "update"),
+	} {
+		if err := SendToEdge(nodeName, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msgs := storeMessages(nodeName)
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 pending messages, got %d", len(msgs))
+	}
+	// the newer message of d1 replaces the older one
+	if msgs[1].ResourceName != "d1" || msgs[1].Operation != "update" || msgs[1].Sequence != 3 {
+		t.Errorf("expected the update of d1 with sequence 3, got %+v", msgs[1].MessageHeader)
+	}
+}
+
+func TestResyncSupersedesPendingMessages(t *testing.T) {
+	nodeName := "node-resync"
+	for _, msg := range []*model.Message{
+		newResourceMessage("dataset", "d1", "insert"),
+		newResourceMessage("model", "m1", "delete"),
+		newResyncMessage(nodeName),
+	} {
+		if err := SendToEdge(nodeName, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the deletion is kept, which may be newer than the answer
+	msgs := storeMessages(nodeName)
+	if len(msgs) != 2 || msgs[0].ResourceName != "m1" || msgs[1].Operation != model.ResyncOperation {
+		t.Fatalf("expected the deletion of m1 and the resync answer pending, got %v", messageIDs(msgs))
+	}
+
+	// the messages after the resync are kept
+	if err := SendToEdge(nodeName, newResourceMessage("dataset", "d2", "insert")); err != nil {
+		t.Fatal(err)
+	}
+	if msgs = storeMessages(nodeName); len(msgs) != 3 {
+		t.Fatalf("expected the deletion of m1, the resync answer and d2 pending, got %v", messageIDs(msgs))
+	}
+}
+
+func TestFlushPersistsResourceMessages(t *testing.T) {
+	nodeName := "node-flush"
+	for _, msg := range []*model.Message{
+		newResourceMessage("model", "m1", "insert"),
+		newResyncMessage(nodeName),
+		newResourceMessage("dataset", "d1", "insert"),
+		newResourceMessage("dataset", "d2", "insert"),
+	} {
+		if err := SendToEdge(nodeName, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outbox := &memoryOutbox{saved: make(map[string][]model.Message)}
+	f := &outboxFlusher{outbox: outbox}
+	if err := f.flush(nodeName); err != nil {
+		t.Fatal(err)
+	}
+
+	// m1 is superseded by the resync answer, which is not persisted
+	saved := outbox.saved[nodeName]
+	var names []string
+	for _, msg := range saved {
+		names = append(names, msg.ResourceName)
+	}
+	if expected := []string{"d1", "d2"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected persisted messages %v, got %v", expected, names)
+	}
+
+	// restored after restarting, the messages are replayed in order
+	restoreNodeMessages("node-flush-restored", saved)
+	if ids, expected := messageIDs(storeMessages("node-flush-restored")), messageIDs(saved); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected replayed messages %v, got %v", expected, ids)
+	}
+}
@@ -1,31 +1,136 @@
 package ws
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"k8s.io/klog/v2"
 
 	"github.com/gorilla/websocket"
 
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
 	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/metrics"
+	"github.com/edgeai-neptune/neptune/pkg/messagelayer"
+	"github.com/edgeai-neptune/neptune/pkg/util"
 )
 
+// Authenticator authenticates the node of a connection by the token it presents
+type Authenticator interface {
+	AuthenticateNode(nodeName, token string) error
+}
+
 // Server defines websocket protocol server
 type Server struct {
 	server *http.Server
+
+	// authenticator is nil when the nodes are not authenticated
+	authenticator Authenticator
+
+	// certificates is nil when serving without TLS
+	certificates *util.CertificateReloader
+	// verifyClient is true when the client certificates are required
+	verifyClient bool
+
+	pingInterval time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
 }
 
-// NewServer creates a websocket server
-func NewServer(address string) *Server {
+// NewServer creates a websocket server, which serves over TLS when the
+// server certificate is configured, and authenticates the nodes by
+// authenticator if not nil
+func NewServer(address string, cfg config.WebSocket, authenticator Authenticator) (*Server, error) {
 	server := http.Server{
 		Addr: address,
 	}
 
 	wsServer := &Server{
-		server: &server,
+		server:        &server,
+		authenticator: authenticator,
+		pingInterval:  time.Duration(cfg.PingInterval) * time.Second,
+		readTimeout:   time.Duration(cfg.ReadTimeout) * time.Second,
+		writeTimeout:  time.Duration(cfg.WriteTimeout) * time.Second,
+	}
+
+	if cfg.CertFile != "" {
+		certificates, err := util.NewCertificateReloader(cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load websocket certificates: %w", err)
+		}
+		wsServer.certificates = certificates
+		wsServer.verifyClient = cfg.ClientCAFile != ""
+		server.TLSConfig = NewTLSConfig(certificates, wsServer.verifyClient)
+	}
+
+	// a dedicated mux, so that nothing registered to http.DefaultServeMux is exposed
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", wsServer.ServeHTTP)
+	mux.Handle("/metrics", metrics.Handler())
+	server.Handler = mux
+	return wsServer, nil
+}
+
+// NewTLSConfig returns the TLS config serving the certificates, which are
+// fetched for each handshake so that the rotated certificates take effect
+// immediately. The client certificates are required if verifyClient.
+// nextProtos are the application protocols negotiated, e.g. h2 of gRPC.
+func NewTLSConfig(certificates *util.CertificateReloader, verifyClient bool, nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return certificates.Certificate(), nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certificate := certificates.Certificate()
+			tlsConfig := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*certificate},
+				NextProtos:   nextProtos,
+			}
+
+			if verifyClient {
+				tlsConfig.ClientCAs = certificates.CAPool()
+				tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return tlsConfig, nil
+		},
+	}
+}
+
+// VerifyNodeCertificate checks that the client certificate is issued to the
+// node, i.e. its common name or one of its DNS names is the node name.
+func VerifyNodeCertificate(cert *x509.Certificate, nodeName string) error {
+	if nodeName != "" {
+		if cert.Subject.CommonName == nodeName {
+			return nil
+		}
+		for _, name := range cert.DNSNames {
+			if name == nodeName {
+				return nil
+			}
+		}
 	}
-	http.HandleFunc("/", wsServer.ServeHTTP)
-	return wsServer
+	return fmt.Errorf("client certificate(CN=%s, DNSNames=%v) doesn't match node name %q",
+		cert.Subject.CommonName, cert.DNSNames, nodeName)
+}
+
+// verifyNodeIdentity checks the client certificate of the request if required
+func (srv *Server) verifyNodeIdentity(req *http.Request, nodeName string) error {
+	if !srv.verifyClient {
+		return nil
+	}
+
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return fmt.Errorf("no client certificate")
+	}
+	return VerifyNodeCertificate(req.TLS.PeerCertificates[0], nodeName)
 }
 
 func (srv *Server) upgrade(w http.ResponseWriter, r *http.Request) *websocket.Conn {
@@ -40,8 +145,34 @@ func (srv *Server) upgrade(w http.ResponseWriter, r *http.Request) *websocket.Co
 	return conn
 }
 
+// authenticateNode checks the bearer token presented for the node
+func (srv *Server) authenticateNode(req *http.Request, nodeName string) error {
+	if srv.authenticator == nil {
+		return nil
+	}
+
+	const prefix = "Bearer "
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return fmt.Errorf("no bearer token")
+	}
+	return srv.authenticator.AuthenticateNode(nodeName, strings.TrimSpace(auth[len(prefix):]))
+}
+
 func (srv *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	nodeName := req.Header.Get("Node-Name")
+	if err := srv.authenticateNode(req, nodeName); err != nil {
+		klog.Warningf("reject the connection from %s for node %s: %v", req.RemoteAddr, nodeName, err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := srv.verifyNodeIdentity(req, nodeName); err != nil {
+		klog.Warningf("reject the connection from %s for node %s: %v", req.RemoteAddr, nodeName, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
 	wsConn := srv.upgrade(w, req)
 	if wsConn == nil {
 		klog.Errorf("failed to upgrade to websocket for node %s", nodeName)
@@ -49,12 +180,22 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// serve connection
-	nodeClient := &nodeClient{conn: wsConn, req: req}
+	nodeClient := &nodeClient{
+		conn:         wsConn,
+		req:          req,
+		pingInterval: srv.pingInterval,
+		readTimeout:  srv.readTimeout,
+		writeTimeout: srv.writeTimeout,
+	}
 	go nodeClient.Serve()
 }
 
 // ListenAndServe listens and serves the server
 func (srv *Server) ListenAndServe() error {
+	if srv.certificates != nil {
+		// the certificates are provided by the TLS config
+		return srv.server.ListenAndServeTLS("", "")
+	}
 	return srv.server.ListenAndServe()
 }
 
@@ -70,6 +211,20 @@ type nodeClient struct {
 	conn     *websocket.Conn
 	req      *http.Request
 	nodeName string
+
+	// writeLock serializes the writes of the messages and acks
+	writeLock sync.Mutex
+
+	pingInterval time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// extendReadDeadline postpones the read deadline since the node is alive
+func (nc *nodeClient) extendReadDeadline() {
+	if nc.readTimeout > 0 {
+		_ = nc.conn.SetReadDeadline(time.Now().Add(nc.readTimeout))
+	}
 }
 
 func (nc *nodeClient) readOneMsg() (model.Message, error) {
@@ -80,22 +235,78 @@ func (nc *nodeClient) readOneMsg() (model.Message, error) {
 		return msg, err
 	}
 
+	nc.extendReadDeadline()
 	return msg, nil
 }
 
 func (nc *nodeClient) writeOneMsg(msg model.Message) error {
+	nc.writeLock.Lock()
+	defer nc.writeLock.Unlock()
+
+	if nc.writeTimeout > 0 {
+		_ = nc.conn.SetWriteDeadline(time.Now().Add(nc.writeTimeout))
+	}
 	return nc.conn.WriteJSON(&msg)
 }
 
+// keepAlive sends pings to the node periodically until stopCh closed
+func (nc *nodeClient) keepAlive(stopCh <-chan struct{}) {
+	if nc.pingInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(nc.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			deadline := time.Now().Add(nc.writeTimeout)
+			if nc.writeTimeout <= 0 {
+				deadline = time.Time{}
+			}
+			if err := nc.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				klog.Warningf("failed to ping node %s: %+v", nc.nodeName, err)
+				// closing the connection breaks the read loop
+				_ = nc.conn.Close()
+				return
+			}
+		}
+	}
+}
+
 func (nc *nodeClient) Serve() {
 	nodeName := nc.req.Header.Get("Node-Name")
 	nc.nodeName = nodeName
 	klog.Infof("established connection for node %s", nodeName)
-	// nc.conn.SetCloseHandler
-	closeCh := make(chan struct{}, 2)
-	AddNode(nodeName, nc.readOneMsg, nc.writeOneMsg, closeCh)
+
+	// the node is considered dead when neither message nor heartbeat
+	// received within the read timeout.
+	nc.extendReadDeadline()
+	nc.conn.SetPongHandler(func(string) error {
+		MarkNodeHeartbeat(nodeName)
+		nc.extendReadDeadline()
+		return nil
+	})
+	nc.conn.SetPingHandler(func(data string) error {
+		MarkNodeHeartbeat(nodeName)
+		nc.extendReadDeadline()
+		err := nc.conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	stopCh := make(chan struct{})
+	go nc.keepAlive(stopCh)
+
+	closeCh := make(chan struct{}, 1)
+	AddNode(nodeName, nc.req.Header.Get(messagelayer.WSHeaderNodeVersion), nc.readOneMsg, nc.writeOneMsg, closeCh)
 	<-closeCh
 
+	close(stopCh)
 	klog.Infof("closed connection for node %s", nodeName)
 	_ = nc.conn.Close()
 }
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "neptune"
+	subsystem = "gm"
+)
+
+var (
+	// UpstreamUpdatesRejected counts the updates from the nodes which the
+	// resources are not bound to, by kind and node
+	UpstreamUpdatesRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "upstream_updates_rejected_total",
+		Help:      "Number of upstream updates rejected since the sending node is not bound to the resource.",
+	}, []string{"kind", "node"})
+
+	// OutboxMessagesNotPersisted reports the number of the pending messages of
+	// the node not persisted since its outbox is full, by node
+	OutboxMessagesNotPersisted = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "outbox_messages_not_persisted",
+		Help:      "Number of the oldest pending messages of the node not persisted since its outbox is full.",
+	}, []string{"node"})
+
+	registry = prometheus.NewRegistry()
+)
+
+func init() {
+	registry.MustRegister(
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		prometheus.NewGoCollector(),
+		UpstreamUpdatesRejected,
+		OutboxMessagesNotPersisted,
+	)
+}
+
+// Handler returns the http handler serving the metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,113 @@
+package globalmanager
+
+import (
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+)
+
+// modelCacheVolumeName is the name of the volume of the model verified in the cache of LC
+const modelCacheVolumeName = "model-cache"
+
+// ModelNotVerifiedError means the model is not verified on the node of the worker using it,
+// and the worker is not created until verified
+type ModelNotVerifiedError struct {
+	Namespace string
+	Name      string
+	NodeName  string
+	Message   string
+}
+
+func (e *ModelNotVerifiedError) Error() string {
+	return fmt.Sprintf("model %s/%s is not verified on node %s: %s", e.Namespace, e.Name, e.NodeName, e.Message)
+}
+
+// isModelNotVerified returns whether the error is caused by a model not verified
+func isModelNotVerified(err error) bool {
+	var notVerified *ModelNotVerifiedError
+	return errors.As(err, &notVerified)
+}
+
+// getModelVerification returns the verification of the current generation of the model
+// on the node, nil if not reported yet
+func getModelVerification(model *neptunev1.Model, nodeName string) *neptunev1.ModelVerification {
+	for i := range model.Status.Verifications {
+		v := &model.Status.Verifications[i]
+		if v.NodeName == nodeName && v.ObservedGeneration == model.Generation {
+			return v
+		}
+	}
+	return nil
+}
+
+// isModelVerificationRequested returns whether the spec of the model has the digest, the size or
+// the signature to verify
+func isModelVerificationRequested(model *neptunev1.Model) bool {
+	return model.Spec.Digest != "" || model.Spec.Size > 0 || model.Spec.Signature != ""
+}
+
+// resolveModelCache returns the path of the model in the cache of LC on the node, which the worker
+// on the node loads the model from if verified by its digest or signature, or "" if the spec
+// requests no verification, or the LC requires none, and the worker loads the model from its url.
+// Otherwise a ModelNotVerifiedError is returned until the LC of the node has verified the current
+// generation of the model, so that a worker never starts with a model not verified.
+func resolveModelCache(model *neptunev1.Model, nodeName string) (string, error) {
+	if !isModelVerificationRequested(model) {
+		return "", nil
+	}
+
+	v := getModelVerification(model, nodeName)
+	if v == nil {
+		return "", &ModelNotVerifiedError{
+			Namespace: model.Namespace,
+			Name:      model.Name,
+			NodeName:  nodeName,
+			Message:   fmt.Sprintf("generation %d is not verified yet", model.Generation),
+		}
+	}
+	message := v.Message
+	if v.Verified {
+		message = checkModelVerification(model, v)
+	}
+	if message != "" {
+		return "", &ModelNotVerifiedError{
+			Namespace: model.Namespace,
+			Name:      model.Name,
+			NodeName:  nodeName,
+			Message:   message,
+		}
+	}
+	return v.CachePath, nil
+}
+
+// checkModelVerification double checks that the verification reported covers the digest and the
+// signature of the spec, and returns why not, "" if covered.
+// The signature is only trusted with the SignatureVerified condition, since a model signed is
+// never loaded unless its signature is verified, no matter whether the LC has trusted keys.
+func checkModelVerification(model *neptunev1.Model, v *neptunev1.ModelVerification) string {
+	if model.Spec.Digest != "" {
+		if !isModelConditionTrue(v, neptunev1.ModelCondIntegrityVerified) {
+			return "the integrity is not verified"
+		}
+		if v.Digest != model.Spec.Digest {
+			return fmt.Sprintf("the digest %s verified mismatches %s", v.Digest, model.Spec.Digest)
+		}
+	}
+	if model.Spec.Signature != "" && !isModelConditionTrue(v, neptunev1.ModelCondSignatureVerified) {
+		return "the signature is not verified"
+	}
+	return ""
+}
+
+// isModelConditionTrue returns whether the condition of the verification is true
+func isModelConditionTrue(v *neptunev1.ModelVerification, conditionType neptunev1.ModelConditionType) bool {
+	for _, c := range v.Conditions {
+		if c.Type == conditionType {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package globalmanager
+
+import (
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+)
+
+func TestResolveModelCache(t *testing.T) {
+	const digest = "sha256:0123"
+	integrityVerified := []neptunev1.ModelCondition{{Type: neptunev1.ModelCondIntegrityVerified, Status: v1.ConditionTrue}}
+	model := &neptunev1.Model{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "model1", Generation: 2},
+		Spec:       neptunev1.ModelSpec{Size: 100},
+		Status: neptunev1.ModelStatus{
+			Verifications: []neptunev1.ModelVerification{
+				{NodeName: "edge1", ObservedGeneration: 2, Verified: true, CachePath: "/var/lib/neptune/models/sha256/1"},
+				{NodeName: "edge2", ObservedGeneration: 2, Verified: true, Digest: digest, Conditions: integrityVerified,
+					CachePath: "/var/lib/neptune/models/sha256/0123"},
+				{NodeName: "edge3", ObservedGeneration: 2, Verified: false, Message: "digest mismatches"},
+				{NodeName: "edge4", ObservedGeneration: 1, Verified: true, CachePath: "/var/lib/neptune/models/sha256/0"},
+			},
+		},
+	}
+	withDigest := model.DeepCopy()
+	withDigest.Spec.Digest = digest
+	noVerification := model.DeepCopy()
+	noVerification.Spec = neptunev1.ModelSpec{}
+
+	cases := []struct {
+		name      string
+		model     *neptunev1.Model
+		nodeName  string
+		cachePath string
+		verified  bool
+	}{
+		{"size verified", model, "edge1", "/var/lib/neptune/models/sha256/1", true},
+		{"digest verified", withDigest, "edge2", "/var/lib/neptune/models/sha256/0123", true},
+		{"digest not verified", withDigest, "edge1", "", false},
+		{"verification failed", model, "edge3", "", false},
+		// the previous generation verified only
+		{"previous generation verified", model, "edge4", "", false},
+		{"not reported", model, "edge5", "", false},
+		// no verification requested, loaded from the url whatever reported
+		{"no verification requested", noVerification, "edge1", "", true},
+		{"no verification requested but failed", noVerification, "edge3", "", true},
+		{"no verification requested nor reported", noVerification, "edge5", "", true},
+	}
+	for _, c := range cases {
+		cachePath, err := resolveModelCache(c.model, c.nodeName)
+		if verified := err == nil; verified != c.verified {
+			t.Errorf("%s: expected verified %v, got error %v", c.name, c.verified, err)
+			continue
+		}
+		if err != nil && !isModelNotVerified(fmt.Errorf("failed to create pod: %w", err)) {
+			t.Errorf("%s: expected ModelNotVerifiedError, got %v", c.name, err)
+		}
+		if cachePath != c.cachePath {
+			t.Errorf("%s: expected cache path %q, got %q", c.name, c.cachePath, cachePath)
+		}
+	}
+}
+
+func TestResolveModelCacheRequiresConditions(t *testing.T) {
+	const digest = "sha256:0123"
+	verified := func(conditions ...neptunev1.ModelConditionType) neptunev1.ModelVerification {
+		v := neptunev1.ModelVerification{NodeName: "edge1", ObservedGeneration: 1, Verified: true, Digest: digest,
+			CachePath: "/var/lib/neptune/models/sha256/0123"}
+		for _, c := range conditions {
+			v.Conditions = append(v.Conditions, neptunev1.ModelCondition{Type: c, Status: v1.ConditionTrue})
+		}
+		return v
+	}
+
+	cases := []struct {
+		name         string
+		spec         neptunev1.ModelSpec
+		verification neptunev1.ModelVerification
+		verified     bool
+	}{
+		{"digest verified", neptunev1.ModelSpec{Digest: digest}, verified(neptunev1.ModelCondIntegrityVerified), true},
+		{"digest without condition", neptunev1.ModelSpec{Digest: digest}, verified(), false},
+		{"another digest verified", neptunev1.ModelSpec{Digest: "sha256:4567"}, verified(neptunev1.ModelCondIntegrityVerified), false},
+		{"signature verified", neptunev1.ModelSpec{Signature: "c2ln"},
+			verified(neptunev1.ModelCondIntegrityVerified, neptunev1.ModelCondSignatureVerified), true},
+		{"signature without condition", neptunev1.ModelSpec{Signature: "c2ln"}, verified(neptunev1.ModelCondIntegrityVerified), false},
+		{"no verification required", neptunev1.ModelSpec{}, verified(), true},
+	}
+	for _, c := range cases {
+		model := &neptunev1.Model{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "model1", Generation: 1},
+			Spec:       c.spec,
+			Status:     neptunev1.ModelStatus{Verifications: []neptunev1.ModelVerification{c.verification}},
+		}
+		if _, err := resolveModelCache(model, "edge1"); (err == nil) != c.verified {
+			t.Errorf("%s: expected verified %v, got error %v", c.name, c.verified, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package globalmanager
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kubeinformers "k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+	messageContext "github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/ws"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/utils"
+	"github.com/edgeai-neptune/neptune/pkg/messagelayer"
+)
+
+const (
+	// NodeTokenSecretType is the type of the secrets holding the bootstrap
+	// tokens of the nodes
+	NodeTokenSecretType v1.SecretType = "neptune.io/node-token"
+	// NodeTokenNodeNameLabel is the label of the node token secret,
+	// whose value is the name of the node the token belongs to
+	NodeTokenNodeNameLabel = "neptune.io/node-name"
+	// NodeTokenKey is the key of the token in the node token secret
+	NodeTokenKey = "token"
+)
+
+// NodeTokenAuthenticator authenticates LCs by the bootstrap tokens of their
+// nodes, which are stored as node token secrets created by admins.
+// A node is revoked by deleting its secrets, which also closes its connection.
+type NodeTokenAuthenticator struct {
+	secretLister corelisters.SecretLister
+}
+
+// AuthenticateNode checks the token against the node token secrets of the node.
+// More than one secret of a node is allowed for rotating the token.
+func (na *NodeTokenAuthenticator) AuthenticateNode(nodeName, token string) error {
+	if nodeName == "" || token == "" {
+		return fmt.Errorf("node name and token are required")
+	}
+
+	secrets, err := na.listNodeSecrets(nodeName)
+	if err != nil {
+		return err
+	}
+
+	for _, secret := range secrets {
+		expected := secret.Data[NodeTokenKey]
+		if len(expected) > 0 && subtle.ConstantTimeCompare(expected, []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown or revoked node %s", nodeName)
+}
+
+// NodeSigningKeys returns the keys signing the messages of the node, which
+// are derived from the tokens of the node.
+func (na *NodeTokenAuthenticator) NodeSigningKeys(nodeName string) ([][]byte, error) {
+	secrets, err := na.listNodeSecrets(nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys [][]byte
+	for _, secret := range secrets {
+		if token := secret.Data[NodeTokenKey]; len(token) > 0 {
+			keys = append(keys, messagelayer.DeriveSigningKey(string(token)))
+		}
+	}
+	return keys, nil
+}
+
+// listNodeSecrets lists the node token secrets of the node
+func (na *NodeTokenAuthenticator) listNodeSecrets(nodeName string) ([]*v1.Secret, error) {
+	selector := labels.SelectorFromSet(labels.Set{NodeTokenNodeNameLabel: nodeName})
+	secrets, err := na.secretLister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeSecrets []*v1.Secret
+	for _, secret := range secrets {
+		if secret.Type == NodeTokenSecretType {
+			nodeSecrets = append(nodeSecrets, secret)
+		}
+	}
+	return nodeSecrets, nil
+}
+
+// revokeSecret closes the connection of the node whose token secret is
+// deleted or changed, the LC needs to authenticate again.
+func (na *NodeTokenAuthenticator) revokeSecret(obj interface{}) {
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Warningf("couldn't get object from tombstone %+v", obj)
+			return
+		}
+		secret, ok = tombstone.Obj.(*v1.Secret)
+		if !ok {
+			klog.Warningf("tombstone contained object that is not a secret %+v", obj)
+			return
+		}
+	}
+
+	if nodeName := secret.Labels[NodeTokenNodeNameLabel]; nodeName != "" {
+		klog.Infof("token secret %s/%s of node %s revoked", secret.Namespace, secret.Name, nodeName)
+		messageContext.CloseNode(nodeName)
+	}
+}
+
+// updateSecret revokes the old token if it's changed
+func (na *NodeTokenAuthenticator) updateSecret(old, cur interface{}) {
+	oldSecret := old.(*v1.Secret)
+	curSecret := cur.(*v1.Secret)
+	if oldSecret.ResourceVersion == curSecret.ResourceVersion {
+		return
+	}
+
+	if oldSecret.Type != curSecret.Type ||
+		oldSecret.Labels[NodeTokenNodeNameLabel] != curSecret.Labels[NodeTokenNodeNameLabel] ||
+		subtle.ConstantTimeCompare(oldSecret.Data[NodeTokenKey], curSecret.Data[NodeTokenKey]) != 1 {
+		na.revokeSecret(oldSecret)
+	}
+}
+
+// NewNodeTokenAuthenticator creates a NodeTokenAuthenticator watching the
+// node token secrets, and waits for the secrets synced.
+func NewNodeTokenAuthenticator(cfg *config.ControllerConfig) (*NodeTokenAuthenticator, error) {
+	kubeClient, err := utils.KubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("create kube client failed with error: %w", err)
+	}
+
+	namespace := cfg.WebSocket.NodeAuth.Namespace
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, time.Second*30,
+		kubeinformers.WithNamespace(namespace),
+		kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = NodeTokenNodeNameLabel
+		}))
+	secretInformer := kubeInformerFactory.Core().V1().Secrets()
+
+	na := &NodeTokenAuthenticator{
+		secretLister: secretInformer.Lister(),
+	}
+
+	secretInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: na.updateSecret,
+		DeleteFunc: na.revokeSecret,
+	})
+
+	stopCh := messageContext.Done()
+	kubeInformerFactory.Start(stopCh)
+	if !cache.WaitForNamedCacheSync("node token", stopCh, secretInformer.Informer().HasSynced) {
+		return nil, fmt.Errorf("failed to sync node token secrets in namespace %q", namespace)
+	}
+
+	klog.Infof("authenticating nodes by the token secrets in namespace %q", namespace)
+	return na, nil
+}
@@ -0,0 +1,117 @@
+package globalmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+	messageContext "github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/ws"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/utils"
+)
+
+const (
+	// NodeConditionLCReady is the condition type on the core Node
+	// which reports the connection state of the node's LC
+	NodeConditionLCReady v1.NodeConditionType = "NeptuneLocalControllerReady"
+
+	nodeStatusUpdatePeriod = 15 * time.Second
+)
+
+// NodeStatusController publishes the connection states of LCs to the
+// conditions of the corresponding nodes, so that an offline edge can be told
+// apart from a stuck job.
+type NodeStatusController struct {
+	kubeClient kubernetes.Interface
+
+	cfg *config.ControllerConfig
+}
+
+// newLCReadyCondition converts the connection info to a node condition
+func newLCReadyCondition(info messageContext.NodeInfo) v1.NodeCondition {
+	cond := v1.NodeCondition{
+		Type:              NodeConditionLCReady,
+		LastHeartbeatTime: metav1.NewTime(info.LastHeartbeatTime),
+	}
+
+	if info.Connected {
+		cond.Status = v1.ConditionTrue
+		cond.Reason = "LocalControllerConnected"
+		cond.LastTransitionTime = metav1.NewTime(info.ConnectTime)
+		cond.Message = fmt.Sprintf("local controller(version: %s) connected at %s, %d pending messages",
+			info.Version, info.ConnectTime.Format(time.RFC3339), info.PendingMessages)
+	} else {
+		cond.Status = v1.ConditionFalse
+		cond.Reason = "LocalControllerDisconnected"
+		cond.LastTransitionTime = metav1.NewTime(info.DisconnectTime)
+		cond.Message = fmt.Sprintf("local controller(version: %s) disconnected at %s, %d pending messages",
+			info.Version, info.DisconnectTime.Format(time.RFC3339), info.PendingMessages)
+	}
+
+	return cond
+}
+
+// updateNodeStatus patches the LC condition of the node
+func (nc *NodeStatusController) updateNodeStatus(info messageContext.NodeInfo) error {
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []v1.NodeCondition{newLCReadyCondition(info)},
+		},
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = nc.kubeClient.CoreV1().Nodes().PatchStatus(context.TODO(), info.Name, data)
+	return err
+}
+
+// sync publishes all connection states of LCs
+func (nc *NodeStatusController) sync() {
+	for _, info := range messageContext.ListNodes() {
+		err := nc.updateNodeStatus(info)
+		if errors.IsNotFound(err) {
+			klog.V(4).Infof("node %s of the connected LC not found", info.Name)
+		} else if err != nil {
+			klog.Warningf("failed to update LC condition of node %s: %+v", info.Name, err)
+		}
+	}
+}
+
+// Start starts the controller
+func (nc *NodeStatusController) Start() error {
+	stopCh := messageContext.Done()
+
+	go wait.Until(nc.sync, nodeStatusUpdatePeriod, stopCh)
+	return nil
+}
+
+// GetName returns the name of the node status controller
+func (nc *NodeStatusController) GetName() string {
+	return "NodeStatusController"
+}
+
+// NewNodeStatusController creates a new NodeStatusController from config
+func NewNodeStatusController(cfg *config.ControllerConfig) (FeatureControllerI, error) {
+	kubeClient, err := utils.KubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("create kube client failed with error: %w", err)
+	}
+
+	nc := &NodeStatusController{
+		kubeClient: kubeClient,
+		cfg:        cfg,
+	}
+
+	return nc, nil
+}
@@ -0,0 +1,156 @@
+package globalmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+	websocket "github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/ws"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/utils"
+)
+
+const (
+	// OutboxNodeNameLabel is the label of the outbox configmap,
+	// whose value is the name of the node the messages are sent to
+	OutboxNodeNameLabel = "neptune.io/outbox-node"
+	// OutboxMessagesKey is the key of the messages in the outbox configmap
+	OutboxMessagesKey = "messages"
+
+	outboxConfigMapPrefix = "neptune-outbox-"
+	// maxOutboxDataSize is the max size of the messages in an outbox configmap,
+	// leaving room for the metadata within the 1 MiB limit of configmaps
+	maxOutboxDataSize = 1000 * 1024
+)
+
+// ConfigMapOutbox persists the pending messages of each node to a configmap,
+// which is deleted when nothing is pending for the node.
+type ConfigMapOutbox struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+}
+
+// Load returns the messages of all outbox configmaps
+func (o *ConfigMapOutbox) Load() (map[string][]model.Message, error) {
+	cms, err := o.kubeClient.CoreV1().ConfigMaps(o.namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: OutboxNodeNameLabel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nodeMsgs := make(map[string][]model.Message)
+	for _, cm := range cms.Items {
+		nodeName := cm.Labels[OutboxNodeNameLabel]
+		var msgs []model.Message
+		if err := json.Unmarshal([]byte(cm.Data[OutboxMessagesKey]), &msgs); err != nil {
+			return nil, fmt.Errorf("invalid outbox configmap %s/%s: %w", cm.Namespace, cm.Name, err)
+		}
+		nodeMsgs[nodeName] = append(nodeMsgs[nodeName], msgs...)
+	}
+	return nodeMsgs, nil
+}
+
+// fitOutboxMessages encodes the newest messages fitting in maxSize, and
+// returns the data and the number of the oldest messages dropped.
+// The messages are in order, and no data is returned if none fits.
+func fitOutboxMessages(msgs []model.Message, maxSize int) ([]byte, int, error) {
+	items := make([][]byte, len(msgs))
+	// the brackets of the array
+	size := 2
+	for i := range msgs {
+		item, err := json.Marshal(&msgs[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		items[i] = item
+		size += len(item)
+	}
+	if len(items) > 1 {
+		// the commas between the items
+		size += len(items) - 1
+	}
+
+	dropped := 0
+	for ; dropped < len(items) && size > maxSize; dropped++ {
+		size -= len(items[dropped])
+		if dropped < len(items)-1 {
+			size--
+		}
+	}
+	if dropped == len(items) {
+		return nil, dropped, nil
+	}
+
+	data := make([]byte, 0, size)
+	data = append(data, '[')
+	data = append(data, bytes.Join(items[dropped:], []byte{','})...)
+	data = append(data, ']')
+	return data, dropped, nil
+}
+
+// Save replaces the messages of the outbox configmap of the node, only the
+// newest messages fitting in the configmap are saved.
+func (o *ConfigMapOutbox) Save(nodeName string, msgs []model.Message) error {
+	client := o.kubeClient.CoreV1().ConfigMaps(o.namespace)
+	name := outboxConfigMapPrefix + nodeName
+
+	data, dropped, err := fitOutboxMessages(msgs, maxOutboxDataSize)
+	if err != nil {
+		return err
+	}
+	var overflow error
+	if dropped > 0 {
+		overflow = &websocket.OutboxOverflowError{NodeName: nodeName, Dropped: dropped}
+	}
+
+	if len(data) == 0 {
+		err = client.Delete(context.TODO(), name, metav1.DeleteOptions{})
+		if err == nil || errors.IsNotFound(err) {
+			return overflow
+		}
+		return err
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: o.namespace,
+			Labels: map[string]string{
+				OutboxNodeNameLabel: nodeName,
+			},
+		},
+		Data: map[string]string{
+			OutboxMessagesKey: string(data),
+		},
+	}
+
+	_, err = client.Update(context.TODO(), cm, metav1.UpdateOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(context.TODO(), cm, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	return overflow
+}
+
+// NewConfigMapOutbox creates a ConfigMapOutbox in the outbox namespace
+func NewConfigMapOutbox(cfg *config.ControllerConfig) (*ConfigMapOutbox, error) {
+	kubeClient, err := utils.KubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("create kube client failed with error: %w", err)
+	}
+
+	return &ConfigMapOutbox{
+		kubeClient: kubeClient,
+		namespace:  cfg.Outbox.Namespace,
+	}, nil
+}
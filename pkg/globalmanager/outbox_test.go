@@ -0,0 +1,70 @@
+package globalmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+)
+
+func TestFitOutboxMessages(t *testing.T) {
+	var msgs []model.Message
+	for i := 1; i <= 5; i++ {
+		msgs = append(msgs, model.Message{
+			MessageHeader: model.MessageHeader{
+				ResourceKind: "dataset",
+				ResourceName: fmt.Sprintf("d%d", i),
+				Sequence:     uint64(i),
+			},
+			Content: []byte("0123456789"),
+		})
+	}
+	all, err := json.Marshal(msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := json.Marshal(msgs[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		maxSize int
+		dropped int
+	}{
+		{"all fit", len(all), 0},
+		{"one byte short drops the oldest", len(all) - 1, 1},
+		{"only the newest fits", len(last), 4},
+		{"none fits", len(last) - 1, 5},
+	}
+	for _, c := range cases {
+		data, dropped, err := fitOutboxMessages(msgs, c.maxSize)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if dropped != c.dropped {
+			t.Errorf("%s: expected %d dropped, got %d", c.name, c.dropped, dropped)
+			continue
+		}
+		if len(data) > c.maxSize {
+			t.Errorf("%s: data size %d exceeds %d", c.name, len(data), c.maxSize)
+		}
+		if dropped == len(msgs) {
+			if data != nil {
+				t.Errorf("%s: expected no data, got %s", c.name, data)
+			}
+			continue
+		}
+
+		var kept []model.Message
+		if err := json.Unmarshal(data, &kept); err != nil {
+			t.Fatalf("%s: invalid data: %v", c.name, err)
+		}
+		if len(kept) != len(msgs)-dropped || kept[0].Sequence != uint64(dropped+1) {
+			t.Errorf("%s: expected the newest %d messages kept, got %d from sequence %d",
+				c.name, len(msgs)-dropped, len(kept), kept[0].Sequence)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package globalmanager
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+)
+
+// renderNodePortBase is the first node port allocated to the services rendered,
+// which is the beginning of the default node port range of kubernetes
+const renderNodePortBase = 30000
+
+// RenderNodeIP returns the placeholder of the ip of the node not given to Render
+func RenderNodeIP(nodeName string) string {
+	return fmt.Sprintf("<ip of node %s>", nodeName)
+}
+
+// RenderWorkerToken is the placeholder of the worker tokens rendered, which are issued when the workers created
+const RenderWorkerToken = "<worker token issued when created>"
+
+// renderRefs looks up the objects referenced by the workers in the objects to render,
+// and the nodes not given are the placeholders of no labels and the ips of RenderNodeIP
+type renderRefs struct {
+	models   map[string]*neptunev1.Model
+	datasets map[string]*neptunev1.Dataset
+	nodes    map[string]*v1.Node
+}
+
+func (r *renderRefs) getModel(namespace, name string) (*neptunev1.Model, error) {
+	if model, ok := r.models[namespace+"/"+name]; ok {
+		return model, nil
+	}
+	return nil, errors.NewNotFound(neptunev1.Resource("model"), name)
+}
+
+func (r *renderRefs) getDataset(namespace, name string) (*neptunev1.Dataset, error) {
+	if dataset, ok := r.datasets[namespace+"/"+name]; ok {
+		return dataset, nil
+	}
+	return nil, errors.NewNotFound(neptunev1.Resource("dataset"), name)
+}
+
+func (r *renderRefs) getNode(name string) (*v1.Node, error) {
+	if node, ok := r.nodes[name]; ok {
+		return node, nil
+	}
+	klog.Warningf("node %s is not given, it is rendered as the node of no labels and the ip %q", name, RenderNodeIP(name))
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+			{Type: v1.NodeInternalIP, Address: RenderNodeIP(name)},
+		}},
+	}
+	r.nodes[name] = node
+	return node, nil
+}
+
+// Render returns the pods, services and worker token secrets the controllers would create for the federated learning jobs
+// and the joint inference services in the objects, in the order of creation.
+// The models and datasets referenced are looked up in the objects, so are the nodes of the workers,
+// and the nodes not given have the placeholder ips of RenderNodeIP.
+// The names of the pods are rendered as their generateName, the node ports of the services are allocated
+// from renderNodePortBase, and the worker tokens are rendered as RenderWorkerToken, which are all
+// generated when created actually. The models not verified on the nodes of the workers are rendered as
+// loaded from their urls.
+func Render(cfg *config.ControllerConfig, objects []runtime.Object) ([]runtime.Object, error) {
+	var (
+		jobs     []*neptunev1.FederatedLearningJob
+		services []*neptunev1.JointInferenceService
+		refs     = &renderRefs{
+			models:   make(map[string]*neptunev1.Model),
+			datasets: make(map[string]*neptunev1.Dataset),
+			nodes:    make(map[string]*v1.Node),
+		}
+	)
+	for _, obj := range objects {
+		obj = obj.DeepCopyObject()
+		// the namespaced objects without namespace are in the default namespace, like kubectl
+		if meta, ok := obj.(metav1.Object); ok && meta.GetNamespace() == "" {
+			if _, isNode := obj.(*v1.Node); !isNode {
+				meta.SetNamespace(metav1.NamespaceDefault)
+			}
+		}
+
+		switch o := obj.(type) {
+		case *neptunev1.FederatedLearningJob:
+			jobs = append(jobs, o)
+		case *neptunev1.JointInferenceService:
+			services = append(services, o)
+		case *neptunev1.Model:
+			refs.models[o.Namespace+"/"+o.Name] = o
+		case *neptunev1.Dataset:
+			refs.datasets[o.Namespace+"/"+o.Name] = o
+		case *v1.Node:
+			refs.nodes[o.Name] = o
+		default:
+			return nil, fmt.Errorf("unsupported object %s to render", obj.GetObjectKind().GroupVersionKind())
+		}
+	}
+	if len(jobs) == 0 && len(services) == 0 {
+		return nil, fmt.Errorf("no federated learning job or joint inference service to render")
+	}
+
+	renderModelVerifications(jobs, services, refs.models)
+
+	// the objects created in order, with the node ports allocated like the API server
+	var rendered []runtime.Object
+	nextNodePort := int32(renderNodePortBase)
+	renderWorker := func(w *workerPod) {
+		if w.tokenSecret != nil {
+			w.tokenSecret.StringData = map[string]string{workerTokenSecretKey: RenderWorkerToken}
+			w.tokenSecret.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Secret"))
+			rendered = append(rendered, w.tokenSecret)
+		}
+		w.pod.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Pod"))
+		rendered = append(rendered, w.pod)
+	}
+	renderService := func(object CommonInterface, port int32, ip string) int32 {
+		service := NewKubernetesService(object, port, ip)
+		service.Spec.Ports[0].NodePort = nextNodePort
+		nextNodePort++
+		service.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Service"))
+		rendered = append(rendered, service)
+		return service.Spec.Ports[0].NodePort
+	}
+
+	lc := cfg.GetLC()
+	for _, job := range jobs {
+		job.SetGroupVersionKind(neptunev1.SchemeGroupVersion.WithKind("FederatedLearningJob"))
+		r, err := resolveFLJobRefs(refs, cfg, job)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render federated learning job %s/%s: %w", job.Namespace, job.Name, err)
+		}
+		renderWorker(newFLAggWorker(job, r))
+		aggServicePort := renderService(job, flAggPort, r.aggIP)
+		for i := range job.Spec.TrainingWorkers {
+			renderWorker(newFLTrainWorker(job, r, i, aggServicePort, lc))
+		}
+	}
+
+	for _, service := range services {
+		service.SetGroupVersionKind(jointServiceControllerKind)
+		r, err := resolveJointInferenceRefs(refs, cfg, service)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render joint inference service %s/%s: %w", service.Namespace, service.Name, err)
+		}
+		renderWorker(newJointInferenceCloudWorker(service, r))
+		bigServicePort := renderService(service, bigModelPort, r.cloudIP)
+		renderWorker(newJointInferenceEdgeWorker(service, r, bigServicePort, lc))
+	}
+	return rendered, nil
+}
+
+// renderModelVerifications adds the verifications of the models not reported on the nodes of the
+// workers using them, as if the LCs require no verification
+func renderModelVerifications(jobs []*neptunev1.FederatedLearningJob, services []*neptunev1.JointInferenceService,
+	models map[string]*neptunev1.Model) {
+	verify := func(namespace, name, nodeName string) {
+		model := models[namespace+"/"+name]
+		if model == nil || getModelVerification(model, nodeName) != nil {
+			return
+		}
+		klog.Warningf("model %s/%s is not verified on node %s, it's rendered as loaded from its url", namespace, name, nodeName)
+		model.Status.Verifications = append(model.Status.Verifications, neptunev1.ModelVerification{
+			NodeName:           nodeName,
+			ObservedGeneration: model.Generation,
+			Verified:           true,
+			Digest:             model.Spec.Digest,
+			Conditions: []neptunev1.ModelCondition{
+				{Type: neptunev1.ModelCondIntegrityVerified, Status: v1.ConditionTrue},
+				{Type: neptunev1.ModelCondSignatureVerified, Status: v1.ConditionTrue},
+			},
+		})
+	}
+	for _, job := range jobs {
+		for _, trainingWorker := range job.Spec.TrainingWorkers {
+			verify(job.Namespace, job.Spec.AggregationWorker.Model.Name, trainingWorker.NodeName)
+		}
+	}
+	for _, service := range services {
+		verify(service.Namespace, service.Spec.EdgeWorker.Model.Name, service.Spec.EdgeWorker.NodeName)
+	}
+}
@@ -0,0 +1,117 @@
+package globalmanager
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
+)
+
+func newRenderObjects() []runtime.Object {
+	workerSpec := neptunev1.CommonWorkerSpec{ScriptDir: "/code", ScriptBootFile: "main.py",
+		FrameworkType: "tensorflow", FrameworkVersion: "1.15"}
+	job := &neptunev1.FederatedLearningJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "job1"},
+		Spec: neptunev1.FLJobSpec{
+			AggregationWorker: neptunev1.AggregationWorker{
+				NodeName:   "cloud",
+				WorkerSpec: neptunev1.AggregationWorkerSpec{CommonWorkerSpec: workerSpec},
+			},
+			TrainingWorkers: []neptunev1.TrainingWorker{
+				{NodeName: "edge1", WorkerSpec: neptunev1.TrainingWorkerSpec{CommonWorkerSpec: workerSpec}},
+				{NodeName: "edge2", WorkerSpec: neptunev1.TrainingWorkerSpec{CommonWorkerSpec: workerSpec}},
+			},
+		},
+	}
+	job.Spec.AggregationWorker.Model.Name = "model1"
+	for i := range job.Spec.TrainingWorkers {
+		job.Spec.TrainingWorkers[i].Dataset.Name = "dataset1"
+	}
+	service := &neptunev1.JointInferenceService{
+		ObjectMeta: metav1.ObjectMeta{Name: "service1"},
+		Spec: neptunev1.JointInferenceServiceSpec{
+			CloudWorker: neptunev1.CloudWorker{Model: neptunev1.BigModel{Name: "model1"}, NodeName: "cloud", WorkerSpec: workerSpec},
+			EdgeWorker:  neptunev1.EdgeWorker{Model: neptunev1.SmallModel{Name: "model1"}, NodeName: "edge1", WorkerSpec: workerSpec},
+		},
+	}
+	return []runtime.Object{
+		job, service,
+		&neptunev1.Model{ObjectMeta: metav1.ObjectMeta{Name: "model1"}, Spec: neptunev1.ModelSpec{ModelURL: "/models/model.pb"}},
+		&neptunev1.Dataset{ObjectMeta: metav1.ObjectMeta{Name: "dataset1"}, Spec: neptunev1.DatasetSpec{URL: "/data/train.txt"}},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "edge1", Labels: map[string]string{v1.LabelArchStable: "arm64"}},
+			Status:     v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}}},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	cfg := &config.ControllerConfig{
+		ImageHub: map[string]string{
+			"tensorflow:1.15":       "tensorflow:1.15",
+			"tensorflow:1.15:arm64": "tensorflow:1.15-arm64",
+		},
+		// the key is never read, since the worker tokens are not issued by rendering
+		LC: config.LCConfig{Server: "http://localhost:9100", TokenKeyFile: "/nonexistent/key"},
+	}
+	objects := newRenderObjects()
+	rendered, err := Render(cfg, objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rendered exactly the same again
+	again, err := Render(cfg, objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rendered, again) {
+		t.Errorf("expected rendered exactly the same again")
+	}
+
+	var kinds, workerNames, nodePorts []string
+	images := make(map[string]string)
+	for _, obj := range rendered {
+		kinds = append(kinds, obj.GetObjectKind().GroupVersionKind().Kind)
+		switch o := obj.(type) {
+		case *v1.Secret:
+			if o.StringData[workerTokenSecretKey] != RenderWorkerToken || len(o.Data) != 0 {
+				t.Errorf("expected the worker token of secret %s redacted, got %v %v", o.Name, o.StringData, o.Data)
+			}
+		case *v1.Pod:
+			if o.Name != "" {
+				t.Errorf("expected the pod name generated when created, got %s", o.Name)
+			}
+			for _, env := range o.Spec.Containers[0].Env {
+				switch env.Name {
+				case workerNameEnv:
+					workerNames = append(workerNames, env.Value)
+					images[env.Value] = o.Spec.Containers[0].Image
+				case "AGG_PORT", "BIG_MODEL_PORT":
+					nodePorts = append(nodePorts, env.Value)
+				}
+			}
+		}
+	}
+
+	expectedKinds := []string{"Pod", "Service", "Secret", "Pod", "Secret", "Pod", "Pod", "Service", "Secret", "Pod"}
+	if !reflect.DeepEqual(kinds, expectedKinds) {
+		t.Errorf("expected %v rendered, got %v", expectedKinds, kinds)
+	}
+	expectedWorkerNames := []string{"job1-aggworker", "job1-trainworker-0", "job1-trainworker-1", "service1-cloudworker", "service1-edgeworker"}
+	if !reflect.DeepEqual(workerNames, expectedWorkerNames) {
+		t.Errorf("expected workers %v, got %v", expectedWorkerNames, workerNames)
+	}
+	if expected := []string{"30000", "30000", "30001"}; !reflect.DeepEqual(nodePorts, expected) {
+		t.Errorf("expected node ports %v, got %v", expected, nodePorts)
+	}
+	// the nodes not given are rendered without the architecture
+	if images["job1-trainworker-0"] != "tensorflow:1.15-arm64" || images["job1-trainworker-1"] != "tensorflow:1.15" {
+		t.Errorf("unexpected base images %v", images)
+	}
+}
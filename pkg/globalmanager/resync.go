@@ -0,0 +1,187 @@
+package globalmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	neptunev1listers "github.com/edgeai-neptune/neptune/pkg/client/listers/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+)
+
+// resyncKey returns the key of a resync resource
+func resyncKey(kind, namespace, name string) string {
+	return strings.Join([]string{kind, namespace, name}, "/")
+}
+
+// newResyncResource creates a resync resource of the object, the object is
+// omitted when the node holds the same resource version.
+func newResyncResource(obj CommonInterface, held map[string]string) (model.ResyncResource, error) {
+	kind := strings.ToLower(obj.GroupVersionKind().Kind)
+	r := model.ResyncResource{
+		Kind:            kind,
+		Namespace:       obj.GetNamespace(),
+		Name:            obj.GetName(),
+		ResourceVersion: obj.GetResourceVersion(),
+	}
+
+	if version, ok := held[resyncKey(kind, r.Namespace, r.Name)]; ok && version == r.ResourceVersion {
+		return r, nil
+	}
+
+	payload, err := json.Marshal(obj)
+	if err != nil {
+		return r, err
+	}
+	r.Object = payload
+	return r, nil
+}
+
+// listNodeResources lists the authoritative datasets, models, federated
+// learning jobs and joint inference services bound to the node
+func (uc *UpstreamController) listNodeResources(nodeName string) ([]CommonInterface, error) {
+	ctx := context.TODO()
+	namespace := uc.cfg.Namespace
+	var objects []CommonInterface
+
+	// namespace/name => model
+	models := make(map[string]bool)
+	addModel := func(namespace, name string) {
+		if name != "" {
+			models[namespace+"/"+name] = true
+		}
+	}
+
+	datasets, err := uc.client.Datasets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list datasets: %w", err)
+	}
+	for i := range datasets.Items {
+		dataset := &datasets.Items[i]
+		if dataset.Spec.NodeName != nodeName {
+			continue
+		}
+		dataset.SetGroupVersionKind(neptunev1.SchemeGroupVersion.WithKind("Dataset"))
+		objects = append(objects, dataset)
+	}
+
+	services, err := uc.client.JointInferenceServices(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list joint inference services: %w", err)
+	}
+	for i := range services.Items {
+		service := &services.Items[i]
+		if service.Spec.EdgeWorker.NodeName != nodeName {
+			continue
+		}
+		service.SetGroupVersionKind(neptunev1.SchemeGroupVersion.WithKind("JointInferenceService"))
+		objects = append(objects, service)
+		addModel(service.Namespace, service.Spec.EdgeWorker.Model.Name)
+	}
+
+	jobs, err := uc.client.FederatedLearningJobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list federated learning jobs: %w", err)
+	}
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		for _, trainingWorker := range job.Spec.TrainingWorkers {
+			if trainingWorker.NodeName == nodeName {
+				job.SetGroupVersionKind(neptunev1.SchemeGroupVersion.WithKind("FederatedLearningJob"))
+				objects = append(objects, job)
+				addModel(job.Namespace, job.Spec.AggregationWorker.Model.Name)
+				break
+			}
+		}
+	}
+
+	for key := range models {
+		parts := strings.SplitN(key, "/", 2)
+		modelNamespace, modelName := parts[0], parts[1]
+		m, err := uc.client.Models(modelNamespace).Get(ctx, modelName, metav1.GetOptions{})
+		if err != nil {
+			klog.Warningf("failed to get model %s for resyncing node %s: %+v", key, nodeName, err)
+			continue
+		}
+		m.SetGroupVersionKind(neptunev1.SchemeGroupVersion.WithKind("Model"))
+		objects = append(objects, m)
+	}
+
+	return objects, nil
+}
+
+// getModelBoundNodes returns the nodes which the model is bound to, i.e. the
+// edge worker nodes of the joint inference services and the training worker
+// nodes of the federated learning jobs using the model
+func getModelBoundNodes(serviceLister neptunev1listers.JointInferenceServiceLister,
+	jobLister neptunev1listers.FederatedLearningJobLister, namespace, name string) ([]string, error) {
+	nodeset := make(map[string]bool)
+
+	services, err := serviceLister.JointInferenceServices(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list joint inference services: %w", err)
+	}
+	for _, service := range services {
+		if service.Spec.EdgeWorker.Model.Name == name && service.Spec.EdgeWorker.NodeName != "" {
+			nodeset[service.Spec.EdgeWorker.NodeName] = true
+		}
+	}
+
+	jobs, err := jobLister.FederatedLearningJobs(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list federated learning jobs: %w", err)
+	}
+	for _, job := range jobs {
+		if job.Spec.AggregationWorker.Model.Name != name {
+			continue
+		}
+		for _, trainingWorker := range job.Spec.TrainingWorkers {
+			if trainingWorker.NodeName != "" {
+				nodeset[trainingWorker.NodeName] = true
+			}
+		}
+	}
+
+	nodes := make([]string, 0, len(nodeset))
+	for node := range nodeset {
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// resyncNode answers the resync request of the node with the authoritative
+// resources bound to it, so that LC converges after reconnecting.
+func (uc *UpstreamController) resyncNode(nodeName string, content []byte) error {
+	var heldResources []model.ResyncResource
+	if err := json.Unmarshal(content, &heldResources); err != nil {
+		return fmt.Errorf("unable to unmarshal resync request from node %s: %w", nodeName, err)
+	}
+
+	held := make(map[string]string)
+	for _, r := range heldResources {
+		held[resyncKey(r.Kind, r.Namespace, r.Name)] = r.ResourceVersion
+	}
+
+	objects, err := uc.listNodeResources(nodeName)
+	if err != nil {
+		return err
+	}
+
+	resources := make([]model.ResyncResource, 0, len(objects))
+	for _, obj := range objects {
+		r, err := newResyncResource(obj, held)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, r)
+	}
+
+	klog.Infof("resync node %s: %d resources held, %d resources bound", nodeName, len(heldResources), len(resources))
+	return uc.messageLayer.SendResyncResources(nodeName, resources)
+}
@@ -1,8 +1,11 @@
 package globalmanager
 
 import (
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/edgeai-neptune/neptune/pkg/util"
 )
 
 // ContainerPara describes initial values need by creating a pod
@@ -15,6 +18,13 @@ type ContainerPara struct {
 	frameVersion    string
 	scriptBootFile  string
 	nodeName        string
+	// tokenSecret is the secret of the worker token created with the pod, and tokenClaims
+	// are the claims of the token, nil if disabled
+	tokenSecret *v1.Secret
+	tokenClaims *util.WorkerTokenClaims
+	// modelCachePath is the path of the model verified in the cache of LC on the node,
+	// mounted read-only at the same path under dataPrefix, empty if not verified
+	modelCachePath string
 }
 
 // CommonInterface describes the commom interface of CRs
@@ -4,14 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	kubeinformers "k8s.io/client-go/informers"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 
 	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned"
+	neptunescheme "github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned/scheme"
 	clientset "github.com/edgeai-neptune/neptune/pkg/client/clientset/versioned/typed/neptune/v1alpha1"
+	informers "github.com/edgeai-neptune/neptune/pkg/client/informers/externalversions"
+	neptunev1listers "github.com/edgeai-neptune/neptune/pkg/client/listers/neptune/v1alpha1"
 	"github.com/edgeai-neptune/neptune/pkg/globalmanager/config"
 	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer/model"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/metrics"
 	"github.com/edgeai-neptune/neptune/pkg/globalmanager/utils"
 )
 
@@ -21,9 +36,21 @@ type updateHandler func(namespace, name, operation string, content []byte) error
 
 // UpstreamController subscribes the updates from edge and syncs to k8s api server
 type UpstreamController struct {
+	cfg            *config.ControllerConfig
 	client         *clientset.NeptuneV1alpha1Client
 	messageLayer   messagelayer.MessageLayer
 	updateHandlers map[string]updateHandler
+	recorder       record.EventRecorder
+
+	// the listers authorizing the updates without requesting the api server
+	informerFactory     informers.SharedInformerFactory
+	kubeInformerFactory kubeinformers.SharedInformerFactory
+	informersSynced     []cache.InformerSynced
+	datasetLister       neptunev1listers.DatasetLister
+	serviceLister       neptunev1listers.JointInferenceServiceLister
+	jobLister           neptunev1listers.FederatedLearningJobLister
+	// podLister lists the pods of the workers
+	podLister corelisters.PodLister
 }
 
 const upstreamStatusUpdateRetries = 3
@@ -173,7 +200,102 @@ func (uc *UpstreamController) updateJointInferenceFromEdge(name, namespace, oper
 	return nil
 }
 
-func (uc *UpstreamController) updateModelMetrics(name, namespace string, metrics []neptunev1.Metric) error {
+// mergeModelConditions keeps the transition time of the conditions not changed since the previous
+// verification, and returns the changed ones
+func mergeModelConditions(previous *neptunev1.ModelVerification, conditions []neptunev1.ModelCondition) []neptunev1.ModelCondition {
+	var changed []neptunev1.ModelCondition
+	for i := range conditions {
+		c := &conditions[i]
+		transited := true
+		if previous != nil {
+			for _, p := range previous.Conditions {
+				if p.Type == c.Type && p.Status == c.Status {
+					c.LastTransitionTime = p.LastTransitionTime
+					transited = false
+					break
+				}
+			}
+		}
+		if transited {
+			changed = append(changed, *c)
+		}
+	}
+	return changed
+}
+
+// recordModelConditions records the changed conditions of the model verification as the events of the model
+func (uc *UpstreamController) recordModelConditions(model *neptunev1.Model, nodeName string, conditions []neptunev1.ModelCondition) {
+	for _, c := range conditions {
+		eventType := v1.EventTypeNormal
+		if c.Status != v1.ConditionTrue {
+			eventType = v1.EventTypeWarning
+		}
+		reason := c.Reason
+		if reason == "" {
+			reason = string(c.Type)
+		}
+		uc.recorder.Eventf(model, eventType, reason, "%s is %s on node %s: %s", c.Type, c.Status, nodeName, c.Message)
+	}
+}
+
+// modelVerificationHandler returns the handler of the model verifications reported by the LC of nodeName
+func (uc *UpstreamController) modelVerificationHandler(nodeName string) updateHandler {
+	return func(name, namespace, operation string, content []byte) error {
+		return uc.updateModelFromEdge(nodeName, name, namespace, operation, content)
+	}
+}
+
+// updateModelFromEdge records the verification of the model on the node,
+// and the changes of its conditions as the events of the model
+func (uc *UpstreamController) updateModelFromEdge(nodeName, name, namespace, operation string, content []byte) error {
+	err := checkUpstreamOpeation(operation)
+	if err != nil {
+		return err
+	}
+
+	var verification neptunev1.ModelVerification
+	if err = json.Unmarshal(content, &verification); err != nil {
+		return newUnmarshalError(namespace, name, operation, content)
+	}
+	// the node is the one sending the update rather than claimed in the content
+	verification.NodeName = nodeName
+
+	var model *neptunev1.Model
+	var changed []neptunev1.ModelCondition
+	client := uc.client.Models(namespace)
+	err = retryUpdateStatus(name, namespace, func() error {
+		var err error
+		model, err = client.Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		current := verification.DeepCopy()
+		var previous *neptunev1.ModelVerification
+		verifications := model.Status.Verifications[:0]
+		for _, v := range model.Status.Verifications {
+			if v.NodeName != nodeName {
+				verifications = append(verifications, v)
+			} else {
+				// copy it since the verifications are filtered in place
+				previous = v.DeepCopy()
+			}
+		}
+		changed = mergeModelConditions(previous, current.Conditions)
+		model.Status.Verifications = append(verifications, *current)
+		_, err = client.UpdateStatus(context.TODO(), model, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	uc.recordModelConditions(model, nodeName, changed)
+	return nil
+}
+
+// updateModelMetrics updates the metrics of the model, and appends them to the metrics history
+func (uc *UpstreamController) updateModelMetrics(name, namespace string, metrics []neptunev1.Metric, round int32) error {
 	client := uc.client.Models(namespace)
 
 	return retryUpdateStatus(name, namespace, (func() error {
@@ -185,12 +307,23 @@ func (uc *UpstreamController) updateModelMetrics(name, namespace string, metrics
 		now := metav1.Now()
 		model.Status.UpdateTime = &now
 		model.Status.Metrics = metrics
+		model.Status.MetricsHistory = appendModelMetricsHistory(model.Status.MetricsHistory,
+			neptunev1.ModelMetricsRecord{UpdateTime: now, Round: round, Metrics: metrics})
 		_, err = client.UpdateStatus(context.TODO(), model, metav1.UpdateOptions{})
 		return err
 	}))
 }
 
-func (uc *UpstreamController) updateModelMetricsByFederatedName(name, namespace string, metrics []neptunev1.Metric) error {
+// appendModelMetricsHistory appends the record to the history, and keeps the latest MaxModelMetricsHistory records
+func appendModelMetricsHistory(history []neptunev1.ModelMetricsRecord, record neptunev1.ModelMetricsRecord) []neptunev1.ModelMetricsRecord {
+	history = append(history, record)
+	if len(history) > neptunev1.MaxModelMetricsHistory {
+		history = history[len(history)-neptunev1.MaxModelMetricsHistory:]
+	}
+	return history
+}
+
+func (uc *UpstreamController) updateModelMetricsByFederatedName(name, namespace string, metrics []neptunev1.Metric, round int32) error {
 	client := uc.client.FederatedLearningJobs(namespace)
 	var err error
 	federatedLearningJob, err := client.Get(context.TODO(), name, metav1.GetOptions{})
@@ -199,10 +332,12 @@ func (uc *UpstreamController) updateModelMetricsByFederatedName(name, namespace
 		return err
 	}
 	modelName := federatedLearningJob.Spec.AggregationWorker.Model.Name
-	return uc.updateModelMetrics(modelName, namespace, metrics)
+	return uc.updateModelMetrics(modelName, namespace, metrics, round)
 }
 
-func (uc *UpstreamController) appendFederatedLearningJobStatusCondition(name, namespace string, cond neptunev1.FLJobCondition) error {
+// updateFederatedLearningJobRound appends the training condition of the round to the job,
+// and updates the current round of the job
+func (uc *UpstreamController) updateFederatedLearningJobRound(name, namespace string, round int32, cond neptunev1.FLJobCondition) error {
 	client := uc.client.FederatedLearningJobs(namespace)
 
 	return retryUpdateStatus(name, namespace, (func() error {
@@ -211,6 +346,9 @@ func (uc *UpstreamController) appendFederatedLearningJobStatusCondition(name, na
 			return err
 		}
 		job.Status.Conditions = append(job.Status.Conditions, cond)
+		job.Status.CurrentRound = round
+		now := metav1.Now()
+		job.Status.RoundUpdateTime = &now
 		_, err = client.UpdateStatus(context.TODO(), job, metav1.UpdateOptions{})
 		return err
 	}))
@@ -251,13 +389,18 @@ func (uc *UpstreamController) updateFederatedLearningJobFromEdge(name, namespace
 	output := status.Output
 
 	if output != nil {
+		var round int32
+		if output.JobInfo != nil {
+			round = int32(output.JobInfo.CurrentRound)
+		}
+
 		// Update the model's metrics
 		if len(output.Models) > 0 {
 			// only one model
 			model := output.Models[0]
 			metrics := convertToMetrics(model.Metrics)
 			if len(metrics) > 0 {
-				uc.updateModelMetricsByFederatedName(name, namespace, metrics)
+				uc.updateModelMetricsByFederatedName(name, namespace, metrics, round)
 			}
 		}
 
@@ -269,13 +412,158 @@ func (uc *UpstreamController) updateFederatedLearningJobFromEdge(name, namespace
 			reason := "DoTraining"
 			message := fmt.Sprintf("Round %v reaches at %s", jobInfo.CurrentRound, jobInfo.UpdateTime)
 			cond := NewFLJobCondition(neptunev1.FLJobCondTraining, reason, message)
-			uc.appendFederatedLearningJobStatusCondition(name, namespace, cond)
+			uc.updateFederatedLearningJobRound(name, namespace, round, cond)
 		}
 	}
 
 	return nil
 }
 
+// setWorkerHealth updates the health of the worker in workers, or appends it if not found,
+// and prunes the other workers not in live, i.e. whose pods no longer exist
+func setWorkerHealth(workers []neptunev1.WorkerHealth, health neptunev1.WorkerHealth, live sets.String) []neptunev1.WorkerHealth {
+	result := make([]neptunev1.WorkerHealth, 0, len(workers)+1)
+	found := false
+	for _, w := range workers {
+		switch {
+		case w.Name == health.Name:
+			result = append(result, health)
+			found = true
+		case live.Has(w.Name):
+			result = append(result, w)
+		}
+	}
+	if !found {
+		result = append(result, health)
+	}
+	return result
+}
+
+// liveWorkerNames returns the names of the workers whose pods of the object exist
+func (uc *UpstreamController) liveWorkerNames(object CommonInterface) (sets.String, error) {
+	selector := labels.SelectorFromSet(GenerateLabels(object))
+	pods, err := uc.podLister.Pods(object.GetNamespace()).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return podWorkerNames(pods), nil
+}
+
+// updateJointInferenceWorkerHealth updates the health of the edge worker of the joint inference service
+func (uc *UpstreamController) updateJointInferenceWorkerHealth(name, namespace string, health neptunev1.WorkerHealth) error {
+	client := uc.client.JointInferenceServices(namespace)
+
+	return retryUpdateStatus(name, namespace, func() error {
+		service, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		service.SetGroupVersionKind(jointServiceControllerKind)
+		live, err := uc.liveWorkerNames(service)
+		if err != nil {
+			return err
+		}
+		service.Status.Workers = setWorkerHealth(service.Status.Workers, health, live)
+		_, err = client.UpdateStatus(context.TODO(), service, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// updateFederatedLearningJobWorkerHealth updates the health of the training worker of the federated learning job
+func (uc *UpstreamController) updateFederatedLearningJobWorkerHealth(name, namespace string, health neptunev1.WorkerHealth) error {
+	client := uc.client.FederatedLearningJobs(namespace)
+
+	return retryUpdateStatus(name, namespace, func() error {
+		job, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		job.SetGroupVersionKind(neptunev1.SchemeGroupVersion.WithKind("FederatedLearningJob"))
+		live, err := uc.liveWorkerNames(job)
+		if err != nil {
+			return err
+		}
+		job.Status.Workers = setWorkerHealth(job.Status.Workers, health, live)
+		_, err = client.UpdateStatus(context.TODO(), job, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// workerHealthHandler returns the handler of the worker liveness of the resource kind
+// reported by the LC of nodeName, false if the kind has no workers
+func (uc *UpstreamController) workerHealthHandler(kind, nodeName string) (updateHandler, bool) {
+	var update func(name, namespace string, health neptunev1.WorkerHealth) error
+	switch kind {
+	case "jointinferenceservice":
+		update = uc.updateJointInferenceWorkerHealth
+	case "federatedlearningjob":
+		update = uc.updateFederatedLearningJobWorkerHealth
+	default:
+		return nil, false
+	}
+
+	return func(name, namespace, operation string, content []byte) error {
+		var health neptunev1.WorkerHealth
+		if err := json.Unmarshal(content, &health); err != nil || health.Name == "" {
+			return newUnmarshalError(namespace, name, operation, content)
+		}
+		// the node is the one sending the update rather than claimed in the content
+		health.NodeName = nodeName
+		return update(name, namespace, health)
+	}, true
+}
+
+// getBoundNodes returns the nodes which the resource is bound to, i.e. the
+// nodes allowed to update the resource
+func (uc *UpstreamController) getBoundNodes(kind, namespace, name string) ([]string, error) {
+	switch kind {
+	case "dataset":
+		dataset, err := uc.datasetLister.Datasets(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return []string{dataset.Spec.NodeName}, nil
+
+	case "jointinferenceservice":
+		service, err := uc.serviceLister.JointInferenceServices(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return []string{service.Spec.EdgeWorker.NodeName}, nil
+
+	case "federatedlearningjob":
+		job, err := uc.jobLister.FederatedLearningJobs(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		var nodes []string
+		for _, trainingWorker := range job.Spec.TrainingWorkers {
+			nodes = append(nodes, trainingWorker.NodeName)
+		}
+		return nodes, nil
+
+	case "model":
+		return getModelBoundNodes(uc.serviceLister, uc.jobLister, namespace, name)
+	}
+	return nil, fmt.Errorf("unknown resource kind %s", kind)
+}
+
+// authorizeUpdate checks that the update is sent by a node which the
+// resource is bound to, so that a node can't update others' resources.
+func (uc *UpstreamController) authorizeUpdate(update *messagelayer.ResourceUpdateSpec) (bool, error) {
+	nodes, err := uc.getBoundNodes(update.Kind, update.Namespace, update.Name)
+	if err != nil {
+		return false, err
+	}
+
+	for _, node := range nodes {
+		if node != "" && node == update.NodeName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // syncEdgeUpdate receives the updates from edge and syncs these to k8s.
 func (uc *UpstreamController) syncEdgeUpdate() {
 	for {
@@ -297,14 +585,39 @@ func (uc *UpstreamController) syncEdgeUpdate() {
 		name := update.Name
 		operation := update.Operation
 
+		if kind == model.NodeKind && operation == model.ResyncOperation {
+			if err := uc.resyncNode(update.NodeName, update.Content); err != nil {
+				klog.Errorf("Error to resync node %s: %+v", update.NodeName, err)
+			}
+			continue
+		}
+
 		handler, ok := uc.updateHandlers[kind]
+		switch {
+		case operation == model.WorkerHealthOperation:
+			handler, ok = uc.workerHealthHandler(kind, update.NodeName)
+		case kind == "model":
+			// the model updates from edge are only the verifications of the nodes
+			handler, ok = uc.modelVerificationHandler(update.NodeName), true
+		}
 		if ok {
-			err := handler(name, namespace, operation, update.Content)
+			authorized, err := uc.authorizeUpdate(update)
+			if err != nil {
+				klog.Errorf("Error to authorize %s %s/%s operation(%s) from node %s: %+v", kind, namespace, name, operation, update.NodeName, err)
+				continue
+			}
+			if !authorized {
+				metrics.UpstreamUpdatesRejected.WithLabelValues(kind, update.NodeName).Inc()
+				klog.Warningf("Reject %s %s/%s operation(%s) from node %s which the resource is not bound to", kind, namespace, name, operation, update.NodeName)
+				continue
+			}
+
+			err = handler(name, namespace, operation, update.Content)
 			if err != nil {
 				klog.Errorf("Error to handle %s %s/%s operation(%s): %+v", kind, namespace, name, operation, err)
 			}
 		} else {
-			klog.Warningf("No handler for resource kind %s", kind)
+			klog.Warningf("No handler for resource kind %s operation(%s)", kind, operation)
 		}
 	}
 }
@@ -313,7 +626,15 @@ func (uc *UpstreamController) syncEdgeUpdate() {
 func (uc *UpstreamController) Start() error {
 	klog.Info("Start the neptune upstream controller")
 
-	go uc.syncEdgeUpdate()
+	stopCh := uc.messageLayer.Done()
+	uc.informerFactory.Start(stopCh)
+	uc.kubeInformerFactory.Start(stopCh)
+	go func() {
+		if !cache.WaitForNamedCacheSync("upstream", stopCh, uc.informersSynced...) {
+			return
+		}
+		uc.syncEdgeUpdate()
+	}()
 	return nil
 }
 
@@ -328,12 +649,47 @@ func NewUpstreamController(cfg *config.ControllerConfig) (FeatureControllerI, er
 	if err != nil {
 		return nil, fmt.Errorf("create crd client failed with error: %w", err)
 	}
+	kubeClient, err := utils.KubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("create kube client failed with error: %w", err)
+	}
+
+	kubecfg, _ := utils.KubeConfig()
+	crdclient, err := versioned.NewForConfig(kubecfg)
+	if err != nil {
+		return nil, fmt.Errorf("create crd clientset failed with error: %w", err)
+	}
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(crdclient, time.Second*30, informers.WithNamespace(cfg.Namespace))
+	datasetInformer := informerFactory.Neptune().V1alpha1().Datasets()
+	serviceInformer := informerFactory.Neptune().V1alpha1().JointInferenceServices()
+	jobInformer := informerFactory.Neptune().V1alpha1().FederatedLearningJobs()
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, time.Second*30, kubeinformers.WithNamespace(cfg.Namespace))
+	podInformer := kubeInformerFactory.Core().V1().Pods()
+
 	uc := &UpstreamController{
-		client:       client,
-		messageLayer: messagelayer.NewContextMessageLayer(),
+		cfg:                 cfg,
+		client:              client,
+		messageLayer:        messagelayer.NewContextMessageLayer(),
+		recorder:            eventBroadcaster.NewRecorder(neptunescheme.Scheme, v1.EventSource{Component: "upstream-controller"}),
+		informerFactory:     informerFactory,
+		kubeInformerFactory: kubeInformerFactory,
+		informersSynced: []cache.InformerSynced{
+			datasetInformer.Informer().HasSynced,
+			serviceInformer.Informer().HasSynced,
+			jobInformer.Informer().HasSynced,
+			podInformer.Informer().HasSynced,
+		},
+		datasetLister: datasetInformer.Lister(),
+		serviceLister: serviceInformer.Lister(),
+		jobLister:     jobInformer.Lister(),
+		podLister:     podInformer.Lister(),
 	}
 
-	// NOTE: current no direct model update from edge,
+	// NOTE: current no direct model update from edge except the verifications,
 	// model update will be triggered by the corresponding training feature
 	uc.updateHandlers = map[string]updateHandler{
 		"dataset":               uc.updateDatasetFromEdge,
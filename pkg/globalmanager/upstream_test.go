@@ -0,0 +1,108 @@
+package globalmanager
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+
+	neptunev1 "github.com/edgeai-neptune/neptune/pkg/apis/neptune/v1alpha1"
+	neptunev1listers "github.com/edgeai-neptune/neptune/pkg/client/listers/neptune/v1alpha1"
+	"github.com/edgeai-neptune/neptune/pkg/globalmanager/messagelayer"
+)
+
+func TestSetWorkerHealth(t *testing.T) {
+	workers := []neptunev1.WorkerHealth{
+		{Name: "worker1", Healthy: true},
+		{Name: "worker2", Healthy: true},
+		{Name: "worker3", Healthy: false},
+	}
+
+	// worker2 is updated, worker3 whose pod is deleted is pruned
+	workers = setWorkerHealth(workers, neptunev1.WorkerHealth{Name: "worker2", Healthy: false},
+		sets.NewString("worker1", "worker2"))
+	expected := []neptunev1.WorkerHealth{
+		{Name: "worker1", Healthy: true},
+		{Name: "worker2", Healthy: false},
+	}
+	if !reflect.DeepEqual(workers, expected) {
+		t.Fatalf("expected workers %+v, got %+v", expected, workers)
+	}
+
+	// the reporting worker is appended even if its pod is not listed yet
+	workers = setWorkerHealth(workers, neptunev1.WorkerHealth{Name: "worker4", Healthy: true},
+		sets.NewString("worker1", "worker2"))
+	if len(workers) != 3 || workers[2].Name != "worker4" {
+		t.Fatalf("expected worker4 appended, got %+v", workers)
+	}
+}
+
+// newTestIndexer returns an indexer holding the objects
+func newTestIndexer(t *testing.T, objects ...interface{}) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, obj := range objects {
+		if err := indexer.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return indexer
+}
+
+func TestAuthorizeUpdate(t *testing.T) {
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: "default", Name: name}
+	}
+	dataset := &neptunev1.Dataset{ObjectMeta: objectMeta("dataset1"), Spec: neptunev1.DatasetSpec{NodeName: "edge1"}}
+	service := &neptunev1.JointInferenceService{ObjectMeta: objectMeta("service1"), Spec: neptunev1.JointInferenceServiceSpec{
+		EdgeWorker: neptunev1.EdgeWorker{Model: neptunev1.SmallModel{Name: "model1"}, NodeName: "edge1"},
+	}}
+	job := &neptunev1.FederatedLearningJob{ObjectMeta: objectMeta("job1"), Spec: neptunev1.FLJobSpec{
+		TrainingWorkers: []neptunev1.TrainingWorker{{NodeName: "edge1"}, {NodeName: "edge2"}},
+	}}
+	job.Spec.AggregationWorker.Model.Name = "model2"
+
+	uc := &UpstreamController{
+		datasetLister: neptunev1listers.NewDatasetLister(newTestIndexer(t, dataset)),
+		serviceLister: neptunev1listers.NewJointInferenceServiceLister(newTestIndexer(t, service)),
+		jobLister:     neptunev1listers.NewFederatedLearningJobLister(newTestIndexer(t, job)),
+	}
+
+	cases := []struct {
+		kind, name, nodeName string
+		authorized           bool
+	}{
+		{"dataset", "dataset1", "edge1", true},
+		{"dataset", "dataset1", "edge2", false},
+		{"jointinferenceservice", "service1", "edge1", true},
+		{"jointinferenceservice", "service1", "edge2", false},
+		{"federatedlearningjob", "job1", "edge2", true},
+		{"federatedlearningjob", "job1", "edge3", false},
+		{"model", "model1", "edge1", true},
+		{"model", "model1", "edge2", false},
+		{"model", "model2", "edge2", true},
+		{"model", "model3", "edge1", false},
+		{"dataset", "dataset1", "", false},
+	}
+	for _, c := range cases {
+		authorized, err := uc.authorizeUpdate(&messagelayer.ResourceUpdateSpec{
+			NodeName: c.nodeName, Kind: c.kind, Namespace: "default", Name: c.name,
+		})
+		if err != nil {
+			t.Errorf("%s %s from node %q: %v", c.kind, c.name, c.nodeName, err)
+			continue
+		}
+		if authorized != c.authorized {
+			t.Errorf("expected the update of %s %s from node %q authorized %v, got %v",
+				c.kind, c.name, c.nodeName, c.authorized, authorized)
+		}
+	}
+
+	// the resources not found are rejected
+	if authorized, err := uc.authorizeUpdate(&messagelayer.ResourceUpdateSpec{
+		NodeName: "edge1", Kind: "dataset", Namespace: "default", Name: "dataset2",
+	}); authorized || err == nil {
+		t.Errorf("expected the update of the dataset not found rejected, got %v, %v", authorized, err)
+	}
+}
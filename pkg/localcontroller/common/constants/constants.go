@@ -10,6 +10,9 @@ const (
 	// WSScheme is the scheme of websocket
 	WSScheme = "ws"
 
+	// WSSScheme is the scheme of websocket over TLS
+	WSSScheme = "wss"
+
 	// WSHeaderNodeName is the name of header of websocket
 	WSHeaderNodeName = "Node-Name"
 
@@ -27,4 +30,62 @@ const (
 
 	// BindPortENV is the env of binding port
 	BindPortENV = "BIND_PORT"
+
+	// PingIntervalENV is the env of the interval seconds of pings to GM
+	PingIntervalENV = "GM_PING_INTERVAL"
+
+	// ReadTimeoutENV is the env of the timeout seconds waiting for messages or heartbeats from GM
+	ReadTimeoutENV = "GM_READ_TIMEOUT"
+
+	// WriteTimeoutENV is the env of the timeout seconds writing a message to GM
+	WriteTimeoutENV = "GM_WRITE_TIMEOUT"
+
+	// GMCAFileENV is the env of the CA file verifying the certificate of GM
+	GMCAFileENV = "GM_CA_FILE"
+
+	// CertFileENV is the env of the client certificate file presented to GM
+	CertFileENV = "LC_CERT_FILE"
+
+	// KeyFileENV is the env of the client key file presented to GM
+	KeyFileENV = "LC_KEY_FILE"
+
+	// TokenENV is the env of the bootstrap token of the node presented to GM
+	TokenENV = "GM_TOKEN"
+
+	// TokenFileENV is the env of the file of the bootstrap token of the node
+	TokenFileENV = "GM_TOKEN_FILE"
+
+	// TransportENV is the env of the transport to GM, websocket, mqtt or grpc
+	TransportENV = "GM_TRANSPORT"
+
+	// MQTTBrokerENV is the env of the address of the MQTT broker
+	MQTTBrokerENV = "GM_MQTT_BROKER"
+
+	// MQTTTopicPrefixENV is the env of the prefix of the MQTT topics
+	MQTTTopicPrefixENV = "GM_MQTT_TOPIC_PREFIX"
+
+	// MQTTQoSENV is the env of the QoS of the MQTT messages
+	MQTTQoSENV = "GM_MQTT_QOS"
+
+	// WorkerHeartbeatIntervalENV is the env of the interval seconds which the workers must report within
+	WorkerHeartbeatIntervalENV = "WORKER_HEARTBEAT_INTERVAL"
+
+	// WorkerSocketENV is the env of the path of the unix domain socket serving the workers
+	WorkerSocketENV = "WORKER_SOCKET"
+
+	// WorkerTokenPublicKeyFileENV is the env of the public key file verifying the tokens of the workers
+	WorkerTokenPublicKeyFileENV = "WORKER_TOKEN_PUBLIC_KEY_FILE"
+
+	// WorkerTokenKeyGracePeriodENV is the env of the seconds which the previous public key
+	// of the worker tokens is still accepted for after rotated
+	WorkerTokenKeyGracePeriodENV = "WORKER_TOKEN_KEY_GRACE_PERIOD"
+
+	// DatasetStatsMaxSamplesENV is the env of the maximum number of the sample files checked per scan of a data source
+	DatasetStatsMaxSamplesENV = "DATASET_STATS_MAX_SAMPLES"
+
+	// ModelCacheDirENV is the env of the directory of the node caching the verified models
+	ModelCacheDirENV = "MODEL_CACHE_DIR"
+
+	// ModelTrustedKeyFilesENV is the env of the comma separated files of the public keys trusted to sign the models
+	ModelTrustedKeyFilesENV = "MODEL_TRUSTED_KEY_FILES"
 )
@@ -1,93 +1,81 @@
 package db
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 	"k8s.io/klog/v2"
-
-	"github.com/edgeai-neptune/neptune/pkg/localcontroller/common/constants"
 )
 
-// Resource defines resource (e.g., dataset, model, jointinferenceservice) table
-type Resource struct {
-	gorm.Model
-	Name       string `gorm:"unique"`
-	APIVersion string `json:"apiVersion"`
-	Kind       string `json:"kind"`
-	MetaData   string `json:"metadata"`
-	Spec       string `json:"spec"`
-}
-
-// SaveResource saves resource info in db
-func SaveResource(resource *Resource) error {
-	var err error
-	dbClient := getClient()
+const (
+	// busyTimeout is the milliseconds to wait for the lock of the database
+	busyTimeout = 5000
+)
 
-	r := Resource{}
+var (
+	// dbClient is the database handle shared by LC
+	dbClient *gorm.DB
+	dbLock   sync.Mutex
+)
 
-	queryResult := dbClient.Where("name = ?", resource.Name).First(&r)
+// Open opens the database at url and migrates its schema, the handle is
+// shared by all the queries afterwards.
+func Open(url string) error {
+	dbLock.Lock()
+	defer dbLock.Unlock()
 
-	if queryResult.RowsAffected == 0 {
-		if err = dbClient.Create(resource).Error; err != nil {
-			klog.Errorf("saved resource(name=%s) failed, error: %v", resource.Name, err)
-			return err
-		}
-	} else {
-		r.APIVersion = resource.APIVersion
-		r.Kind = resource.Kind
-		r.MetaData = resource.MetaData
-		r.Spec = resource.Spec
-		if err := dbClient.Save(&r).Error; err != nil {
-			klog.Errorf("Update resource(name=%s) failed, error: %v", resource.Name, err)
-			return err
-		}
+	if dbClient != nil {
+		return nil
 	}
 
-	return nil
-}
-
-// DeleteResource deletes resource info in db
-func DeleteResource(name string) error {
-	var err error
-	dbClient := getClient()
-
-	r := Resource{}
-
-	queryResult := dbClient.Where("name = ?", name).First(&r)
+	if err := os.MkdirAll(filepath.Dir(url), os.ModePerm); err != nil {
+		return fmt.Errorf("create fold(url=%s) failed: %w", filepath.Dir(url), err)
+	}
 
-	if queryResult.RowsAffected == 0 {
-		return nil
+	// the write-ahead log lets the queries go on while writing
+	dsn := fmt.Sprintf("file:%s?_busy_timeout=%d&_journal_mode=WAL&_synchronous=NORMAL", url, busyTimeout)
+	client, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+	})
+	if err != nil {
+		return fmt.Errorf("connect the db(url=%s) failed: %w", url, err)
 	}
 
-	if err = dbClient.Unscoped().Delete(&r).Error; err != nil {
-		klog.Errorf("delete resource(name=%s) to db failed, error: %v", name, err)
+	sqlDB, err := client.DB()
+	if err != nil {
 		return err
 	}
+	// sqlite allows one writer at a time, serializing the connections
+	// avoids "database is locked"
+	sqlDB.SetMaxOpenConns(1)
 
+	if err = migrate(client); err != nil {
+		_ = sqlDB.Close()
+		return fmt.Errorf("migrate the db(url=%s) failed: %w", url, err)
+	}
+
+	dbClient = client
+	klog.Infof("opened the db(url=%s)", url)
 	return nil
 }
 
-// getClient gets db client
-func getClient() *gorm.DB {
-	dbURL := constants.DataBaseURL
-
-	if _, err := os.Stat(dbURL); err != nil {
-		if os.IsNotExist(err) {
-			if err := os.MkdirAll(filepath.Dir(dbURL), os.ModePerm); err != nil {
-				klog.Errorf("create fold(url=%s) failed, error: %v", filepath.Dir(dbURL), err)
-			}
-		}
+// errNotOpened means the database is queried before opened
+var errNotOpened = errors.New("db is not opened")
+
+// getClient gets the shared db client, which must have been opened by Open
+func getClient() (*gorm.DB, error) {
+	dbLock.Lock()
+	client := dbClient
+	dbLock.Unlock()
+	if client == nil {
+		klog.Errorf("get the db client failed, error: %v", errNotOpened)
+		return nil, errNotOpened
 	}
-
-	db, err := gorm.Open(sqlite.Open(dbURL), &gorm.Config{})
-	if err != nil {
-		klog.Errorf("try to connect the db failed, error: %v", err)
-	}
-
-	_ = db.AutoMigrate(&Resource{})
-
-	return db
+	return client, nil
 }
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+// SchemaMigration records a migration applied to the database
+type SchemaMigration struct {
+	Version   int `gorm:"primarykey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// migration upgrades the schema of the database from the previous version
+type migration struct {
+	version int
+	name    string
+	migrate func(tx *gorm.DB) error
+}
+
+// migrations are applied in order, each of them is applied once in a
+// transaction. Never modify the released ones, append a new one instead.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create the resource and upstream message tables",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&legacyResource{}, &UpstreamMessage{})
+		},
+	},
+	{
+		version: 2,
+		name:    "split the resource table into the typed tables",
+		migrate: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&Dataset{}, &Model{}, &Job{}, &Worker{}, &WorkerStatus{}); err != nil {
+				return err
+			}
+			if err := splitLegacyResources(tx); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&legacyResource{})
+		},
+	},
+	{
+		version: 3,
+		name:    "add the digest and the verification of the models",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&Model{})
+		},
+	},
+	{
+		version: 4,
+		name:    "add the signature of the models",
+		migrate: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&Model{}); err != nil {
+				return err
+			}
+			return tx.Model(&Model{}).Where("digest <> '' OR size > 0").Update("verify_required", true).Error
+		},
+	},
+	{
+		version: 5,
+		name:    "add the liveness of the workers",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&Worker{})
+		},
+	},
+	{
+		version: 6,
+		name:    "add the total number of samples added of the datasets",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&Dataset{})
+		},
+	},
+	{
+		version: 7,
+		name:    "add the tombstones of the deleted resources",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&Tombstone{})
+		},
+	},
+}
+
+// migrate applies the migrations not applied yet
+func migrate(client *gorm.DB) error {
+	if err := client.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+
+	var applied []SchemaMigration
+	if err := client.Find(&applied).Error; err != nil {
+		return err
+	}
+	appliedVersions := make(map[int]bool)
+	for _, m := range applied {
+		appliedVersions[m.Version] = true
+	}
+
+	for _, m := range migrations {
+		if appliedVersions[m.version] {
+			continue
+		}
+
+		err := client.Transaction(func(tx *gorm.DB) error {
+			if err := m.migrate(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.version, Name: m.name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return err
+		}
+		klog.Infof("applied db migration %d: %s", m.version, m.name)
+	}
+	return nil
+}
+
+// legacyResource is the stringly-typed resource table of the schema version 1
+type legacyResource struct {
+	gorm.Model
+	Name       string `gorm:"unique"`
+	APIVersion string
+	Kind       string
+	MetaData   string
+	Spec       string
+
+	ResourceKind    string
+	Namespace       string
+	ResourceName    string
+	ResourceVersion string
+}
+
+// TableName returns the table name of legacyResource
+func (legacyResource) TableName() string {
+	return "resources"
+}
+
+// splitLegacyResources moves the rows of the resource table to the typed
+// tables, the rows without the identity saved by the older versions are
+// dropped, which are sent again by GM when resyncing.
+func splitLegacyResources(tx *gorm.DB) error {
+	var resources []legacyResource
+	if err := tx.Find(&resources).Error; err != nil {
+		return err
+	}
+
+	for _, r := range resources {
+		meta := ResourceMeta{
+			Name:            r.Name,
+			Namespace:       r.Namespace,
+			ResourceName:    r.ResourceName,
+			ResourceKind:    r.ResourceKind,
+			ResourceVersion: r.ResourceVersion,
+			APIVersion:      r.APIVersion,
+			Kind:            r.Kind,
+			Spec:            r.Spec,
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
+		}
+
+		var row interface{}
+		switch r.ResourceKind {
+		case DatasetKind:
+			var spec struct {
+				Format string `json:"format"`
+				URL    string `json:"url"`
+			}
+			_ = json.Unmarshal([]byte(r.Spec), &spec)
+			row = &Dataset{ResourceMeta: meta, Format: spec.Format, URL: spec.URL}
+		case ModelKind:
+			var spec struct {
+				Format string `json:"format"`
+				URL    string `json:"url"`
+			}
+			_ = json.Unmarshal([]byte(r.Spec), &spec)
+			row = &Model{ResourceMeta: meta, Format: spec.Format, URL: spec.URL}
+		case FederatedLearningJobKind, JointInferenceServiceKind:
+			row = &Job{ResourceMeta: meta}
+		default:
+			klog.Warningf("drop the resource(name=%s) without identity when migrating", r.Name)
+			continue
+		}
+
+		if err := tx.Create(row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
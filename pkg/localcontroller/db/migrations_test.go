@@ -0,0 +1,138 @@
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// reopen opens the database at url in place of the shared one by Open,
+// and returns the function restoring the shared one
+func reopen(t *testing.T, url string) func() {
+	t.Helper()
+	dbLock.Lock()
+	shared := dbClient
+	dbClient = nil
+	dbLock.Unlock()
+
+	restore := func() {
+		dbLock.Lock()
+		defer dbLock.Unlock()
+		if dbClient != nil {
+			if sqlDB, err := dbClient.DB(); err == nil {
+				_ = sqlDB.Close()
+			}
+		}
+		dbClient = shared
+	}
+	if err := Open(url); err != nil {
+		restore()
+		t.Fatal(err)
+	}
+	return restore
+}
+
+func TestMigrateLegacyResources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	url := filepath.Join(dir, "database.db")
+
+	// the resource table of the schema version 1, whose rows saved by the
+	// older versions have no identity
+	legacy, err := gorm.Open(sqlite.Open(url), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = legacy.AutoMigrate(&legacyResource{}); err != nil {
+		t.Fatal(err)
+	}
+	rows := []legacyResource{
+		{Name: "default-dataset1", Kind: "Dataset", Spec: `{"format":"txt","url":"/data/d1.txt"}`,
+			ResourceKind: DatasetKind, Namespace: "default", ResourceName: "dataset1", ResourceVersion: "10"},
+		{Name: "default-model1", Kind: "Model", Spec: `{"format":"pb","url":"/models/m1"}`,
+			ResourceKind: ModelKind, Namespace: "default", ResourceName: "model1", ResourceVersion: "11"},
+		{Name: "default-job1", Kind: "FederatedLearningJob", Spec: "{}",
+			ResourceKind: FederatedLearningJobKind, Namespace: "default", ResourceName: "job1", ResourceVersion: "12"},
+		{Name: "default-service1", Kind: "JointInferenceService", Spec: "{}",
+			ResourceKind: JointInferenceServiceKind, Namespace: "default", ResourceName: "service1", ResourceVersion: "13"},
+		// saved before the identity recorded
+		{Name: "default-dataset2", Kind: "Dataset", Spec: `{"format":"txt","url":"/data/d2.txt"}`},
+	}
+	if err = legacy.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := legacy.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+
+	restore := reopen(t, url)
+	defer func() {
+		restore()
+	}()
+
+	var datasets []Dataset
+	var models []Model
+	var jobs []Job
+	for _, table := range []interface{}{&datasets, &models, &jobs} {
+		if err = dbClient.Find(table).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(datasets) != 1 {
+		t.Fatalf("expected the dataset without identity dropped, got %d datasets", len(datasets))
+	}
+	d := datasets[0]
+	if d.Name != "default-dataset1" || d.ResourceName != "dataset1" || d.ResourceVersion != "10" ||
+		d.Format != "txt" || d.URL != "/data/d1.txt" {
+		t.Errorf("unexpected dataset %+v", d)
+	}
+	if len(models) != 1 || models[0].ResourceName != "model1" || models[0].Format != "pb" || models[0].URL != "/models/m1" {
+		t.Errorf("unexpected models %+v", models)
+	}
+	var jobNames []string
+	for _, j := range jobs {
+		jobNames = append(jobNames, j.ResourceKind+"/"+j.ResourceName)
+	}
+	sort.Strings(jobNames)
+	if expected := []string{"federatedlearningjob/job1", "jointinferenceservice/service1"}; !reflect.DeepEqual(jobNames, expected) {
+		t.Errorf("expected jobs %v, got %v", expected, jobNames)
+	}
+	if dbClient.Migrator().HasTable("resources") {
+		t.Errorf("expected the resource table dropped")
+	}
+
+	var applied []SchemaMigration
+	if err = dbClient.Order("version").Find(&applied).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("expected %d migrations applied, got %d", len(migrations), len(applied))
+	}
+
+	// reopening applies nothing
+	restore()
+	restore = reopen(t, url)
+	var reapplied []SchemaMigration
+	if err = dbClient.Order("version").Find(&reapplied).Error; err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(reapplied) != fmt.Sprint(applied) {
+		t.Errorf("expected the migrations %v unchanged, got %v", applied, reapplied)
+	}
+	var count int64
+	if err = dbClient.Model(&Dataset{}).Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("expected the datasets unchanged, got %d, %v", count, err)
+	}
+}
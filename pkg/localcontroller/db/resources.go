@@ -0,0 +1,423 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/metrics"
+)
+
+const (
+	// DatasetKind is the resource kind of the datasets
+	DatasetKind = "dataset"
+	// ModelKind is the resource kind of the models
+	ModelKind = "model"
+	// FederatedLearningJobKind is the resource kind of the federated learning jobs
+	FederatedLearningJobKind = "federatedlearningjob"
+	// JointInferenceServiceKind is the resource kind of the joint inference services
+	JointInferenceServiceKind = "jointinferenceservice"
+)
+
+// ResourceMeta defines the identity of a resource from GM,
+// which is shared by the resource tables
+type ResourceMeta struct {
+	ID uint `gorm:"primarykey"`
+	// Name is the unique identifier of the resource in LC
+	Name string `gorm:"uniqueIndex"`
+
+	// Namespace, ResourceName, ResourceKind and ResourceVersion identify
+	// the resource in GM, which are used for resyncing
+	Namespace       string
+	ResourceName    string
+	ResourceKind    string
+	ResourceVersion string
+
+	APIVersion string
+	Kind       string
+	// Spec is the spec of the resource in json
+	Spec string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Dataset defines the dataset table
+type Dataset struct {
+	ResourceMeta `gorm:"embedded"`
+
+	Format string
+	URL    string
+	// NumberOfSamples is the number of samples found in the data source
+	NumberOfSamples int
+	// SamplesAdded is the total number of samples added to the data source, which only increases
+	SamplesAdded int
+}
+
+// Model defines the model table
+type Model struct {
+	ResourceMeta `gorm:"embedded"`
+
+	Format string
+	URL    string
+	// Digest and Size are the expected sha256 digest and size of the model file
+	Digest string
+	Size   int64
+	// Signature is the signature of the digest of the model file
+	Signature string
+	// VerifyRequired is whether the model is only exposed after verified
+	VerifyRequired bool
+
+	// CachePath is the path of the verified model in the cache of LC
+	CachePath string
+	// Verified is whether the model in CachePath matches Digest, Size and Signature
+	Verified bool
+	// VerifyMessage is the details of the last verification
+	VerifyMessage string
+	// VerifyTime is the time of the last verification
+	VerifyTime time.Time
+}
+
+// ModelVerification defines the result of verifying a model
+type ModelVerification struct {
+	CachePath     string
+	Verified      bool
+	VerifyMessage string
+	VerifyTime    time.Time
+}
+
+// Job defines the table of the jobs and services,
+// e.g., federated learning jobs and joint inference services
+type Job struct {
+	ResourceMeta `gorm:"embedded"`
+}
+
+// Tombstone defines the table of the resources deleted by GM, which keeps
+// the stale resources listed before the deletions from being saved again
+type Tombstone struct {
+	ID              uint   `gorm:"primarykey"`
+	ResourceKind    string `gorm:"uniqueIndex:idx_tombstone_resource"`
+	Namespace       string `gorm:"uniqueIndex:idx_tombstone_resource"`
+	ResourceName    string `gorm:"uniqueIndex:idx_tombstone_resource"`
+	ResourceVersion string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// resourceRow is a row of the resource tables
+type resourceRow interface {
+	meta() *ResourceMeta
+}
+
+func (m *ResourceMeta) meta() *ResourceMeta {
+	return m
+}
+
+// saveResource inserts the resource row or updates the one with the same name
+func saveResource(operation string, row resourceRow) error {
+	defer metrics.ObserveDBOperation(operation, time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	meta := row.meta()
+	err = client.Transaction(func(tx *gorm.DB) error {
+		var existing ResourceMeta
+		result := tx.Model(row).Select("id", "created_at").Where("name = ?", meta.Name).Limit(1).Find(&existing)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return tx.Create(row).Error
+		}
+
+		meta.ID = existing.ID
+		meta.CreatedAt = existing.CreatedAt
+		return tx.Save(row).Error
+	})
+	if err != nil {
+		klog.Errorf("save resource(name=%s) failed, error: %v", meta.Name, err)
+	}
+	return err
+}
+
+// deleteResource deletes the resource row by the name
+func deleteResource(operation string, model interface{}, name string) error {
+	defer metrics.ObserveDBOperation(operation, time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	if err = client.Where("name = ?", name).Delete(model).Error; err != nil {
+		klog.Errorf("delete resource(name=%s) failed, error: %v", name, err)
+	}
+	return err
+}
+
+// getResource gets the resource row by the name, nil if not found
+func getResource(operation string, row interface{}, name string) (bool, error) {
+	defer metrics.ObserveDBOperation(operation, time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return false, err
+	}
+
+	result := client.Where("name = ?", name).Limit(1).Find(row)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// listResources lists the resource rows matching the query if not nil
+func listResources(operation string, rows interface{}, query func(*gorm.DB) *gorm.DB) error {
+	defer metrics.ObserveDBOperation(operation, time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	if query != nil {
+		client = query(client)
+	}
+	return client.Order("name").Find(rows).Error
+}
+
+// byNamespace returns the query of the rows in the namespace, all if empty
+func byNamespace(namespace string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if namespace == "" {
+			return db
+		}
+		return db.Where("namespace = ?", namespace)
+	}
+}
+
+// SaveDataset saves the dataset
+func SaveDataset(dataset *Dataset) error {
+	return saveResource("save_dataset", dataset)
+}
+
+// DeleteDataset deletes the dataset by name
+func DeleteDataset(name string) error {
+	return deleteResource("delete_dataset", &Dataset{}, name)
+}
+
+// GetDataset gets the dataset by name, nil if not found
+func GetDataset(name string) (*Dataset, error) {
+	var dataset Dataset
+	found, err := getResource("get_dataset", &dataset, name)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+// ListDatasets lists the datasets in the namespace, all if empty
+func ListDatasets(namespace string) ([]Dataset, error) {
+	var datasets []Dataset
+	err := listResources("list_datasets", &datasets, byNamespace(namespace))
+	return datasets, err
+}
+
+// UpdateDatasetSamples updates the number of samples of the dataset and the total number of samples added
+func UpdateDatasetSamples(name string, numberOfSamples, samplesAdded int) error {
+	defer metrics.ObserveDBOperation("update_dataset", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+	return client.Model(&Dataset{}).Where("name = ?", name).Updates(map[string]interface{}{
+		"number_of_samples": numberOfSamples,
+		"samples_added":     samplesAdded,
+	}).Error
+}
+
+// SaveModel saves the model
+func SaveModel(model *Model) error {
+	return saveResource("save_model", model)
+}
+
+// DeleteModel deletes the model by name
+func DeleteModel(name string) error {
+	return deleteResource("delete_model", &Model{}, name)
+}
+
+// GetModel gets the model by name, nil if not found
+func GetModel(name string) (*Model, error) {
+	var model Model
+	found, err := getResource("get_model", &model, name)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// ListModels lists the models in the namespace, all if empty
+func ListModels(namespace string) ([]Model, error) {
+	var models []Model
+	err := listResources("list_models", &models, byNamespace(namespace))
+	return models, err
+}
+
+// UpdateModelVerification updates the verification result of the model
+func UpdateModelVerification(name string, verification *ModelVerification) error {
+	defer metrics.ObserveDBOperation("update_model", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+	return client.Model(&Model{}).Where("name = ?", name).Select("cache_path", "verified", "verify_message", "verify_time").
+		Updates(&Model{
+			CachePath:     verification.CachePath,
+			Verified:      verification.Verified,
+			VerifyMessage: verification.VerifyMessage,
+			VerifyTime:    verification.VerifyTime,
+		}).Error
+}
+
+// SaveJob saves the job
+func SaveJob(job *Job) error {
+	return saveResource("save_job", job)
+}
+
+// DeleteJob deletes the job by name
+func DeleteJob(name string) error {
+	return deleteResource("delete_job", &Job{}, name)
+}
+
+// GetJob gets the job by name, nil if not found
+func GetJob(name string) (*Job, error) {
+	var job Job
+	found, err := getResource("get_job", &job, name)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs lists the jobs of the resource kind in the namespace,
+// all if kind or namespace is empty
+func ListJobs(kind, namespace string) ([]Job, error) {
+	var jobs []Job
+	err := listResources("list_jobs", &jobs, func(db *gorm.DB) *gorm.DB {
+		db = byNamespace(namespace)(db)
+		if kind != "" {
+			db = db.Where("resource_kind = ?", kind)
+		}
+		return db
+	})
+	return jobs, err
+}
+
+// ListResources lists the identities of all resources held
+func ListResources() ([]ResourceMeta, error) {
+	datasets, err := ListDatasets("")
+	if err != nil {
+		return nil, err
+	}
+	models, err := ListModels("")
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := ListJobs("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]ResourceMeta, 0, len(datasets)+len(models)+len(jobs))
+	for _, d := range datasets {
+		resources = append(resources, d.ResourceMeta)
+	}
+	for _, m := range models {
+		resources = append(resources, m.ResourceMeta)
+	}
+	for _, j := range jobs {
+		resources = append(resources, j.ResourceMeta)
+	}
+	return resources, nil
+}
+
+// byResource returns the query of the rows of the resource in GM
+func byResource(kind, namespace, name string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("resource_kind = ? AND namespace = ? AND resource_name = ?", kind, namespace, name)
+	}
+}
+
+// GetResourceVersion gets the resource version of the resource held, or of
+// its tombstone if deleted, false if neither found
+func GetResourceVersion(kind, namespace, name string) (string, bool, error) {
+	defer metrics.ObserveDBOperation("get_resource_version", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return "", false, err
+	}
+
+	var table interface{}
+	switch kind {
+	case DatasetKind:
+		table = &Dataset{}
+	case ModelKind:
+		table = &Model{}
+	default:
+		table = &Job{}
+	}
+
+	for _, row := range []interface{}{table, &Tombstone{}} {
+		var versions []string
+		err = byResource(kind, namespace, name)(client.Model(row)).Limit(1).Pluck("resource_version", &versions).Error
+		if err != nil {
+			return "", false, err
+		}
+		if len(versions) > 0 {
+			return versions[0], true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// SaveTombstone records the resource deleted with its last resource version
+func SaveTombstone(kind, namespace, name, version string) error {
+	defer metrics.ObserveDBOperation("save_tombstone", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	return client.Transaction(func(tx *gorm.DB) error {
+		var existing Tombstone
+		result := byResource(kind, namespace, name)(tx).Limit(1).Find(&existing)
+		if result.Error != nil {
+			return result.Error
+		}
+		existing.ResourceKind = kind
+		existing.Namespace = namespace
+		existing.ResourceName = name
+		existing.ResourceVersion = version
+		return tx.Save(&existing).Error
+	})
+}
+
+// PruneTombstones deletes the tombstones recorded before the time
+func PruneTombstones(before time.Time) error {
+	defer metrics.ObserveDBOperation("prune_tombstones", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+	return client.Where("updated_at < ?", before).Delete(&Tombstone{}).Error
+}
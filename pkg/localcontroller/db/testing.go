@@ -0,0 +1,31 @@
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// testRunner runs the tests, i.e. *testing.M
+type testRunner interface {
+	Run() int
+}
+
+// RunWithTempDB runs the tests with the database opened in a temporary
+// directory, which is removed afterwards, and returns the exit code of the
+// tests, e.g. os.Exit(db.RunWithTempDB(m, "server")) in TestMain.
+func RunWithTempDB(m testRunner, prefix string) int {
+	dir, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	if err = Open(filepath.Join(dir, "database.db")); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return m.Run()
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/metrics"
+)
+
+// UpstreamMessage defines the upstream message (e.g., the status of a resource)
+// table, which holds the messages to GM until acknowledged
+type UpstreamMessage struct {
+	ID uint `gorm:"primarykey"`
+	// CoalesceKey identifies the messages replaced by the newer one with the
+	// same key, empty means never replaced
+	CoalesceKey string `gorm:"index"`
+	// Sequence orders the messages
+	Sequence  uint64 `gorm:"uniqueIndex"`
+	MessageID string
+
+	Namespace    string
+	ResourceKind string
+	ResourceName string
+	Operation    string
+	Content      []byte
+
+	CreatedAt time.Time
+}
+
+// AddUpstreamMessage adds the message, the messages with the same coalesce
+// key are replaced
+func AddUpstreamMessage(message *UpstreamMessage) error {
+	defer metrics.ObserveDBOperation("add_upstream", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	err = client.Transaction(func(tx *gorm.DB) error {
+		if message.CoalesceKey != "" {
+			if err := tx.Where("coalesce_key = ?", message.CoalesceKey).Delete(&UpstreamMessage{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(message).Error
+	})
+	if err != nil {
+		klog.Errorf("add upstream message(key=%s) failed, error: %v", message.CoalesceKey, err)
+	}
+	return err
+}
+
+// DeleteUpstreamMessage deletes the message of the sequence
+func DeleteUpstreamMessage(sequence uint64) error {
+	defer metrics.ObserveDBOperation("delete_upstream", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	if err = client.Where("sequence = ?", sequence).Delete(&UpstreamMessage{}).Error; err != nil {
+		klog.Errorf("delete upstream message(sequence=%d) failed, error: %v", sequence, err)
+		return err
+	}
+	return nil
+}
+
+// ListUpstreamMessages lists the messages whose sequences are greater than
+// afterSequence in the order of sequence
+func ListUpstreamMessages(afterSequence uint64) ([]UpstreamMessage, error) {
+	defer metrics.ObserveDBOperation("list_upstream", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []UpstreamMessage
+	if err = client.Where("sequence > ?", afterSequence).Order("sequence").Find(&messages).Error; err != nil {
+		klog.Errorf("list upstream messages failed, error: %v", err)
+		return nil, err
+	}
+	return messages, nil
+}
+
+// CountUpstreamMessages returns the number of the messages
+func CountUpstreamMessages() (int64, error) {
+	client, err := getClient()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err = client.Model(&UpstreamMessage{}).Count(&count).Error
+	return count, err
+}
+
+// MaxUpstreamSequence returns the max sequence of the messages, 0 if none
+func MaxUpstreamSequence() (uint64, error) {
+	client, err := getClient()
+	if err != nil {
+		return 0, err
+	}
+
+	var sequence uint64
+	err = client.Model(&UpstreamMessage{}).Select("COALESCE(MAX(sequence), 0)").Row().Scan(&sequence)
+	return sequence, err
+}
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/metrics"
+)
+
+const (
+	// workerStatusHistoryLimit is the number of the latest status records kept per worker
+	workerStatusHistoryLimit = 100
+)
+
+// Worker defines the worker table, which holds the latest state of the workers
+type Worker struct {
+	ID uint `gorm:"primarykey"`
+	// Name is the unique identifier of the worker in LC
+	Name string `gorm:"uniqueIndex"`
+	// ReportedName is the name the worker reports itself by
+	ReportedName string
+	Namespace    string `gorm:"index:idx_worker_owner"`
+	OwnerKind    string `gorm:"index:idx_worker_owner"`
+	OwnerName    string `gorm:"index:idx_worker_owner"`
+	// Phase is the phase of the worker, e.g. train, eval and inference
+	Phase  string
+	Status string
+
+	// LastReportTime is the last time the worker reported, including the heartbeats
+	LastReportTime time.Time `gorm:"index"`
+	// Healthy is whether the worker reported within the heartbeat interval
+	Healthy bool
+	// TransitionTime is the last time Healthy changed
+	TransitionTime time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WorkerStatus defines the status history table of the workers
+type WorkerStatus struct {
+	ID         uint   `gorm:"primarykey"`
+	WorkerName string `gorm:"index"`
+	Namespace  string `gorm:"index:idx_worker_status_owner"`
+	OwnerKind  string `gorm:"index:idx_worker_status_owner"`
+	OwnerName  string `gorm:"index:idx_worker_status_owner"`
+	Phase      string
+	Status     string
+	// Output is the output reported by the worker in json
+	Output string
+
+	CreatedAt time.Time
+}
+
+// AddWorkerStatus records the status reported by the worker, which updates
+// the state of the worker and appends to its status history.
+// Only the latest workerStatusHistoryLimit records are kept per worker.
+func AddWorkerStatus(status *WorkerStatus) error {
+	defer metrics.ObserveDBOperation("add_worker_status", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	err = client.Transaction(func(tx *gorm.DB) error {
+		worker := Worker{
+			Name:      status.WorkerName,
+			Namespace: status.Namespace,
+			OwnerKind: status.OwnerKind,
+			OwnerName: status.OwnerName,
+			Phase:     status.Phase,
+			Status:    status.Status,
+		}
+		result := tx.Model(&Worker{}).Where("name = ?", worker.Name).Updates(&worker)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if err := tx.Create(&worker).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(status).Error; err != nil {
+			return err
+		}
+
+		// prune the records older than the latest ones
+		return tx.Where("worker_name = ?", status.WorkerName).
+			Where("id NOT IN (?)", tx.Model(&WorkerStatus{}).Select("id").
+				Where("worker_name = ?", status.WorkerName).Order("id DESC").Limit(workerStatusHistoryLimit)).
+			Delete(&WorkerStatus{}).Error
+	})
+	if err != nil {
+		klog.Errorf("add status of worker(name=%s) failed, error: %v", status.WorkerName, err)
+	}
+	return err
+}
+
+// RecordWorkerReport records the worker reporting at now, whose phase and
+// status are kept if empty in report, e.g. a heartbeat.
+// It returns the worker recorded, and whether the worker turned healthy.
+func RecordWorkerReport(report *Worker, now time.Time) (*Worker, bool, error) {
+	defer metrics.ObserveDBOperation("record_worker_report", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var worker Worker
+	var recovered bool
+	err = client.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("name = ?", report.Name).Limit(1).Find(&worker)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			worker = Worker{Name: report.Name}
+		}
+
+		worker.ReportedName = report.ReportedName
+		worker.Namespace = report.Namespace
+		worker.OwnerKind = report.OwnerKind
+		worker.OwnerName = report.OwnerName
+		if report.Phase != "" {
+			worker.Phase = report.Phase
+			worker.Status = report.Status
+		}
+		worker.LastReportTime = now
+
+		recovered = !worker.Healthy
+		if recovered {
+			worker.Healthy = true
+			worker.TransitionTime = now
+		}
+		return tx.Save(&worker).Error
+	})
+	if err != nil {
+		klog.Errorf("record report of worker(name=%s) failed, error: %v", report.Name, err)
+		return nil, false, err
+	}
+	return &worker, recovered, nil
+}
+
+// ListStaleWorkers lists the healthy workers which have not reported since before
+func ListStaleWorkers(before time.Time) ([]Worker, error) {
+	var workers []Worker
+	err := listResources("list_stale_workers", &workers, func(db *gorm.DB) *gorm.DB {
+		return db.Where("healthy = ? AND last_report_time < ?", true, before)
+	})
+	return workers, err
+}
+
+// MarkWorkerUnhealthy flags the worker unhealthy at now if it's still healthy
+// and has not reported since before, and returns whether it's flagged
+func MarkWorkerUnhealthy(name string, before, now time.Time) (bool, error) {
+	defer metrics.ObserveDBOperation("mark_worker_unhealthy", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return false, err
+	}
+
+	// the conditions are checked again, the worker may have reported meanwhile
+	result := client.Model(&Worker{}).
+		Where("name = ? AND healthy = ? AND last_report_time < ?", name, true, before).
+		Updates(map[string]interface{}{"healthy": false, "transition_time": now})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetWorker gets the worker by name, nil if not found
+func GetWorker(name string) (*Worker, error) {
+	var worker Worker
+	found, err := getResource("get_worker", &worker, name)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &worker, nil
+}
+
+// ListWorkers lists the workers of the owner, the empty arguments match any
+func ListWorkers(namespace, ownerKind, ownerName string) ([]Worker, error) {
+	var workers []Worker
+	err := listResources("list_workers", &workers, byOwner(namespace, ownerKind, ownerName))
+	return workers, err
+}
+
+// DeleteWorkers deletes the workers of the owner and their status history
+func DeleteWorkers(namespace, ownerKind, ownerName string) error {
+	defer metrics.ObserveDBOperation("delete_workers", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	return client.Transaction(func(tx *gorm.DB) error {
+		query := "namespace = ? AND owner_kind = ? AND owner_name = ?"
+		if err := tx.Where(query, namespace, ownerKind, ownerName).Delete(&WorkerStatus{}).Error; err != nil {
+			return err
+		}
+		return tx.Where(query, namespace, ownerKind, ownerName).Delete(&Worker{}).Error
+	})
+}
+
+// ListWorkerStatus lists the latest status records of the owner in the
+// reverse order of time, the empty arguments match any, and limit <= 0
+// means no limit
+func ListWorkerStatus(namespace, ownerKind, ownerName string, limit int) ([]WorkerStatus, error) {
+	defer metrics.ObserveDBOperation("list_worker_status", time.Now())
+
+	client, err := getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	query := byOwner(namespace, ownerKind, ownerName)(client).Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var records []WorkerStatus
+	err = query.Find(&records).Error
+	return records, err
+}
+
+// byOwner returns the query of the rows of the owner, the empty ones of
+// namespace, ownerKind and ownerName match any
+func byOwner(namespace, ownerKind, ownerName string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		// in the order of the columns, so the query is deterministic
+		for _, condition := range []struct {
+			column string
+			value  string
+		}{
+			{"namespace", namespace},
+			{"owner_kind", ownerKind},
+			{"owner_name", ownerName},
+		} {
+			if condition.value != "" {
+				db = db.Where(condition.column+" = ?", condition.value)
+			}
+		}
+		return db
+	}
+}
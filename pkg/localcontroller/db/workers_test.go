@@ -0,0 +1,80 @@
+package db
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(RunWithTempDB(m, "db"))
+}
+
+func TestWorkerLiveness(t *testing.T) {
+	start := time.Now()
+	report := Worker{
+		Name:         "default-worker1-worker",
+		ReportedName: "worker1",
+		Namespace:    "default",
+		OwnerKind:    "federatedlearningjob",
+		OwnerName:    "job1",
+		Phase:        "train",
+		Status:       "running",
+	}
+
+	w, recovered, err := RecordWorkerReport(&report, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !recovered || !w.Healthy || !w.TransitionTime.Equal(start) {
+		t.Fatalf("expected a new worker healthy since %v, got %+v", start, w)
+	}
+
+	// a heartbeat keeps the phase and the status
+	heartbeat := report
+	heartbeat.Phase, heartbeat.Status = "", ""
+	w, recovered, err = RecordWorkerReport(&heartbeat, start.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered || w.Phase != "train" || w.Status != "running" {
+		t.Fatalf("expected the heartbeat keeping the worker, got recovered %v and %+v", recovered, w)
+	}
+
+	// stale after the heartbeat interval
+	before := start.Add(time.Minute)
+	stale, err := ListStaleWorkers(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 1 || stale[0].Name != report.Name {
+		t.Fatalf("expected worker %s stale, got %+v", report.Name, stale)
+	}
+
+	// the worker reported meanwhile is not flagged
+	if _, _, err = RecordWorkerReport(&heartbeat, before.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	flagged, err := MarkWorkerUnhealthy(report.Name, before, before.Add(2*time.Second))
+	if err != nil || flagged {
+		t.Fatalf("expected the reporting worker not flagged, got %v, error %v", flagged, err)
+	}
+
+	later := before.Add(time.Minute)
+	flagged, err = MarkWorkerUnhealthy(report.Name, later, later)
+	if err != nil || !flagged {
+		t.Fatalf("expected the worker flagged unhealthy, got %v, error %v", flagged, err)
+	}
+	if stale, err = ListStaleWorkers(later); err != nil || len(stale) != 0 {
+		t.Fatalf("expected no healthy stale workers, got %+v, error %v", stale, err)
+	}
+
+	// recovered once reporting again
+	w, recovered, err = RecordWorkerReport(&heartbeat, later.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !recovered || !w.Healthy {
+		t.Fatalf("expected the worker recovered, got %+v", w)
+	}
+}
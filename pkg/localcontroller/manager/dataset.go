@@ -1,17 +1,16 @@
 package manager
 
 import (
-	"bufio"
 	"encoding/json"
-	"fmt"
-	"os"
 	"path/filepath"
-	"time"
+	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	"k8s.io/klog/v2"
 
 	"github.com/edgeai-neptune/neptune/cmd/neptune-lc/app/options"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/metrics"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/util"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/wsclient"
 )
@@ -21,8 +20,24 @@ type DatasetManager struct {
 	Client            *wsclient.Client
 	DatasetChannelMap map[string]chan Dataset
 	DatasetMap        map[string]*Dataset
-	DataSourcesSignal map[string]bool
 	VolumeMountPrefix string
+	// StatsMaxSamples is the maximum number of the latest sample files checked per scan
+	StatsMaxSamples int
+
+	// datasetLock protects DatasetMap, DatasetChannelMap and the data sources
+	// of the datasets, which are read by the LC server
+	datasetLock sync.RWMutex
+
+	// watcher is the inotify watcher of the directories of the data sources, nil if unavailable
+	watcher *fsnotify.Watcher
+	// watchLock protects dataSourceWatches, watchedFiles and watchedDirs
+	watchLock sync.Mutex
+	// dataSourceWatches is the watch of the data source of each dataset
+	dataSourceWatches map[string]*dataSourceWatch
+	// watchedFiles is the watches of each data source file
+	watchedFiles map[string][]*dataSourceWatch
+	// watchedDirs is the number of the watches in each directory watched by inotify
+	watchedDirs map[string]int
 }
 
 // Dataset defines config for dataset
@@ -50,22 +65,28 @@ type DataSource struct {
 const (
 	// DatasetChannelCacheSize is size of channel cache
 	DatasetChannelCacheSize = 100
-	// MonitorDataSourceIntervalSeconds is interval time of monitoring data source
+	// MonitorDataSourceIntervalSeconds is interval time of polling the data source
+	// which can't be watched by inotify
 	MonitorDataSourceIntervalSeconds = 10
 	// DatasetResourceKind is kind of dataset resource
-	DatasetResourceKind = "dataset"
+	DatasetResourceKind = db.DatasetKind
 )
 
 // NewDatasetManager creates a dataset manager
 func NewDatasetManager(client *wsclient.Client, options *options.LocalControllerOptions) (*DatasetManager, error) {
 	dm := DatasetManager{
 		Client:            client,
-		DataSourcesSignal: make(map[string]bool),
 		DatasetChannelMap: make(map[string]chan Dataset),
 		DatasetMap:        make(map[string]*Dataset),
 		VolumeMountPrefix: options.VolumeMountPrefix,
+		StatsMaxSamples:   options.DatasetStatsMaxSamples,
+		dataSourceWatches: make(map[string]*dataSourceWatch),
+		watchedFiles:      make(map[string][]*dataSourceWatch),
+		watchedDirs:       make(map[string]int),
 	}
 
+	dm.startWatcher()
+
 	if err := dm.initDatasetManager(); err != nil {
 		klog.Errorf("init dataset manager failed, error: %v", err)
 		return nil, err
@@ -87,6 +108,9 @@ func (dm *DatasetManager) initDatasetManager() error {
 
 // GetDatasetChannel gets dataset channel
 func (dm *DatasetManager) GetDatasetChannel(name string) chan Dataset {
+	dm.datasetLock.RLock()
+	defer dm.datasetLock.RUnlock()
+
 	ds, ok := dm.DatasetChannelMap[name]
 	if !ok {
 		return nil
@@ -95,13 +119,24 @@ func (dm *DatasetManager) GetDatasetChannel(name string) chan Dataset {
 	return ds
 }
 
-// addNewDataset adds dataset
+// addNewDataset adds dataset, which is dropped if the channel is full
 func (dm *DatasetManager) addNewDataset(name string, dataset Dataset) {
+	dm.datasetLock.Lock()
+	defer dm.datasetLock.Unlock()
+
+	if _, ok := dm.DatasetMap[name]; !ok {
+		// deleted meanwhile
+		return
+	}
 	if _, ok := dm.DatasetChannelMap[name]; !ok {
 		dm.DatasetChannelMap[name] = make(chan Dataset, DatasetChannelCacheSize)
 	}
 
-	dm.DatasetChannelMap[name] <- dataset
+	select {
+	case dm.DatasetChannelMap[name] <- dataset:
+	default:
+		klog.Warningf("channel of dataset(name=%s) is full, drop the dataset", name)
+	}
 }
 
 // handleMessage handles the message from GlobalManager
@@ -114,10 +149,7 @@ func (dm *DatasetManager) handleMessage(message *wsclient.Message) {
 				klog.Errorf("insert %s(name=%s) to db failed, error: %v", message.Header.ResourceKind, uniqueIdentifier, err)
 			}
 
-			if _, ok := dm.DataSourcesSignal[uniqueIdentifier]; !ok {
-				dm.DataSourcesSignal[uniqueIdentifier] = true
-				go dm.monitorDataSources(message, uniqueIdentifier)
-			}
+			dm.updateDataSourceWatch(uniqueIdentifier, message.Header)
 		}
 	case DeleteOperation:
 		{
@@ -125,15 +157,15 @@ func (dm *DatasetManager) handleMessage(message *wsclient.Message) {
 				klog.Errorf("delete %s(name=%s) to db failed, error: %v", message.Header.ResourceKind, uniqueIdentifier, err)
 			}
 
-			if _, ok := dm.DataSourcesSignal[uniqueIdentifier]; ok {
-				dm.DataSourcesSignal[uniqueIdentifier] = false
-			}
+			metrics.DatasetSamples.DeleteLabelValues(message.Header.Namespace, message.Header.ResourceName)
+			metrics.DatasetMissingSamples.DeleteLabelValues(message.Header.Namespace, message.Header.ResourceName)
 		}
 	}
 }
 
 // insertDataset inserts dataset to db
 func (dm *DatasetManager) insertDataset(name string, payload []byte) error {
+	dm.datasetLock.Lock()
 	if _, ok := dm.DatasetMap[name]; !ok {
 		dm.DatasetMap[name] = &Dataset{}
 	}
@@ -141,153 +173,127 @@ func (dm *DatasetManager) insertDataset(name string, payload []byte) error {
 	dataset := dm.DatasetMap[name]
 
 	if err := json.Unmarshal(payload, &dataset); err != nil {
+		dm.datasetLock.Unlock()
 		return err
 	}
 
-	metaData, err := json.Marshal(dataset.MetaData)
-	if err != nil {
-		return err
+	r := db.Dataset{}
+	if dataset.Spec != nil {
+		r.Format = dataset.Spec.Format
+		r.URL = dataset.Spec.DataURL
+	}
+	dataSource := dataset.DataSource
+	if dataSource != nil {
+		r.NumberOfSamples = dataSource.NumberOfSamples
 	}
+	dm.datasetLock.Unlock()
 
-	spec, err := json.Marshal(dataset.Spec)
+	meta, err := newResourceMeta(name, DatasetResourceKind, payload)
 	if err != nil {
 		return err
 	}
+	r.ResourceMeta = meta
 
-	r := db.Resource{
-		Name:       name,
-		APIVersion: dataset.APIVersion,
-		Kind:       dataset.Kind,
-		MetaData:   string(metaData),
-		Spec:       string(spec),
-	}
-
-	if err = db.SaveResource(&r); err != nil {
+	// keep the samples counted before, which are the baseline of the samples added after LC restarts
+	existing, err := db.GetDataset(name)
+	if err != nil {
 		return err
 	}
+	if existing != nil {
+		r.SamplesAdded = existing.SamplesAdded
+		if dataSource == nil {
+			r.NumberOfSamples = existing.NumberOfSamples
+		}
+	}
 
-	return nil
+	return db.SaveDataset(&r)
 }
 
 // deleteDataset deletes dataset config in db
 func (dm *DatasetManager) deleteDataset(name string) error {
-	if err := db.DeleteResource(name); err != nil {
+	if err := db.DeleteDataset(name); err != nil {
 		return err
 	}
 
-	if datasetChannel := dm.GetDatasetChannel(name); datasetChannel != nil {
+	dm.stopDataSourceWatch(name)
+
+	dm.datasetLock.Lock()
+	if datasetChannel := dm.DatasetChannelMap[name]; datasetChannel != nil {
 		close(datasetChannel)
 		delete(dm.DatasetChannelMap, name)
 	}
-
 	delete(dm.DatasetMap, name)
-
-	delete(dm.DataSourcesSignal, name)
+	dm.datasetLock.Unlock()
 
 	return nil
 }
 
-// monitorDataSources monitors the data sources
-func (dm *DatasetManager) monitorDataSources(message *wsclient.Message, uniqueIdentifier string) {
-	for dm.DataSourcesSignal[uniqueIdentifier] {
-		time.Sleep(time.Duration(MonitorDataSourceIntervalSeconds) * time.Second)
-
-		ds, ok := dm.DatasetMap[uniqueIdentifier]
-		if !ok {
-			break
-		}
+// updateDataSourceWatch watches the data source of the dataset,
+// the previous watch is replaced if the data source changed
+func (dm *DatasetManager) updateDataSourceWatch(name string, header wsclient.MessageHeader) {
+	dm.datasetLock.RLock()
+	var spec DatasetSpec
+	if ds, ok := dm.DatasetMap[name]; ok && ds.Spec != nil {
+		spec = *ds.Spec
+	}
+	dm.datasetLock.RUnlock()
 
-		if ds.Spec == nil {
-			continue
-		}
+	path := ""
+	if spec.DataURL != "" {
+		path = util.AddPrefixPath(dm.VolumeMountPrefix, filepath.Join(spec.DataURL))
+	}
 
-		if ds.Spec.DataURL == "" {
-			klog.Errorf("dataset(name=%s) not found valid data source url.", uniqueIdentifier)
-			break
-		}
+	dm.watchLock.Lock()
+	defer dm.watchLock.Unlock()
 
-		dataURL := util.AddPrefixPath(dm.VolumeMountPrefix, filepath.Join(ds.Spec.DataURL))
-		dataSource, err := dm.getDataSource(dataURL, ds.Spec.Format)
-		if err != nil {
-			klog.Errorf("dataset(name=%s) get samples from %s failed", uniqueIdentifier, dataURL)
-			continue
+	if w, ok := dm.dataSourceWatches[name]; ok {
+		if w.path == path && w.format == spec.Format {
+			return
 		}
-		ds.DataSource = dataSource
-
-		klog.Infof("dataset(name=%s) get samples from data source(url=%s) successfully. number of samples: %d",
-			uniqueIdentifier, dataURL, dataSource.NumberOfSamples)
-
-		message.Header.Operation = StatusOperation
-		if err := dm.Client.WriteMessage(struct {
-			NameSpace       string `json:"namespace"`
-			Name            string `json:"name"`
-			NumberOfSamples int    `json:"numberOfSamples"`
-		}{
-			message.Header.ResourceName,
-			message.Header.ResourceName,
-			dataSource.NumberOfSamples,
-		}, message.Header); err != nil {
-			klog.Errorf("dataset(name=%s) publish samples info failed", uniqueIdentifier)
-		}
-
-		dm.addNewDataset(uniqueIdentifier, *ds)
+		dm.removeDataSourceWatch(name)
 	}
-}
 
-// getDataSource gets data source info
-func (dm *DatasetManager) getDataSource(dataURL string, format string) (*DataSource, error) {
-	switch format {
-	case "txt":
-		return dm.readByLine(dataURL)
-	}
-	return nil, fmt.Errorf("not vaild file format")
-}
-
-// readByLine reads file by line
-func (dm *DatasetManager) readByLine(url string) (*DataSource, error) {
-	samples, err := getSamples(url)
-	if err != nil {
-		klog.Errorf("read file %s failed, error: %v", url, err)
-		return nil, err
+	if path == "" {
+		klog.Errorf("dataset(name=%s) not found valid data source url.", name)
+		return
 	}
 
-	numberOfSamples := 0
-	numberOfSamples += len(samples)
-
-	dataSource := DataSource{
-		TrainSamples:    samples,
-		NumberOfSamples: numberOfSamples,
+	w := newDataSourceWatch(name, header, path, spec.Format)
+	// the samples added are counted since the ones counted before LC restarts
+	if r, err := db.GetDataset(name); err != nil {
+		klog.Errorf("dataset(name=%s) get the samples counted failed, error: %v", name, err)
+	} else if r != nil {
+		w.countedSamples = r.NumberOfSamples
+		w.samplesAdded = r.SamplesAdded
 	}
-
-	return &dataSource, nil
+	dm.dataSourceWatches[name] = w
+	go dm.monitorDataSource(w)
 }
 
-// getSamples gets samples in a file
-func getSamples(url string) ([]string, error) {
-	var samples = []string{}
-	if !util.IsExists(url) {
-		return nil, fmt.Errorf("url(%s) does not exist", url)
-	}
+// stopDataSourceWatch stops watching the data source of the dataset
+func (dm *DatasetManager) stopDataSourceWatch(name string) {
+	dm.watchLock.Lock()
+	defer dm.watchLock.Unlock()
 
-	if !util.IsFile(url) {
-		return nil, fmt.Errorf("url(%s) is not a file, not vaild", url)
-	}
+	dm.removeDataSourceWatch(name)
+}
 
-	file, err := os.Open(url)
-	if err != nil {
-		klog.Errorf("read %s failed, error: %v", url, err)
-		return samples, err
+// removeDataSourceWatch stops and removes the watch of the dataset, the caller must hold watchLock
+func (dm *DatasetManager) removeDataSourceWatch(name string) {
+	if w, ok := dm.dataSourceWatches[name]; ok {
+		close(w.stop)
+		delete(dm.dataSourceWatches, name)
 	}
+}
 
-	fileScanner := bufio.NewScanner(file)
-	for fileScanner.Scan() {
-		samples = append(samples, fileScanner.Text())
-	}
+// GetDataSource gets the latest data source of the dataset, nil if not scanned yet
+func (dm *DatasetManager) GetDataSource(name string) *DataSource {
+	dm.datasetLock.RLock()
+	defer dm.datasetLock.RUnlock()
 
-	if err = file.Close(); err != nil {
-		klog.Errorf("close file(url=%s) failed, error: %v", url, err)
-		return samples, err
+	ds, ok := dm.DatasetMap[name]
+	if !ok {
+		return nil
 	}
-
-	return samples, nil
+	return ds.DataSource
 }
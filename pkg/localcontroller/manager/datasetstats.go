@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/util"
+)
+
+// datasetStatus defines the status of the dataset reported to GlobalManager,
+// see DatasetStatus of the api
+type datasetStatus struct {
+	Namespace         string         `json:"namespace"`
+	Name              string         `json:"name"`
+	NumberOfSamples   int            `json:"numberOfSamples"`
+	SamplesAdded      int            `json:"samplesAdded,omitempty"`
+	CheckedSamples    int            `json:"checkedSamples,omitempty"`
+	TotalBytes        int64          `json:"totalBytes,omitempty"`
+	MissingSamples    int            `json:"missingSamples,omitempty"`
+	LabelDistribution map[string]int `json:"labelDistribution,omitempty"`
+	LastModifiedTime  *time.Time     `json:"lastModifiedTime,omitempty"`
+}
+
+// parseSample parses the sample line of the format into the path of the sample file and its label,
+// e.g. `images/1.jpg,0` of txt
+func parseSample(format, line string) (path string, label string, labeled bool) {
+	switch format {
+	case "txt":
+		parts := strings.SplitN(line, ",", 2)
+		path = strings.TrimSpace(parts[0])
+		if len(parts) == 2 {
+			return path, strings.TrimSpace(parts[1]), true
+		}
+	}
+	return path, "", false
+}
+
+// samplePath returns the path in LC of the sample file, which is relative to the directory
+// of the data source unless absolute
+func (dm *DatasetManager) samplePath(dataSourcePath, path string) string {
+	path = filepath.FromSlash(strings.ReplaceAll(path, "\\", "/"))
+	if filepath.IsAbs(path) {
+		return util.AddPrefixPath(dm.VolumeMountPrefix, path)
+	}
+	return filepath.Join(filepath.Dir(dataSourcePath), path)
+}
+
+// computeDatasetStatus computes the status of the samples read from the data source.
+// The labels are counted incrementally for the samples appended, while only the latest
+// StatsMaxSamples sample files are checked, which bounds the cost of a scan.
+func (dm *DatasetManager) computeDatasetStatus(w *dataSourceWatch, dataSource *DataSource) *datasetStatus {
+	samples := dataSource.TrainSamples
+	status := &datasetStatus{
+		Namespace:         w.header.Namespace,
+		Name:              w.header.ResourceName,
+		NumberOfSamples:   dataSource.NumberOfSamples,
+		SamplesAdded:      w.countSamplesAdded(dataSource.NumberOfSamples),
+		LabelDistribution: w.labelDistribution(),
+	}
+
+	lastModified := w.file.ModTime()
+	checkFrom := len(samples) - dm.StatsMaxSamples
+	if checkFrom < 0 {
+		checkFrom = 0
+	}
+	for _, sample := range samples[checkFrom:] {
+		path, _, _ := parseSample(w.format, sample)
+		if path == "" {
+			continue
+		}
+
+		status.CheckedSamples++
+		info, err := statReadable(dm.samplePath(w.path, path))
+		if err != nil {
+			status.MissingSamples++
+			continue
+		}
+		status.TotalBytes += info.Size()
+		if info.ModTime().After(lastModified) {
+			lastModified = info.ModTime()
+		}
+	}
+
+	status.LastModifiedTime = &lastModified
+	return status
+}
+
+// countSamplesAdded adds the growth of the number of samples since counted lastly to the total
+// number of samples added, and returns the total. The total is reported rather than the growth
+// since the previous report, since only the latest status is kept in the outbox when GM is
+// unreachable, and the samples removed are not subtracted.
+func (w *dataSourceWatch) countSamplesAdded(numberOfSamples int) int {
+	if numberOfSamples > w.countedSamples {
+		w.samplesAdded += numberOfSamples - w.countedSamples
+	}
+	w.countedSamples = numberOfSamples
+	return w.samplesAdded
+}
+
+// labelDistribution returns the number of samples of each label, nil if none is labeled.
+// Only the lines read since the last call are counted, and the last line being written is
+// counted until completed.
+func (w *dataSourceWatch) labelDistribution() map[string]int {
+	for ; w.labeledLines < len(w.samples); w.labeledLines++ {
+		if _, label, labeled := parseSample(w.format, w.samples[w.labeledLines]); labeled {
+			if w.labels == nil {
+				w.labels = make(map[string]int)
+			}
+			w.labels[label]++
+		}
+	}
+
+	var distribution map[string]int
+	if len(w.labels) > 0 {
+		distribution = make(map[string]int, len(w.labels)+1)
+		for label, n := range w.labels {
+			distribution[label] = n
+		}
+	}
+	if w.tail != "" {
+		if _, label, labeled := parseSample(w.format, strings.TrimSuffix(w.tail, "\r")); labeled {
+			if distribution == nil {
+				distribution = make(map[string]int, 1)
+			}
+			distribution[label]++
+		}
+	}
+	return distribution
+}
+
+// statReadable returns the info of the file if it's a regular file and readable
+func statReadable(path string) (os.FileInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, os.ErrInvalid
+	}
+	return info, nil
+}
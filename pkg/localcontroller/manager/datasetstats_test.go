@@ -0,0 +1,119 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/wsclient"
+)
+
+func TestComputeDatasetStatus(t *testing.T) {
+	dir := tempDir(t)
+	writeFiles(t, dir, map[string]string{"images/1.jpg": "1", "images/2.jpg": "22", "images/3.jpg": "333"})
+	path := filepath.Join(dir, "train.txt")
+	dm := &DatasetManager{StatsMaxSamples: 2}
+	w := newDataSourceWatch("dataset1", wsclient.MessageHeader{Namespace: "default", ResourceName: "dataset1"}, path, "txt")
+
+	// scan writes the data source and computes its status
+	scan := func(flag int, content string) *datasetStatus {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if _, err = w.read(true); err != nil {
+			t.Fatal(err)
+		}
+		dataSource, _ := w.dataSource()
+		return dm.computeDatasetStatus(w, dataSource)
+	}
+
+	status := scan(os.O_TRUNC, "images/1.jpg,cat\nimages/2.jpg,dog\n")
+	if status.NumberOfSamples != 2 || status.SamplesAdded != 2 {
+		t.Errorf("created: expected 2 samples added of 2, got %d of %d", status.SamplesAdded, status.NumberOfSamples)
+	}
+	if expected := map[string]int{"cat": 1, "dog": 1}; !reflect.DeepEqual(status.LabelDistribution, expected) {
+		t.Errorf("created: expected labels %v, got %v", expected, status.LabelDistribution)
+	}
+	if status.CheckedSamples != 2 || status.TotalBytes != 3 || status.MissingSamples != 0 {
+		t.Errorf("created: expected 2 samples of 3 bytes checked, got %+v", status)
+	}
+
+	// the line being written is counted, while only the latest 2 sample files are checked
+	status = scan(os.O_APPEND, "images/3.jpg,cat\nimages/4.jpg,bi")
+	if status.NumberOfSamples != 4 || status.SamplesAdded != 4 {
+		t.Errorf("appended: expected 4 samples added of 4, got %d of %d", status.SamplesAdded, status.NumberOfSamples)
+	}
+	if expected := map[string]int{"cat": 2, "dog": 1, "bi": 1}; !reflect.DeepEqual(status.LabelDistribution, expected) {
+		t.Errorf("appended: expected labels %v, got %v", expected, status.LabelDistribution)
+	}
+	if status.CheckedSamples != 2 || status.TotalBytes != 3 || status.MissingSamples != 1 {
+		t.Errorf("appended: expected 2 samples checked with 1 missing, got %+v", status)
+	}
+
+	status = scan(os.O_APPEND, "rd\n")
+	if expected := map[string]int{"cat": 2, "dog": 1, "bird": 1}; !reflect.DeepEqual(status.LabelDistribution, expected) {
+		t.Errorf("line completed: expected labels %v, got %v", expected, status.LabelDistribution)
+	}
+
+	// the samples removed are not subtracted from the total added, and the labels are counted again
+	status = scan(os.O_TRUNC, "images/1.jpg,dog\n")
+	if status.NumberOfSamples != 1 || status.SamplesAdded != 4 {
+		t.Errorf("truncated: expected 4 samples added of 1, got %d of %d", status.SamplesAdded, status.NumberOfSamples)
+	}
+	if expected := map[string]int{"dog": 1}; !reflect.DeepEqual(status.LabelDistribution, expected) {
+		t.Errorf("truncated: expected labels %v, got %v", expected, status.LabelDistribution)
+	}
+
+	status = scan(os.O_APPEND, "images/2.jpg\nimages/3.jpg\n")
+	if status.NumberOfSamples != 3 || status.SamplesAdded != 6 {
+		t.Errorf("appended again: expected 6 samples added of 3, got %d of %d", status.SamplesAdded, status.NumberOfSamples)
+	}
+	if expected := map[string]int{"dog": 1}; !reflect.DeepEqual(status.LabelDistribution, expected) {
+		t.Errorf("appended unlabeled: expected labels %v, got %v", expected, status.LabelDistribution)
+	}
+}
+
+func TestCountSamplesAddedAfterRestart(t *testing.T) {
+	// the samples counted before LC restarts
+	w := newDataSourceWatch("dataset1", wsclient.MessageHeader{}, "", "txt")
+	w.countedSamples, w.samplesAdded = 10, 15
+
+	for _, c := range []struct {
+		numberOfSamples int
+		samplesAdded    int
+	}{
+		{10, 15},
+		{12, 17},
+		{5, 17},
+		{8, 20},
+	} {
+		if added := w.countSamplesAdded(c.numberOfSamples); added != c.samplesAdded {
+			t.Errorf("%d samples: expected %d added, got %d", c.numberOfSamples, c.samplesAdded, added)
+		}
+	}
+}
+
+func TestParseSample(t *testing.T) {
+	for _, c := range []struct {
+		line    string
+		path    string
+		label   string
+		labeled bool
+	}{
+		{"images/1.jpg,0", "images/1.jpg", "0", true},
+		{" images/1.jpg , cat ", "images/1.jpg", "cat", true},
+		{"images/1.jpg", "images/1.jpg", "", false},
+		{"images/1.jpg,a,b", "images/1.jpg", "a,b", true},
+	} {
+		path, label, labeled := parseSample("txt", c.line)
+		if path != c.path || label != c.label || labeled != c.labeled {
+			t.Errorf("%q: expected %q %q %v, got %q %q %v", c.line, c.path, c.label, c.labeled, path, label, labeled)
+		}
+	}
+}
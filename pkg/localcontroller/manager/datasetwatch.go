@@ -0,0 +1,435 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/metrics"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/wsclient"
+)
+
+const (
+	// dataSourceDebounceInterval is the quiet period after the last change of a data source
+	// before scanning it, so that a burst of writes is scanned once
+	dataSourceDebounceInterval = 500 * time.Millisecond
+	// dataSourceResyncInterval is the interval of checking the data sources watched by inotify,
+	// in case of the events missed, e.g. the directory of the data source is recreated
+	dataSourceResyncInterval = 5 * time.Minute
+	// dataSourceCheckSize is the size of the head and the tail of the lines read, which are
+	// checked unchanged before reading the lines appended to the data source
+	dataSourceCheckSize = 4096
+)
+
+// dataSourceWatch defines the watch of the data source of a dataset,
+// which reads the samples appended to the data source incrementally
+type dataSourceWatch struct {
+	name   string
+	header wsclient.MessageHeader
+	// path is the path of the data source in LC
+	path   string
+	format string
+
+	// trigger is notified once the data source may be changed
+	trigger chan struct{}
+	stop    chan struct{}
+
+	// file is the data source file read, which is read again from the beginning once replaced
+	file os.FileInfo
+	// offset is the end of the last complete line read
+	offset  int64
+	samples []string
+	hash    hash.Hash
+	// tail is the last line being written without the line break yet
+	tail string
+	// readChecksum is the checksum of the head and the tail of the lines read, see checkRead
+	readChecksum string
+	// labels is the number of each label of the first labeledLines samples read,
+	// which are counted incrementally
+	labels       map[string]int
+	labeledLines int
+
+	// countedSamples is the number of samples when samplesAdded counted lastly, and samplesAdded
+	// is the total number of samples added to the data source
+	countedSamples int
+	samplesAdded   int
+
+	// numberOfSamples and checksum are the last reported
+	numberOfSamples int
+	checksum        string
+}
+
+// newDataSourceWatch creates the watch of the data source of the dataset
+func newDataSourceWatch(name string, header wsclient.MessageHeader, path, format string) *dataSourceWatch {
+	return &dataSourceWatch{
+		name:            name,
+		header:          header,
+		path:            path,
+		format:          format,
+		trigger:         make(chan struct{}, 1),
+		stop:            make(chan struct{}),
+		numberOfSamples: -1,
+	}
+}
+
+// notify notifies the watch to scan the data source, without blocking
+func (w *dataSourceWatch) notify() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// reset forgets the samples read, so that the data source is read from the beginning
+func (w *dataSourceWatch) reset(file os.FileInfo) {
+	w.file = file
+	w.offset = 0
+	w.samples = nil
+	w.hash = sha256.New()
+	w.tail = ""
+	w.labels = nil
+	w.labeledLines = 0
+}
+
+// read reads the lines appended to the data source since the last read,
+// and returns whether the data source has been changed.
+// The data source is read again from the beginning once replaced, truncated or rewritten in place,
+// which is told by the lines read changed, see checkRead.
+func (w *dataSourceWatch) read(full bool) (bool, error) {
+	if w.format != "txt" {
+		return false, fmt.Errorf("not vaild file format %s", w.format)
+	}
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() {
+		return false, fmt.Errorf("url(%s) is not a file, not vaild", w.path)
+	}
+
+	end := w.offset + int64(len(w.tail))
+	if w.file != nil && os.SameFile(w.file, info) && info.Size() == end &&
+		info.ModTime().Equal(w.file.ModTime()) && !full {
+		return false, nil
+	}
+
+	f, err := os.Open(w.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	if w.file == nil || !os.SameFile(w.file, info) || info.Size() < end {
+		w.reset(info)
+	} else {
+		unchanged, err := w.checkRead(f, full)
+		if err != nil {
+			return false, err
+		}
+		if !unchanged {
+			klog.Infof("data source %s is rewritten, read it again", w.path)
+			w.reset(info)
+		}
+	}
+	w.file = info
+
+	if _, err = f.Seek(w.offset, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	reader := bufio.NewReader(f)
+	w.tail = ""
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			// the last line may be still being written, which is read again next time
+			w.tail = line
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+
+		w.offset += int64(len(line))
+		w.hash.Write([]byte(line))
+		w.samples = append(w.samples, strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+	}
+
+	if w.readChecksum, err = w.readEndsChecksum(f); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// checkRead returns whether the lines read are unchanged in the file. Only the head and the
+// tail of them are checked unless full, which is cheap enough for every change of the data source,
+// and all of them are checked against their checksum on the periodic resync.
+func (w *dataSourceWatch) checkRead(f *os.File, full bool) (bool, error) {
+	if full {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, io.NewSectionReader(f, 0, w.offset)); err != nil {
+			return false, err
+		}
+		return bytes.Equal(hash.Sum(nil), w.hash.Sum(nil)), nil
+	}
+
+	checksum, err := w.readEndsChecksum(f)
+	if err != nil {
+		return false, err
+	}
+	return checksum == w.readChecksum, nil
+}
+
+// readEndsChecksum returns the checksum of the first and the last dataSourceCheckSize bytes
+// of the lines read
+func (w *dataSourceWatch) readEndsChecksum(f *os.File) (string, error) {
+	headSize := w.offset
+	if headSize > dataSourceCheckSize {
+		headSize = dataSourceCheckSize
+	}
+	tailStart := w.offset - dataSourceCheckSize
+	if tailStart < headSize {
+		tailStart = headSize
+	}
+
+	hash := sha256.New()
+	for _, r := range []*io.SectionReader{
+		io.NewSectionReader(f, 0, headSize),
+		io.NewSectionReader(f, tailStart, w.offset-tailStart),
+	} {
+		if n, err := io.Copy(hash, r); err != nil {
+			return "", err
+		} else if n != r.Size() {
+			// truncated meanwhile
+			return "", nil
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// dataSource returns the samples read and the checksum of them
+func (w *dataSourceWatch) dataSource() (*DataSource, string) {
+	samples := make([]string, len(w.samples), len(w.samples)+1)
+	copy(samples, w.samples)
+
+	sum := w.hash.Sum(nil)
+	if w.tail != "" {
+		samples = append(samples, strings.TrimSuffix(w.tail, "\r"))
+		tailHash := sha256.New()
+		tailHash.Write(sum)
+		tailHash.Write([]byte(w.tail))
+		sum = tailHash.Sum(nil)
+	}
+
+	return &DataSource{
+		TrainSamples:    samples,
+		NumberOfSamples: len(samples),
+	}, hex.EncodeToString(sum)
+}
+
+// startWatcher starts watching the data sources by inotify,
+// the data sources are polled instead if failed
+func (dm *DatasetManager) startWatcher() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		klog.Warningf("create inotify watcher failed, the data sources are polled instead, error: %v", err)
+		return
+	}
+	dm.watcher = watcher
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				dm.watchLock.Lock()
+				for _, w := range dm.watchedFiles[filepath.Clean(event.Name)] {
+					w.notify()
+				}
+				dm.watchLock.Unlock()
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Errorf("inotify watcher of data sources failed, error: %v", err)
+			}
+		}
+	}()
+}
+
+// watchDataSource starts the watch of the data source, and returns whether it's watched by inotify.
+// The directory of the data source is watched so that the replaced data source is noticed.
+func (dm *DatasetManager) watchDataSource(w *dataSourceWatch) bool {
+	dm.watchLock.Lock()
+	defer dm.watchLock.Unlock()
+
+	dm.watchedFiles[w.path] = append(dm.watchedFiles[w.path], w)
+	if dm.watcher == nil {
+		return false
+	}
+
+	dir := filepath.Dir(w.path)
+	if dm.watchedDirs[dir] == 0 {
+		if err := dm.watcher.Add(dir); err != nil {
+			klog.Warningf("watch directory %s by inotify failed, the data source %s is polled instead, error: %v",
+				dir, w.path, err)
+			return false
+		}
+	}
+	dm.watchedDirs[dir]++
+	return true
+}
+
+// unwatchDataSource stops the watch of the data source
+func (dm *DatasetManager) unwatchDataSource(w *dataSourceWatch, watched bool) {
+	dm.watchLock.Lock()
+	defer dm.watchLock.Unlock()
+
+	watches := dm.watchedFiles[w.path]
+	for i := range watches {
+		if watches[i] == w {
+			watches = append(watches[:i], watches[i+1:]...)
+			break
+		}
+	}
+	if len(watches) == 0 {
+		delete(dm.watchedFiles, w.path)
+	} else {
+		dm.watchedFiles[w.path] = watches
+	}
+
+	if !watched {
+		return
+	}
+	dir := filepath.Dir(w.path)
+	if dm.watchedDirs[dir]--; dm.watchedDirs[dir] <= 0 {
+		delete(dm.watchedDirs, dir)
+		if err := dm.watcher.Remove(dir); err != nil {
+			klog.Warningf("remove inotify watch of directory %s failed, error: %v", dir, err)
+		}
+	}
+}
+
+// monitorDataSource scans the data source once it's changed until the watch stopped,
+// and reports the samples only if changed
+func (dm *DatasetManager) monitorDataSource(w *dataSourceWatch) {
+	watched := dm.watchDataSource(w)
+	defer dm.unwatchDataSource(w, watched)
+
+	interval := time.Duration(MonitorDataSourceIntervalSeconds) * time.Second
+	if watched {
+		interval = dataSourceResyncInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	w.notify()
+	for {
+		// the lines read are fully checked on the resync of the data sources watched by inotify,
+		// rather than every poll of the others
+		full := false
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			full = watched
+		case <-w.trigger:
+			if !debounce(w.trigger, w.stop) {
+				return
+			}
+		}
+
+		dm.scanDataSource(w, full)
+	}
+}
+
+// debounce waits until no more notifications within the debounce interval,
+// returns false if stopped meanwhile
+func debounce(trigger <-chan struct{}, stop <-chan struct{}) bool {
+	timer := time.NewTimer(dataSourceDebounceInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-trigger:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(dataSourceDebounceInterval)
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// scanDataSource reads the changes of the data source, and reports the samples if changed.
+// All the lines read before are checked unchanged if full.
+func (dm *DatasetManager) scanDataSource(w *dataSourceWatch, full bool) {
+	scanStart := time.Now()
+	changed, err := w.read(full)
+	metrics.DatasetScanDuration.Observe(time.Since(scanStart).Seconds())
+	if err != nil {
+		klog.Errorf("dataset(name=%s) get samples from %s failed, error: %v", w.name, w.path, err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	dataSource, checksum := w.dataSource()
+	if dataSource.NumberOfSamples == w.numberOfSamples && checksum == w.checksum {
+		return
+	}
+	status := dm.computeDatasetStatus(w, dataSource)
+	w.numberOfSamples = dataSource.NumberOfSamples
+	w.checksum = checksum
+
+	dm.datasetLock.Lock()
+	ds, ok := dm.DatasetMap[w.name]
+	if ok {
+		ds.DataSource = dataSource
+	}
+	dm.datasetLock.Unlock()
+	if !ok {
+		return
+	}
+
+	if err := db.UpdateDatasetSamples(w.name, dataSource.NumberOfSamples, w.samplesAdded); err != nil {
+		klog.Errorf("dataset(name=%s) saves samples info failed, error: %v", w.name, err)
+	}
+	metrics.DatasetSamples.WithLabelValues(w.header.Namespace, w.header.ResourceName).
+		Set(float64(dataSource.NumberOfSamples))
+	metrics.DatasetMissingSamples.WithLabelValues(w.header.Namespace, w.header.ResourceName).
+		Set(float64(status.MissingSamples))
+
+	klog.Infof("dataset(name=%s) get samples from data source(url=%s) successfully. number of samples: %d",
+		w.name, w.path, dataSource.NumberOfSamples)
+
+	header := w.header
+	header.Operation = StatusOperation
+	if err := dm.Client.WriteSnapshotMessage(status, header); err != nil {
+		klog.Errorf("dataset(name=%s) publish samples info failed", w.name)
+	}
+
+	dm.datasetLock.RLock()
+	dataset := *ds
+	dm.datasetLock.RUnlock()
+	dm.addNewDataset(w.name, dataset)
+}
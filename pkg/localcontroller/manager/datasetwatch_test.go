@@ -0,0 +1,89 @@
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/wsclient"
+)
+
+func TestDataSourceWatchRead(t *testing.T) {
+	path := filepath.Join(tempDir(t), "train.txt")
+	w := newDataSourceWatch("dataset1", wsclient.MessageHeader{}, path, "txt")
+	modTime := time.Now()
+
+	// write writes the data source in place, with a new modification time
+	write := func(flag int, content string) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		modTime = modTime.Add(time.Second)
+		if err = os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectSamples := func(step string, full bool, changed bool, samples ...string) {
+		c, err := w.read(full)
+		if err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		if c != changed {
+			t.Errorf("%s: expected changed %v, got %v", step, changed, c)
+		}
+		dataSource, _ := w.dataSource()
+		if !reflect.DeepEqual(dataSource.TrainSamples, samples) {
+			t.Errorf("%s: expected samples %v, got %v", step, samples, dataSource.TrainSamples)
+		}
+	}
+
+	write(os.O_TRUNC, "a.jpg\nb.jpg\n")
+	expectSamples("created", false, true, "a.jpg", "b.jpg")
+	expectSamples("unchanged", false, false, "a.jpg", "b.jpg")
+
+	write(os.O_APPEND, "c.jpg\nd.j")
+	expectSamples("appended", false, true, "a.jpg", "b.jpg", "c.jpg", "d.j")
+	write(os.O_APPEND, "pg\n")
+	expectSamples("line completed", false, true, "a.jpg", "b.jpg", "c.jpg", "d.jpg")
+
+	// rewritten in place to a larger size, which is not an append
+	write(0, "x.jpg\ny.jpg\nz.jpg\nw.jpg\nv.jpg\n")
+	expectSamples("rewritten larger", false, true, "x.jpg", "y.jpg", "z.jpg", "w.jpg", "v.jpg")
+
+	write(os.O_TRUNC, "a.jpg\n")
+	expectSamples("truncated", false, true, "a.jpg")
+
+	// a line changed out of the head and the tail checked is only noticed by the full check
+	lines := make([]string, 0, 2000)
+	for i := 0; i < cap(lines); i++ {
+		lines = append(lines, strings.Repeat("0", 9))
+	}
+	write(os.O_TRUNC, strings.Join(lines, "\n")+"\n")
+	expectSamples("long", false, true, lines...)
+	rewritten := append([]string(nil), lines...)
+	rewritten[1000] = strings.Repeat("1", 9)
+	write(0, strings.Join(rewritten, "\n")+"\nnew.jpg\n")
+	if _, err := w.read(true); err != nil {
+		t.Fatal(err)
+	}
+	if dataSource, _ := w.dataSource(); !reflect.DeepEqual(dataSource.TrainSamples, append(rewritten, "new.jpg")) {
+		t.Errorf("full check: expected the rewritten line read, got %v", dataSource.TrainSamples[1000])
+	}
+
+	if err := ioutil.WriteFile(path+".new", []byte("n.jpg\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		t.Fatal(err)
+	}
+	expectSamples("replaced", false, true, "n.jpg")
+}
@@ -26,7 +26,7 @@ type FederatedLearning struct {
 
 const (
 	//FederatedLearningJobKind is kind of federated-learning-job resource
-	FederatedLearningJobKind = "federatedlearningjob"
+	FederatedLearningJobKind = db.FederatedLearningJobKind
 )
 
 // NewFederatedLearningManager creates a federated-learning-job manager
@@ -66,6 +66,9 @@ func (fm *FederatedLearningManager) handleMessage(message *wsclient.Message) {
 		if err := fm.deleteJob(uniqueIdentifier); err != nil {
 			klog.Errorf("delete %s(name=%s) to db failed, error: %v", message.Header.ResourceKind, uniqueIdentifier, err)
 		}
+		if err := db.DeleteWorkers(message.Header.Namespace, message.Header.ResourceKind, message.Header.ResourceName); err != nil {
+			klog.Errorf("delete workers of %s(name=%s) in db failed, error: %v", message.Header.ResourceKind, uniqueIdentifier, err)
+		}
 	}
 }
 
@@ -95,6 +98,8 @@ func (fm *FederatedLearningManager) monitorWorker() {
 			},
 		}
 
+		recordWorkerStatus(&workerMessage, um.Output)
+
 		if err := fm.Client.WriteMessage(um, header); err != nil {
 			klog.Errorf("federated-learning-job(name=%s) uploads worker(name=%s) message failed, error: %v",
 				name, workerMessage.Name, err)
@@ -110,25 +115,16 @@ func (fm *FederatedLearningManager) insertJob(name string, payload []byte) error
 		return err
 	}
 
-	metaData, err := json.Marshal(federatedLearning.MetaData)
-	if err != nil {
-		return err
-	}
-
-	spec, err := json.Marshal(federatedLearning.Spec)
+	meta, err := newResourceMeta(name, FederatedLearningJobKind, payload)
 	if err != nil {
 		return err
 	}
 
-	r := db.Resource{
-		Name:       name,
-		APIVersion: federatedLearning.APIVersion,
-		Kind:       federatedLearning.Kind,
-		MetaData:   string(metaData),
-		Spec:       string(spec),
+	r := db.Job{
+		ResourceMeta: meta,
 	}
 
-	if err = db.SaveResource(&r); err != nil {
+	if err = db.SaveJob(&r); err != nil {
 		return err
 	}
 
@@ -137,7 +133,7 @@ func (fm *FederatedLearningManager) insertJob(name string, payload []byte) error
 
 // deleteJob deletes federated-learning-job config in db
 func (fm *FederatedLearningManager) deleteJob(name string) error {
-	if err := db.DeleteResource(name); err != nil {
+	if err := db.DeleteJob(name); err != nil {
 		return err
 	}
 
@@ -26,7 +26,7 @@ type JointInference struct {
 
 const (
 	// JointInferenceServiceKind is kind of joint-inference-service resource
-	JointInferenceServiceKind = "jointinferenceservice"
+	JointInferenceServiceKind = db.JointInferenceServiceKind
 )
 
 // NewJointInferenceManager creates a joint inference manager
@@ -67,6 +67,9 @@ func (jm *JointInferenceManager) handleMessage(message *wsclient.Message) {
 		if err := jm.deleteService(uniqueIdentifier); err != nil {
 			klog.Errorf("delete %s(name=%s) to db failed, error: %v", message.Header.ResourceKind, uniqueIdentifier, err)
 		}
+		if err := db.DeleteWorkers(message.Header.Namespace, message.Header.ResourceKind, message.Header.ResourceName); err != nil {
+			klog.Errorf("delete workers of %s(name=%s) in db failed, error: %v", message.Header.ResourceKind, uniqueIdentifier, err)
+		}
 	}
 }
 
@@ -95,6 +98,8 @@ func (jm *JointInferenceManager) monitorWorker() {
 			},
 		}
 
+		recordWorkerStatus(&workerMessage, um.Output)
+
 		if err := jm.Client.WriteMessage(um, header); err != nil {
 			klog.Errorf("joint-inference-service(name=%s) uploads worker(name=%s) message failed, error: %v",
 				name, workerMessage.Name, err)
@@ -110,25 +115,16 @@ func (jm *JointInferenceManager) insertService(name string, payload []byte) erro
 		return err
 	}
 
-	metaData, err := json.Marshal(jointInference.MetaData)
-	if err != nil {
-		return err
-	}
-
-	spec, err := json.Marshal(jointInference.Spec)
+	meta, err := newResourceMeta(name, JointInferenceServiceKind, payload)
 	if err != nil {
 		return err
 	}
 
-	r := db.Resource{
-		Name:       name,
-		APIVersion: jointInference.APIVersion,
-		Kind:       jointInference.Kind,
-		MetaData:   string(metaData),
-		Spec:       string(spec),
+	r := db.Job{
+		ResourceMeta: meta,
 	}
 
-	if err = db.SaveResource(&r); err != nil {
+	if err = db.SaveJob(&r); err != nil {
 		return err
 	}
 
@@ -137,7 +133,7 @@ func (jm *JointInferenceManager) insertService(name string, payload []byte) erro
 
 // deleteService deletes joint-inference-service config in db
 func (jm *JointInferenceManager) deleteService(name string) error {
-	if err := db.DeleteResource(name); err != nil {
+	if err := db.DeleteJob(name); err != nil {
 		return err
 	}
 
@@ -1,10 +1,15 @@
 package manager
 
 import (
+	"crypto"
 	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
 
 	"k8s.io/klog/v2"
 
+	"github.com/edgeai-neptune/neptune/cmd/neptune-lc/app/options"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/util"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/wsclient"
@@ -12,8 +17,22 @@ import (
 
 // ModelManager defines model manager
 type ModelManager struct {
-	Client          *wsclient.Client
-	ModelChannelMap map[string]chan Model
+	Client            *wsclient.Client
+	ModelChannelMap   map[string]chan Model
+	VolumeMountPrefix string
+	// CacheDir is the directory of the node caching the verified models
+	CacheDir string
+	// TrustedKeyFiles are the files of the public keys trusted to sign the models
+	TrustedKeyFiles []string
+
+	// modelLock protects ModelChannelMap and modelStates, and serializes
+	// saving the models and their verifications in db
+	modelLock sync.Mutex
+	// modelStates is the latest model received of each model name
+	modelStates map[string]*modelState
+	// verifying is the number of the models being verified, the model cache is not
+	// collected meanwhile, see gcModelCache
+	verifying int
 }
 
 // Model defines config for model
@@ -24,24 +43,79 @@ type Model struct {
 	Spec       *ModelSpec `json:"spec"`
 }
 
+// generation returns the generation of the model, 0 if unknown
+func (m *Model) generation() int64 {
+	if m.MetaData == nil {
+		return 0
+	}
+	return m.MetaData.Generation
+}
+
 // ModelSpec defines model spec
 type ModelSpec struct {
 	Format string `json:"format"`
 	URL    string `json:"url"`
+	// Digest is the expected digest of the model file, such as sha256:<hex>
+	Digest string `json:"digest,omitempty"`
+	// Size is the expected size in bytes of the model file
+	Size int64 `json:"size,omitempty"`
+	// Signature is the base64 encoded signature of the sha256 digest of the model file
+	Signature string `json:"signature,omitempty"`
+}
+
+// modelState defines the model being verified or verified by LC
+type modelState struct {
+	model     Model
+	spec      ModelSpec
+	verifying bool
+	// verification is the verification reported lastly, nil if being verified
+	verification *modelVerification
+}
+
+// modelVerification defines the verification of the model reported to GlobalManager
+type modelVerification struct {
+	// ObservedGeneration is the generation of the model verified
+	ObservedGeneration int64            `json:"observedGeneration,omitempty"`
+	Verified           bool             `json:"verified"`
+	Digest             string           `json:"digest,omitempty"`
+	Size               int64            `json:"size,omitempty"`
+	CachePath          string           `json:"cachePath,omitempty"`
+	Message            string           `json:"message,omitempty"`
+	LastVerifyTime     time.Time        `json:"lastVerifyTime"`
+	Conditions         []modelCondition `json:"conditions,omitempty"`
+}
+
+// modelCondition defines the condition of the verification reported to GlobalManager
+type modelCondition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
 }
 
 const (
 	// ModelChannelCacheSize is size of channel cache
 	ModelChannelCacheSize = 100
 	// ModelResourceKind is kind of dataset resource
-	ModelResourceKind = "model"
+	ModelResourceKind = db.ModelKind
+
+	// the conditions of the model verification, see ModelCondition of the api
+	modelIntegrityVerifiedCondition = "IntegrityVerified"
+	modelSignatureVerifiedCondition = "SignatureVerified"
+	conditionTrue                   = "True"
+	conditionFalse                  = "False"
 )
 
 // NewModelManager creates a model manager
-func NewModelManager(client *wsclient.Client) (*ModelManager, error) {
+func NewModelManager(client *wsclient.Client, options *options.LocalControllerOptions) (*ModelManager, error) {
 	mm := ModelManager{
-		ModelChannelMap: make(map[string]chan Model),
-		Client:          client,
+		ModelChannelMap:   make(map[string]chan Model),
+		modelStates:       make(map[string]*modelState),
+		Client:            client,
+		VolumeMountPrefix: options.VolumeMountPrefix,
+		CacheDir:          options.ModelCacheDir,
+		TrustedKeyFiles:   options.ModelTrustedKeyFiles,
 	}
 
 	if err := mm.initModelManager(); err != nil {
@@ -49,6 +123,12 @@ func NewModelManager(client *wsclient.Client) (*ModelManager, error) {
 		return nil, err
 	}
 
+	// nothing is being fetched before the models received
+	mm.removeStaleFetches()
+	mm.modelLock.Lock()
+	mm.gcModelCache()
+	mm.modelLock.Unlock()
+
 	return &mm, nil
 }
 
@@ -65,6 +145,9 @@ func (mm *ModelManager) initModelManager() error {
 
 // GetModelChannel gets model channel
 func (mm *ModelManager) GetModelChannel(name string) chan Model {
+	mm.modelLock.Lock()
+	defer mm.modelLock.Unlock()
+
 	m, ok := mm.ModelChannelMap[name]
 	if !ok {
 		return nil
@@ -72,13 +155,18 @@ func (mm *ModelManager) GetModelChannel(name string) chan Model {
 	return m
 }
 
-// addNewModel adds model to the channel
+// addNewModel adds model to the channel, the caller must hold modelLock.
+// The model is dropped if the channel is full, since GM resends the model for its status updates.
 func (mm *ModelManager) addNewModel(name string, model Model) {
 	if _, ok := mm.ModelChannelMap[name]; !ok {
 		mm.ModelChannelMap[name] = make(chan Model, ModelChannelCacheSize)
 	}
 
-	mm.ModelChannelMap[name] <- model
+	select {
+	case mm.ModelChannelMap[name] <- model:
+	default:
+		klog.Warningf("channel of model(name=%s) is full, drop the model", name)
+	}
 }
 
 // handleMessage handles the message from GlobalManager
@@ -86,7 +174,7 @@ func (mm *ModelManager) handleMessage(message *wsclient.Message) {
 	uniqueIdentifier := util.GetUniqueIdentifier(message.Header.Namespace, message.Header.ResourceName, message.Header.ResourceKind)
 	switch message.Header.Operation {
 	case InsertOperation:
-		if err := mm.insertModel(uniqueIdentifier, message.Content); err != nil {
+		if err := mm.insertModel(uniqueIdentifier, message.Header, message.Content); err != nil {
 			klog.Errorf("insert %s(name=%s) to db failed, error: %v", message.Header.ResourceKind, uniqueIdentifier, err)
 		}
 
@@ -97,46 +185,256 @@ func (mm *ModelManager) handleMessage(message *wsclient.Message) {
 	}
 }
 
-// insertModel inserts model config to db
-func (mm *ModelManager) insertModel(name string, payload []byte) error {
+// insertModel inserts model config to db, and verifies the model if its digest, size or signature
+// is specified, or the trusted keys are configured. The model is added to the channel only after verified.
+// The verification is reported for every generation of the model, including the ones not required to
+// be verified, since GlobalManager only starts the workers using the model on the node after reported.
+func (mm *ModelManager) insertModel(name string, header wsclient.MessageHeader, payload []byte) error {
+	verification, err := mm.saveModel(name, header, payload)
+	if err != nil || verification == nil {
+		return err
+	}
+
+	mm.reportVerification(name, header, verification)
+	return nil
+}
+
+// saveModel saves the model in db, and returns the verification to report if the model
+// is not verified again
+func (mm *ModelManager) saveModel(name string, header wsclient.MessageHeader, payload []byte) (*modelVerification, error) {
 	model := Model{}
 
 	if err := json.Unmarshal(payload, &model); err != nil {
-		return err
+		return nil, err
 	}
 
-	metaData, err := json.Marshal(model.MetaData)
+	meta, err := newResourceMeta(name, ModelResourceKind, payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	spec, err := json.Marshal(model.Spec)
+	var spec ModelSpec
+	if model.Spec != nil {
+		spec = *model.Spec
+	}
+
+	r := db.Model{
+		ResourceMeta: meta,
+		Format:       spec.Format,
+		URL:          spec.URL,
+		Digest:       spec.Digest,
+		Size:         spec.Size,
+		Signature:    spec.Signature,
+	}
+	r.VerifyRequired = mm.needVerify(&spec)
+
+	mm.modelLock.Lock()
+	defer mm.modelLock.Unlock()
+
+	existing, err := db.GetModel(name)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// keep the verification of the same model file
+	if existing != nil && sameModelFile(&spec, &ModelSpec{URL: existing.URL, Digest: existing.Digest,
+		Size: existing.Size, Signature: existing.Signature}) {
+		r.CachePath = existing.CachePath
+		r.Verified = existing.Verified
+		r.VerifyMessage = existing.VerifyMessage
+		r.VerifyTime = existing.VerifyTime
 	}
 
-	r := db.Resource{
-		Name:       name,
-		APIVersion: model.APIVersion,
-		Kind:       model.Kind,
-		MetaData:   string(metaData),
-		Spec:       string(spec),
+	if err = db.SaveModel(&r); err != nil {
+		return nil, err
 	}
 
-	if err = db.SaveResource(&r); err != nil {
-		return err
+	state, known := mm.modelStates[name]
+	if known && sameModelFile(&state.spec, &spec) {
+		// the model file has been verified or is being verified since LC started,
+		// which is not verified again for the status updates of the model
+		state.model = model
+		if state.verifying {
+			return nil, nil
+		}
+		if !r.VerifyRequired || r.Verified {
+			mm.addNewModel(name, model)
+		}
+		// the same verification holds for the new generation of the model
+		if state.verification != nil && state.verification.ObservedGeneration != model.generation() {
+			verification := *state.verification
+			verification.ObservedGeneration = model.generation()
+			state.verification = &verification
+			return &verification, nil
+		}
+		return nil, nil
+	}
+
+	state = &modelState{model: model, spec: spec}
+	mm.modelStates[name] = state
+
+	if !r.VerifyRequired {
+		mm.addNewModel(name, model)
+		state.verification = &modelVerification{
+			ObservedGeneration: model.generation(),
+			Verified:           true,
+			Message:            "verification is not required",
+			LastVerifyTime:     time.Now(),
+		}
+		return state.verification, nil
+	}
+
+	// the signature is verified again since the trusted keys may have been changed
+	if r.Verified && !mm.needSign(&spec) && mm.isCached(r.CachePath, spec.Size) {
+		mm.addNewModel(name, model)
+		now := time.Now()
+		state.verification = &modelVerification{
+			ObservedGeneration: model.generation(),
+			Verified:           true,
+			Digest:             r.Digest,
+			Size:               r.Size,
+			CachePath:          r.CachePath,
+			Message:            r.VerifyMessage,
+			LastVerifyTime:     r.VerifyTime,
+			Conditions: []modelCondition{{Type: modelIntegrityVerifiedCondition, Status: conditionTrue,
+				LastTransitionTime: now, Reason: "IntegrityVerified"}},
+		}
+		return state.verification, nil
+	}
+
+	state.verifying = true
+	mm.verifying++
+	go mm.verifyModel(name, header, state)
+
+	return nil, nil
+}
+
+// verifyModel fetches the model file into the cache and verifies it,
+// then saves and reports the result unless the model has been changed meanwhile
+func (mm *ModelManager) verifyModel(name string, header wsclient.MessageHeader, state *modelState) {
+	klog.Infof("verify model(name=%s) from %s", name, state.spec.URL)
+
+	start := time.Now()
+	verification := mm.verifyModelFile(&state.spec)
+	if !verification.Verified {
+		klog.Errorf("verify model(name=%s) failed, error: %s", name, verification.Message)
+	} else {
+		klog.Infof("model(name=%s) is verified and cached at %s in %v", name, verification.CachePath, time.Since(start))
+	}
+	cachePath := verification.CachePath
+
+	mm.modelLock.Lock()
+	mm.verifying--
+	if mm.modelStates[name] != state {
+		// the model fetched may be referenced by no model
+		mm.gcModelCache()
+		mm.modelLock.Unlock()
+		klog.Infof("drop the verification of model(name=%s) since the model has been changed", name)
+		return
+	}
+	state.verifying = false
+	// the model may have been updated meanwhile without changing its file
+	verification.ObservedGeneration = state.model.generation()
+	state.verification = &verification
+
+	if err := db.UpdateModelVerification(name, &db.ModelVerification{
+		CachePath:     cachePath,
+		Verified:      verification.Verified,
+		VerifyMessage: verification.Message,
+		VerifyTime:    verification.LastVerifyTime,
+	}); err != nil {
+		klog.Errorf("save verification of model(name=%s) failed, error: %v", name, err)
+	}
+	// the model cached before the model file changed is no longer referenced
+	mm.gcModelCache()
+
+	if verification.Verified {
+		mm.addNewModel(name, state.model)
+	}
+	mm.modelLock.Unlock()
+
+	mm.reportVerification(name, header, &verification)
+}
+
+// reportVerification reports the verification of the model to GlobalManager
+func (mm *ModelManager) reportVerification(name string, header wsclient.MessageHeader, verification *modelVerification) {
+	header.Operation = StatusOperation
+	if err := mm.Client.WriteSnapshotMessage(verification, header); err != nil {
+		klog.Errorf("model(name=%s) publish verification failed, error: %v", name, err)
 	}
+}
 
-	mm.addNewModel(name, model)
+// needVerify returns whether the model is only exposed after verified
+func (mm *ModelManager) needVerify(spec *ModelSpec) bool {
+	return spec.Digest != "" || spec.Size > 0 || mm.needSign(spec)
+}
 
-	return err
+// needSign returns whether the model is only exposed after its signature verified
+func (mm *ModelManager) needSign(spec *ModelSpec) bool {
+	return spec.Signature != "" || len(mm.TrustedKeyFiles) > 0
 }
 
-// deleteModel deletes model in db
+// verifyModelFile fetches the model file into the cache, then verifies its integrity and signature
+func (mm *ModelManager) verifyModelFile(spec *ModelSpec) modelVerification {
+	now := time.Now()
+	verification := modelVerification{LastVerifyTime: now}
+	setCondition := func(conditionType string, err error, reason string) {
+		c := modelCondition{Type: conditionType, Status: conditionTrue, LastTransitionTime: now, Reason: reason}
+		if err != nil {
+			c.Status = conditionFalse
+			c.Message = err.Error()
+		}
+		verification.Conditions = append(verification.Conditions, c)
+	}
+
+	cachePath, digest, size, err := mm.fetchModel(spec)
+	verification.Digest = digest
+	verification.Size = size
+	if err != nil {
+		setCondition(modelIntegrityVerifiedCondition, err, "IntegrityCheckFailed")
+		verification.Message = err.Error()
+		return verification
+	}
+	setCondition(modelIntegrityVerifiedCondition, nil, "IntegrityVerified")
+
+	if mm.needSign(spec) {
+		reason := "SignatureVerified"
+		var keys []crypto.PublicKey
+		switch {
+		case spec.Signature == "":
+			reason, err = "Unsigned", fmt.Errorf("model is not signed")
+		case len(mm.TrustedKeyFiles) == 0:
+			reason, err = "NoTrustedKeys", fmt.Errorf("no trusted key is configured to verify the signature")
+		default:
+			if keys, err = loadTrustedKeys(mm.TrustedKeyFiles); err != nil {
+				reason = "TrustedKeysUnavailable"
+			} else if err = verifyModelSignature(keys, digest, spec.Signature); err != nil {
+				reason = "SignatureInvalid"
+			}
+		}
+		setCondition(modelSignatureVerifiedCondition, err, reason)
+		if err != nil {
+			verification.Message = err.Error()
+			return verification
+		}
+	}
+
+	verification.Verified = true
+	verification.CachePath = cachePath
+	verification.Message = "model is verified"
+	return verification
+}
+
+// deleteModel deletes model in db, and removes its cached model unless referenced by other models
 func (mm *ModelManager) deleteModel(name string) error {
-	if err := db.DeleteResource(name); err != nil {
+	mm.modelLock.Lock()
+	defer mm.modelLock.Unlock()
+
+	if err := db.DeleteModel(name); err != nil {
 		return err
 	}
+	mm.gcModelCache()
+
+	delete(mm.modelStates, name)
 
 	if modelChannel := mm.ModelChannelMap[name]; modelChannel != nil {
 		close(modelChannel)
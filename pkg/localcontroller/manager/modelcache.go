@@ -0,0 +1,374 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/util"
+)
+
+const (
+	// sha256DigestPrefix is the prefix of the sha256 digests of the models
+	sha256DigestPrefix = "sha256:"
+
+	// modelFetchTimeout is the timeout of fetching a model over http
+	modelFetchTimeout = 30 * time.Minute
+
+	// fetchDirPrefix is the prefix of the temporary directories of the models being fetched
+	fetchDirPrefix = ".fetch-"
+)
+
+// modelHTTPClient is the client fetching the models over http
+var modelHTTPClient = &http.Client{Timeout: modelFetchTimeout}
+
+// sameModelFile returns whether the specs refer to the same model file
+func sameModelFile(a, b *ModelSpec) bool {
+	return a.URL == b.URL && a.Digest == b.Digest && a.Size == b.Size && a.Signature == b.Signature
+}
+
+// isCached returns whether the model of the node path is still in the cache, with the size
+// if not zero and the model is a file
+func (mm *ModelManager) isCached(cachePath string, size int64) bool {
+	if cachePath == "" {
+		return false
+	}
+
+	info, err := os.Stat(util.AddPrefixPath(mm.VolumeMountPrefix, cachePath))
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		return true
+	}
+	return info.Mode().IsRegular() && (size == 0 || info.Size() == size)
+}
+
+// fetchModel fetches the model of the spec into the cache, named by its sha256 digest,
+// and returns the node path of the cache, the digest and the size of the model fetched.
+// The model is only moved into the cache after fully fetched and verified, so the cache
+// never holds a partial or corrupted model.
+// A model of a directory of the node is copied as a whole, see hashDir for its digest.
+func (mm *ModelManager) fetchModel(spec *ModelSpec) (string, string, int64, error) {
+	expected := ""
+	if spec.Digest != "" {
+		if !strings.HasPrefix(spec.Digest, sha256DigestPrefix) {
+			return "", "", 0, fmt.Errorf("unsupported digest %s, only %s is supported", spec.Digest, sha256DigestPrefix)
+		}
+		expected = strings.TrimPrefix(spec.Digest, sha256DigestPrefix)
+	}
+
+	cacheDir := filepath.Join(mm.CacheDir, "sha256")
+	localCacheDir := util.AddPrefixPath(mm.VolumeMountPrefix, cacheDir)
+
+	// reuse the cached model after checking it's intact
+	if expected != "" {
+		cached := filepath.Join(localCacheDir, expected)
+		if digest, size, err := hashPath(cached); err == nil {
+			if digest == expected && (spec.Size == 0 || size == spec.Size) {
+				return filepath.Join(cacheDir, expected), spec.Digest, size, nil
+			}
+			klog.Warningf("cached model %s is corrupted, fetch it again", cached)
+			if err = os.RemoveAll(cached); err != nil {
+				return "", "", 0, fmt.Errorf("failed to remove the corrupted model %s: %v", cached, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return "", "", 0, err
+		}
+	}
+
+	if err := os.MkdirAll(localCacheDir, 0755); err != nil {
+		return "", "", 0, err
+	}
+
+	tmp, err := ioutil.TempDir(localCacheDir, fetchDirPrefix)
+	if err != nil {
+		return "", "", 0, err
+	}
+	// the temporary directory is left only if failed
+	defer os.RemoveAll(tmp)
+
+	fetched := filepath.Join(tmp, "model")
+	if err = mm.copyModel(spec.URL, fetched); err != nil {
+		return "", "", 0, fmt.Errorf("failed to fetch model from %s: %v", spec.URL, err)
+	}
+
+	actual, size, err := hashPath(fetched)
+	if err != nil {
+		return "", "", size, err
+	}
+	digest := sha256DigestPrefix + actual
+	if spec.Size > 0 && size != spec.Size {
+		return "", digest, size, fmt.Errorf("size %d of model from %s mismatches the expected size %d",
+			size, spec.URL, spec.Size)
+	}
+	if expected != "" && actual != expected {
+		return "", digest, size, fmt.Errorf("digest %s of model from %s mismatches the expected digest %s",
+			digest, spec.URL, spec.Digest)
+	}
+
+	// the same model may have been cached meanwhile by another verification, which is
+	// kept since it may be mounted into the workers
+	target := filepath.Join(localCacheDir, actual)
+	if cached, _, err := hashPath(target); err == nil && cached == actual {
+		return filepath.Join(cacheDir, actual), digest, size, nil
+	}
+	if err = os.RemoveAll(target); err != nil {
+		return "", digest, size, err
+	}
+	if err = os.Rename(fetched, target); err != nil {
+		return "", digest, size, err
+	}
+	return filepath.Join(cacheDir, actual), digest, size, nil
+}
+
+// gcModelCache removes the cached models which no model refers to, i.e. the ones of the models
+// deleted or changed since. The caller must hold modelLock. It's skipped while any model is being
+// verified, whose model fetched is not referenced until its verification saved, and done again
+// after the last verification saved.
+func (mm *ModelManager) gcModelCache() {
+	if mm.verifying > 0 {
+		return
+	}
+
+	models, err := db.ListModels("")
+	if err != nil {
+		klog.Errorf("list models for collecting the model cache failed, error: %v", err)
+		return
+	}
+	referenced := make(map[string]bool)
+	for _, m := range models {
+		if m.CachePath != "" {
+			referenced[filepath.Clean(m.CachePath)] = true
+		}
+	}
+	mm.removeUnreferencedModels(referenced)
+}
+
+// removeUnreferencedModels removes the cached models whose node paths are not referenced,
+// the temporary directories of the models being fetched are kept
+func (mm *ModelManager) removeUnreferencedModels(referenced map[string]bool) {
+	cacheDir := filepath.Join(mm.CacheDir, "sha256")
+	entries, err := ioutil.ReadDir(util.AddPrefixPath(mm.VolumeMountPrefix, cacheDir))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			klog.Errorf("read the model cache %s failed, error: %v", cacheDir, err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), fetchDirPrefix) {
+			continue
+		}
+		cachePath := filepath.Join(cacheDir, entry.Name())
+		if referenced[cachePath] {
+			continue
+		}
+		if err = os.RemoveAll(util.AddPrefixPath(mm.VolumeMountPrefix, cachePath)); err != nil {
+			klog.Errorf("remove the unreferenced model %s from the cache failed, error: %v", cachePath, err)
+			continue
+		}
+		klog.Infof("removed the unreferenced model %s from the cache", cachePath)
+	}
+}
+
+// removeStaleFetches removes the temporary directories of the models fetched by the last LC,
+// e.g. killed while fetching, which must be called before any model fetched.
+func (mm *ModelManager) removeStaleFetches() {
+	cacheDir := filepath.Join(mm.CacheDir, "sha256")
+	stale, err := filepath.Glob(filepath.Join(util.AddPrefixPath(mm.VolumeMountPrefix, cacheDir), fetchDirPrefix+"*"))
+	if err != nil {
+		klog.Errorf("find the stale fetches in the model cache %s failed, error: %v", cacheDir, err)
+		return
+	}
+	for _, dir := range stale {
+		if err = os.RemoveAll(dir); err != nil {
+			klog.Errorf("remove the stale fetch %s failed, error: %v", dir, err)
+			continue
+		}
+		klog.Infof("removed the stale fetch %s from the model cache", dir)
+	}
+}
+
+// copyModel copies the model of the url, which is a http(s) url of a file or a path of the node,
+// to dst
+func (mm *ModelManager) copyModel(url, dst string) error {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		resp, err := modelHTTPClient.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s", resp.Status)
+		}
+		return copyFile(resp.Body, dst)
+	}
+
+	src := util.AddPrefixPath(mm.VolumeMountPrefix, url)
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return copyDir(src, dst)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("model %s is neither a regular file nor a directory", url)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return copyFile(f, dst)
+}
+
+// copyFile writes the content of src to the file dst, and syncs it to the disk
+func copyFile(src io.Reader, dst string) error {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, src)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// copyDir copies the directory src to dst, which only contains the regular files and
+// the directories, so that a model never refers to the files out of it by the symlinks
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0755)
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return copyFile(f, target)
+		default:
+			return fmt.Errorf("%s of the model directory is neither a regular file nor a directory", rel)
+		}
+	})
+}
+
+// hashPath returns the sha256 digest in hex and the size of the model file or directory
+func hashPath(path string) (string, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	if info.IsDir() {
+		return hashDir(path)
+	}
+	return hashFile(path)
+}
+
+// hashFile returns the sha256 digest in hex and the size of the file
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// dirManifest returns the manifest of the files in the directory and their total size.
+// The manifest has a line of "<sha256 in hex>  <path>" for each regular file, whose path
+// is relative to the directory and separated by slashes, sorted by the paths in bytes,
+// i.e. the output of `find . -type f | sed 's|^\./||' | LC_ALL=C sort | xargs -d '\n' sha256sum`
+// in the directory. The directories are ignored except for their files, and the other files like the
+// symlinks are rejected.
+func dirManifest(dir string) ([]byte, int64, error) {
+	type entry struct {
+		path   string
+		digest string
+	}
+	var entries []entry
+	var total int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s of the model directory is neither a regular file nor a directory", rel)
+		}
+
+		digest, size, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{path: filepath.ToSlash(rel), digest: digest})
+		total += size
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].path < entries[j].path
+	})
+	var manifest bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&manifest, "%s  %s\n", e.digest, e.path)
+	}
+	return manifest.Bytes(), total, nil
+}
+
+// hashDir returns the sha256 digest in hex of the manifest of the directory, see dirManifest,
+// and the total size of its files
+func hashDir(dir string) (string, int64, error) {
+	manifest, size, err := dirManifest(dir)
+	if err != nil {
+		return "", 0, err
+	}
+	sum := sha256.Sum256(manifest)
+	return hex.EncodeToString(sum[:]), size, nil
+}
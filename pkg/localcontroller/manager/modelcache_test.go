@@ -0,0 +1,206 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFiles writes the files of the paths relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "modelcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestHashDir(t *testing.T) {
+	dir := tempDir(t)
+	writeFiles(t, dir, map[string]string{
+		"model.pb":               "pb",
+		"checkpoint":             "ckpt",
+		"variables/variables.0":  "v0",
+		"variables/variables.10": "v10",
+		"B":                      "upper",
+	})
+	if err := os.MkdirAll(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// sorted by the paths in bytes, and the empty directory is ignored
+	expected := fmt.Sprintf("%s  B\n%s  checkpoint\n%s  model.pb\n%s  variables/variables.0\n%s  variables/variables.10\n",
+		sha256Hex("upper"), sha256Hex("ckpt"), sha256Hex("pb"), sha256Hex("v0"), sha256Hex("v10"))
+
+	manifest, size, err := dirManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(manifest) != expected {
+		t.Errorf("expected manifest:\n%s\ngot:\n%s", expected, manifest)
+	}
+	if size != int64(len("pbckptv0v10upper")) {
+		t.Errorf("expected size %d, got %d", len("pbckptv0v10upper"), size)
+	}
+
+	digest, _, err := hashPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != sha256Hex(expected) {
+		t.Errorf("expected digest %s, got %s", sha256Hex(expected), digest)
+	}
+}
+
+func TestHashDirRejectsSymlinks(t *testing.T) {
+	dir := tempDir(t)
+	writeFiles(t, dir, map[string]string{"model.pb": "pb"})
+	if err := os.Symlink("/etc/passwd", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+
+	if _, _, err := hashDir(dir); err == nil {
+		t.Errorf("expected the symlink rejected")
+	}
+	mm := &ModelManager{CacheDir: tempDir(t)}
+	if _, _, _, err := mm.fetchModel(&ModelSpec{URL: dir}); err == nil {
+		t.Errorf("expected the model with the symlink failed to fetch")
+	}
+}
+
+func TestFetchModel(t *testing.T) {
+	src := tempDir(t)
+	file := filepath.Join(src, "model.pb")
+	writeFiles(t, src, map[string]string{"model.pb": "pb"})
+	modelDir := filepath.Join(src, "ckpt")
+	writeFiles(t, modelDir, map[string]string{"checkpoint": "ckpt", "variables/variables.0": "v0"})
+
+	manifest := fmt.Sprintf("%s  checkpoint\n%s  variables/variables.0\n", sha256Hex("ckpt"), sha256Hex("v0"))
+	fileDigest := sha256DigestPrefix + sha256Hex("pb")
+	dirDigest := sha256DigestPrefix + sha256Hex(manifest)
+
+	cases := []struct {
+		name  string
+		spec  ModelSpec
+		valid bool
+	}{
+		{"file", ModelSpec{URL: file, Digest: fileDigest, Size: 2}, true},
+		{"file without digest", ModelSpec{URL: file}, true},
+		{"file of wrong digest", ModelSpec{URL: file, Digest: dirDigest}, false},
+		{"file of wrong size", ModelSpec{URL: file, Digest: fileDigest, Size: 3}, false},
+		{"directory", ModelSpec{URL: modelDir, Digest: dirDigest, Size: 6}, true},
+		{"directory of wrong digest", ModelSpec{URL: modelDir, Digest: fileDigest}, false},
+		{"unsupported digest", ModelSpec{URL: file, Digest: "md5:0"}, false},
+		{"not found", ModelSpec{URL: filepath.Join(src, "none")}, false},
+	}
+	for _, c := range cases {
+		mm := &ModelManager{CacheDir: tempDir(t)}
+		cachePath, digest, _, err := mm.fetchModel(&c.spec)
+		if valid := err == nil; valid != c.valid {
+			t.Errorf("%s: expected valid %v, got error %v", c.name, c.valid, err)
+			continue
+		}
+
+		entries, _ := ioutil.ReadDir(filepath.Join(mm.CacheDir, "sha256"))
+		if !c.valid {
+			// neither the model nor the temporary directory is left in the cache
+			if len(entries) != 0 {
+				t.Errorf("%s: expected nothing cached, got %d entries", c.name, len(entries))
+			}
+			continue
+		}
+		if len(entries) != 1 {
+			t.Errorf("%s: expected only the model cached, got %d entries", c.name, len(entries))
+		}
+		if c.spec.Digest != "" && digest != c.spec.Digest {
+			t.Errorf("%s: expected digest %s, got %s", c.name, c.spec.Digest, digest)
+		}
+		if expected := filepath.Join(mm.CacheDir, "sha256", digest[len(sha256DigestPrefix):]); cachePath != expected {
+			t.Errorf("%s: expected cache path %s, got %s", c.name, expected, cachePath)
+		}
+		if actual, _, err := hashPath(cachePath); err != nil || sha256DigestPrefix+actual != digest {
+			t.Errorf("%s: expected the cached model of digest %s, got %s, %v", c.name, digest, actual, err)
+		}
+		if !mm.isCached(cachePath, c.spec.Size) {
+			t.Errorf("%s: expected the model cached", c.name)
+		}
+	}
+}
+
+func TestFetchModelReplacesCorruptedCache(t *testing.T) {
+	src := tempDir(t)
+	writeFiles(t, src, map[string]string{"checkpoint": "ckpt"})
+	digest := sha256DigestPrefix + sha256Hex(fmt.Sprintf("%s  checkpoint\n", sha256Hex("ckpt")))
+
+	mm := &ModelManager{CacheDir: tempDir(t)}
+	cachePath, _, _, err := mm.fetchModel(&ModelSpec{URL: src, Digest: digest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, cachePath, map[string]string{"checkpoint": "corrupted"})
+
+	if _, _, _, err = mm.fetchModel(&ModelSpec{URL: src, Digest: digest}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(cachePath, "checkpoint")); string(data) != "ckpt" {
+		t.Errorf("expected the corrupted model fetched again, got %q", data)
+	}
+}
+
+func TestRemoveUnreferencedModels(t *testing.T) {
+	mm := &ModelManager{CacheDir: tempDir(t)}
+	cacheDir := filepath.Join(mm.CacheDir, "sha256")
+	writeFiles(t, cacheDir, map[string]string{
+		"referenced":               "pb",
+		"unreferenced":             "pb",
+		"unreferenced-dir/ckpt":    "ckpt",
+		fetchDirPrefix + "1/model": "pb",
+	})
+
+	mm.removeUnreferencedModels(map[string]bool{filepath.Join(cacheDir, "referenced"): true})
+	expectCached := func(name string, expected ...string) {
+		t.Helper()
+		entries, err := ioutil.ReadDir(cacheDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if fmt.Sprint(names) != fmt.Sprint(expected) {
+			t.Errorf("%s: expected %v cached, got %v", name, expected, names)
+		}
+	}
+	// the models being fetched are kept
+	expectCached("collected", fetchDirPrefix+"1", "referenced")
+
+	// the fetches left by the last LC are removed at startup
+	mm.removeStaleFetches()
+	expectCached("stale fetches removed", "referenced")
+
+	// nothing to collect without the cache
+	(&ModelManager{CacheDir: filepath.Join(mm.CacheDir, "none")}).removeUnreferencedModels(nil)
+}
@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+)
+
+// loadTrustedKeys loads the public keys in PEM from the files, which are read on every
+// verification so that the keys can be rotated
+func loadTrustedKeys(files []string) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			if block.Type != "PUBLIC KEY" {
+				continue
+			}
+
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse public key in %s: %v", file, err)
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key found in %s", strings.Join(files, ","))
+	}
+	return keys, nil
+}
+
+// verifyModelSignature verifies the signature of the sha256 digest of the model by any of the keys.
+// The digest is signed in the raw 32 bytes by ed25519 keys, and as a prehashed sha256 digest
+// by ecdsa keys or rsa keys in PKCS #1 v1.5, e.g. `openssl dgst -sha256 -sign`.
+// The digest of a model directory is the one of its manifest, see dirManifest, so a directory
+// is signed by signing its manifest in the same way as a file.
+func verifyModelSignature(keys []crypto.PublicKey, digest, signature string) error {
+	sum, err := hex.DecodeString(strings.TrimPrefix(digest, sha256DigestPrefix))
+	if err != nil || !strings.HasPrefix(digest, sha256DigestPrefix) {
+		return fmt.Errorf("invalid digest %s", digest)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+
+	for _, key := range keys {
+		if verifySignature(key, sum, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %s is not signed by any trusted key", digest)
+}
+
+// verifySignature returns whether the signature of the sha256 sum is signed by the key
+func verifySignature(key crypto.PublicKey, sum, sig []byte) bool {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, sum, sig)
+	case *ecdsa.PublicKey:
+		var esig struct {
+			R, S *big.Int
+		}
+		if rest, err := asn1.Unmarshal(sig, &esig); err != nil || len(rest) != 0 {
+			return false
+		}
+		return ecdsa.Verify(k, sum, esig.R, esig.S)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, sum, sig) == nil
+	}
+	return false
+}
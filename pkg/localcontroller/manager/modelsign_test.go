@@ -0,0 +1,173 @@
+package manager
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+)
+
+// testSigner signs the sha256 sums, and writes its public key in PEM
+type testSigner struct {
+	name   string
+	public crypto.PublicKey
+	sign   func(sum []byte) []byte
+}
+
+func newTestSigners(t *testing.T) []testSigner {
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	must := func(sig []byte, err error) []byte {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	return []testSigner{
+		{"ed25519", edPublic, func(sum []byte) []byte { return ed25519.Sign(edPrivate, sum) }},
+		{"ecdsa", &ecKey.PublicKey, func(sum []byte) []byte {
+			r, s, err := ecdsa.Sign(rand.Reader, ecKey, sum)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return must(asn1.Marshal(struct{ R, S *big.Int }{r, s}))
+		}},
+		{"rsa", &rsaKey.PublicKey, func(sum []byte) []byte {
+			return must(rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sum))
+		}},
+	}
+}
+
+// writeKeyFile writes the public key in PEM into dir
+func (s *testSigner) writeKeyFile(t *testing.T, dir string) string {
+	der, err := x509.MarshalPKIXPublicKey(s.public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, s.name+".pem")
+	if err = ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// signDigest returns the base64 encoded signature of the digest of sha256:<hex>
+func (s *testSigner) signDigest(t *testing.T, digest string) string {
+	sum, err := hex.DecodeString(digest[len(sha256DigestPrefix):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(s.sign(sum))
+}
+
+func TestVerifyModelSignature(t *testing.T) {
+	signers := newTestSigners(t)
+	digest := sha256DigestPrefix + sha256Hex("pb")
+	other := sha256DigestPrefix + sha256Hex("other")
+
+	for i := range signers {
+		signer := &signers[i]
+		another := &signers[(i+1)%len(signers)]
+		signature := signer.signDigest(t, digest)
+
+		if err := verifyModelSignature([]crypto.PublicKey{another.public, signer.public}, digest, signature); err != nil {
+			t.Errorf("%s: expected verified by any of the keys, got %v", signer.name, err)
+		}
+		if err := verifyModelSignature([]crypto.PublicKey{another.public}, digest, signature); err == nil {
+			t.Errorf("%s: expected rejected by another key", signer.name)
+		}
+		if err := verifyModelSignature([]crypto.PublicKey{signer.public}, other, signature); err == nil {
+			t.Errorf("%s: expected rejected for another digest", signer.name)
+		}
+		if err := verifyModelSignature([]crypto.PublicKey{signer.public}, digest, "!"+signature); err == nil {
+			t.Errorf("%s: expected rejected for the malformed signature", signer.name)
+		}
+	}
+
+	if err := verifyModelSignature([]crypto.PublicKey{signers[0].public}, "md5:0", ""); err == nil {
+		t.Errorf("expected rejected for the unsupported digest")
+	}
+}
+
+func TestVerifyModelFileSignature(t *testing.T) {
+	signers := newTestSigners(t)
+	keyDir := tempDir(t)
+	keyFile := signers[0].writeKeyFile(t, keyDir)
+	otherKeyFile := signers[2].writeKeyFile(t, keyDir)
+
+	src := tempDir(t)
+	file := filepath.Join(src, "model.pb")
+	writeFiles(t, src, map[string]string{"model.pb": "pb"})
+	modelDir := filepath.Join(src, "ckpt")
+	writeFiles(t, modelDir, map[string]string{"checkpoint": "ckpt", "variables/variables.0": "v0"})
+
+	manifest, _, err := dirManifest(modelDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileDigest := sha256DigestPrefix + sha256Hex("pb")
+	dirDigest := sha256DigestPrefix + sha256Hex(string(manifest))
+
+	cases := []struct {
+		name      string
+		keyFiles  []string
+		spec      ModelSpec
+		verified  bool
+		condition string
+	}{
+		{"signed file", []string{keyFile}, ModelSpec{URL: file, Signature: signers[0].signDigest(t, fileDigest)}, true, conditionTrue},
+		{"signed directory", []string{otherKeyFile, keyFile}, ModelSpec{URL: modelDir, Digest: dirDigest,
+			Signature: signers[0].signDigest(t, dirDigest)}, true, conditionTrue},
+		{"unsigned directory", []string{keyFile}, ModelSpec{URL: modelDir}, false, conditionFalse},
+		{"directory signed by another key", []string{keyFile}, ModelSpec{URL: modelDir,
+			Signature: signers[2].signDigest(t, dirDigest)}, false, conditionFalse},
+		{"signature of another model", []string{keyFile}, ModelSpec{URL: modelDir,
+			Signature: signers[0].signDigest(t, fileDigest)}, false, conditionFalse},
+		{"signed without trusted keys", nil, ModelSpec{URL: file, Signature: signers[0].signDigest(t, fileDigest)}, false, conditionFalse},
+		{"trusted keys unavailable", []string{filepath.Join(keyDir, "none.pem")}, ModelSpec{URL: file,
+			Signature: signers[0].signDigest(t, fileDigest)}, false, conditionFalse},
+	}
+	for _, c := range cases {
+		mm := &ModelManager{CacheDir: tempDir(t), TrustedKeyFiles: c.keyFiles}
+		v := mm.verifyModelFile(&c.spec)
+		if v.Verified != c.verified {
+			t.Errorf("%s: expected verified %v, got %+v", c.name, c.verified, v)
+			continue
+		}
+		if c.verified != (v.CachePath != "") {
+			t.Errorf("%s: expected the cache path only if verified, got %q", c.name, v.CachePath)
+		}
+
+		status := ""
+		for _, condition := range v.Conditions {
+			if condition.Type == modelSignatureVerifiedCondition {
+				status = condition.Status
+			}
+		}
+		if status != c.condition {
+			t.Errorf("%s: expected condition %s of %s, got %q", c.name, modelSignatureVerifiedCondition, c.condition, status)
+		}
+	}
+}
@@ -1,5 +1,14 @@
 package manager
 
+import (
+	"encoding/json"
+
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/util"
+)
+
 const (
 	// WorkerMessageChannelCacheSize is size of channel cache
 	WorkerMessageChannelCacheSize = 100
@@ -10,6 +19,14 @@ const (
 	DeleteOperation = "delete"
 	// StatusOperation is the status value
 	StatusOperation = "status"
+	// WorkerHealthOperation is the operation reporting the liveness of a worker
+	WorkerHealthOperation = "workerhealth"
+
+	// HeartbeatKind is the kind of the worker messages only reporting the liveness
+	HeartbeatKind = "heartbeat"
+
+	// workerKind is the kind of the unique identifiers of the workers
+	workerKind = "worker"
 )
 
 // WorkerMessage defines message struct from worker
@@ -26,8 +43,11 @@ type WorkerMessage struct {
 
 // MetaData defines metadata
 type MetaData struct {
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion"`
+	// Generation is the generation of the spec of the resource
+	Generation int64 `json:"generation,omitempty"`
 }
 
 // UpstreamMessage defines send message to GlobalManager
@@ -43,6 +63,57 @@ type WorkerOutput struct {
 	OwnerInfo map[string]interface{}   `json:"ownerInfo"`
 }
 
+// newResourceMeta creates the row identity of the resource named name in db
+// from the object payload, including the identity in GlobalManager which LC
+// reports when resyncing
+func newResourceMeta(name, kind string, payload []byte) (db.ResourceMeta, error) {
+	object := struct {
+		APIVersion string          `json:"apiVersion"`
+		Kind       string          `json:"kind"`
+		MetaData   MetaData        `json:"metadata"`
+		Spec       json.RawMessage `json:"spec"`
+	}{}
+	if err := json.Unmarshal(payload, &object); err != nil {
+		return db.ResourceMeta{}, err
+	}
+
+	return db.ResourceMeta{
+		Name:            name,
+		Namespace:       object.MetaData.Namespace,
+		ResourceName:    object.MetaData.Name,
+		ResourceKind:    kind,
+		ResourceVersion: object.MetaData.ResourceVersion,
+		APIVersion:      object.APIVersion,
+		Kind:            object.Kind,
+		Spec:            string(object.Spec),
+	}, nil
+}
+
+// WorkerIdentifier returns the unique identifier of the worker in LC
+func WorkerIdentifier(namespace, name string) string {
+	return util.GetUniqueIdentifier(namespace, name, workerKind)
+}
+
+// recordWorkerStatus saves the status reported by the worker in db
+func recordWorkerStatus(message *WorkerMessage, output *WorkerOutput) {
+	data, err := json.Marshal(output)
+	if err != nil {
+		klog.Errorf("marshal output of worker(name=%s) failed, error: %v", message.Name, err)
+	}
+
+	status := db.WorkerStatus{
+		WorkerName: WorkerIdentifier(message.Namespace, message.Name),
+		Namespace:  message.Namespace,
+		OwnerKind:  message.OwnerKind,
+		OwnerName:  message.OwnerName,
+		Phase:      message.Kind,
+		Status:     message.Status,
+		Output:     string(data),
+	}
+	// the status is still reported to GlobalManager if failed to save
+	_ = db.AddWorkerStatus(&status)
+}
+
 // FeatureManager defines feature manager
 type FeatureManager interface {
 	Start() error
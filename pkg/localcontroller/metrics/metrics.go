@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "neptune"
+	subsystem = "lc"
+)
+
+var (
+	// GMConnected reports whether the websocket to GM is up (1) or not (0)
+	GMConnected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "gm_connected",
+		Help:      "Whether the connection to global manager is established.",
+	})
+
+	// GMReconnects counts the times the connection to GM was rebuilt after lost
+	GMReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "gm_reconnects_total",
+		Help:      "Number of times the connection to global manager was lost and reconnected.",
+	})
+
+	// SendMessageBacklog reports the number of messages in the outbox not acknowledged by GM
+	SendMessageBacklog = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "send_message_backlog",
+		Help:      "Number of messages in the outbox waiting to be sent to or acknowledged by global manager.",
+	})
+
+	// WorkerMessages counts the messages received from workers by owner kind
+	WorkerMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "worker_messages_total",
+		Help:      "Number of messages received from workers.",
+	}, []string{"owner_kind"})
+
+	// DatasetScanDuration observes the time to scan the data source of a dataset
+	DatasetScanDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "dataset_scan_duration_seconds",
+		Help:      "Duration of scanning the data source of a dataset.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// DatasetSamples reports the number of samples of a dataset
+	DatasetSamples = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "dataset_samples",
+		Help:      "Number of samples in the data source of a dataset.",
+	}, []string{"namespace", "name"})
+
+	// DatasetMissingSamples reports the number of the missing or unreadable sample files of a dataset
+	DatasetMissingSamples = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "dataset_missing_samples",
+		Help:      "Number of the missing or unreadable sample files of a dataset, among the ones checked.",
+	}, []string{"namespace", "name"})
+
+	// DBOperationDuration observes the latency of the sqlite operations
+	DBOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "db_operation_duration_seconds",
+		Help:      "Latency of the local database operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	registry = prometheus.NewRegistry()
+)
+
+func init() {
+	registry.MustRegister(
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		prometheus.NewGoCollector(),
+		GMConnected,
+		GMReconnects,
+		SendMessageBacklog,
+		WorkerMessages,
+		DatasetScanDuration,
+		DatasetSamples,
+		DatasetMissingSamples,
+		DBOperationDuration,
+	)
+}
+
+// ObserveDBOperation records the latency of the db operation started at start
+func ObserveDBOperation(operation string, start time.Time) {
+	DBOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// Handler returns the http handler serving the metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
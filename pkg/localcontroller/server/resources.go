@@ -0,0 +1,185 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/manager"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/util"
+)
+
+// ResourceInfo defines the resource replied to the worker
+type ResourceInfo struct {
+	Namespace       string          `json:"namespace"`
+	Name            string          `json:"name"`
+	Kind            string          `json:"kind"`
+	ResourceVersion string          `json:"resourceVersion"`
+	Spec            json.RawMessage `json:"spec,omitempty"`
+	UpdateTime      time.Time       `json:"updateTime"`
+}
+
+// DatasetInfo defines the dataset replied to the worker
+type DatasetInfo struct {
+	ResourceInfo
+	Format          string `json:"format"`
+	URL             string `json:"url"`
+	NumberOfSamples int    `json:"numberOfSamples"`
+}
+
+// ModelInfo defines the model replied to the worker
+type ModelInfo struct {
+	ResourceInfo
+	Format string `json:"format"`
+	URL    string `json:"url"`
+	Digest string `json:"digest,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	// CachePath is the path of the verified model in the cache of LC,
+	// which the worker should load instead of URL
+	CachePath string `json:"cachePath,omitempty"`
+	Verified  bool   `json:"verified"`
+}
+
+// jobKinds defines the kinds of jobs served to the workers
+var jobKinds = map[string]bool{
+	db.FederatedLearningJobKind:  true,
+	db.JointInferenceServiceKind: true,
+}
+
+// registerResources registers the api of the resources stored in LC
+func (s *Server) registerResources(ws *restful.WebService) {
+	ws.Route(ws.GET("/datasets/{namespace}/{name}").
+		Filter(s.authenticateReader).
+		To(s.datasetHandler).
+		Doc("get dataset"))
+	ws.Route(ws.GET("/datasets/{namespace}/{name}/samples").
+		Filter(s.authenticateReader).
+		To(s.datasetSamplesHandler).
+		Doc("get the latest samples of dataset"))
+	ws.Route(ws.GET("/models/{namespace}/{name}").
+		Filter(s.authenticateReader).
+		To(s.modelHandler).
+		Doc("get model"))
+	ws.Route(ws.GET("/jobs/{kind}/{namespace}/{name}").
+		Filter(s.authenticateReader).
+		To(s.jobHandler).
+		Doc("get job"))
+}
+
+// newResourceInfo creates the resource info from the resource in db
+func newResourceInfo(meta *db.ResourceMeta) ResourceInfo {
+	info := ResourceInfo{
+		Namespace:       meta.Namespace,
+		Name:            meta.ResourceName,
+		Kind:            meta.ResourceKind,
+		ResourceVersion: meta.ResourceVersion,
+		UpdateTime:      meta.UpdatedAt,
+	}
+	if json.Valid([]byte(meta.Spec)) {
+		info.Spec = json.RawMessage(meta.Spec)
+	}
+	return info
+}
+
+// writeResource replies the resource to the worker, or the error if failed to get it
+func (s *Server) writeResource(response *restful.Response, kind string, name string, found bool, err error,
+	entity func() interface{}) {
+	if err != nil {
+		msg := fmt.Sprintf("get %s(name=%s) failed, error: %v", kind, name, err)
+		klog.Errorf(msg)
+		_ = s.reply(response, http.StatusInternalServerError, msg)
+		return
+	}
+
+	if !found {
+		_ = s.reply(response, http.StatusNotFound, fmt.Sprintf("%s(name=%s) not found", kind, name))
+		return
+	}
+
+	if err = response.WriteHeaderAndEntity(http.StatusOK, entity()); err != nil {
+		klog.Errorf("reply %s(name=%s) to worker failed, error: %v", kind, name, err)
+	}
+}
+
+// datasetHandler replies the dataset to the worker
+func (s *Server) datasetHandler(request *restful.Request, response *restful.Response) {
+	name := util.GetUniqueIdentifier(request.PathParameter("namespace"), request.PathParameter("name"), db.DatasetKind)
+	dataset, err := db.GetDataset(name)
+	s.writeResource(response, db.DatasetKind, name, dataset != nil, err, func() interface{} {
+		return DatasetInfo{
+			ResourceInfo:    newResourceInfo(&dataset.ResourceMeta),
+			Format:          dataset.Format,
+			URL:             dataset.URL,
+			NumberOfSamples: dataset.NumberOfSamples,
+		}
+	})
+}
+
+// datasetSamplesHandler replies the latest samples of the dataset to the worker
+func (s *Server) datasetSamplesHandler(request *restful.Request, response *restful.Response) {
+	name := util.GetUniqueIdentifier(request.PathParameter("namespace"), request.PathParameter("name"), db.DatasetKind)
+
+	var dataSource *manager.DataSource
+	if s.DatasetManager != nil {
+		dataSource = s.DatasetManager.GetDataSource(name)
+	}
+
+	if dataSource == nil {
+		_ = s.reply(response, http.StatusNotFound,
+			fmt.Sprintf("samples of %s(name=%s) not found, the dataset may not exist or not be scanned yet",
+				db.DatasetKind, name))
+		return
+	}
+
+	if err := response.WriteHeaderAndEntity(http.StatusOK, dataSource); err != nil {
+		klog.Errorf("reply samples of %s(name=%s) to worker failed, error: %v", db.DatasetKind, name, err)
+	}
+}
+
+// modelHandler replies the model to the worker
+func (s *Server) modelHandler(request *restful.Request, response *restful.Response) {
+	name := util.GetUniqueIdentifier(request.PathParameter("namespace"), request.PathParameter("name"), db.ModelKind)
+	model, err := db.GetModel(name)
+
+	// never serve the model which is expected to be verified but not
+	if model != nil && model.VerifyRequired && !model.Verified {
+		msg := fmt.Sprintf("%s(name=%s) is not verified", db.ModelKind, name)
+		if model.VerifyMessage != "" {
+			msg += ": " + model.VerifyMessage
+		}
+		_ = s.reply(response, http.StatusConflict, msg)
+		return
+	}
+
+	s.writeResource(response, db.ModelKind, name, model != nil, err, func() interface{} {
+		return ModelInfo{
+			ResourceInfo: newResourceInfo(&model.ResourceMeta),
+			Format:       model.Format,
+			URL:          model.URL,
+			Digest:       model.Digest,
+			Size:         model.Size,
+			CachePath:    model.CachePath,
+			Verified:     model.Verified,
+		}
+	})
+}
+
+// jobHandler replies the job to the worker
+func (s *Server) jobHandler(request *restful.Request, response *restful.Response) {
+	kind := request.PathParameter("kind")
+	if !jobKinds[kind] {
+		_ = s.reply(response, http.StatusBadRequest, fmt.Sprintf("unknown job kind %s", kind))
+		return
+	}
+
+	name := util.GetUniqueIdentifier(request.PathParameter("namespace"), request.PathParameter("name"), kind)
+	job, err := db.GetJob(name)
+	s.writeResource(response, kind, name, job != nil, err, func() interface{} {
+		return newResourceInfo(&job.ResourceMeta)
+	})
+}
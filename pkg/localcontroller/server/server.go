@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/emicklei/go-restful/v3"
 	"k8s.io/klog/v2"
@@ -10,18 +11,28 @@ import (
 	"github.com/edgeai-neptune/neptune/cmd/neptune-lc/app/options"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/common/constants"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/manager"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/metrics"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/wsclient"
+	"github.com/edgeai-neptune/neptune/pkg/util"
 )
 
 // Server defines server
 type Server struct {
 	Port            string
-	Resource        *Resource
+	Client          *wsclient.Client
 	FeatureManagers featureManagerMap
-}
-
-// Resource defines resource
-type Resource struct {
-	Worker map[string]manager.WorkerMessage
+	// DatasetManager provides the latest samples of the datasets, nil if not available
+	DatasetManager *manager.DatasetManager
+	// HeartbeatInterval is the interval which the workers must report within
+	HeartbeatInterval time.Duration
+	// Socket is the path of the unix domain socket serving the workers, disabled if empty
+	Socket string
+	// TokenPublicKeyFile is the public key file verifying the tokens of the workers, disabled if empty
+	TokenPublicKeyFile string
+	// NodeName is the node of LC, the tokens issued to the workers on other nodes are rejected
+	NodeName string
+	// tokenKey holds the keys loaded from TokenPublicKeyFile, nil if disabled
+	tokenKey *util.WorkerTokenKeyReloader
 }
 
 // ResponseMessage defines send message to worker
@@ -33,9 +44,24 @@ type ResponseMessage struct {
 type featureManagerMap map[string]manager.FeatureManager
 
 // NewServer create a new LC server
-func NewServer(options *options.LocalControllerOptions, featureManagers ...manager.FeatureManager) *Server {
+func NewServer(options *options.LocalControllerOptions, client *wsclient.Client,
+	datasetManager *manager.DatasetManager, featureManagers ...manager.FeatureManager) *Server {
 	s := Server{
-		Port: options.BindPort,
+		Port:               options.BindPort,
+		Client:             client,
+		DatasetManager:     datasetManager,
+		HeartbeatInterval:  options.WorkerHeartbeatInterval,
+		Socket:             options.WorkerSocket,
+		TokenPublicKeyFile: options.WorkerTokenPublicKeyFile,
+		NodeName:           options.NodeName,
+	}
+
+	if s.TokenPublicKeyFile != "" {
+		tokenKey, err := util.NewWorkerTokenKeyReloader(s.TokenPublicKeyFile, options.WorkerTokenKeyGracePeriod)
+		if err != nil {
+			klog.Fatalf("load worker token key file %s failed, error: %v", s.TokenPublicKeyFile, err)
+		}
+		s.tokenKey = tokenKey
 	}
 
 	fms := featureManagerMap{}
@@ -61,7 +87,34 @@ func (s *Server) register(container *restful.Container) {
 	ws.Route(ws.POST("/workers/{worker-name}/info").
 		To(s.messageHandler).
 		Doc("receive worker message"))
+	ws.Route(ws.GET("/workers").
+		Filter(s.authenticateReader).
+		To(s.listWorkersHandler).
+		Doc("list workers"))
+	s.registerResources(ws)
 	container.Add(ws)
+
+	container.Handle("/healthz", http.HandlerFunc(s.healthzHandler))
+	container.Handle("/readyz", http.HandlerFunc(s.readyzHandler))
+	container.Handle("/metrics", metrics.Handler())
+}
+
+// healthzHandler reports the liveness of LC
+func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// readyzHandler reports the readiness of LC, which requires the connection to GM
+func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Client == nil || !s.Client.IsConnected() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("global manager is not connected"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
 }
 
 // reply replies message to the worker
@@ -105,8 +158,19 @@ func (s *Server) messageHandler(request *restful.Request, response *restful.Resp
 		return
 	}
 
-	if m, ok := s.FeatureManagers[workerMessage.OwnerKind]; ok {
-		m.AddWorkerMessageToChannel(workerMessage)
+	if !s.authenticateWorker(request, response, workerName, &workerMessage) {
+		return
+	}
+
+	s.recordWorker(&workerMessage)
+
+	// the heartbeats only report the liveness of the worker
+	if workerMessage.Kind != manager.HeartbeatKind {
+		metrics.WorkerMessages.WithLabelValues(workerMessage.OwnerKind).Inc()
+
+		if m, ok := s.FeatureManagers[workerMessage.OwnerKind]; ok {
+			m.AddWorkerMessageToChannel(workerMessage)
+		}
 	}
 
 	err = s.reply(response, http.StatusOK, "OK")
@@ -119,10 +183,14 @@ func (s *Server) messageHandler(request *restful.Request, response *restful.Resp
 // Start starts server
 func (s *Server) Start() {
 	wsContainer := restful.NewContainer()
-	resource := Resource{map[string]manager.WorkerMessage{}}
-	s.Resource = &resource
 	s.register(wsContainer)
 
+	go s.monitorWorkers()
+
+	if s.Socket != "" {
+		go s.serveSocket(wsContainer)
+	}
+
 	server := &http.Server{Addr: fmt.Sprintf(":%s", s.Port), Handler: wsContainer}
 
 	klog.Infof("server binds port %s successfully", s.Port)
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/manager"
+	"github.com/edgeai-neptune/neptune/pkg/util"
+)
+
+const (
+	// bearerPrefix is the prefix of the worker token in the authorization header
+	bearerPrefix = "Bearer "
+
+	// socketMode is the mode of the worker socket, the workers may run as any
+	// user and are authenticated by their tokens instead
+	socketMode = 0666
+)
+
+// serveSocket serves the handler at the unix domain socket mounted into the workers
+func (s *Server) serveSocket(handler http.Handler) {
+	if err := os.MkdirAll(filepath.Dir(s.Socket), 0755); err != nil {
+		klog.Fatalf("create the directory of socket %s failed, error: %v", s.Socket, err)
+	}
+
+	// remove the socket left by the last LC
+	if err := os.Remove(s.Socket); err != nil && !os.IsNotExist(err) {
+		klog.Fatalf("remove the stale socket %s failed, error: %v", s.Socket, err)
+	}
+
+	listener, err := net.Listen("unix", s.Socket)
+	if err != nil {
+		klog.Fatalf("listen socket %s failed, error: %v", s.Socket, err)
+	}
+
+	if err = os.Chmod(s.Socket, socketMode); err != nil {
+		klog.Fatalf("change the mode of socket %s failed, error: %v", s.Socket, err)
+	}
+
+	klog.Infof("server listens socket %s successfully", s.Socket)
+	klog.Fatal(http.Serve(listener, handler))
+}
+
+// workerTokenAttribute is the attribute of the request holding the claims of the worker token
+const workerTokenAttribute = "workerToken"
+
+// verifyWorkerToken verifies the token presented by the worker is issued to
+// a worker on the node of LC, and returns its claims, nil if the worker tokens
+// are disabled. The error is replied if the token is missing or invalid.
+func (s *Server) verifyWorkerToken(request *restful.Request, response *restful.Response) (*util.WorkerTokenClaims, bool) {
+	if s.tokenKey == nil {
+		return nil, true
+	}
+
+	token := ""
+	if header := request.HeaderParameter("Authorization"); strings.HasPrefix(header, bearerPrefix) {
+		token = strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix))
+	}
+
+	if token == "" {
+		msg := fmt.Sprintf("%s %s presents no worker token", request.Request.Method, request.Request.URL.Path)
+		klog.Warningf(msg)
+		_ = s.reply(response, http.StatusUnauthorized, msg)
+		return nil, false
+	}
+
+	claims, err := util.ParseWorkerToken(s.tokenKey.Keys(), token, time.Now())
+	if err != nil {
+		msg := fmt.Sprintf("%s %s presents an invalid worker token: %v", request.Request.Method, request.Request.URL.Path, err)
+		klog.Warningf(msg)
+		_ = s.reply(response, http.StatusUnauthorized, msg)
+		return nil, false
+	}
+
+	if claims.NodeName != s.NodeName {
+		msg := fmt.Sprintf("token of worker(name=%s) is issued on node %q instead of node %q",
+			claims.WorkerName, claims.NodeName, s.NodeName)
+		klog.Warningf(msg)
+		_ = s.reply(response, http.StatusForbidden, msg)
+		return nil, false
+	}
+	return claims, true
+}
+
+// authenticateWorker checks the token presented by the worker is issued to the
+// worker of the api path and its owner in the message, and replies the error if not
+func (s *Server) authenticateWorker(request *restful.Request, response *restful.Response,
+	workerName string, message *manager.WorkerMessage) bool {
+	claims, ok := s.verifyWorkerToken(request, response)
+	if !ok || claims == nil {
+		return ok
+	}
+
+	if workerName != message.Name ||
+		!claims.IsIssuedTo(message.Namespace, message.OwnerKind, message.OwnerName, workerName) {
+		msg := fmt.Sprintf("token of worker(name=%s) is not issued to the worker(name=%s) of %s(name=%s/%s)",
+			claims.WorkerName, workerName, message.OwnerKind, message.Namespace, message.OwnerName)
+		klog.Warningf(msg)
+		_ = s.reply(response, http.StatusForbidden, msg)
+		return false
+	}
+
+	return true
+}
+
+// authenticateReader is the filter of the read api, which requires the token of
+// a worker in the namespace of the resources read
+func (s *Server) authenticateReader(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	claims, ok := s.verifyWorkerToken(request, response)
+	if !ok {
+		return
+	}
+
+	if claims != nil {
+		if namespace := request.PathParameter("namespace"); namespace != "" && namespace != claims.Namespace {
+			msg := fmt.Sprintf("token of worker(name=%s) is not issued in namespace %s", claims.WorkerName, namespace)
+			klog.Warningf(msg)
+			_ = s.reply(response, http.StatusForbidden, msg)
+			return
+		}
+		request.SetAttribute(workerTokenAttribute, claims)
+	}
+	chain.ProcessFilter(request, response)
+}
+
+// workerTokenNamespace returns the namespace of the worker token verified by
+// authenticateReader, "" if the worker tokens are disabled
+func workerTokenNamespace(request *restful.Request) string {
+	if claims, ok := request.Attribute(workerTokenAttribute).(*util.WorkerTokenClaims); ok {
+		return claims.Namespace
+	}
+	return ""
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/manager"
+	"github.com/edgeai-neptune/neptune/pkg/util"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(db.RunWithTempDB(m, "server"))
+}
+
+// testNodeName is the node of LC serving the api
+const testNodeName = "edge1"
+
+// newTestKey generates an ed25519 key of the worker tokens
+func newTestKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public, private
+}
+
+// newTestServer serves the api of LC with the worker tokens verified by the public key
+func newTestServer(t *testing.T, key ed25519.PublicKey) *httptest.Server {
+	dir, err := ioutil.TempDir("", "workerauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "key.pub")
+	if err = ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tokenKey, err := util.NewWorkerTokenKeyReloader(file, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{FeatureManagers: featureManagerMap{}, TokenPublicKeyFile: file, NodeName: testNodeName, tokenKey: tokenKey}
+	container := restful.NewContainer()
+	s.register(container)
+	server := httptest.NewServer(container)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestToken(t *testing.T, key ed25519.PrivateKey, namespace, workerName, nodeName string) string {
+	token, err := util.NewWorkerToken(key, util.WorkerTokenClaims{
+		Namespace:  namespace,
+		OwnerKind:  "federatedlearningjob",
+		OwnerName:  "job1",
+		WorkerName: workerName,
+		NodeName:   nodeName,
+	}, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func doRequest(t *testing.T, method, url, token string, body interface{}) int {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+	if token != "" {
+		req.Header.Set("Authorization", bearerPrefix+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAuthenticateWorker(t *testing.T) {
+	public, key := newTestKey(t)
+	_, otherKey := newTestKey(t)
+	server := newTestServer(t, public)
+	message := manager.WorkerMessage{
+		Name:      "trainworker-1",
+		Namespace: "default",
+		OwnerKind: "federatedlearningjob",
+		OwnerName: "job1",
+		Kind:      manager.HeartbeatKind,
+	}
+	url := func(workerName string) string {
+		return server.URL + "/neptune/workers/" + workerName + "/info"
+	}
+
+	cases := []struct {
+		name       string
+		workerName string
+		token      string
+		status     int
+	}{
+		{"valid", "trainworker-1", newTestToken(t, key, "default", "trainworker-1", testNodeName), http.StatusOK},
+		{"no token", "trainworker-1", "", http.StatusUnauthorized},
+		{"signed by another key", "trainworker-1", newTestToken(t, otherKey, "default", "trainworker-1", testNodeName), http.StatusUnauthorized},
+		{"token of another worker", "trainworker-1", newTestToken(t, key, "default", "trainworker-2", testNodeName), http.StatusForbidden},
+		{"token of another namespace", "trainworker-1", newTestToken(t, key, "other", "trainworker-1", testNodeName), http.StatusForbidden},
+		{"token of another node", "trainworker-1", newTestToken(t, key, "default", "trainworker-1", "edge2"), http.StatusForbidden},
+		{"token of no node", "trainworker-1", newTestToken(t, key, "default", "trainworker-1", ""), http.StatusForbidden},
+		{"api path of another worker", "trainworker-2", newTestToken(t, key, "default", "trainworker-2", testNodeName), http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if status := doRequest(t, http.MethodPost, url(c.workerName), c.token, message); status != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.status, status)
+		}
+	}
+}
+
+func TestAuthenticateReader(t *testing.T) {
+	public, key := newTestKey(t)
+	_, otherKey := newTestKey(t)
+	server := newTestServer(t, public)
+	token := newTestToken(t, key, "default", "trainworker-1", testNodeName)
+
+	cases := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"dataset", "/neptune/datasets/default/dataset1", token, http.StatusNotFound},
+		{"dataset without token", "/neptune/datasets/default/dataset1", "", http.StatusUnauthorized},
+		{"dataset of another namespace", "/neptune/datasets/other/dataset1", token, http.StatusForbidden},
+		{"samples without token", "/neptune/datasets/default/dataset1/samples", "", http.StatusUnauthorized},
+		{"model without token", "/neptune/models/default/model1", "", http.StatusUnauthorized},
+		{"model of another namespace", "/neptune/models/other/model1", token, http.StatusForbidden},
+		{"job without token", "/neptune/jobs/federatedlearningjob/default/job1", "", http.StatusUnauthorized},
+		{"job of another namespace", "/neptune/jobs/federatedlearningjob/other/job1", token, http.StatusForbidden},
+		{"workers", "/neptune/workers", token, http.StatusOK},
+		{"workers without token", "/neptune/workers", "", http.StatusUnauthorized},
+		{"workers with invalid token", "/neptune/workers", newTestToken(t, otherKey, "default", "trainworker-1", testNodeName), http.StatusUnauthorized},
+		{"workers with token of another node", "/neptune/workers", newTestToken(t, key, "default", "trainworker-1", "edge2"), http.StatusForbidden},
+	}
+	for _, c := range cases {
+		if status := doRequest(t, http.MethodGet, server.URL+c.path, c.token, nil); status != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.status, status)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/manager"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/util"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/wsclient"
+)
+
+const (
+	// workerCompletedStatus and workerFailedStatus are the final status of the workers,
+	// the workers reported them are expected to stop reporting
+	workerCompletedStatus = "completed"
+	workerFailedStatus    = "failed"
+
+	// minWorkerCheckPeriod is the minimum period of checking the liveness of the workers
+	minWorkerCheckPeriod = time.Second
+)
+
+// WorkerInfo defines the worker tracked by LC from its messages
+type WorkerInfo struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	OwnerName string `json:"ownerName"`
+	OwnerKind string `json:"ownerKind"`
+	// Phase and Status are the latest reported by the worker
+	Phase  string `json:"phase"`
+	Status string `json:"status"`
+	// LastReportTime is the last time the worker reported to LC, including the heartbeats
+	LastReportTime time.Time `json:"lastReportTime"`
+	// Healthy is whether the worker reported within the heartbeat interval
+	Healthy bool `json:"healthy"`
+	// TransitionTime is the last time Healthy changed
+	TransitionTime time.Time `json:"transitionTime"`
+}
+
+// workerHealth defines the liveness of a worker reported to GlobalManager
+type workerHealth struct {
+	Name               string    `json:"name"`
+	Healthy            bool      `json:"healthy"`
+	Phase              string    `json:"phase,omitempty"`
+	Status             string    `json:"status,omitempty"`
+	LastHeartbeatTime  time.Time `json:"lastHeartbeatTime"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+	Message            string    `json:"message,omitempty"`
+}
+
+// finished returns whether the worker has reported its final status
+func (w *WorkerInfo) finished() bool {
+	return w.Status == workerCompletedStatus || w.Status == workerFailedStatus
+}
+
+// health returns the liveness of the worker reported to GlobalManager
+func (w *WorkerInfo) health(message string) workerHealth {
+	return workerHealth{
+		Name:               w.Name,
+		Healthy:            w.Healthy,
+		Phase:              w.Phase,
+		Status:             w.Status,
+		LastHeartbeatTime:  w.LastReportTime,
+		LastTransitionTime: w.TransitionTime,
+		Message:            message,
+	}
+}
+
+// newWorkerInfo converts the worker in db
+func newWorkerInfo(w *db.Worker) WorkerInfo {
+	return WorkerInfo{
+		Name:           w.ReportedName,
+		Namespace:      w.Namespace,
+		OwnerName:      w.OwnerName,
+		OwnerKind:      w.OwnerKind,
+		Phase:          w.Phase,
+		Status:         w.Status,
+		LastReportTime: w.LastReportTime,
+		Healthy:        w.Healthy,
+		TransitionTime: w.TransitionTime,
+	}
+}
+
+// recordWorker updates the worker from its message,
+// and reports it healthy to GlobalManager if it's new or recovered
+func (s *Server) recordWorker(message *manager.WorkerMessage) {
+	report := db.Worker{
+		Name:         manager.WorkerIdentifier(message.Namespace, message.Name),
+		ReportedName: message.Name,
+		Namespace:    message.Namespace,
+		OwnerKind:    message.OwnerKind,
+		OwnerName:    message.OwnerName,
+	}
+	if message.Kind != manager.HeartbeatKind {
+		report.Phase = message.Kind
+		report.Status = message.Status
+	}
+
+	w, recovered, err := db.RecordWorkerReport(&report, time.Now())
+	if err != nil || !recovered {
+		return
+	}
+
+	info := newWorkerInfo(w)
+	klog.Infof("worker(name=%s) of %s(name=%s) is healthy", info.Name, info.OwnerKind, info.OwnerName)
+	s.reportWorkerHealth(&info, "worker is reporting")
+}
+
+// monitorWorkers checks the liveness of the workers periodically
+func (s *Server) monitorWorkers() {
+	if s.HeartbeatInterval <= 0 {
+		return
+	}
+
+	period := s.HeartbeatInterval / 2
+	if period < minWorkerCheckPeriod {
+		period = minWorkerCheckPeriod
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.checkWorkers(now)
+	}
+}
+
+// checkWorkers flags the workers which missed the heartbeat interval as unhealthy,
+// and forgets the workers whose owners have been deleted
+func (s *Server) checkWorkers(now time.Time) {
+	before := now.Add(-s.HeartbeatInterval)
+	workers, err := db.ListStaleWorkers(before)
+	if err != nil {
+		klog.Errorf("list the workers not reporting failed, error: %v", err)
+		return
+	}
+
+	for i := range workers {
+		w := &workers[i]
+		owner, err := db.GetJob(util.GetUniqueIdentifier(w.Namespace, w.OwnerName, w.OwnerKind))
+		if err != nil {
+			klog.Errorf("get owner of worker(name=%s) failed, error: %v", w.ReportedName, err)
+			continue
+		}
+		if owner == nil {
+			if err = db.DeleteWorkers(w.Namespace, w.OwnerKind, w.OwnerName); err != nil {
+				klog.Errorf("delete workers of %s(name=%s) failed, error: %v", w.OwnerKind, w.OwnerName, err)
+			}
+			continue
+		}
+
+		info := newWorkerInfo(w)
+		if info.finished() {
+			continue
+		}
+		flagged, err := db.MarkWorkerUnhealthy(w.Name, before, now)
+		if err != nil {
+			klog.Errorf("flag worker(name=%s) unhealthy failed, error: %v", info.Name, err)
+			continue
+		}
+		if !flagged {
+			// reported meanwhile
+			continue
+		}
+
+		info.Healthy = false
+		info.TransitionTime = now
+		klog.Warningf("worker(name=%s) of %s(name=%s) has not reported since %s",
+			info.Name, info.OwnerKind, info.OwnerName, info.LastReportTime.Format(time.RFC3339))
+		s.reportWorkerHealth(&info, "worker missed the heartbeat interval "+s.HeartbeatInterval.String())
+	}
+}
+
+// reportWorkerHealth reports the liveness of the worker to GlobalManager
+func (s *Server) reportWorkerHealth(w *WorkerInfo, message string) {
+	if s.Client == nil {
+		return
+	}
+
+	header := wsclient.MessageHeader{
+		Namespace:    w.Namespace,
+		ResourceKind: w.OwnerKind,
+		ResourceName: w.OwnerName,
+		Operation:    manager.WorkerHealthOperation,
+	}
+	if err := s.Client.WriteMessage(w.health(message), header); err != nil {
+		klog.Errorf("report health of worker(name=%s) failed, error: %v", w.Name, err)
+	}
+}
+
+// listWorkersHandler replies the workers known by LC,
+// only those in the namespace of the worker token if presented
+func (s *Server) listWorkersHandler(request *restful.Request, response *restful.Response) {
+	rows, err := db.ListWorkers(workerTokenNamespace(request), "", "")
+	if err != nil {
+		if err = s.reply(response, http.StatusInternalServerError, "list workers failed"); err != nil {
+			klog.Errorf("reply workers failed, error: %v", err)
+		}
+		return
+	}
+
+	workers := make([]WorkerInfo, 0, len(rows))
+	for i := range rows {
+		workers = append(workers, newWorkerInfo(&rows[i]))
+	}
+	sort.Slice(workers, func(i, j int) bool {
+		return workers[i].Name < workers[j].Name
+	})
+
+	if err := response.WriteHeaderAndEntity(http.StatusOK, workers); err != nil {
+		klog.Errorf("reply workers failed, error: %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package wsclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/messagelayer/pb"
+	"github.com/edgeai-neptune/neptune/pkg/version"
+)
+
+// grpcTransport connects GM by a grpc bidirectional stream
+type grpcTransport struct {
+	c *Client
+}
+
+// GRPCConnection is the connection to GM on a grpc stream
+type GRPCConnection struct {
+	conn   *grpclib.ClientConn
+	stream pb.MessageService_StreamClient
+	cancel context.CancelFunc
+
+	// sendLock serializes the sends of the messages and pings
+	sendLock sync.Mutex
+}
+
+// Dial opens the message stream to the grpc server of GM, the messages
+// are compressed by gzip.
+func (t *grpcTransport) Dial() (Connection, error) {
+	c := t.c
+
+	opts := []grpclib.DialOption{
+		grpclib.WithBlock(),
+		grpclib.WithDefaultCallOptions(grpclib.UseCompressor(gzip.Name)),
+	}
+	if c.certificates != nil {
+		opts = append(opts, grpclib.WithTransportCredentials(credentials.NewTLS(c.newTLSConfig())))
+	} else {
+		opts = append(opts, grpclib.WithInsecure())
+	}
+	if c.Options.PingInterval > 0 {
+		// GM is considered dead when the ping is not answered within the read timeout
+		params := keepalive.ClientParameters{
+			Time:                c.Options.PingInterval,
+			PermitWithoutStream: true,
+		}
+		if params.Time < pb.MinKeepaliveInterval {
+			// GM closes the streams pinging faster
+			klog.Warningf("ping interval %v is less than the minimum %v of grpc keepalive, use the minimum",
+				params.Time, pb.MinKeepaliveInterval)
+			params.Time = pb.MinKeepaliveInterval
+		}
+		if c.Options.ReadTimeout > 0 {
+			params.Timeout = c.Options.ReadTimeout
+		}
+		opts = append(opts, grpclib.WithKeepaliveParams(params))
+	}
+
+	dialCtx := context.Background()
+	if c.Options.WriteTimeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(dialCtx, c.Options.WriteTimeout)
+		defer cancel()
+	}
+	conn, err := grpclib.DialContext(dialCtx, c.Options.GMAddr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	md := metadata.Pairs(
+		pb.MetadataNodeName, c.Options.NodeName,
+		pb.MetadataNodeVersion, version.Get().GitVersion,
+	)
+	token, err := c.getToken()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if token != "" {
+		md.Set(pb.MetadataAuthorization, "Bearer "+token)
+	}
+
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(context.Background(), md))
+	stream, err := pb.NewMessageServiceClient(conn).Stream(ctx)
+	if err == nil {
+		// wait for GM accepting the stream by the header echoing the node name,
+		// otherwise the stream is rejected with the error status
+		var header metadata.MD
+		header, err = stream.Header()
+		if err == nil && len(header.Get(pb.MetadataNodeName)) == 0 {
+			if _, err = stream.Recv(); err == nil {
+				err = fmt.Errorf("no accepted header received from the stream")
+			}
+		}
+	}
+	if err != nil {
+		cancel()
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &GRPCConnection{
+		conn:   conn,
+		stream: stream,
+		cancel: cancel,
+	}, nil
+}
+
+func (conn *GRPCConnection) send(m *pb.Message) error {
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
+	return conn.stream.Send(m)
+}
+
+// ReadMessage receives a message from GM
+func (conn *GRPCConnection) ReadMessage(message *Message) error {
+	m, err := conn.stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	h := m.GetHeader()
+	*message = Message{
+		Header: MessageHeader{
+			Namespace:    h.GetNamespace(),
+			ResourceKind: h.GetResourceKind(),
+			ResourceName: h.GetResourceName(),
+			Operation:    h.GetOperation(),
+			Sequence:     h.GetSequence(),
+			MessageID:    h.GetMessageId(),
+		},
+		Content: m.GetContent(),
+	}
+	return nil
+}
+
+// WriteMessage sends a message to GM, which blocks while the flow control
+// window is exhausted, i.e. GM is not keeping up.
+func (conn *GRPCConnection) WriteMessage(message *Message) error {
+	return conn.send(&pb.Message{
+		Header: &pb.MessageHeader{
+			Namespace:    message.Header.Namespace,
+			ResourceKind: message.Header.ResourceKind,
+			ResourceName: message.Header.ResourceName,
+			Operation:    message.Header.Operation,
+			Sequence:     message.Header.Sequence,
+			MessageId:    message.Header.MessageID,
+		},
+		Content: message.Content,
+	})
+}
+
+// Ping sends a heartbeat message to GM
+func (conn *GRPCConnection) Ping() error {
+	return conn.send(&pb.Message{
+		Header: &pb.MessageHeader{Operation: pb.PingOperation},
+	})
+}
+
+// Close cancels the stream and closes the connection
+func (conn *GRPCConnection) Close() error {
+	conn.cancel()
+	return conn.conn.Close()
+}
@@ -0,0 +1,146 @@
+package wsclient
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptune-lc/app/options"
+	"github.com/edgeai-neptune/neptune/pkg/messagelayer/pb"
+)
+
+// echoServer accepts the streams of the node with the token, sends the
+// downstream message and passes the upstream messages received
+type echoServer struct {
+	pb.UnimplementedMessageServiceServer
+	token      string
+	downstream *pb.Message
+
+	metadata chan metadata.MD
+	upstream chan *pb.Message
+	// closed is closed when a stream ends
+	closed chan struct{}
+}
+
+func (s *echoServer) Stream(stream pb.MessageService_StreamServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.metadata <- md
+	if auth := md.Get(pb.MetadataAuthorization); len(auth) == 0 || auth[0] != "Bearer "+s.token {
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+	defer close(s.closed)
+
+	if err := stream.SendHeader(metadata.Pairs(pb.MetadataNodeName, md.Get(pb.MetadataNodeName)[0])); err != nil {
+		return err
+	}
+	if err := stream.Send(s.downstream); err != nil {
+		return err
+	}
+	for {
+		m, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		s.upstream <- m
+	}
+}
+
+func TestGRPCTransport(t *testing.T) {
+	s := &echoServer{
+		token: "token1",
+		downstream: &pb.Message{
+			Header:  &pb.MessageHeader{ResourceKind: "model", ResourceName: "m1", Operation: "update", Sequence: 1, MessageId: "gm-1"},
+			Content: []byte("{}"),
+		},
+		metadata: make(chan metadata.MD, 2),
+		upstream: make(chan *pb.Message, 10),
+		closed:   make(chan struct{}),
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpclib.NewServer()
+	pb.RegisterMessageServiceServer(server, s)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	dial := func(token string) (Connection, error) {
+		c := NewClient(&options.LocalControllerOptions{
+			NodeName:     "node1",
+			GMAddr:       lis.Addr().String(),
+			Token:        token,
+			WriteTimeout: 5 * time.Second,
+		})
+		return (&grpcTransport{c: c}).Dial()
+	}
+
+	// rejected by the status instead of the header
+	if conn, err := dial("wrong-token"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected the stream rejected, got %v", err)
+		if conn != nil {
+			conn.Close()
+		}
+	}
+	<-s.metadata
+
+	conn, err := dial("token1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := <-s.metadata
+	if names := md.Get(pb.MetadataNodeName); len(names) != 1 || names[0] != "node1" {
+		t.Errorf("expected the stream opened with the node name, got %v", md)
+	}
+
+	var message Message
+	if err = conn.ReadMessage(&message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Header.MessageID != "gm-1" || message.Header.ResourceName != "m1" || string(message.Content) != "{}" {
+		t.Errorf("unexpected message %+v", message)
+	}
+
+	if err = conn.WriteMessage(&Message{Header: MessageHeader{ResourceKind: "dataset", Sequence: 1, MessageID: "lc-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"lc-1", pb.PingOperation} {
+		select {
+		case m := <-s.upstream:
+			if id := m.GetHeader().GetMessageId(); id != expected && m.GetHeader().GetOperation() != expected {
+				t.Errorf("expected message %q, got %+v", expected, m.GetHeader())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for message %q", expected)
+		}
+	}
+
+	// closing cancels the stream, which releases the blocked read and ends
+	// the stream of GM
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- conn.ReadMessage(&message)
+	}()
+	conn.Close()
+	select {
+	case err = <-readErr:
+		if err == nil {
+			t.Errorf("expected the read failed after closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the read released after closed")
+	}
+	select {
+	case <-s.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream of GM ended after closed")
+	}
+}
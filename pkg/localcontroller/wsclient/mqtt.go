@@ -0,0 +1,293 @@
+package wsclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/messagelayer"
+	"github.com/edgeai-neptune/neptune/pkg/version"
+)
+
+const (
+	// MQTTDownstreamTopic is the topic suffix of the messages from GM to LC
+	MQTTDownstreamTopic = "downstream"
+	// MQTTUpstreamTopic is the topic suffix of the messages from LC to GM
+	MQTTUpstreamTopic = "upstream"
+	// MQTTPresenceTopic is the topic suffix of the retained presence of LC
+	MQTTPresenceTopic = "presence"
+
+	// mqttDisconnectQuiesce is the milliseconds to wait for the pending work
+	// when disconnecting the broker
+	mqttDisconnectQuiesce = 250
+)
+
+// Presence is published retained to the presence topic of the node when
+// connected and as the heartbeat, and as the last will when disconnected.
+// GM reopens the session of the node once SessionID changed.
+type Presence struct {
+	Online    bool   `json:"online"`
+	Version   string `json:"version,omitempty"`
+	SessionID string `json:"sessionID,omitempty"`
+}
+
+// mqttTransport exchanges the messages with GM through a MQTT broker
+type mqttTransport struct {
+	c *Client
+}
+
+// MQTTConnection is the emulated connection to GM on a MQTT client
+type MQTTConnection struct {
+	client       paho.Client
+	qos          byte
+	writeTimeout time.Duration
+
+	upstreamTopic   string
+	presenceTopic   string
+	downstreamTopic string
+	sessionID       string
+	// key is derived from the token of the node, which signs the messages
+	// published and verifies the ones received, nil if the node has no token
+	key []byte
+
+	// publishLock serializes the messages published, so that they're
+	// published in the order of their sequences
+	publishLock sync.Mutex
+	// sequence is the last sequence of the messages signed
+	sequence uint64
+	// lastDownstream is the sequence of the last downstream message accepted
+	lastDownstream uint64
+	downstreamLock sync.Mutex
+
+	downstream chan Message
+	// closed is closed when the connection is lost or closed
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (t *mqttTransport) topic(suffix string) string {
+	return strings.Join([]string{t.c.Options.MQTTTopicPrefix, t.c.Options.NodeName, suffix}, "/")
+}
+
+// Dial connects the broker, subscribes the downstream topic of the node,
+// and announces the presence.
+func (t *mqttTransport) Dial() (Connection, error) {
+	c := t.c
+	sessionID := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	conn := &MQTTConnection{
+		qos:             c.Options.MQTTQoS,
+		writeTimeout:    c.Options.WriteTimeout,
+		upstreamTopic:   t.topic(MQTTUpstreamTopic),
+		presenceTopic:   t.topic(MQTTPresenceTopic),
+		downstreamTopic: t.topic(MQTTDownstreamTopic),
+		sessionID:       sessionID,
+		downstream:      make(chan Message, MessageChannelCacheSize),
+		closed:          make(chan struct{}),
+	}
+
+	// the broker authenticates the node by its bootstrap token, and the
+	// messages between LC and GM are signed by the key derived from the
+	// token, which the broker doesn't know
+	token, err := c.getToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the token of node %s: %w", c.Options.NodeName, err)
+	}
+	if token != "" {
+		conn.key = messagelayer.DeriveSigningKey(token)
+	}
+
+	conn.publishLock.Lock()
+	will, err := conn.signPresence(&Presence{Online: false, SessionID: sessionID})
+	conn.publishLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(c.Options.MQTTBroker).
+		SetClientID("neptune-lc-"+c.Options.NodeName).
+		SetCleanSession(true).
+		// reconnecting is done by the client with a new session
+		SetAutoReconnect(false).
+		SetBinaryWill(conn.presenceTopic, will, conn.qos, true).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			klog.Errorf("client lost connection to mqtt broker(address: %s), error: %v", c.Options.MQTTBroker, err)
+			conn.close()
+		})
+	if c.Options.WriteTimeout > 0 {
+		opts.SetWriteTimeout(c.Options.WriteTimeout)
+	}
+
+	if token != "" {
+		opts.SetUsername(c.Options.NodeName).SetPassword(token)
+	}
+	if c.certificates != nil {
+		opts.SetTLSConfig(c.newTLSConfig())
+	}
+
+	conn.client = paho.NewClient(opts)
+	if err = conn.wait(conn.client.Connect()); err != nil {
+		return nil, fmt.Errorf("failed to connect mqtt broker %s: %w", c.Options.MQTTBroker, err)
+	}
+
+	err = conn.wait(conn.client.Subscribe(conn.downstreamTopic, conn.qos, conn.handleDownstream))
+	if err == nil {
+		err = conn.Ping()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// wait waits for the token completed within the write timeout
+func (conn *MQTTConnection) wait(token paho.Token) error {
+	if conn.writeTimeout > 0 {
+		if !token.WaitTimeout(conn.writeTimeout) {
+			return fmt.Errorf("mqtt operation timeout")
+		}
+	} else {
+		token.Wait()
+	}
+	return token.Error()
+}
+
+// sign wraps the data published to the topic with its signature,
+// which must be called with publishLock held
+func (conn *MQTTConnection) sign(topic string, data []byte) ([]byte, error) {
+	signed := messagelayer.SignedPayload{Data: data}
+	if conn.key != nil {
+		conn.sequence++
+		signed.SessionID = conn.sessionID
+		signed.Sequence = conn.sequence
+		signed.Timestamp = time.Now().Unix()
+		signed.Sign(conn.key, topic)
+	}
+	return json.Marshal(&signed)
+}
+
+// publish signs the data and publishes it to the topic
+func (conn *MQTTConnection) publish(topic string, data []byte, retained bool) error {
+	conn.publishLock.Lock()
+	defer conn.publishLock.Unlock()
+
+	payload, err := conn.sign(topic, data)
+	if err != nil {
+		return err
+	}
+	return conn.wait(conn.client.Publish(topic, conn.qos, retained, payload))
+}
+
+// signPresence returns the signed payload of the presence,
+// which must be called with publishLock held
+func (conn *MQTTConnection) signPresence(p *Presence) ([]byte, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return conn.sign(conn.presenceTopic, data)
+}
+
+func (conn *MQTTConnection) close() {
+	conn.closeOnce.Do(func() {
+		close(conn.closed)
+	})
+}
+
+// verifyDownstream returns the data of the downstream payload, which must be
+// signed by GM for this session if the node has a token
+func (conn *MQTTConnection) verifyDownstream(topic string, payload []byte) ([]byte, error) {
+	var signed messagelayer.SignedPayload
+	if err := json.Unmarshal(payload, &signed); err != nil {
+		return nil, err
+	}
+	if conn.key == nil {
+		return signed.Data, nil
+	}
+
+	if !signed.Verify(conn.key, topic) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	if signed.SessionID != conn.sessionID {
+		return nil, fmt.Errorf("signed for session %s other than %s", signed.SessionID, conn.sessionID)
+	}
+
+	conn.downstreamLock.Lock()
+	defer conn.downstreamLock.Unlock()
+	if signed.Sequence <= conn.lastDownstream {
+		return nil, fmt.Errorf("sequence %d is not after %d", signed.Sequence, conn.lastDownstream)
+	}
+	conn.lastDownstream = signed.Sequence
+	return signed.Data, nil
+}
+
+func (conn *MQTTConnection) handleDownstream(_ paho.Client, m paho.Message) {
+	data, err := conn.verifyDownstream(m.Topic(), m.Payload())
+	if err != nil {
+		klog.Errorf("client rejected the message from mqtt topic %s, error: %v", m.Topic(), err)
+		return
+	}
+
+	var message Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		klog.Errorf("client received invalid message from mqtt topic %s, error: %v", m.Topic(), err)
+		return
+	}
+
+	select {
+	case conn.downstream <- message:
+	case <-conn.closed:
+	}
+}
+
+// ReadMessage reads a message from the downstream topic
+func (conn *MQTTConnection) ReadMessage(message *Message) error {
+	select {
+	case *message = <-conn.downstream:
+		return nil
+	case <-conn.closed:
+		return fmt.Errorf("mqtt connection closed")
+	}
+}
+
+// WriteMessage publishes a message to the upstream topic
+func (conn *MQTTConnection) WriteMessage(message *Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return conn.publish(conn.upstreamTopic, data, false)
+}
+
+// Ping publishes the presence as the heartbeat
+func (conn *MQTTConnection) Ping() error {
+	data, err := json.Marshal(&Presence{
+		Online:    true,
+		Version:   version.Get().GitVersion,
+		SessionID: conn.sessionID,
+	})
+	if err != nil {
+		return err
+	}
+	return conn.publish(conn.presenceTopic, data, true)
+}
+
+// Close announces the absence and disconnects the broker
+func (conn *MQTTConnection) Close() error {
+	data, err := json.Marshal(&Presence{Online: false, SessionID: conn.sessionID})
+	if err == nil && conn.client.IsConnectionOpen() {
+		_ = conn.publish(conn.presenceTopic, data, true)
+	}
+	conn.client.Disconnect(mqttDisconnectQuiesce)
+	conn.close()
+	return nil
+}
@@ -0,0 +1,43 @@
+package wsclient
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/edgeai-neptune/neptune/pkg/messagelayer"
+)
+
+func TestMQTTVerifyDownstream(t *testing.T) {
+	const topic = "neptune/node1/downstream"
+	key := messagelayer.DeriveSigningKey("token1")
+	conn := &MQTTConnection{key: key, sessionID: "session1"}
+
+	payload := func(sessionID string, sequence uint64, signKey []byte) []byte {
+		signed := messagelayer.SignedPayload{Data: []byte("{}"), SessionID: sessionID,
+			Sequence: sequence, Timestamp: time.Now().Unix()}
+		if signKey != nil {
+			signed.Sign(signKey, topic)
+		}
+		data, _ := json.Marshal(&signed)
+		return data
+	}
+
+	steps := []struct {
+		name     string
+		payload  []byte
+		accepted bool
+	}{
+		{"signed by GM", payload("session1", 1, key), true},
+		{"next", payload("session1", 3, key), true},
+		{"replayed", payload("session1", 3, key), false},
+		{"of the previous session", payload("session0", 4, key), false},
+		{"signed by the token", payload("session1", 5, []byte("token1")), false},
+		{"unsigned", payload("session1", 6, nil), false},
+	}
+	for _, s := range steps {
+		if _, err := conn.verifyDownstream(topic, s.payload); (err == nil) != s.accepted {
+			t.Errorf("%s: expected accepted %v, got %v", s.name, s.accepted, err)
+		}
+	}
+}
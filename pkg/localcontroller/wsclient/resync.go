@@ -0,0 +1,250 @@
+package wsclient
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
+)
+
+const (
+	// ResyncOperation is the operation of the messages resyncing the resources
+	// of the node between LC and GM
+	ResyncOperation = "resync"
+	// NodeKind is the resource kind of the resync messages
+	NodeKind = "node"
+
+	insertOperation = "insert"
+	deleteOperation = "delete"
+
+	// tombstoneTTL is how long a deleted resource is remembered, which is far
+	// longer than a resync answer could be delayed
+	tombstoneTTL = time.Hour
+)
+
+// ResyncResource defines a resource in the resync messages.
+// In the request LC reports the resources it holds, and in the response GM
+// returns all resources bound to the node, whose Object is omitted when LC
+// holds the same resource version.
+type ResyncResource struct {
+	Kind            string `json:"kind"`
+	Namespace       string `json:"namespace"`
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+	Object          []byte `json:"object,omitempty"`
+}
+
+func resyncKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// listHeldResources lists the resources held in db
+func listHeldResources() ([]ResyncResource, error) {
+	resources, err := db.ListResources()
+	if err != nil {
+		return nil, err
+	}
+
+	held := make([]ResyncResource, 0, len(resources))
+	for _, r := range resources {
+		if r.ResourceKind == "" {
+			// saved by the old version without the identity
+			continue
+		}
+		held = append(held, ResyncResource{
+			Kind:            r.ResourceKind,
+			Namespace:       r.Namespace,
+			Name:            r.ResourceName,
+			ResourceVersion: r.ResourceVersion,
+		})
+	}
+	return held, nil
+}
+
+// resourceVersionOf returns the resource version of the object in json
+func resourceVersionOf(object []byte) string {
+	var meta struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}
+	_ = json.Unmarshal(object, &meta)
+	return meta.Metadata.ResourceVersion
+}
+
+// isNewerVersion compares the resource versions, which are the revisions of
+// etcd in practice. The versions not comparable are considered newer unless
+// they're the same, so that an update is never skipped by mistake.
+func isNewerVersion(version, than string) bool {
+	v, err := strconv.ParseUint(version, 10, 64)
+	if err != nil {
+		return version != than
+	}
+	t, err := strconv.ParseUint(than, 10, 64)
+	if err != nil {
+		return version != than
+	}
+	return v > t
+}
+
+// applyResource passes the message of the resource to the handler, unless
+// the insert is not newer than the resource held or deleted, e.g. listed by
+// the resync answer before a deletion, or the deletion is older than the
+// resource held. The deletions are recorded as the tombstones.
+func (c *Client) applyResource(handler MessageHandler, message *Message, version string) {
+	header := &message.Header
+	if header.Operation != insertOperation && header.Operation != deleteOperation {
+		handler(message)
+		return
+	}
+
+	stored, found, err := db.GetResourceVersion(header.ResourceKind, header.Namespace, header.ResourceName)
+	if err != nil {
+		klog.Errorf("client failed to get the resource version of %s %s/%s, error: %v",
+			header.ResourceKind, header.Namespace, header.ResourceName, err)
+	} else if found && version != "" {
+		stale := !isNewerVersion(version, stored)
+		if header.Operation == deleteOperation {
+			stale = isNewerVersion(stored, version)
+		}
+		if stale {
+			klog.V(2).Infof("client skipped stale %s of %s %s/%s: version %s, held %s", header.Operation,
+				header.ResourceKind, header.Namespace, header.ResourceName, version, stored)
+			return
+		}
+	}
+
+	if header.Operation == deleteOperation {
+		if err := db.SaveTombstone(header.ResourceKind, header.Namespace, header.ResourceName, version); err != nil {
+			klog.Errorf("client failed to record the deletion of %s %s/%s, error: %v",
+				header.ResourceKind, header.Namespace, header.ResourceName, err)
+		}
+	}
+	handler(message)
+}
+
+// newResyncRequest creates the message requesting GM to resync the resources
+// of this node. It's sent first on every connection, so it requires no ack.
+func (c *Client) newResyncRequest() (*Message, error) {
+	held, err := listHeldResources()
+	if err != nil {
+		return nil, err
+	}
+
+	requested := make(map[string]string, len(held))
+	for _, r := range held {
+		requested[resyncKey(r.Kind, r.Namespace, r.Name)] = r.ResourceVersion
+	}
+	c.requestLock.Lock()
+	c.requestedVersions = requested
+	c.requestLock.Unlock()
+
+	content, err := json.Marshal(held)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Header: MessageHeader{
+			ResourceKind: NodeKind,
+			ResourceName: c.Options.NodeName,
+			Operation:    ResyncOperation,
+		},
+		Content: content,
+	}, nil
+}
+
+// dispatch applies the message of the resource of version by the handler
+// subscribed its kind
+func (c *Client) dispatch(message *Message, version string) {
+	handler := c.SubscribeMessageMap[message.Header.ResourceKind]
+	if handler == nil {
+		klog.Errorf("%s hadn't registered in websocket client", message.Header.ResourceKind)
+		return
+	}
+	c.applyResource(handler, message, version)
+}
+
+// handleResync converges the resources held by LC to the ones in the resync
+// response: the updated resources are inserted, and the resources no longer
+// bound to the node are deleted.
+// It's serialized with the other handlers, and the resources of the answer
+// not newer than the ones held or deleted are skipped, so that the stale
+// snapshot doesn't revert the messages handled before it. The resources held
+// are only deleted if unchanged since requested, since the ones changed after
+// are newer than the answer. The kinds without handler are left untouched.
+func (c *Client) handleResync(message *Message) {
+	var resources []ResyncResource
+	if err := json.Unmarshal(message.Content, &resources); err != nil {
+		klog.Errorf("client failed to unmarshal resync response from global manager(address: %s), error: %v",
+			c.Options.GMAddr, err)
+		return
+	}
+
+	held, err := listHeldResources()
+	if err != nil {
+		klog.Errorf("client failed to list the held resources for resyncing, error: %v", err)
+		return
+	}
+
+	bound := make(map[string]bool)
+	var inserted, deleted int
+	for _, r := range resources {
+		bound[resyncKey(r.Kind, r.Namespace, r.Name)] = true
+		if len(r.Object) == 0 {
+			// unchanged
+			continue
+		}
+		if c.SubscribeMessageMap[r.Kind] == nil {
+			// the kind is not synced by this version of LC
+			klog.V(2).Infof("client skipped resyncing %s %s/%s without handler", r.Kind, r.Namespace, r.Name)
+			continue
+		}
+
+		c.dispatch(&Message{
+			Header: MessageHeader{
+				Namespace:    r.Namespace,
+				ResourceKind: r.Kind,
+				ResourceName: r.Name,
+				Operation:    insertOperation,
+			},
+			Content: r.Object,
+		}, r.ResourceVersion)
+		inserted++
+	}
+
+	c.requestLock.Lock()
+	requested := c.requestedVersions
+	c.requestLock.Unlock()
+	for _, r := range held {
+		key := resyncKey(r.Kind, r.Namespace, r.Name)
+		if bound[key] || c.SubscribeMessageMap[r.Kind] == nil {
+			continue
+		}
+		if version, ok := requested[key]; !ok || version != r.ResourceVersion {
+			klog.V(2).Infof("client skipped deleting %s %s/%s changed since the resync requested",
+				r.Kind, r.Namespace, r.Name)
+			continue
+		}
+
+		c.dispatch(&Message{
+			Header: MessageHeader{
+				Namespace:    r.Namespace,
+				ResourceKind: r.Kind,
+				ResourceName: r.Name,
+				Operation:    deleteOperation,
+			},
+		}, r.ResourceVersion)
+		deleted++
+	}
+
+	if err := db.PruneTombstones(time.Now().Add(-tombstoneTTL)); err != nil {
+		klog.Errorf("client failed to prune the tombstones, error: %v", err)
+	}
+
+	klog.Infof("client resynced with global manager(address: %s): %d resources bound, %d inserted, %d deleted",
+		c.Options.GMAddr, len(resources), inserted, deleted)
+}
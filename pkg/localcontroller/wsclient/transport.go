@@ -0,0 +1,48 @@
+package wsclient
+
+import (
+	"fmt"
+)
+
+const (
+	// TransportWebSocket means LC connects the websocket server of GM
+	TransportWebSocket = "websocket"
+	// TransportMQTT means LC exchanges the messages with GM through a MQTT broker
+	TransportMQTT = "mqtt"
+	// TransportGRPC means LC opens a grpc stream to GM
+	TransportGRPC = "grpc"
+)
+
+// Connection defines a connection to GM carrying the messages
+type Connection interface {
+	// ReadMessage blocks until a message received from GM
+	ReadMessage(message *Message) error
+	// WriteMessage writes a message to GM
+	WriteMessage(message *Message) error
+	// Ping sends a heartbeat to GM
+	Ping() error
+	// Close closes the connection, which breaks the blocked ReadMessage
+	Close() error
+}
+
+// Transport defines how to connect GM
+type Transport interface {
+	// Dial connects GM once
+	Dial() (Connection, error)
+}
+
+// newTransport creates the transport by the options
+func (c *Client) newTransport() (Transport, error) {
+	switch c.Options.Transport {
+	case "", TransportWebSocket:
+		return &wsTransport{c: c}, nil
+	case TransportMQTT:
+		if c.Options.MQTTBroker == "" {
+			return nil, fmt.Errorf("mqtt broker is required by mqtt transport")
+		}
+		return &mqttTransport{c: c}, nil
+	case TransportGRPC:
+		return &grpcTransport{c: c}, nil
+	}
+	return nil, fmt.Errorf("unknown transport %s", c.Options.Transport)
+}
@@ -1,10 +1,16 @@
 package wsclient
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -12,6 +18,11 @@ import (
 
 	"github.com/edgeai-neptune/neptune/cmd/neptune-lc/app/options"
 	"github.com/edgeai-neptune/neptune/pkg/localcontroller/common/constants"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/metrics"
+	"github.com/edgeai-neptune/neptune/pkg/messagelayer"
+	"github.com/edgeai-neptune/neptune/pkg/util"
+	"github.com/edgeai-neptune/neptune/pkg/version"
 )
 
 // MessageHandler defines message handler function
@@ -20,10 +31,37 @@ type MessageHandler func(*Message)
 // Client defines a client
 type Client struct {
 	Options             *options.LocalControllerOptions
-	WSConnection        *WSConnection
+	Connection          Connection
 	SubscribeMessageMap map[string]MessageHandler
-	SendMessageChannel  chan Message
 	ReconnectChannel    chan struct{}
+	// connected is 1 when the connection to GM is established
+	connected int32
+
+	// sequence is the last sequence assigned to the upstream messages,
+	// which are persisted in the outbox until acknowledged by GM
+	sequence uint64
+	// outboxLock serializes assigning the sequences and adding to the outbox,
+	// so that the messages are added in the order of sequence
+	outboxLock sync.Mutex
+	// outboxSignal notifies the new messages added to the outbox
+	outboxSignal chan struct{}
+	// ackChannel holds the acks to be sent to GM
+	ackChannel chan Message
+	// recentMessageIDs detects the retransmitted downstream messages
+	recentMessageIDs *util.RecentSet
+	// handlerLock serializes the resyncs with the other handlers: the handlers
+	// hold the read lock, and the resyncs hold the write lock. The handlers may
+	// run in any order, the stale resources are skipped by the resource versions.
+	handlerLock sync.RWMutex
+	// requestedVersions are the resource versions held when the last resync
+	// requested, keyed by resyncKey
+	requestedVersions map[string]string
+	requestLock       sync.Mutex
+
+	// certificates is nil when connecting GM without TLS
+	certificates *util.CertificateReloader
+	// transport establishes the connections to GM
+	transport Transport
 }
 
 // Message defines message
@@ -38,11 +76,127 @@ type MessageHeader struct {
 	ResourceKind string `json:"resourceKind"`
 	ResourceName string `json:"resourceName"`
 	Operation    string `json:"operation"`
+	// Sequence is the sequence number of the message acknowledged by the receiver,
+	// 0 means no ack required
+	Sequence uint64 `json:"sequence,omitempty"`
+	// MessageID is the idempotency key of the message,
+	// a retransmitted message keeps its MessageID
+	MessageID string `json:"messageID,omitempty"`
 }
 
 // WSConnection defines conn
 type WSConnection struct {
 	WSConn *websocket.Conn
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// extendReadDeadline postpones the read deadline since GM is alive
+func (conn *WSConnection) extendReadDeadline() {
+	if conn.readTimeout > 0 {
+		_ = conn.WSConn.SetReadDeadline(time.Now().Add(conn.readTimeout))
+	}
+}
+
+// writeDeadline returns the deadline of a write starting now
+func (conn *WSConnection) writeDeadline() time.Time {
+	if conn.writeTimeout > 0 {
+		return time.Now().Add(conn.writeTimeout)
+	}
+	return time.Time{}
+}
+
+// writeJSON writes the message with the write deadline
+func (conn *WSConnection) writeJSON(v interface{}) error {
+	_ = conn.WSConn.SetWriteDeadline(conn.writeDeadline())
+	return conn.WSConn.WriteJSON(v)
+}
+
+// ReadMessage reads a message from GM
+func (conn *WSConnection) ReadMessage(message *Message) error {
+	if err := conn.WSConn.ReadJSON(message); err != nil {
+		return err
+	}
+	conn.extendReadDeadline()
+	return nil
+}
+
+// WriteMessage writes a message to GM
+func (conn *WSConnection) WriteMessage(message *Message) error {
+	return conn.writeJSON(message)
+}
+
+// Ping sends a ping to GM
+func (conn *WSConnection) Ping() error {
+	return conn.WSConn.WriteControl(websocket.PingMessage, nil, conn.writeDeadline())
+}
+
+// Close closes the connection
+func (conn *WSConnection) Close() error {
+	return conn.WSConn.Close()
+}
+
+// wsTransport connects GM by websocket
+type wsTransport struct {
+	c *Client
+}
+
+// Dial connects the websocket server of GM
+func (t *wsTransport) Dial() (Connection, error) {
+	c := t.c
+	header := http.Header{}
+	header.Add(constants.WSHeaderNodeName, c.Options.NodeName)
+	header.Add(messagelayer.WSHeaderNodeVersion, version.Get().GitVersion)
+	token, err := c.getToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the token of node %s: %w", c.Options.NodeName, err)
+	}
+	if token != "" {
+		header.Add("Authorization", "Bearer "+token)
+	}
+	u := url.URL{Scheme: constants.WSScheme, Host: c.Options.GMAddr, Path: "/"}
+
+	dialer := websocket.DefaultDialer
+	if c.certificates != nil {
+		u.Scheme = constants.WSSScheme
+		d := *websocket.DefaultDialer
+		d.TLSClientConfig = c.newTLSConfig()
+		dialer = &d
+	}
+
+	wsConn, _, err := dialer.Dial(u.String(), header)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &WSConnection{
+		WSConn:       wsConn,
+		readTimeout:  c.Options.ReadTimeout,
+		writeTimeout: c.Options.WriteTimeout,
+	}
+	if err = conn.writeJSON(&MessageHeader{}); err != nil {
+		_ = wsConn.Close()
+		return nil, err
+	}
+
+	// GM is considered dead when neither message nor heartbeat
+	// received within the read timeout.
+	conn.extendReadDeadline()
+	wsConn.SetPongHandler(func(string) error {
+		conn.extendReadDeadline()
+		return nil
+	})
+	wsConn.SetPingHandler(func(data string) error {
+		conn.extendReadDeadline()
+		err := wsConn.WriteControl(websocket.PongMessage, []byte(data), conn.writeDeadline())
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	return conn, nil
 }
 
 const (
@@ -52,14 +206,36 @@ const (
 	RetryConnectIntervalSeconds = 5
 	// MessageChannelCacheSize is size of channel cache
 	MessageChannelCacheSize = 100
+	// RecentMessageIDsSize is the number of recent message ids remembered for detecting duplicates
+	RecentMessageIDsSize = 1024
+	// AckOperation is the operation of the message acknowledging a received message
+	AckOperation = "ack"
+
+	// outboxRetryInterval is the interval of retrying to list the outbox when failed
+	outboxRetryInterval = 5 * time.Second
+)
+
+var (
+	// retransmitTimeout is the time waiting for the ack of a message before
+	// it's sent again on the same connection, since GM may drop the message
+	// without the connection broken, e.g. the mqtt server overloaded
+	retransmitTimeout = 30 * time.Second
+	// retransmitCheckInterval is the interval of checking the messages
+	// waiting for the acks
+	retransmitCheckInterval = 10 * time.Second
 )
 
+// instanceID distinguishes the message ids of this LC instance from the ones of previous instances
+var instanceID = strconv.FormatInt(time.Now().UnixNano(), 36)
+
 // NewClient creates client
 func NewClient(options *options.LocalControllerOptions) *Client {
 	c := Client{
 		Options:             options,
 		SubscribeMessageMap: make(map[string]MessageHandler),
-		SendMessageChannel:  make(chan Message, MessageChannelCacheSize),
+		outboxSignal:        make(chan struct{}, 1),
+		ackChannel:          make(chan Message, MessageChannelCacheSize),
+		recentMessageIDs:    util.NewRecentSet(RecentMessageIDsSize),
 	}
 
 	return &c
@@ -82,11 +258,11 @@ func (c *Client) handleReceivedMessage(stop chan struct{}) {
 		stop <- struct{}{}
 	}()
 
-	ws := c.WSConnection.WSConn
+	conn := c.Connection
 
 	for {
 		message := Message{}
-		if err := ws.ReadJSON(&message); err != nil {
+		if err := conn.ReadMessage(&message); err != nil {
 			klog.Errorf("client received message from global manager(address: %s) failed, error: %v",
 				c.Options.GMAddr, err)
 			return
@@ -97,81 +273,272 @@ func (c *Client) handleReceivedMessage(stop chan struct{}) {
 		klog.V(4).Infof("client received message content: %s from global manager(address: %s)",
 			message.Content, c.Options.GMAddr)
 
+		if message.Header.Operation == AckOperation {
+			c.ackMessage(message.Header.Sequence)
+			continue
+		}
+
+		if id := message.Header.MessageID; id != "" && !c.recentMessageIDs.Add(id) {
+			// the message had been handled, but GM didn't receive the ack
+			klog.V(2).Infof("client ignored duplicate message %s from global manager(address: %s)",
+				id, c.Options.GMAddr)
+			c.sendAck(&message)
+			continue
+		}
+
+		if message.Header.ResourceKind == NodeKind && message.Header.Operation == ResyncOperation {
+			go func(message Message) {
+				// the locks are acquired by the handlers rather than the read
+				// loop, which keeps reading while a handler is slow
+				c.handlerLock.Lock()
+				defer c.handlerLock.Unlock()
+				c.handleResync(&message)
+				c.sendAck(&message)
+			}(message)
+			continue
+		}
+
 		handler := c.SubscribeMessageMap[message.Header.ResourceKind]
 		if handler != nil {
-			go handler(&message)
+			go func(message Message) {
+				c.handlerLock.RLock()
+				defer c.handlerLock.RUnlock()
+				c.applyResource(handler, &message, resourceVersionOf(message.Content))
+				c.sendAck(&message)
+			}(message)
 		} else {
 			klog.Errorf("%s hadn't registered in websocket client", message.Header.ResourceKind)
+			c.sendAck(&message)
 		}
 	}
 }
 
-// WriteMessage saves message in a queue
+// sendAck acknowledges the message received from GM
+func (c *Client) sendAck(message *Message) {
+	if message.Header.Sequence == 0 {
+		return
+	}
+
+	ack := Message{
+		Header: MessageHeader{
+			Operation: AckOperation,
+			Sequence:  message.Header.Sequence,
+			MessageID: message.Header.MessageID,
+		},
+	}
+
+	select {
+	case c.ackChannel <- ack:
+	default:
+		// GM retransmits the message if the ack is lost
+		klog.Warningf("ack channel is full, drop the ack of message %s", message.Header.MessageID)
+	}
+}
+
+// ackMessage deletes the message acknowledged by GM from the outbox
+func (c *Client) ackMessage(sequence uint64) {
+	if err := db.DeleteUpstreamMessage(sequence); err != nil {
+		return
+	}
+	c.updateBacklog()
+}
+
+// updateBacklog records the number of messages in the outbox
+func (c *Client) updateBacklog() {
+	if count, err := db.CountUpstreamMessages(); err == nil {
+		metrics.SendMessageBacklog.Set(float64(count))
+	}
+}
+
+// coalesceKey returns the key of the snapshot message replaced by the newer one
+func coalesceKey(header *MessageHeader) string {
+	return strings.Join([]string{header.ResourceKind, header.Namespace, header.ResourceName, header.Operation}, "/")
+}
+
+// WriteMessage saves message in the outbox, which doesn't block while GM is
+// unreachable. The messages are sent in order after connected, and kept until
+// acknowledged by GM.
 func (c *Client) WriteMessage(messageBody interface{}, messageHeader MessageHeader) error {
+	return c.writeMessage(messageBody, messageHeader, "")
+}
+
+// WriteSnapshotMessage saves message like WriteMessage, but replaces the
+// pending message of the same resource and operation, so only the latest one
+// is sent. It's only for the messages carrying the whole state, e.g. the
+// number of samples of a dataset, whose older ones are obsolete.
+func (c *Client) WriteSnapshotMessage(messageBody interface{}, messageHeader MessageHeader) error {
+	return c.writeMessage(messageBody, messageHeader, coalesceKey(&messageHeader))
+}
+
+// writeMessage saves message in the outbox, replacing the pending messages
+// with the same coalesce key if not empty
+func (c *Client) writeMessage(messageBody interface{}, messageHeader MessageHeader, key string) error {
 	content, err := json.Marshal(&messageBody)
 	if err != nil {
 		return err
 	}
 
-	message := Message{
-		Content: content,
-		Header:  messageHeader,
+	c.outboxLock.Lock()
+	messageHeader.Sequence = c.sequence + 1
+	messageHeader.MessageID = instanceID + "-" + strconv.FormatUint(messageHeader.Sequence, 10)
+	err = db.AddUpstreamMessage(&db.UpstreamMessage{
+		CoalesceKey:  key,
+		Sequence:     messageHeader.Sequence,
+		MessageID:    messageHeader.MessageID,
+		Namespace:    messageHeader.Namespace,
+		ResourceKind: messageHeader.ResourceKind,
+		ResourceName: messageHeader.ResourceName,
+		Operation:    messageHeader.Operation,
+		Content:      content,
+	})
+	if err == nil {
+		c.sequence = messageHeader.Sequence
+	}
+	c.outboxLock.Unlock()
+	if err != nil {
+		return err
 	}
 
-	c.SendMessageChannel <- message
+	select {
+	case c.outboxSignal <- struct{}{}:
+	default:
+		// the sender has been notified
+	}
+	c.updateBacklog()
 
 	return nil
 }
 
-// sendMessage sends the message through the connection
-func (c *Client) sendMessage(stop chan struct{}) {
+// newOutboxMessage converts the message in the outbox
+func newOutboxMessage(m *db.UpstreamMessage) Message {
+	return Message{
+		Header: MessageHeader{
+			Namespace:    m.Namespace,
+			ResourceKind: m.ResourceKind,
+			ResourceName: m.ResourceName,
+			Operation:    m.Operation,
+			Sequence:     m.Sequence,
+			MessageID:    m.MessageID,
+		},
+		Content: m.Content,
+	}
+}
+
+// sendMessage sends the message through the connection until done closed
+func (c *Client) sendMessage(stop chan struct{}, done <-chan struct{}) {
 	defer func() {
 		stop <- struct{}{}
 	}()
 
-	messageChannel := c.SendMessageChannel
-	ws := c.WSConnection.WSConn
+	conn := c.Connection
 
+	// request GM to resync the resources changed while disconnected
+	if request, err := c.newResyncRequest(); err != nil {
+		klog.Errorf("client failed to create resync request, error: %v", err)
+	} else if err = conn.WriteMessage(request); err != nil {
+		klog.Errorf("client sent resync request to global manager(address: %s) failed, error: %v",
+			c.Options.GMAddr, err)
+		return
+	}
+
+	// the messages not acknowledged in the previous connections are
+	// retransmitted first
+	var lastSequence uint64
+	// sent records the time each message sent on this connection, the ones
+	// not acknowledged within retransmitTimeout are sent again
+	sent := make(map[uint64]time.Time)
+	retransmitTicker := time.NewTicker(retransmitCheckInterval)
+	defer retransmitTicker.Stop()
+	retransmitting := false
 	for {
-		message, ok := <-messageChannel
-		if !ok {
-			return
+		after := lastSequence
+		if retransmitting {
+			// the messages sent are listed too
+			after = 0
+		}
+		messages, err := db.ListUpstreamMessages(after)
+		if err != nil {
+			klog.Errorf("client failed to list the outbox, retry later, error: %v", err)
 		}
 
-		if err := ws.WriteJSON(&message); err != nil {
-			klog.Errorf("client sent message to global manager(address: %s) failed, error: %v",
-				c.Options.GMAddr, err)
+		now := time.Now()
+		if retransmitting && err == nil {
+			// forget the messages acknowledged
+			pending := make(map[uint64]bool, len(messages))
+			for i := range messages {
+				pending[messages[i].Sequence] = true
+			}
+			for sequence := range sent {
+				if !pending[sequence] {
+					delete(sent, sequence)
+				}
+			}
+		}
+		retransmitting = false
+
+		for i := range messages {
+			message := newOutboxMessage(&messages[i])
+			sequence := message.Header.Sequence
+			if sequence <= lastSequence {
+				if now.Sub(sent[sequence]) < retransmitTimeout {
+					continue
+				}
+				klog.Warningf("client retransmits message %s not acknowledged by global manager(address: %s) in %v",
+					message.Header.MessageID, c.Options.GMAddr, retransmitTimeout)
+			}
+			if err := conn.WriteMessage(&message); err != nil {
+				klog.Errorf("client sent message to global manager(address: %s) failed, error: %v",
+					c.Options.GMAddr, err)
+				return
+			}
+			sent[sequence] = now
+			if sequence > lastSequence {
+				lastSequence = sequence
+			}
 
-			c.SendMessageChannel <- message
+			klog.V(2).Infof("client sent message header: %+v to global manager(address: %s)",
+				message.Header, c.Options.GMAddr)
+			klog.V(4).Infof("client sent message content: %s to global manager(address: %s)",
+				message.Content, c.Options.GMAddr)
+		}
 
-			return
+		// retry is nil, which blocks forever, unless failed to list the outbox
+		var retry <-chan time.Time
+		if err != nil {
+			retry = time.After(outboxRetryInterval)
 		}
 
-		klog.V(2).Infof("client sent message header: %+v to global manager(address: %s)",
-			message.Header, c.Options.GMAddr)
-		klog.V(4).Infof("client sent message content: %s to global manager(address: %s)",
-			message.Content, c.Options.GMAddr)
+		for waiting := true; waiting; {
+			select {
+			case <-done:
+				return
+			case ack := <-c.ackChannel:
+				if err := conn.WriteMessage(&ack); err != nil {
+					klog.Errorf("client sent ack to global manager(address: %s) failed, error: %v",
+						c.Options.GMAddr, err)
+					return
+				}
+			case <-c.outboxSignal:
+				waiting = false
+			case <-retry:
+				waiting = false
+			case <-retransmitTicker.C:
+				retransmitting = true
+				waiting = false
+			}
+		}
 	}
 }
 
 // connect tries to connect remote server
 func (c *Client) connect() error {
-	header := http.Header{}
-	header.Add(constants.WSHeaderNodeName, c.Options.NodeName)
-	u := url.URL{Scheme: constants.WSScheme, Host: c.Options.GMAddr, Path: "/"}
-
 	klog.Infof("client starts to connect global manager(address: %s)", c.Options.GMAddr)
 
 	for i := 0; i < RetryCount; i++ {
-		wsConn, _, err := websocket.DefaultDialer.Dial(u.String(), header)
-
+		conn, err := c.transport.Dial()
 		if err == nil {
-			if errW := wsConn.WriteJSON(&MessageHeader{}); errW != nil {
-				return errW
-			}
-
-			c.WSConnection = &WSConnection{WSConn: wsConn}
-			klog.Infof("websocket connects global manager(address: %s) successful", c.Options.GMAddr)
+			c.Connection = conn
+			klog.Infof("client connects global manager(address: %s) successful", c.Options.GMAddr)
 
 			return nil
 		}
@@ -189,8 +556,64 @@ func (c *Client) connect() error {
 	return errorMsg
 }
 
+// getToken returns the bootstrap token of the node, empty if not configured
+func (c *Client) getToken() (string, error) {
+	if c.Options.TokenFile == "" {
+		return c.Options.Token, nil
+	}
+
+	data, err := ioutil.ReadFile(c.Options.TokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// newTLSConfig returns the TLS config with the current certificates, which is
+// created for each connection so that the rotated certificates take effect
+// after reconnecting
+func (c *Client) newTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// nil means the system CAs
+		RootCAs: c.certificates.CAPool(),
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if certificate := c.certificates.Certificate(); certificate != nil {
+				return certificate, nil
+			}
+			// no client certificate
+			return &tls.Certificate{}, nil
+		},
+	}
+}
+
 // Start starts websocket client
 func (c *Client) Start() error {
+	if c.Options.GMCAFile != "" || c.Options.CertFile != "" {
+		certificates, err := util.NewCertificateReloader(c.Options.CertFile, c.Options.KeyFile, c.Options.GMCAFile)
+		if err != nil {
+			klog.Errorf("client failed to load the certificates for global manager, error: %v", err)
+			return err
+		}
+		c.certificates = certificates
+	}
+
+	// the sequences continue after the ones in the outbox
+	sequence, err := db.MaxUpstreamSequence()
+	if err != nil {
+		klog.Errorf("client failed to read the outbox, error: %v", err)
+		return err
+	}
+	c.sequence = sequence
+	c.updateBacklog()
+
+	transport, err := c.newTransport()
+	if err != nil {
+		klog.Errorf("client failed to create the transport to global manager, error: %v", err)
+		return err
+	}
+	c.transport = transport
+
 	go c.reconnect()
 
 	return nil
@@ -198,17 +621,71 @@ func (c *Client) Start() error {
 
 // reconnect reconnects global manager
 func (c *Client) reconnect() {
+	lost := false
 	for {
 		if err := c.connect(); err != nil {
 			continue
 		}
-		ws := c.WSConnection.WSConn
+		conn := c.Connection
+
+		c.setConnected(true)
+		if lost {
+			metrics.GMReconnects.Inc()
+		}
 
-		stop := make(chan struct{}, 2)
+		stop := make(chan struct{}, 3)
+		done := make(chan struct{})
 		go c.handleReceivedMessage(stop)
-		go c.sendMessage(stop)
+		go c.sendMessage(stop, done)
+		go c.keepAlive(stop, done)
 		<-stop
 
-		_ = ws.Close()
+		// tear down the loops of this connection, the unsent and unacked
+		// messages stay in the outbox of the database, which are sent
+		// on the next connection.
+		close(done)
+		_ = conn.Close()
+
+		c.setConnected(false)
+		lost = true
+	}
+}
+
+// keepAlive sends pings to global manager periodically until done closed
+func (c *Client) keepAlive(stop chan struct{}, done <-chan struct{}) {
+	if c.Options.PingInterval <= 0 {
+		return
+	}
+
+	conn := c.Connection
+	ticker := time.NewTicker(c.Options.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := conn.Ping(); err != nil {
+				klog.Errorf("client pinged global manager(address: %s) failed, error: %v",
+					c.Options.GMAddr, err)
+				stop <- struct{}{}
+				return
+			}
+		}
 	}
 }
+
+// setConnected records the connection state to global manager
+func (c *Client) setConnected(connected bool) {
+	var v int32
+	if connected {
+		v = 1
+	}
+	atomic.StoreInt32(&c.connected, v)
+	metrics.GMConnected.Set(float64(v))
+}
+
+// IsConnected returns whether the connection to global manager is established
+func (c *Client) IsConnected() bool {
+	return atomic.LoadInt32(&c.connected) == 1
+}
@@ -0,0 +1,377 @@
+package wsclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgeai-neptune/neptune/cmd/neptune-lc/app/options"
+	"github.com/edgeai-neptune/neptune/pkg/localcontroller/db"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(db.RunWithTempDB(m, "wsclient"))
+}
+
+// recordConnection records the messages written
+type recordConnection struct {
+	lock     sync.Mutex
+	messages []Message
+}
+
+func (conn *recordConnection) ReadMessage(*Message) error {
+	select {}
+}
+
+func (conn *recordConnection) WriteMessage(message *Message) error {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	conn.messages = append(conn.messages, *message)
+	return nil
+}
+
+func (conn *recordConnection) Ping() error {
+	return nil
+}
+
+func (conn *recordConnection) Close() error {
+	return nil
+}
+
+func (conn *recordConnection) written() []Message {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	return append([]Message(nil), conn.messages...)
+}
+
+// feedConnection returns the messages fed in order, then fails
+type feedConnection struct {
+	recordConnection
+	feed chan Message
+}
+
+func (conn *feedConnection) ReadMessage(message *Message) error {
+	m, ok := <-conn.feed
+	if !ok {
+		return fmt.Errorf("connection closed")
+	}
+	*message = m
+	return nil
+}
+
+// describe returns the resource name and the content of the messages
+func describe(messages []Message) []string {
+	var desc []string
+	for _, m := range messages {
+		desc = append(desc, m.Header.ResourceName+":"+string(m.Content))
+	}
+	return desc
+}
+
+func TestOutboxReplaysMessagesInOrder(t *testing.T) {
+	c := NewClient(&options.LocalControllerOptions{NodeName: "node1"})
+
+	job := MessageHeader{Namespace: "default", ResourceKind: "federatedlearningjob", ResourceName: "job1", Operation: "status"}
+	dataset := MessageHeader{Namespace: "default", ResourceKind: "dataset", ResourceName: "dataset1", Operation: "status"}
+	writes := []struct {
+		header   MessageHeader
+		body     string
+		snapshot bool
+	}{
+		{job, "round1", false},
+		{dataset, "samples10", true},
+		{job, "round2", false},
+		{dataset, "samples20", true},
+		{job, "round3", false},
+	}
+	for _, w := range writes {
+		write := c.WriteMessage
+		if w.snapshot {
+			write = c.WriteSnapshotMessage
+		}
+		if err := write(w.body, w.header); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every round of the job is kept, only the latest snapshot of the dataset
+	expected := []string{`job1:"round1"`, `job1:"round2"`, `dataset1:"samples20"`, `job1:"round3"`}
+
+	conn := &recordConnection{}
+	c.Connection = conn
+	stop := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go c.sendMessage(stop, done)
+
+	deadline := time.Now().Add(5 * time.Second)
+	// the resync request is written first
+	for len(conn.written()) < len(expected)+1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for the messages replayed, got %v", describe(conn.written()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(done)
+	<-stop
+
+	written := conn.written()
+	if written[0].Header.Operation != ResyncOperation {
+		t.Errorf("expected the resync request first, got %+v", written[0].Header)
+	}
+	replayed := written[1:]
+	if desc := describe(replayed); !reflect.DeepEqual(desc, expected) {
+		t.Fatalf("expected replayed messages %v, got %v", expected, desc)
+	}
+	for i := 1; i < len(replayed); i++ {
+		if replayed[i].Header.Sequence <= replayed[i-1].Header.Sequence {
+			t.Errorf("expected increasing sequences, got %d after %d",
+				replayed[i].Header.Sequence, replayed[i-1].Header.Sequence)
+		}
+	}
+
+	// the acknowledged messages are not replayed again
+	for _, m := range replayed[:2] {
+		c.ackMessage(m.Header.Sequence)
+	}
+	messages, err := db.ListUpstreamMessages(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pending []string
+	for i := range messages {
+		pending = append(pending, describe([]Message{newOutboxMessage(&messages[i])})...)
+	}
+	if !reflect.DeepEqual(pending, expected[2:]) {
+		t.Errorf("expected pending messages %v after acked, got %v", expected[2:], pending)
+	}
+}
+
+func TestDuplicateMessageHandledOnce(t *testing.T) {
+	c := NewClient(&options.LocalControllerOptions{NodeName: "node1"})
+	handled := make(chan Message, 10)
+	_ = c.Subscribe("model", func(message *Message) {
+		handled <- *message
+	})
+
+	message := Message{
+		Header: MessageHeader{Namespace: "default", ResourceKind: "model", ResourceName: "model1",
+			Operation: "update", Sequence: 7, MessageID: "gm-7"},
+	}
+	conn := &feedConnection{feed: make(chan Message, 2)}
+	// retransmitted by GM since the ack lost
+	conn.feed <- message
+	conn.feed <- message
+	close(conn.feed)
+	c.Connection = conn
+
+	stop := make(chan struct{}, 1)
+	c.handleReceivedMessage(stop)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case ack := <-c.ackChannel:
+			if ack.Header.Operation != AckOperation || ack.Header.Sequence != 7 || ack.Header.MessageID != "gm-7" {
+				t.Errorf("expected the ack of the message, got %+v", ack.Header)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for the ack %d", i)
+		}
+	}
+	if len(handled) != 1 {
+		t.Errorf("expected the message handled once, got %d", len(handled))
+	}
+
+	// no ack for the message not requiring
+	c.sendAck(&Message{Header: MessageHeader{ResourceKind: "model", Operation: "update"}})
+	if len(c.ackChannel) != 0 {
+		t.Errorf("expected no ack of the message without sequence")
+	}
+}
+
+// datasetHandler saves and deletes the datasets in db like the dataset manager
+func datasetHandler() MessageHandler {
+	return func(message *Message) {
+		h := message.Header
+		name := h.Namespace + "/" + h.ResourceName
+		switch h.Operation {
+		case insertOperation:
+			_ = db.SaveDataset(&db.Dataset{ResourceMeta: db.ResourceMeta{
+				Name: name, Namespace: h.Namespace, ResourceName: h.ResourceName,
+				ResourceKind: h.ResourceKind, ResourceVersion: resourceVersionOf(message.Content),
+			}})
+		case deleteOperation:
+			_ = db.DeleteDataset(name)
+		}
+	}
+}
+
+func datasetObject(name, version string) []byte {
+	return []byte(fmt.Sprintf(`{"metadata":{"namespace":"resync","name":%q,"resourceVersion":%q}}`, name, version))
+}
+
+// heldVersions returns the resource versions of the datasets held in the namespace
+func heldVersions(t *testing.T) map[string]string {
+	datasets, err := db.ListDatasets("resync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := make(map[string]string)
+	for _, d := range datasets {
+		versions[d.ResourceName] = d.ResourceVersion
+	}
+	return versions
+}
+
+func TestResyncHandshake(t *testing.T) {
+	c := NewClient(&options.LocalControllerOptions{NodeName: "node1"})
+	_ = c.Subscribe(db.DatasetKind, datasetHandler())
+
+	for _, d := range []struct{ name, version string }{{"d1", "5"}, {"d3", "2"}} {
+		if err := db.SaveDataset(&db.Dataset{ResourceMeta: db.ResourceMeta{Name: "resync/" + d.name,
+			Namespace: "resync", ResourceName: d.name, ResourceKind: db.DatasetKind, ResourceVersion: d.version}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the request reports the resources held
+	request, err := c.newResyncRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var held []ResyncResource
+	if err = json.Unmarshal(request.Content, &held); err != nil {
+		t.Fatal(err)
+	}
+	requested := make(map[string]string)
+	for _, r := range held {
+		if r.Namespace == "resync" {
+			requested[r.Name] = r.ResourceVersion
+		}
+	}
+	if expected := map[string]string{"d1": "5", "d3": "2"}; !reflect.DeepEqual(requested, expected) {
+		t.Errorf("expected the resources held %v requested, got %v", expected, requested)
+	}
+
+	conn := &feedConnection{feed: make(chan Message)}
+	c.Connection = conn
+	stop := make(chan struct{}, 1)
+	go c.handleReceivedMessage(stop)
+	receive := func(message Message) {
+		conn.feed <- message
+		select {
+		case ack := <-c.ackChannel:
+			if ack.Header.Sequence != message.Header.Sequence {
+				t.Fatalf("expected the ack of %d, got %d", message.Header.Sequence, ack.Header.Sequence)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for the ack of %d", message.Header.Sequence)
+		}
+	}
+
+	// before the answer, d4 is created and d1 is deleted
+	receive(Message{Header: MessageHeader{Namespace: "resync", ResourceKind: db.DatasetKind, ResourceName: "d4",
+		Operation: insertOperation, Sequence: 1}, Content: datasetObject("d4", "9")})
+	receive(Message{Header: MessageHeader{Namespace: "resync", ResourceKind: db.DatasetKind, ResourceName: "d1",
+		Operation: deleteOperation, Sequence: 2}, Content: datasetObject("d1", "7")})
+
+	// the answer is listed before d4 created and d1 deleted
+	answer, _ := json.Marshal([]ResyncResource{
+		{Kind: db.DatasetKind, Namespace: "resync", Name: "d1", ResourceVersion: "6", Object: datasetObject("d1", "6")},
+		{Kind: db.DatasetKind, Namespace: "resync", Name: "d2", ResourceVersion: "3", Object: datasetObject("d2", "3")},
+	})
+	receive(Message{Header: MessageHeader{ResourceKind: NodeKind, ResourceName: "node1",
+		Operation: ResyncOperation, Sequence: 3}, Content: answer})
+	close(conn.feed)
+	<-stop
+
+	// d1 deleted is not inserted again, d3 no longer bound is deleted,
+	// and d4 created after the request is kept
+	if versions, expected := heldVersions(t), map[string]string{"d2": "3", "d4": "9"}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("expected the datasets %v held after resynced, got %v", expected, versions)
+	}
+	if version, found, _ := db.GetResourceVersion(db.DatasetKind, "resync", "d1"); !found || version != "7" {
+		t.Errorf("expected the tombstone of d1 with version 7, got %q %v", version, found)
+	}
+}
+
+func TestIsNewerVersion(t *testing.T) {
+	cases := []struct {
+		version, than string
+		newer         bool
+	}{
+		{"10", "9", true},
+		{"9", "10", false},
+		{"9", "9", false},
+		{"abc", "9", true},
+		{"abc", "abc", false},
+	}
+	for _, c := range cases {
+		if newer := isNewerVersion(c.version, c.than); newer != c.newer {
+			t.Errorf("expected isNewerVersion(%q, %q) %v, got %v", c.version, c.than, c.newer, newer)
+		}
+	}
+}
+
+func TestRetransmitUnackedMessages(t *testing.T) {
+	timeout, interval := retransmitTimeout, retransmitCheckInterval
+	retransmitTimeout, retransmitCheckInterval = 50*time.Millisecond, 10*time.Millisecond
+	defer func() {
+		retransmitTimeout, retransmitCheckInterval = timeout, interval
+	}()
+
+	// start with an empty outbox
+	messages, err := db.ListUpstreamMessages(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range messages {
+		if err = db.DeleteUpstreamMessage(messages[i].Sequence); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := NewClient(&options.LocalControllerOptions{NodeName: "node1"})
+	header := MessageHeader{Namespace: "default", ResourceKind: "federatedlearningjob", ResourceName: "job2", Operation: "status"}
+	if err = c.WriteMessage("round1", header); err != nil {
+		t.Fatal(err)
+	}
+
+	conn := &recordConnection{}
+	c.Connection = conn
+	stop := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go c.sendMessage(stop, done)
+	defer func() {
+		close(done)
+		<-stop
+	}()
+
+	// dropped by GM without the connection broken, then sent again
+	waitWritten := func(count int) []Message {
+		deadline := time.Now().Add(5 * time.Second)
+		for len(conn.written()) < count {
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout waiting for %d messages written, got %v", count, describe(conn.written()))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return conn.written()
+	}
+	written := waitWritten(3)
+	if written[1].Header.MessageID != written[2].Header.MessageID {
+		t.Fatalf("expected message %s retransmitted, got %+v", written[1].Header.MessageID, written[2].Header)
+	}
+
+	// never sent again once acknowledged
+	c.ackMessage(written[1].Header.Sequence)
+	time.Sleep(10 * retransmitTimeout)
+	count := len(conn.written())
+	time.Sleep(10 * retransmitTimeout)
+	if len(conn.written()) != count {
+		t.Errorf("expected no message retransmitted after acknowledged, got %v", describe(conn.written()[count:]))
+	}
+}
@@ -0,0 +1,7 @@
+// Package messagelayer holds the protocol constants shared by GM and LC
+package messagelayer
+
+const (
+	// WSHeaderNodeVersion is the websocket header carrying the version of LC
+	WSHeaderNodeVersion = "Node-Version"
+)
@@ -0,0 +1,21 @@
+package pb
+
+import "time"
+
+// MinKeepaliveInterval is the minimum interval of the keepalive pings of LC,
+// which is also the minimum allowed by the grpc client.
+const MinKeepaliveInterval = 10 * time.Second
+
+const (
+	// MetadataNodeName is the metadata key of the stream carrying the node name of LC
+	MetadataNodeName = "node-name"
+	// MetadataNodeVersion is the metadata key of the stream carrying the version of LC
+	MetadataNodeVersion = "node-version"
+	// MetadataAuthorization is the metadata key of the stream carrying the
+	// bootstrap token of the node, i.e. "Bearer <token>"
+	MetadataAuthorization = "authorization"
+
+	// PingOperation is the operation of the heartbeat messages sent by LC,
+	// which are consumed by the transport.
+	PingOperation = "ping"
+)
@@ -0,0 +1,126 @@
+package messagelayer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// signingKeyInfo distinguishes the signing key from the other keys
+	// which might be derived from the token
+	signingKeyInfo = "neptune mqtt message signing"
+
+	// MaxPayloadAge is the max difference between the timestamp of a signed
+	// payload and the time received, which tolerates the clock skew
+	MaxPayloadAge = 5 * time.Minute
+)
+
+// SignedPayload is the payload of the mqtt messages between LC and GM, which
+// is signed by the key derived from the bootstrap token of the node, so that
+// the receiver authenticates the publisher by itself rather than trusting
+// whoever the broker lets publish to the topics of the node. The token is
+// presented to the broker, but the key never leaves LC and GM, so the broker
+// can't forge the messages either.
+type SignedPayload struct {
+	Data []byte `json:"data"`
+
+	// SessionID is the session of LC the payload belongs to, Sequence
+	// increases per topic in the session, and Timestamp is the unix seconds
+	// when signed. They are signed with the data, so that a captured payload
+	// can't be replayed.
+	SessionID string `json:"sessionID,omitempty"`
+	Sequence  uint64 `json:"sequence,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+
+	// Signature is empty when LC has no token
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// DeriveSigningKey derives the key signing the messages from the token by HKDF-SHA256
+func DeriveSigningKey(token string) []byte {
+	key := make([]byte, sha256.Size)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, []byte(token), nil, []byte(signingKeyInfo)), key)
+	return key
+}
+
+// Sign signs the payload published to the topic by the key, the topic is
+// signed so that the payload can't be replayed to other topics.
+func (p *SignedPayload) Sign(key []byte, topic string) {
+	p.Signature = p.mac(key, topic)
+}
+
+// Verify checks the signature of the payload published to the topic against the key
+func (p *SignedPayload) Verify(key []byte, topic string) bool {
+	return len(key) > 0 && len(p.Signature) > 0 && hmac.Equal(p.mac(key, topic), p.Signature)
+}
+
+// mac returns the HMAC-SHA256 of the fields, the variable-length ones are
+// prefixed by their lengths so that they can't be shifted into each other.
+func (p *SignedPayload) mac(key []byte, topic string) []byte {
+	mac := hmac.New(sha256.New, key)
+	var buf [8]byte
+	writeUint := func(v uint64) {
+		binary.BigEndian.PutUint64(buf[:], v)
+		mac.Write(buf[:])
+	}
+	for _, field := range [][]byte{[]byte(topic), []byte(p.SessionID), p.Data} {
+		writeUint(uint64(len(field)))
+		mac.Write(field)
+	}
+	writeUint(p.Sequence)
+	writeUint(uint64(p.Timestamp))
+	return mac.Sum(nil)
+}
+
+// ReplayGuard rejects the signed payloads replayed, i.e. the ones whose
+// timestamp is beyond MaxPayloadAge, or whose sequence is not greater than
+// the last one accepted of the same session and topic.
+type ReplayGuard struct {
+	lock sync.Mutex
+	// publisher/sessionID/topic => the last payload accepted
+	last map[string]acceptedPayload
+}
+
+type acceptedPayload struct {
+	sequence  uint64
+	timestamp time.Time
+}
+
+// NewReplayGuard creates a ReplayGuard
+func NewReplayGuard() *ReplayGuard {
+	return &ReplayGuard{last: make(map[string]acceptedPayload)}
+}
+
+// Check accepts the payload published to the topic by the publisher, e.g.
+// the node, or returns the error if it's replayed.
+func (g *ReplayGuard) Check(publisher, topic string, p *SignedPayload, now time.Time) error {
+	timestamp := time.Unix(p.Timestamp, 0)
+	if timestamp.Before(now.Add(-MaxPayloadAge)) || timestamp.After(now.Add(MaxPayloadAge)) {
+		return fmt.Errorf("payload signed at %v is out of %v", timestamp, MaxPayloadAge)
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	// the payloads beyond the max age are rejected by the timestamp,
+	// so the ones accepted before that are no longer needed
+	for key, accepted := range g.last {
+		if accepted.timestamp.Before(now.Add(-2 * MaxPayloadAge)) {
+			delete(g.last, key)
+		}
+	}
+
+	key := publisher + "/" + p.SessionID + "/" + topic
+	if accepted, ok := g.last[key]; ok && p.Sequence <= accepted.sequence {
+		return fmt.Errorf("payload of sequence %d is not after %d of session %s", p.Sequence, accepted.sequence, p.SessionID)
+	}
+	g.last[key] = acceptedPayload{sequence: p.Sequence, timestamp: timestamp}
+	return nil
+}
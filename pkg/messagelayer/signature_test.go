@@ -0,0 +1,81 @@
+package messagelayer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	const topic = "neptune/node1/upstream"
+	key := DeriveSigningKey("token1")
+	if bytes.Equal(key, []byte("token1")) || bytes.Equal(key, DeriveSigningKey("token2")) {
+		t.Fatalf("expected the key derived from the token")
+	}
+
+	signed := func(modify func(p *SignedPayload)) *SignedPayload {
+		p := &SignedPayload{Data: []byte(`{"header":{}}`), SessionID: "session1", Sequence: 1, Timestamp: 100}
+		p.Sign(key, topic)
+		if modify != nil {
+			modify(p)
+		}
+		return p
+	}
+
+	cases := []struct {
+		name    string
+		key     []byte
+		topic   string
+		payload *SignedPayload
+		valid   bool
+	}{
+		{"valid", key, topic, signed(nil), true},
+		{"wrong key", DeriveSigningKey("token2"), topic, signed(nil), false},
+		{"signed by the token presented to the broker", key, topic, signed(func(p *SignedPayload) {
+			p.Sign([]byte("token1"), topic)
+		}), false},
+		{"replayed to another topic", key, "neptune/node1/presence", signed(nil), false},
+		{"tampered data", key, topic, signed(func(p *SignedPayload) { p.Data = []byte(`{"header":{"x":1}}`) }), false},
+		{"moved to another session", key, topic, signed(func(p *SignedPayload) { p.SessionID = "session2" }), false},
+		{"tampered sequence", key, topic, signed(func(p *SignedPayload) { p.Sequence = 2 }), false},
+		{"tampered timestamp", key, topic, signed(func(p *SignedPayload) { p.Timestamp = 200 }), false},
+		{"unsigned", key, topic, signed(func(p *SignedPayload) { p.Signature = nil }), false},
+		{"no key", nil, topic, signed(nil), false},
+	}
+	for _, c := range cases {
+		if valid := c.payload.Verify(c.key, c.topic); valid != c.valid {
+			t.Errorf("%s: expected valid %v, got %v", c.name, c.valid, valid)
+		}
+	}
+}
+
+func TestReplayGuard(t *testing.T) {
+	const topic = "neptune/node1/presence"
+	now := time.Now()
+	payload := func(session string, sequence uint64, timestamp time.Time) *SignedPayload {
+		return &SignedPayload{SessionID: session, Sequence: sequence, Timestamp: timestamp.Unix()}
+	}
+
+	g := NewReplayGuard()
+	steps := []struct {
+		name     string
+		topic    string
+		payload  *SignedPayload
+		accepted bool
+	}{
+		{"first", topic, payload("session1", 1, now), true},
+		{"next", topic, payload("session1", 3, now), true},
+		{"replayed", topic, payload("session1", 3, now), false},
+		{"older sequence", topic, payload("session1", 2, now), false},
+		{"another topic", "neptune/node1/upstream", payload("session1", 2, now), true},
+		{"another session", topic, payload("session2", 1, now), true},
+		{"expired", topic, payload("session3", 1, now.Add(-2*MaxPayloadAge)), false},
+		{"from the future", topic, payload("session3", 1, now.Add(2*MaxPayloadAge)), false},
+		{"within the clock skew", topic, payload("session3", 1, now.Add(-MaxPayloadAge/2)), true},
+	}
+	for _, s := range steps {
+		if err := g.Check("node1", s.topic, s.payload, now); (err == nil) != s.accepted {
+			t.Errorf("%s: expected accepted %v, got %v", s.name, s.accepted, err)
+		}
+	}
+}
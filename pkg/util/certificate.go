@@ -0,0 +1,133 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// CertificateReloader holds a key pair and a CA bundle loaded from files,
+// and reloads them once the files are modified, so that the certificates
+// can be rotated without restarting.
+// Each of the files is optional.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	lock sync.Mutex
+	// modTimes are the modification times of the files loaded
+	modTimes    [3]time.Time
+	certificate *tls.Certificate
+	caPool      *x509.CertPool
+}
+
+// NewCertificateReloader creates a CertificateReloader, and loads the files
+// for the first time.
+func NewCertificateReloader(certFile, keyFile, caFile string) (*CertificateReloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("certificate file and key file must be specified together")
+	}
+
+	r := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return nil, err
+	}
+	if err = r.load(modTimes); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// statFiles returns the modification times of the files
+func (r *CertificateReloader) statFiles() ([3]time.Time, error) {
+	var modTimes [3]time.Time
+	for i, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// load loads the files, which is called with the lock held except creating
+func (r *CertificateReloader) load(modTimes [3]time.Time) error {
+	var certificate *tls.Certificate
+	if r.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load key pair(%s, %s): %w", r.certFile, r.keyFile, err)
+		}
+		certificate = &cert
+	}
+
+	var caPool *x509.CertPool
+	if r.caFile != "" {
+		data, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file %s: %w", r.caFile, err)
+		}
+		caPool = x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no valid certificate found in CA file %s", r.caFile)
+		}
+	}
+
+	r.certificate = certificate
+	r.caPool = caPool
+	r.modTimes = modTimes
+	return nil
+}
+
+// reload reloads the files if any of them is modified.
+// The previous ones are kept if failed to reload, e.g. the files are being
+// written when rotating.
+func (r *CertificateReloader) reload() {
+	modTimes, err := r.statFiles()
+	if err != nil {
+		klog.Warningf("failed to stat certificate files, keep the loaded ones: %v", err)
+		return
+	}
+	if modTimes == r.modTimes {
+		return
+	}
+
+	if err = r.load(modTimes); err != nil {
+		klog.Warningf("failed to reload certificate files, keep the loaded ones: %v", err)
+		return
+	}
+	klog.Infof("reloaded certificate files(cert: %q, key: %q, ca: %q)", r.certFile, r.keyFile, r.caFile)
+}
+
+// Certificate returns the current key pair, nil if no certificate file specified
+func (r *CertificateReloader) Certificate() *tls.Certificate {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.reload()
+	return r.certificate
+}
+
+// CAPool returns the current CA bundle, nil if no CA file specified
+func (r *CertificateReloader) CAPool() *x509.CertPool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.reload()
+	return r.caPool
+}
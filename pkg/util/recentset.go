@@ -0,0 +1,39 @@
+package util
+
+import "sync"
+
+// RecentSet remembers the most recently added keys up to a capacity,
+// the oldest key is forgotten when the capacity is reached.
+// It's used to detect the duplicate messages by their idempotency keys.
+type RecentSet struct {
+	sync.Mutex
+	keys  map[string]struct{}
+	order []string
+	next  int
+}
+
+// NewRecentSet creates a RecentSet with the capacity
+func NewRecentSet(capacity int) *RecentSet {
+	return &RecentSet{
+		keys:  make(map[string]struct{}, capacity),
+		order: make([]string, capacity),
+	}
+}
+
+// Add adds the key, returns false if the key has been added recently
+func (s *RecentSet) Add(key string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.keys[key]; ok {
+		return false
+	}
+
+	if old := s.order[s.next]; old != "" {
+		delete(s.keys, old)
+	}
+	s.order[s.next] = key
+	s.next = (s.next + 1) % len(s.order)
+	s.keys[key] = struct{}{}
+	return true
+}
@@ -0,0 +1,236 @@
+package util
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// workerTokenSeparator separates the claims and the signature of the worker token
+	workerTokenSeparator = "."
+
+	// workerTokenClockSkew is the clock skew tolerated between GM and LCs
+	// checking the issued-at time of the worker token
+	workerTokenClockSkew = time.Minute
+)
+
+// WorkerTokenClaims is the identity of the worker signed in its token
+type WorkerTokenClaims struct {
+	Namespace  string `json:"namespace"`
+	OwnerKind  string `json:"ownerKind"`
+	OwnerName  string `json:"ownerName"`
+	WorkerName string `json:"workerName"`
+	// NodeName is the node the worker runs on, whose LC only accepts the token
+	NodeName string `json:"node"`
+	// IssuedAt is the unix time when the token is issued
+	IssuedAt int64 `json:"iat"`
+	// ExpiresAt is the unix time after which the token is rejected, 0 if never expires
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+// IsIssuedTo checks that the token is issued to the worker owned by the resource
+func (c *WorkerTokenClaims) IsIssuedTo(namespace, ownerKind, ownerName, workerName string) bool {
+	return c.Namespace == namespace && c.OwnerKind == ownerKind &&
+		c.OwnerName == ownerName && c.WorkerName == workerName
+}
+
+// readPEMBlock reads the first PEM block from the file
+func readPEMBlock(file string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in worker token key file %s", file)
+	}
+	return block, nil
+}
+
+// LoadWorkerTokenSigningKey reads the ed25519 private key signing the worker
+// tokens from the file in PEM(PKCS #8), which only GM holds.
+func LoadWorkerTokenSigningKey(file string) (ed25519.PrivateKey, error) {
+	block, err := readPEMBlock(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key in %s: %w", file, err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key in %s is not an ed25519 key", file)
+	}
+	return privateKey, nil
+}
+
+// LoadWorkerTokenVerifyingKey reads the ed25519 public key verifying the
+// worker tokens from the file in PEM(PKIX), which LCs hold. The private key is
+// refused, LCs must not be able to issue the tokens.
+func LoadWorkerTokenVerifyingKey(file string) (ed25519.PublicKey, error) {
+	block, err := readPEMBlock(file)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("worker token key file %s holds %s instead of the public key", file, block.Type)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key in %s: %w", file, err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key in %s is not an ed25519 key", file)
+	}
+	return publicKey, nil
+}
+
+// NewWorkerToken creates the token of the worker, which is the encoded claims
+// and their ed25519 signature by the key, separated by workerTokenSeparator.
+// The token never expires if ttl is 0.
+func NewWorkerToken(key ed25519.PrivateKey, claims WorkerTokenClaims, now time.Time, ttl time.Duration) (string, error) {
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = 0
+	if ttl > 0 {
+		claims.ExpiresAt = now.Add(ttl).Unix()
+	}
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + workerTokenSeparator +
+		base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(encoded))), nil
+}
+
+// ParseWorkerToken verifies the signature by any of the keys and the lifetime
+// of the token, and returns the claims of the worker.
+func ParseWorkerToken(keys []ed25519.PublicKey, token string, now time.Time) (*WorkerTokenClaims, error) {
+	parts := strings.Split(token, workerTokenSeparator)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed worker token")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !verifyWorkerToken(keys, parts[0], signature) {
+		return nil, fmt.Errorf("invalid signature of worker token")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed claims of worker token: %w", err)
+	}
+	var claims WorkerTokenClaims
+	if err = json.Unmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims of worker token: %w", err)
+	}
+
+	if time.Unix(claims.IssuedAt, 0).After(now.Add(workerTokenClockSkew)) {
+		return nil, fmt.Errorf("worker token is issued in the future at %s", time.Unix(claims.IssuedAt, 0))
+	}
+	if claims.ExpiresAt != 0 && !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, fmt.Errorf("worker token expired at %s", time.Unix(claims.ExpiresAt, 0))
+	}
+	return &claims, nil
+}
+
+// verifyWorkerToken checks the signature of the encoded claims by any of the keys
+func verifyWorkerToken(keys []ed25519.PublicKey, claims string, signature []byte) bool {
+	for _, key := range keys {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, []byte(claims), signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkerTokenKeyReloader holds the public key of the worker tokens loaded
+// from the file, and reloads it once the file is modified, so that the key
+// can be rotated without reading the file on every request. The previous key
+// is still accepted for the grace period after rotated, so that the workers
+// with the tokens issued before then can be recreated meanwhile.
+type WorkerTokenKeyReloader struct {
+	file        string
+	gracePeriod time.Duration
+
+	lock sync.Mutex
+	// modTime is the modification time of the file loaded
+	modTime time.Time
+	key     ed25519.PublicKey
+	// previous is the key replaced at rotatedAt, nil if never rotated
+	previous  ed25519.PublicKey
+	rotatedAt time.Time
+}
+
+// NewWorkerTokenKeyReloader creates a WorkerTokenKeyReloader, and loads the
+// file for the first time.
+func NewWorkerTokenKeyReloader(file string, gracePeriod time.Duration) (*WorkerTokenKeyReloader, error) {
+	r := &WorkerTokenKeyReloader{file: file, gracePeriod: gracePeriod}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if r.key, err = LoadWorkerTokenVerifyingKey(file); err != nil {
+		return nil, err
+	}
+	r.modTime = info.ModTime()
+	return r, nil
+}
+
+// Keys returns the current key, and the previous one within the grace period.
+// The loaded key is kept if failed to reload, e.g. the file is being written
+// when rotating.
+func (r *WorkerTokenKeyReloader) Keys() []ed25519.PublicKey {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.reload()
+	keys := []ed25519.PublicKey{r.key}
+	if r.previous != nil && time.Since(r.rotatedAt) < r.gracePeriod {
+		keys = append(keys, r.previous)
+	}
+	return keys
+}
+
+// reload loads the file if modified, the caller must hold the lock
+func (r *WorkerTokenKeyReloader) reload() {
+	info, err := os.Stat(r.file)
+	if err != nil {
+		klog.Warningf("failed to stat worker token key file %s, keep the loaded one: %v", r.file, err)
+		return
+	}
+	if info.ModTime().Equal(r.modTime) {
+		return
+	}
+
+	key, err := LoadWorkerTokenVerifyingKey(r.file)
+	if err != nil {
+		klog.Warningf("failed to reload worker token key file %s, keep the loaded one: %v", r.file, err)
+		return
+	}
+	r.modTime = info.ModTime()
+	if bytes.Equal(key, r.key) {
+		return
+	}
+	r.previous, r.key, r.rotatedAt = r.key, key, time.Now()
+	klog.Infof("reloaded worker token key file %s, the previous key is accepted for %v", r.file, r.gracePeriod)
+}
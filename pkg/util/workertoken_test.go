@@ -0,0 +1,235 @@
+package util
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestKey generates an ed25519 key of the worker tokens
+func newTestKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public, private
+}
+
+// writeKeyFile writes the key in PEM to the file
+func writeKeyFile(t *testing.T, file string, key interface{}) {
+	var block *pem.Block
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		der, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	default:
+		der, err := x509.MarshalPKIXPublicKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	}
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseWorkerToken(t *testing.T) {
+	public, key := newTestKey(t)
+	otherPublic, _ := newTestKey(t)
+	now := time.Unix(1600000000, 0)
+	claims := WorkerTokenClaims{
+		Namespace:  "default",
+		OwnerKind:  "federatedlearningjob",
+		OwnerName:  "job1",
+		WorkerName: "trainworker-1",
+		NodeName:   "edge1",
+	}
+
+	token, err := NewWorkerToken(key, claims, now, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eternal, err := NewWorkerToken(key, claims, now, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, workerTokenSeparator)
+	// the claims of another worker with the signature of the token
+	forgedClaims := claims
+	forgedClaims.WorkerName = "trainworker-2"
+	forged, err := NewWorkerToken(key, forgedClaims, now, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged = strings.Split(forged, workerTokenSeparator)[0] + workerTokenSeparator + parts[1]
+
+	keys := []ed25519.PublicKey{public}
+	cases := []struct {
+		name  string
+		keys  []ed25519.PublicKey
+		token string
+		now   time.Time
+		valid bool
+	}{
+		{"valid", keys, token, now, true},
+		{"valid before expired", keys, token, now.Add(time.Hour - time.Second), true},
+		{"expired", keys, token, now.Add(time.Hour), false},
+		{"never expires", keys, eternal, now.Add(10000 * time.Hour), true},
+		{"issued within the clock skew", keys, token, now.Add(-workerTokenClockSkew), true},
+		{"issued in the future", keys, token, now.Add(-workerTokenClockSkew - time.Second), false},
+		{"wrong key", []ed25519.PublicKey{otherPublic}, token, now, false},
+		{"any of the keys", []ed25519.PublicKey{otherPublic, public}, token, now, true},
+		{"no key", nil, token, now, false},
+		{"forged claims", keys, forged, now, false},
+		{"truncated signature", keys, token[:len(token)-2], now, false},
+		{"malformed", keys, parts[0], now, false},
+		{"empty", keys, "", now, false},
+	}
+	for _, c := range cases {
+		parsed, err := ParseWorkerToken(c.keys, c.token, c.now)
+		if valid := err == nil; valid != c.valid {
+			t.Errorf("%s: expected valid %v, got error %v", c.name, c.valid, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !parsed.IsIssuedTo(claims.Namespace, claims.OwnerKind, claims.OwnerName, claims.WorkerName) {
+			t.Errorf("%s: expected the token issued to %+v, got %+v", c.name, claims, parsed)
+		}
+		if parsed.NodeName != claims.NodeName {
+			t.Errorf("%s: expected the token issued on node %s, got %s", c.name, claims.NodeName, parsed.NodeName)
+		}
+		if parsed.IssuedAt != now.Unix() {
+			t.Errorf("%s: expected issued at %d, got %d", c.name, now.Unix(), parsed.IssuedAt)
+		}
+	}
+}
+
+func TestWorkerTokenClaimsIsIssuedTo(t *testing.T) {
+	claims := WorkerTokenClaims{
+		Namespace:  "default",
+		OwnerKind:  "jointinferenceservice",
+		OwnerName:  "service1",
+		WorkerName: "edgeworker-1",
+	}
+	if !claims.IsIssuedTo("default", "jointinferenceservice", "service1", "edgeworker-1") {
+		t.Errorf("expected the token issued to its worker")
+	}
+	for _, other := range [][4]string{
+		{"other", "jointinferenceservice", "service1", "edgeworker-1"},
+		{"default", "federatedlearningjob", "service1", "edgeworker-1"},
+		{"default", "jointinferenceservice", "service2", "edgeworker-1"},
+		{"default", "jointinferenceservice", "service1", "edgeworker-2"},
+	} {
+		if claims.IsIssuedTo(other[0], other[1], other[2], other[3]) {
+			t.Errorf("expected the token not issued to %v", other)
+		}
+	}
+}
+
+func TestLoadWorkerTokenKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workertoken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	public, private := newTestKey(t)
+	publicFile, privateFile := filepath.Join(dir, "key.pub"), filepath.Join(dir, "key")
+	writeKeyFile(t, publicFile, public)
+	writeKeyFile(t, privateFile, private)
+
+	signingKey, err := LoadWorkerTokenSigningKey(privateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyingKey, err := LoadWorkerTokenVerifyingKey(publicFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := NewWorkerToken(signingKey, WorkerTokenClaims{WorkerName: "trainworker-1"}, time.Now(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseWorkerToken([]ed25519.PublicKey{verifyingKey}, token, time.Now()); err != nil {
+		t.Errorf("expected the token verified by the loaded public key, got %v", err)
+	}
+
+	// LCs never hold the private key
+	if _, err = LoadWorkerTokenVerifyingKey(privateFile); err == nil {
+		t.Errorf("expected the private key refused as the verifying key")
+	}
+	if _, err = LoadWorkerTokenSigningKey(publicFile); err == nil {
+		t.Errorf("expected the public key refused as the signing key")
+	}
+}
+
+func TestWorkerTokenKeyReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workertoken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key1, _ := newTestKey(t)
+	key2, _ := newTestKey(t)
+	file := filepath.Join(dir, "key.pub")
+	writeKeyFile(t, file, key1)
+	r, err := NewWorkerTokenKeyReloader(file, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys := func(name string, expected ...ed25519.PublicKey) {
+		t.Helper()
+		keys := r.Keys()
+		if len(keys) != len(expected) {
+			t.Fatalf("%s: expected %d keys, got %d", name, len(expected), len(keys))
+		}
+		for i := range keys {
+			if !bytes.Equal(keys[i], expected[i]) {
+				t.Fatalf("%s: unexpected key %d", name, i)
+			}
+		}
+	}
+	expectKeys("loaded", key1)
+
+	// rotated, the previous key is accepted within the grace period
+	writeKeyFile(t, file, key2)
+	modTime := time.Now().Add(time.Second)
+	if err = os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys("rotated", key2, key1)
+
+	// the loaded keys are kept while the file is being written
+	if err = ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	modTime = modTime.Add(time.Second)
+	if err = os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys("being written", key2, key1)
+
+	// rewritten with the same key, which restarts no grace period
+	writeKeyFile(t, file, key2)
+	modTime = modTime.Add(time.Second)
+	if err = os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	r.rotatedAt = r.rotatedAt.Add(-time.Hour)
+	expectKeys("after the grace period", key2)
+}